@@ -6,7 +6,9 @@ require (
 	github.com/KromDaniel/regengo v0.4.0
 	github.com/json-iterator/go v1.1.12
 	github.com/spf13/cobra v1.10.1
+	golang.org/x/mod v0.30.0
 	golang.org/x/term v0.37.0
+	golang.org/x/tools v0.39.0
 )
 
 require (
@@ -15,5 +17,6 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
+	golang.org/x/sync v0.18.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 )
@@ -0,0 +1,219 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/cover"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// FuncCoverage summarises the statement coverage of one function.
+type FuncCoverage struct {
+	// File is the path of the source file on disk.
+	File     string
+	Selector string
+	Line     int
+	EndLine  int
+	// Statements and Uncovered count statements as go test -cover does.
+	Statements int
+	Uncovered  int
+	// Complexity is the cyclomatic complexity of the function.
+	Complexity int
+	// UncoveredLines lists source lines inside blocks that never ran.
+	UncoveredLines []int
+	// Source is the function's source text, starting at Line.
+	Source string
+}
+
+// Percent returns the covered share of statements, 0-100.
+func (f FuncCoverage) Percent() float64 {
+	if f.Statements == 0 {
+		return 100
+	}
+	return 100 * float64(f.Statements-f.Uncovered) / float64(f.Statements)
+}
+
+// Score ranks gaps: uncovered statements weighted by how many paths the function has.
+func (f FuncCoverage) Score() int {
+	return f.Uncovered * f.Complexity
+}
+
+// AnnotatedSource numbers the function's lines and marks uncovered ones with ">>".
+func (f FuncCoverage) AnnotatedSource() string {
+	uncovered := make(map[int]bool, len(f.UncoveredLines))
+	for _, l := range f.UncoveredLines {
+		uncovered[l] = true
+	}
+	var b strings.Builder
+	for i, line := range strings.Split(f.Source, "\n") {
+		n := f.Line + i
+		marker := "  "
+		if uncovered[n] {
+			marker = ">>"
+		}
+		fmt.Fprintf(&b, "%s %4d  %s\n", marker, n, line)
+	}
+	return b.String()
+}
+
+// CoverageGaps maps coverage profiles onto the functions they cover and returns those
+// with uncovered statements, highest Score first. Profile file names (import path plus
+// base name) are resolved to files on disk with go/packages run from dir.
+func CoverageGaps(dir string, profiles []*cover.Profile) ([]FuncCoverage, error) {
+	seen := map[string]bool{}
+	var importPaths []string
+	for _, p := range profiles {
+		if pkgPath := path.Dir(p.FileName); !filepath.IsAbs(p.FileName) && !seen[pkgPath] {
+			seen[pkgPath] = true
+			importPaths = append(importPaths, pkgPath)
+		}
+	}
+	dirs, err := PackageDirs(dir, importPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	var gaps []FuncCoverage
+	for _, p := range profiles {
+		file := p.FileName
+		if !filepath.IsAbs(file) {
+			pkgDir, ok := dirs[path.Dir(p.FileName)]
+			if !ok {
+				return nil, fmt.Errorf("cannot locate package %s for %s", path.Dir(p.FileName), p.FileName)
+			}
+			file = filepath.Join(pkgDir, path.Base(p.FileName))
+		}
+		src, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		funcs, err := fileFunctions(file, src)
+		if err != nil {
+			return nil, err
+		}
+		for i := range funcs {
+			fc := &funcs[i]
+			lines := map[int]bool{}
+			for _, block := range p.Blocks {
+				if block.StartLine < fc.Line || block.EndLine > fc.EndLine {
+					continue
+				}
+				fc.Statements += block.NumStmt
+				if block.Count == 0 {
+					fc.Uncovered += block.NumStmt
+					for l := block.StartLine; l <= block.EndLine; l++ {
+						lines[l] = true
+					}
+				}
+			}
+			if fc.Uncovered == 0 {
+				continue
+			}
+			for l := range lines {
+				fc.UncoveredLines = append(fc.UncoveredLines, l)
+			}
+			sort.Ints(fc.UncoveredLines)
+			gaps = append(gaps, *fc)
+		}
+	}
+
+	sort.SliceStable(gaps, func(i, j int) bool {
+		if si, sj := gaps[i].Score(), gaps[j].Score(); si != sj {
+			return si > sj
+		}
+		if gaps[i].Uncovered != gaps[j].Uncovered {
+			return gaps[i].Uncovered > gaps[j].Uncovered
+		}
+		if gaps[i].File != gaps[j].File {
+			return gaps[i].File < gaps[j].File
+		}
+		return gaps[i].Line < gaps[j].Line
+	})
+	return gaps, nil
+}
+
+// PackageDirs resolves import paths to package directories with go/packages run
+// from dir. Paths that cannot be resolved are missing from the result.
+func PackageDirs(dir string, importPaths []string) (map[string]string, error) {
+	dirs := map[string]string{}
+	if len(importPaths) == 0 {
+		return dirs, nil
+	}
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName | packages.NeedFiles, Dir: dir}, importPaths...)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pkgs {
+		if len(p.GoFiles) > 0 {
+			dirs[p.PkgPath] = filepath.Dir(p.GoFiles[0])
+		}
+	}
+	return dirs, nil
+}
+
+// fileFunctions lists the functions with bodies declared in src, skipping
+// generated files.
+func fileFunctions(filename string, src []byte) ([]FuncCoverage, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	if ast.IsGenerated(file) {
+		// Generated code is fixed by regenerating it, not by testing it.
+		return nil, nil
+	}
+	var funcs []FuncCoverage
+	for _, d := range file.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		start, end := fset.Position(fd.Pos()), fset.Position(fd.End())
+		funcs = append(funcs, FuncCoverage{
+			File:       filename,
+			Selector:   textutil.FuncSelector(fd),
+			Line:       start.Line,
+			EndLine:    end.Line,
+			Complexity: Complexity(fd),
+			Source:     string(src[start.Offset:end.Offset]),
+		})
+	}
+	return funcs, nil
+}
+
+// Complexity returns the cyclomatic complexity of fn: one plus the number of
+// branch points (if, for, range, non-default case and comm clauses, && and ||).
+func Complexity(fn *ast.FuncDecl) int {
+	complexity := 1
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			complexity++
+		case *ast.CaseClause:
+			if n.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				complexity++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/cover"
+)
+
+func TestCoverageGaps(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/demo\n\ngo 1.21\n",
+		"calc/calc.go": `package calc
+
+import "errors"
+
+// Div divides a by b.
+func Div(a, b int) (int, error) {
+	if b == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return a / b, nil
+}
+
+// Sign classifies n.
+func Sign(n int) string {
+	switch {
+	case n < 0:
+		return "negative"
+	case n > 0:
+		return "positive"
+	}
+	return "zero"
+}
+
+func Add(a, b int) int { return a + b }
+`,
+		"calc/calc_test.go": `package calc
+
+import "testing"
+
+func TestDiv(t *testing.T) {
+	if got, _ := Div(6, 3); got != 2 {
+		t.Fatal(got)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Fatal("add")
+	}
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	profile := filepath.Join(dir, "cover.out")
+	cmd := exec.Command("go", "test", "-coverprofile="+profile, "./...")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test: %v\n%s", err, out)
+	}
+	profiles, err := cover.ParseProfiles(profile)
+	if err != nil {
+		t.Fatalf("parse profile: %v", err)
+	}
+
+	gaps, err := CoverageGaps(dir, profiles)
+	if err != nil {
+		t.Fatalf("gaps: %v", err)
+	}
+	if len(gaps) != 2 || gaps[0].Selector != "Sign" || gaps[1].Selector != "Div" {
+		t.Fatalf("expected Sign then Div, got %+v", gaps)
+	}
+	sign, div := gaps[0], gaps[1]
+	if sign.Uncovered != sign.Statements || sign.Complexity != 3 || sign.Percent() != 0 {
+		t.Fatalf("unexpected Sign coverage: %+v", sign)
+	}
+	if div.Uncovered != 1 || div.Complexity != 2 || len(div.UncoveredLines) != 2 || div.UncoveredLines[0] != 8 {
+		t.Fatalf("unexpected Div coverage: %+v", div)
+	}
+	annotated := div.AnnotatedSource()
+	if !strings.Contains(annotated, ">>    8  \t\treturn 0, errors.New(\"division by zero\")") {
+		t.Fatalf("expected the error branch to be marked:\n%s", annotated)
+	}
+	if !strings.Contains(annotated, "      6  func Div(a, b int) (int, error) {") {
+		t.Fatalf("expected the signature unmarked:\n%s", annotated)
+	}
+}
@@ -0,0 +1,411 @@
+package analysis
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Interface is a named interface type located with go/types.
+type Interface struct {
+	Name        string
+	PackageName string
+	PackagePath string
+	Dir         string
+	// Doc is the interface's doc comment, if any.
+	Doc  string
+	Type *types.Interface
+}
+
+// String renders the interface declaration as it would appear in its own package.
+func (i *Interface) String() string {
+	qualifier := func(p *types.Package) string {
+		if p.Path() == i.PackagePath {
+			return ""
+		}
+		return p.Name()
+	}
+	var b strings.Builder
+	if i.Doc != "" {
+		for _, line := range strings.Split(strings.TrimSpace(i.Doc), "\n") {
+			fmt.Fprintf(&b, "// %s\n", line)
+		}
+	}
+	fmt.Fprintf(&b, "type %s interface {\n", i.Name)
+	for m := 0; m < i.Type.NumMethods(); m++ {
+		method := i.Type.Method(m)
+		sig := types.TypeString(method.Type(), qualifier)
+		fmt.Fprintf(&b, "\t%s%s\n", method.Name(), strings.TrimPrefix(sig, "func"))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// LoadInterface finds the interface named by spec, "<package>.<Name>", relative to dir.
+// The package may be an import path ("io", "github.com/x/y/llm"), a relative directory
+// ("./internal/llm"), or a bare package name found anywhere in the module ("llm").
+func LoadInterface(dir, spec string) (*Interface, error) {
+	slash := strings.LastIndex(spec, "/")
+	dot := strings.LastIndex(spec, ".")
+	if dot <= slash+1 || dot == len(spec)-1 {
+		return nil, fmt.Errorf("interface %q must be written as <package>.<Name>", spec)
+	}
+	pattern, name := spec[:dot], spec[dot+1:]
+
+	const mode = packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedImports | packages.NeedDeps
+	cfg := &packages.Config{Mode: mode, Dir: dir}
+	pkg, err := loadSinglePackage(cfg, pattern)
+	if err != nil && !strings.ContainsAny(pattern, "/.") {
+		// A bare package name: search the module for a package called that.
+		path, findErr := findPackageByName(dir, pattern)
+		if findErr != nil {
+			return nil, findErr
+		}
+		pkg, err = loadSinglePackage(cfg, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	obj := pkg.Types.Scope().Lookup(name)
+	if obj == nil {
+		return nil, fmt.Errorf("%s not found in package %s", name, pkg.PkgPath)
+	}
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is not a type", pkg.PkgPath, name)
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is an alias; name the interface it refers to", pkg.PkgPath, name)
+	}
+	if named.TypeParams().Len() > 0 {
+		return nil, fmt.Errorf("%s.%s is generic; generic interfaces are not supported", pkg.PkgPath, name)
+	}
+	iface, ok := named.Underlying().(*types.Interface)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is not an interface", pkg.PkgPath, name)
+	}
+	if !iface.IsMethodSet() {
+		return nil, fmt.Errorf("%s.%s is a type constraint, not a method set", pkg.PkgPath, name)
+	}
+
+	out := &Interface{
+		Name:        name,
+		PackageName: pkg.Name,
+		PackagePath: pkg.PkgPath,
+		Type:        iface,
+		Doc:         typeDoc(pkg, name),
+	}
+	if len(pkg.GoFiles) > 0 {
+		out.Dir = filepath.Dir(pkg.GoFiles[0])
+	}
+	return out, nil
+}
+
+func loadSinglePackage(cfg *packages.Config, pattern string) (*packages.Package, error) {
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", pattern, err)
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s matches %d packages, want exactly one", pattern, len(pkgs))
+	}
+	if len(pkgs[0].Errors) > 0 {
+		return nil, fmt.Errorf("load %s: %v", pattern, pkgs[0].Errors[0])
+	}
+	return pkgs[0], nil
+}
+
+// findPackageByName returns the import path of the only package in the module
+// containing dir whose package clause is name.
+func findPackageByName(dir, name string) (string, error) {
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.NeedName, Dir: dir}, "./...")
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, p := range pkgs {
+		if p.Name == name {
+			matches = append(matches, p.PkgPath)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no package named %s in the module", name)
+	case 1:
+		return matches[0], nil
+	default:
+		sort.Strings(matches)
+		return "", fmt.Errorf("package name %s is ambiguous: %s", name, strings.Join(matches, ", "))
+	}
+}
+
+func typeDoc(pkg *packages.Package, name string) string {
+	for _, f := range pkg.Syntax {
+		for _, d := range f.Decls {
+			gd, ok := d.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts, ok := spec.(*ast.TypeSpec)
+				if !ok || ts.Name.Name != name {
+					continue
+				}
+				if ts.Doc != nil {
+					return ts.Doc.Text()
+				}
+				if gd.Doc != nil {
+					return gd.Doc.Text()
+				}
+				return ""
+			}
+		}
+	}
+	return ""
+}
+
+// FakeOptions controls the generated fake.
+type FakeOptions struct {
+	// Package is the package clause of the generated file.
+	Package string
+	// PackagePath is the import path the file will live in; when it equals the
+	// interface's package, types are not qualified.
+	PackagePath string
+	// TypeName defaults to "Fake" + the interface name.
+	TypeName string
+	// ModelConstructor marks a file that will also hold a model-written New<TypeName>:
+	// the header then says so rather than marking the whole file generated.
+	ModelConstructor bool
+}
+
+// RenderFake generates a hand-rolled fake for iface: one XxxFunc field per method
+// that the method delegates to, and an XxxCalls slice recording every call's
+// arguments. A nil XxxFunc returns zero values. The output is gofmt'd and depends
+// only on the interface, so regenerating it yields identical files.
+func RenderFake(iface *Interface, opts FakeOptions) (string, error) {
+	typeName := opts.TypeName
+	if typeName == "" {
+		typeName = "Fake" + iface.Name
+	}
+	samePackage := opts.PackagePath == iface.PackagePath
+	imports := newImportSet(opts.PackagePath)
+	imports.names["sync"] = "sync"
+	qualifier := imports.qualifier
+
+	for m := 0; m < iface.Type.NumMethods(); m++ {
+		if method := iface.Type.Method(m); !method.Exported() && !samePackage {
+			return "", fmt.Errorf("%s has unexported method %s and can only be faked inside package %s", iface.Name, method.Name(), iface.PackagePath)
+		}
+	}
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "// %s is a hand-rolled fake of %s. Set the XxxFunc fields to script behaviour;\n", typeName, iface.Name)
+	fmt.Fprintf(&body, "// every call is recorded in the matching XxxCalls slice.\n")
+	fmt.Fprintf(&body, "type %s struct {\n\tmu sync.Mutex\n", typeName)
+	for m := 0; m < iface.Type.NumMethods(); m++ {
+		method := iface.Type.Method(m)
+		sig := method.Type().(*types.Signature)
+		fmt.Fprintf(&body, "\n\t%sFunc %s\n", method.Name(), types.TypeString(sig, qualifier))
+		fmt.Fprintf(&body, "\t%sCalls []%s%sCall\n", method.Name(), typeName, method.Name())
+	}
+	body.WriteString("}\n")
+
+	for m := 0; m < iface.Type.NumMethods(); m++ {
+		method := iface.Type.Method(m)
+		writeFakeMethod(&body, typeName, method.Name(), method.Type().(*types.Signature), qualifier)
+	}
+
+	ifaceRef := iface.Name
+	if !samePackage {
+		ifaceRef = imports.qualifier(types.NewPackage(iface.PackagePath, iface.PackageName)) + "." + iface.Name
+	}
+	fmt.Fprintf(&body, "\nvar _ %s = (*%s)(nil)\n", ifaceRef, typeName)
+
+	var file bytes.Buffer
+	if opts.ModelConstructor {
+		fmt.Fprintf(&file, "// %s was generated by sheldon gen-mock from %s.%s, but its New%s\n// constructor was written by a model: review it like hand-written code.\n\n", typeName, iface.PackagePath, iface.Name, typeName)
+	} else {
+		fmt.Fprintf(&file, "// Code generated by sheldon gen-mock from %s.%s. DO NOT EDIT.\n\n", iface.PackagePath, iface.Name)
+	}
+	fmt.Fprintf(&file, "package %s\n\n", opts.Package)
+	file.WriteString(imports.block())
+	file.Write(body.Bytes())
+
+	formatted, err := format.Source(file.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("format fake: %w\n%s", err, file.String())
+	}
+	return string(formatted), nil
+}
+
+// fakeParam is a parameter as it appears in the generated method and call record.
+type fakeParam struct {
+	name     string
+	field    string
+	typ      string
+	variadic bool
+}
+
+func writeFakeMethod(b *bytes.Buffer, typeName, name string, sig *types.Signature, qualifier types.Qualifier) {
+	params := fakeParams(sig, qualifier)
+	callType := typeName + name + "Call"
+
+	fmt.Fprintf(b, "\n// %s records one call to %s.%s.\n", callType, typeName, name)
+	if len(params) == 0 {
+		fmt.Fprintf(b, "type %s struct{}\n", callType)
+	} else {
+		fmt.Fprintf(b, "type %s struct {\n", callType)
+		for _, p := range params {
+			typ := p.typ
+			if p.variadic {
+				typ = "[]" + strings.TrimPrefix(typ, "...")
+			}
+			fmt.Fprintf(b, "\t%s %s\n", p.field, typ)
+		}
+		b.WriteString("}\n")
+	}
+
+	var decl, fields, args []string
+	for _, p := range params {
+		decl = append(decl, p.name+" "+p.typ)
+		fields = append(fields, p.field+": "+p.name)
+		arg := p.name
+		if p.variadic {
+			arg += "..."
+		}
+		args = append(args, arg)
+	}
+	results := sig.Results()
+	resultTypes := make([]string, results.Len())
+	for i := 0; i < results.Len(); i++ {
+		resultTypes[i] = types.TypeString(results.At(i).Type(), qualifier)
+	}
+	resultDecl := strings.Join(resultTypes, ", ")
+	if results.Len() > 1 {
+		resultDecl = "(" + resultDecl + ")"
+	}
+
+	fmt.Fprintf(b, "\n// %s records the call and delegates to %sFunc.\n", name, name)
+	fmt.Fprintf(b, "func (f *%s) %s(%s) %s {\n", typeName, name, strings.Join(decl, ", "), resultDecl)
+	b.WriteString("\tf.mu.Lock()\n")
+	fmt.Fprintf(b, "\tf.%sCalls = append(f.%sCalls, %s{%s})\n", name, name, callType, strings.Join(fields, ", "))
+	fmt.Fprintf(b, "\tfn := f.%sFunc\n", name)
+	b.WriteString("\tf.mu.Unlock()\n")
+	call := fmt.Sprintf("fn(%s)", strings.Join(args, ", "))
+	if results.Len() == 0 {
+		fmt.Fprintf(b, "\tif fn != nil {\n\t\t%s\n\t}\n}\n", call)
+		return
+	}
+	b.WriteString("\tif fn == nil {\n")
+	zeros := make([]string, results.Len())
+	for i, typ := range resultTypes {
+		zeros[i] = fmt.Sprintf("r%d", i)
+		fmt.Fprintf(b, "\t\tvar r%d %s\n", i, typ)
+	}
+	fmt.Fprintf(b, "\t\treturn %s\n\t}\n", strings.Join(zeros, ", "))
+	fmt.Fprintf(b, "\treturn %s\n}\n", call)
+}
+
+// fakeParams names every parameter, replacing blank, missing and clashing names
+// (including the receiver "f" and the local "fn") with argN.
+func fakeParams(sig *types.Signature, qualifier types.Qualifier) []fakeParam {
+	used := map[string]bool{"f": true, "fn": true}
+	params := make([]fakeParam, sig.Params().Len())
+	for i := range params {
+		v := sig.Params().At(i)
+		name := v.Name()
+		if name == "" || name == "_" || used[name] {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		used[name] = true
+		p := fakeParam{name: name, field: exportedField(name, i), typ: types.TypeString(v.Type(), qualifier)}
+		if sig.Variadic() && i == len(params)-1 {
+			p.variadic = true
+			p.typ = "..." + types.TypeString(v.Type().(*types.Slice).Elem(), qualifier)
+		}
+		params[i] = p
+	}
+	return params
+}
+
+func exportedField(name string, i int) string {
+	if strings.HasPrefix(name, "arg") {
+		return fmt.Sprintf("Arg%d", i)
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// importSet assigns package names to the imports a generated file needs,
+// aliasing when two paths share a name.
+type importSet struct {
+	self  string
+	names map[string]string
+	taken map[string]string
+}
+
+func newImportSet(self string) *importSet {
+	return &importSet{self: self, names: map[string]string{}, taken: map[string]string{"sync": "sync"}}
+}
+
+func (s *importSet) qualifier(p *types.Package) string {
+	if p.Path() == s.self {
+		return ""
+	}
+	if name, ok := s.names[p.Path()]; ok {
+		return name
+	}
+	name := p.Name()
+	for n := 2; s.taken[name] != "" && s.taken[name] != p.Path(); n++ {
+		name = fmt.Sprintf("%s%d", p.Name(), n)
+	}
+	s.names[p.Path()] = name
+	s.taken[name] = p.Path()
+	return name
+}
+
+func (s *importSet) block() string {
+	paths := make([]string, 0, len(s.names))
+	for path := range s.names {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	b.WriteString("import (\n")
+	// Standard library first, then everything else, as goimports groups them.
+	for _, std := range []bool{true, false} {
+		wrote := false
+		for _, path := range paths {
+			if isStdlibPath(path) != std {
+				continue
+			}
+			if !std && !wrote && b.Len() > len("import (\n") {
+				b.WriteString("\n")
+			}
+			wrote = true
+			name := s.names[path]
+			if name == path[strings.LastIndex(path, "/")+1:] {
+				fmt.Fprintf(&b, "\t%q\n", path)
+			} else {
+				fmt.Fprintf(&b, "\t%s %q\n", name, path)
+			}
+		}
+	}
+	b.WriteString(")\n\n")
+	return b.String()
+}
+
+// isStdlibPath reports whether path looks like a standard library import: its first
+// element has no dot.
+func isStdlibPath(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".")
+}
@@ -0,0 +1,99 @@
+package analysis
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderFakeCompiles(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/demo\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+import (
+	"context"
+	"io"
+)
+
+// Store persists blobs.
+type Store interface {
+	io.Closer
+	Put(ctx context.Context, key string, data []byte) error
+	Get(context.Context, string) ([]byte, bool, error)
+	Tag(f string, fn func(string) bool, tags ...string)
+}
+`,
+		"fakes/use_test.go": `package fakes
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFake(t *testing.T) {
+	f := &FakeStore{}
+	f.GetFunc = func(context.Context, string) ([]byte, bool, error) { return []byte("v"), true, nil }
+	if _, ok, _ := f.Get(context.Background(), "k"); !ok {
+		t.Fatal("expected scripted Get")
+	}
+	f.Tag("a", nil, "x", "y")
+	if err := f.Put(context.Background(), "k", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.GetCalls) != 1 || f.GetCalls[0].Arg1 != "k" || len(f.TagCalls[0].Tags) != 2 || f.TagCalls[0].Arg0 != "a" {
+		t.Fatalf("calls not recorded: %+v %+v", f.GetCalls, f.TagCalls)
+	}
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	iface, err := LoadInterface(dir, "store.Store")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if iface.PackagePath != "example.com/demo/store" || iface.Doc != "Store persists blobs.\n" {
+		t.Fatalf("unexpected interface: %+v", iface)
+	}
+	code, err := RenderFake(iface, FakeOptions{Package: "fakes"})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	again, _ := RenderFake(iface, FakeOptions{Package: "fakes"})
+	if code != again {
+		t.Fatal("expected deterministic output")
+	}
+	for _, want := range []string{
+		"import (\n\t\"context\"\n\t\"sync\"\n\n\t\"example.com/demo/store\"\n)",
+		"CloseFunc  func() error",
+		"func (f *FakeStore) Tag(arg0 string, arg1 func(string) bool, tags ...string) {",
+		"var _ store.Store = (*FakeStore)(nil)",
+	} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("expected %q in fake:\n%s", want, code)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fakes", "fake.go"), []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "test", "./fakes")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated fake does not compile: %v\n%s\n%s", err, out, code)
+	}
+}
@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Module is the main module governing a directory.
+type Module struct {
+	Path string
+	Dir  string
+}
+
+// FindModule walks up from dir to the nearest go.mod.
+func FindModule(dir string) (Module, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Module{}, err
+	}
+	for {
+		data, err := os.ReadFile(filepath.Join(abs, "go.mod"))
+		if err == nil {
+			path := modfile.ModulePath(data)
+			if path == "" {
+				return Module{}, errors.New(filepath.Join(abs, "go.mod") + ": no module directive")
+			}
+			return Module{Path: path, Dir: abs}, nil
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return Module{}, errors.New("go.mod not found in " + dir + " or any parent directory")
+		}
+		abs = parent
+	}
+}
+
+// SourceFile maps a file named in a stack trace to a file in the module. Binaries
+// are often built elsewhere (CI, a container), so the file is located by the import
+// path of its package first, then by the longest suffix of its path that exists in
+// the module; package main has no import path in traces. It returns "" for files
+// outside the module.
+func (m Module) SourceFile(pkgPath, file string) string {
+	rel, ok := strings.CutPrefix(pkgPath, m.Path)
+	inModule := ok && (rel == "" || strings.HasPrefix(rel, "/"))
+	if !inModule && pkgPath != "main" {
+		return ""
+	}
+	if inModule {
+		if path := filepath.Join(m.Dir, filepath.FromSlash(rel), filepath.Base(filepath.FromSlash(file))); isFile(path) {
+			return path
+		}
+	}
+	parts := strings.Split(filepath.ToSlash(file), "/")
+	for i := range parts {
+		if path := filepath.Join(m.Dir, filepath.FromSlash(strings.Join(parts[i:], "/"))); isFile(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// Declaration is a function or method declared in the module.
+type Declaration struct {
+	File     string
+	Line     int
+	Selector string
+	Source   string
+}
+
+// FindFunctions returns the functions and methods called name declared in the
+// module's non-test, non-generated files. Vendor, testdata and hidden directories
+// are skipped.
+func (m Module) FindFunctions(name string) ([]Declaration, error) {
+	var decls []Declaration
+	err := filepath.WalkDir(m.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != m.Dir && (d.Name() == "vendor" || d.Name() == "testdata" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(src), name) {
+			return err
+		}
+		funcs, err := fileFunctions(path, src)
+		if err != nil {
+			// Files that do not parse cannot declare anything useful.
+			return nil
+		}
+		for _, fn := range funcs {
+			if fn.Selector == name || strings.HasSuffix(fn.Selector, "."+name) {
+				decls = append(decls, Declaration{File: path, Line: fn.Line, Selector: fn.Selector, Source: fn.Source})
+			}
+		}
+		return nil
+	})
+	return decls, err
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
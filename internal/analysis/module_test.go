@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModuleSourceFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/sd\n\ngo 1.21\n",
+		"main.go":        "package main\n",
+		"store/store.go": "package store\n",
+		"client.go":      "package main\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mod, err := FindModule(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatalf("find module: %v", err)
+	}
+	if mod.Path != "example.com/sd" || mod.Dir != dir {
+		t.Fatalf("unexpected module %+v", mod)
+	}
+
+	cases := []struct {
+		pkg, file, want string
+	}{
+		{"example.com/sd/store", "/build/app/store/store.go", filepath.Join(dir, "store", "store.go")},
+		{"main", "/app/main.go", filepath.Join(dir, "main.go")},
+		{"main", filepath.Join(dir, "main.go"), filepath.Join(dir, "main.go")},
+		{"example.com/sd/store", "/build/app/store/missing.go", ""},
+		{"github.com/other/lib", "/go/pkg/mod/github.com/other/lib@v1.0.0/client.go", ""},
+	}
+	for _, tc := range cases {
+		if got := mod.SourceFile(tc.pkg, tc.file); got != tc.want {
+			t.Fatalf("SourceFile(%q, %q) = %q, want %q", tc.pkg, tc.file, got, tc.want)
+		}
+	}
+}
+
+func TestModuleFindFunctions(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"store/store.go":         "package store\n\ntype Store struct{}\n\nfunc (s *Store) Get(key string) int { return 0 }\n",
+		"cache/cache.go":         "package cache\n\nfunc Get(key string) int { return 1 }\n\nfunc GetAll() {}\n",
+		"cache/cache_test.go":    "package cache\n\nfunc Get2() {}\n",
+		"vendor/x/x.go":          "package x\n\nfunc Get() {}\n",
+		"store/broken_syntax.go": "package store\n\nfunc Get( {\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decls, err := Module{Path: "example.com/sd", Dir: dir}.FindFunctions("Get")
+	if err != nil {
+		t.Fatalf("find functions: %v", err)
+	}
+	if len(decls) != 2 {
+		t.Fatalf("expected 2 declarations, got %+v", decls)
+	}
+	if decls[0].Selector != "Get" || decls[0].Line != 3 || decls[1].Selector != "Store.Get" || decls[1].Source != "func (s *Store) Get(key string) int { return 0 }" {
+		t.Fatalf("unexpected declarations %+v", decls)
+	}
+}
@@ -0,0 +1,523 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// FunctionContext is what a test generator needs to know about a function beyond its body.
+type FunctionContext struct {
+	PackageName string
+	ImportPath  string
+	Dir         string
+	Source      string
+	// Types holds definitions of named types used by the signature or receiver,
+	// rendered from type information (one level of field types included).
+	Types []string
+	// Constructors lists signatures of functions returning those types.
+	Constructors []string
+	// Interfaces holds definitions of interfaces whose methods the function calls.
+	Interfaces []string
+	// Helpers holds the source of non-test functions declared in the package's _test.go files.
+	Helpers []string
+	// ExistingTests lists Test/Benchmark/Fuzz/Example functions already in the package.
+	ExistingTests []string
+}
+
+const (
+	maxContextTypes   = 12
+	maxHelperLen      = 1500
+	packageLoadedMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+		packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps | packages.NeedModule
+)
+
+// Package is a type-checked Go package, including its in-package test files.
+type Package struct {
+	pkg        *packages.Package
+	modulePath string
+}
+
+// LoadPackage type-checks the package in dir together with its _test.go files.
+func LoadPackage(dir string) (*Package, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := packages.Load(&packages.Config{Mode: packageLoadedMode, Dir: abs, Tests: true}, ".")
+	if err != nil {
+		return nil, fmt.Errorf("load package in %s: %w", abs, err)
+	}
+	pkg := pickTestVariant(pkgs)
+	if pkg == nil {
+		return nil, fmt.Errorf("no Go package in %s", abs)
+	}
+	if len(pkg.Errors) > 0 {
+		return nil, fmt.Errorf("load package in %s: %v", abs, pkg.Errors[0])
+	}
+	if pkg.Types == nil || pkg.TypesInfo == nil {
+		return nil, errors.New("package loaded without type information")
+	}
+	p := &Package{pkg: pkg}
+	if pkg.Module != nil {
+		p.modulePath = pkg.Module.Path
+	}
+	return p, nil
+}
+
+// LoadFunctionContext type-checks the package containing file (including its tests)
+// and collects the declarations relevant to fn, a function name or Type.Method selector.
+func LoadFunctionContext(file, fn string) (FunctionContext, error) {
+	p, err := LoadPackage(filepath.Dir(file))
+	if err != nil {
+		return FunctionContext{}, err
+	}
+	return p.FunctionContext(file, fn)
+}
+
+// Name returns the package name.
+func (p *Package) Name() string {
+	return p.pkg.Types.Name()
+}
+
+// ExistingTests lists Test/Benchmark/Fuzz/Example functions already in the package.
+func (p *Package) ExistingTests() []string {
+	_, tests := testFileFunctions(p.pkg)
+	return tests
+}
+
+// FuncRef locates a function declaration.
+type FuncRef struct {
+	File     string
+	Line     int
+	Selector string
+}
+
+// ExportedFunctions lists exported functions and methods on exported types declared
+// in the package's non-test files.
+func (p *Package) ExportedFunctions() []FuncRef {
+	var refs []FuncRef
+	for _, f := range p.pkg.Syntax {
+		filename := p.pkg.Fset.Position(f.Pos()).Filename
+		if strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || !fd.Name.IsExported() {
+				continue
+			}
+			sel := textutil.FuncSelector(fd)
+			if typ, _, isMethod := strings.Cut(sel, "."); isMethod && !ast.IsExported(typ) {
+				continue
+			}
+			refs = append(refs, FuncRef{File: filename, Line: p.pkg.Fset.Position(fd.Pos()).Line, Selector: sel})
+		}
+	}
+	return refs
+}
+
+// FunctionContext collects the declarations relevant to fn declared in file.
+func (p *Package) FunctionContext(file, fn string) (FunctionContext, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return FunctionContext{}, err
+	}
+	pkg := p.pkg
+	decl, fileAST := findFuncDecl(pkg, abs, fn)
+	if decl == nil {
+		return FunctionContext{}, fmt.Errorf("function %s not found in %s", fn, file)
+	}
+
+	ctx := FunctionContext{
+		PackageName: pkg.Types.Name(),
+		ImportPath:  pkg.PkgPath,
+		Dir:         filepath.Dir(abs),
+		Source:      nodeSource(pkg, fileAST, decl),
+	}
+
+	// Qualify foreign types by package name, as they would be written in source.
+	qualifier := func(other *types.Package) string {
+		if other == pkg.Types {
+			return ""
+		}
+		return other.Name()
+	}
+	named := signatureTypes(pkg.TypesInfo, decl, p.modulePath)
+	ctx.Types, ctx.Constructors = describeTypes(named, p.modulePath, qualifier)
+	described := make(map[string]bool, len(ctx.Types))
+	for _, t := range ctx.Types {
+		described[t] = true
+	}
+	for _, iface := range calledInterfaces(pkg.TypesInfo, decl, qualifier) {
+		if !described[iface] {
+			ctx.Interfaces = append(ctx.Interfaces, iface)
+		}
+	}
+	ctx.Helpers, ctx.ExistingTests = testFileFunctions(pkg)
+	return ctx, nil
+}
+
+// String renders the context as a prompt section.
+func (c FunctionContext) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Package: %s (import path %s). Tests must use `package %s` and live in %s.\n", c.PackageName, c.ImportPath, c.PackageName, c.Dir)
+	fmt.Fprintf(&b, "\nCode under test:\n%s\n", c.Source)
+	writeList := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s:\n%s\n", title, strings.Join(items, "\n\n"))
+	}
+	writeList("Referenced types (use these exact fields)", c.Types)
+	writeList("Constructors (prefer these over struct literals)", c.Constructors)
+	writeList("Interfaces called (fake these for dependencies)", c.Interfaces)
+	writeList("Existing test helpers in this package (reuse them)", c.Helpers)
+	if len(c.ExistingTests) > 0 {
+		fmt.Fprintf(&b, "\nExisting tests (do not redeclare these names): %s\n", strings.Join(c.ExistingTests, ", "))
+	}
+	return b.String()
+}
+
+// pickTestVariant prefers the in-package test variant, which also type-checks _test.go
+// files, over the plain package. External _test packages and test mains are skipped.
+func pickTestVariant(pkgs []*packages.Package) *packages.Package {
+	var plain *packages.Package
+	for _, p := range pkgs {
+		if strings.HasSuffix(p.PkgPath, "_test") || strings.HasSuffix(p.ID, ".test") {
+			continue
+		}
+		if strings.HasSuffix(p.ID, ".test]") {
+			return p
+		}
+		if plain == nil {
+			plain = p
+		}
+	}
+	return plain
+}
+
+func findFuncDecl(pkg *packages.Package, file, fn string) (*ast.FuncDecl, *ast.File) {
+	for _, f := range pkg.Syntax {
+		if pkg.Fset.Position(f.Pos()).Filename != file {
+			continue
+		}
+		for _, d := range f.Decls {
+			if fd, ok := d.(*ast.FuncDecl); ok && textutil.MatchFunc(fd, fn) {
+				return fd, f
+			}
+		}
+	}
+	return nil, nil
+}
+
+func nodeSource(pkg *packages.Package, f *ast.File, node ast.Node) string {
+	filename := pkg.Fset.Position(f.Pos()).Filename
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	start := pkg.Fset.Position(node.Pos()).Offset
+	if fd, ok := node.(*ast.FuncDecl); ok && fd.Doc != nil {
+		start = pkg.Fset.Position(fd.Doc.Pos()).Offset
+	}
+	end := pkg.Fset.Position(node.End()).Offset
+	if start < 0 || end > len(src) || start > end {
+		return ""
+	}
+	return string(src[start:end])
+}
+
+// signatureTypes returns non-standard-library named types appearing in the receiver,
+// parameters and results of decl.
+func signatureTypes(info *types.Info, decl *ast.FuncDecl, modulePath string) []*types.TypeName {
+	var exprs []ast.Expr
+	for _, list := range []*ast.FieldList{decl.Recv, decl.Type.Params, decl.Type.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			exprs = append(exprs, field.Type)
+		}
+	}
+
+	c := newTypeCollector(modulePath)
+	for _, e := range exprs {
+		if tv, ok := info.Types[e]; ok {
+			c.add(tv.Type)
+		}
+	}
+	return c.out
+}
+
+// typeCollector gathers named types declared outside the standard library.
+type typeCollector struct {
+	modulePath string
+	seen       map[*types.TypeName]bool
+	out        []*types.TypeName
+}
+
+func newTypeCollector(modulePath string) *typeCollector {
+	return &typeCollector{modulePath: modulePath, seen: map[*types.TypeName]bool{}}
+}
+
+func (c *typeCollector) add(t types.Type) {
+	switch tt := t.(type) {
+	case *types.Named:
+		obj := tt.Obj()
+		if obj.Pkg() == nil || c.seen[obj] || c.isStdlib(obj.Pkg().Path()) {
+			return
+		}
+		c.seen[obj] = true
+		c.out = append(c.out, obj)
+		for i := 0; i < tt.TypeArgs().Len(); i++ {
+			c.add(tt.TypeArgs().At(i))
+		}
+	case *types.Alias:
+		c.add(types.Unalias(tt))
+	case *types.Pointer:
+		c.add(tt.Elem())
+	case *types.Slice:
+		c.add(tt.Elem())
+	case *types.Array:
+		c.add(tt.Elem())
+	case *types.Map:
+		c.add(tt.Key())
+		c.add(tt.Elem())
+	case *types.Chan:
+		c.add(tt.Elem())
+	case *types.Signature:
+		for i := 0; i < tt.Params().Len(); i++ {
+			c.add(tt.Params().At(i).Type())
+		}
+		for i := 0; i < tt.Results().Len(); i++ {
+			c.add(tt.Results().At(i).Type())
+		}
+	}
+}
+
+// isStdlib treats import paths without a dot in the first element as standard
+// library, unless they belong to the current module.
+func (c *typeCollector) isStdlib(path string) bool {
+	if c.modulePath != "" && (path == c.modulePath || strings.HasPrefix(path, c.modulePath+"/")) {
+		return false
+	}
+	return isStdlibPath(path)
+}
+
+// describeTypes renders type definitions (plus one level of struct field types) and
+// the constructors declared alongside them.
+func describeTypes(roots []*types.TypeName, modulePath string, qualifier types.Qualifier) ([]string, []string) {
+	c := newTypeCollector(modulePath)
+	for _, r := range roots {
+		c.seen[r] = true
+	}
+	c.out = append(c.out, roots...)
+	for _, r := range roots {
+		if st, ok := r.Type().Underlying().(*types.Struct); ok {
+			for i := 0; i < st.NumFields(); i++ {
+				c.add(st.Field(i).Type())
+			}
+		}
+	}
+	all := c.out
+	if len(all) > maxContextTypes {
+		all = all[:maxContextTypes]
+	}
+
+	var defs, ctors []string
+	ctorSeen := map[string]bool{}
+	for _, obj := range all {
+		defs = append(defs, describeType(obj, qualifier))
+		for _, c := range constructorsFor(obj, qualifier) {
+			if !ctorSeen[c] {
+				ctorSeen[c] = true
+				ctors = append(ctors, c)
+			}
+		}
+	}
+	return defs, ctors
+}
+
+func describeType(obj *types.TypeName, qualifier types.Qualifier) string {
+	name := qualifiedName(obj, qualifier)
+	typeString := func(t types.Type) string { return types.TypeString(t, qualifier) }
+
+	var b strings.Builder
+	switch u := obj.Type().Underlying().(type) {
+	case *types.Struct:
+		fmt.Fprintf(&b, "type %s struct {\n", name)
+		for i := 0; i < u.NumFields(); i++ {
+			f := u.Field(i)
+			if f.Embedded() {
+				fmt.Fprintf(&b, "\t%s", typeString(f.Type()))
+			} else {
+				fmt.Fprintf(&b, "\t%s %s", f.Name(), typeString(f.Type()))
+			}
+			if tag := u.Tag(i); tag != "" {
+				fmt.Fprintf(&b, " `%s`", tag)
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString("}")
+	case *types.Interface:
+		fmt.Fprintf(&b, "type %s interface {\n", name)
+		for i := 0; i < u.NumMethods(); i++ {
+			m := u.Method(i)
+			fmt.Fprintf(&b, "\t%s%s\n", m.Name(), strings.TrimPrefix(typeString(m.Type()), "func"))
+		}
+		b.WriteString("}")
+		return b.String()
+	default:
+		fmt.Fprintf(&b, "type %s %s", name, typeString(u))
+	}
+
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return b.String()
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		m := named.Method(i)
+		if !m.Exported() && qualifier(m.Pkg()) != "" {
+			continue
+		}
+		sig := m.Type().(*types.Signature)
+		recv := name
+		if _, ptr := sig.Recv().Type().(*types.Pointer); ptr {
+			recv = "*" + name
+		}
+		fmt.Fprintf(&b, "\nfunc (%s) %s%s", recv, m.Name(), strings.TrimPrefix(typeString(sig), "func"))
+	}
+	return b.String()
+}
+
+// constructorsFor lists New*/Make*/Must* style functions in obj's package returning obj or *obj.
+func constructorsFor(obj *types.TypeName, qualifier types.Qualifier) []string {
+	if obj.Pkg() == nil {
+		return nil
+	}
+	scope := obj.Pkg().Scope()
+	var out []string
+	for _, name := range scope.Names() {
+		fn, ok := scope.Lookup(name).(*types.Func)
+		if !ok || !isConstructorName(name) {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		for i := 0; i < sig.Results().Len(); i++ {
+			rt := sig.Results().At(i).Type()
+			if p, ok := rt.(*types.Pointer); ok {
+				rt = p.Elem()
+			}
+			if n, ok := rt.(*types.Named); ok && n.Obj() == obj {
+				out = append(out, fmt.Sprintf("func %s%s", qualifiedName(fn, qualifier), strings.TrimPrefix(types.TypeString(sig, qualifier), "func")))
+				break
+			}
+		}
+	}
+	return out
+}
+
+func isConstructorName(name string) bool {
+	for _, prefix := range []string{"New", "new", "Make", "make", "Must", "must"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func qualifiedName(obj types.Object, qualifier types.Qualifier) string {
+	if q := qualifier(obj.Pkg()); q != "" {
+		return q + "." + obj.Name()
+	}
+	return obj.Name()
+}
+
+// calledInterfaces finds interface methods invoked in decl's body and renders their interfaces.
+func calledInterfaces(info *types.Info, decl *ast.FuncDecl, qualifier types.Qualifier) []string {
+	if decl.Body == nil {
+		return nil
+	}
+	seen := map[*types.TypeName]bool{}
+	var ifaces []*types.TypeName
+	ast.Inspect(decl.Body, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		selection, ok := info.Selections[sel]
+		if !ok || selection.Kind() != types.MethodVal {
+			return true
+		}
+		recv := selection.Recv()
+		if p, ok := recv.(*types.Pointer); ok {
+			recv = p.Elem()
+		}
+		named, ok := types.Unalias(recv).(*types.Named)
+		if !ok {
+			return true
+		}
+		if _, isIface := named.Underlying().(*types.Interface); isIface && !seen[named.Obj()] {
+			seen[named.Obj()] = true
+			ifaces = append(ifaces, named.Obj())
+		}
+		return true
+	})
+
+	out := make([]string, 0, len(ifaces))
+	for _, obj := range ifaces {
+		out = append(out, describeType(obj, qualifier))
+	}
+	return out
+}
+
+// testFileFunctions returns helper sources and test names from the package's _test.go files.
+func testFileFunctions(pkg *packages.Package) ([]string, []string) {
+	var helpers, tests []string
+	for _, f := range pkg.Syntax {
+		if !strings.HasSuffix(pkg.Fset.Position(f.Pos()).Filename, "_test.go") {
+			continue
+		}
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			if IsTestFunc(fd.Name.Name) && fd.Recv == nil {
+				tests = append(tests, fd.Name.Name)
+				continue
+			}
+			src := nodeSource(pkg, f, fd)
+			if idx := strings.Index(src, "{"); len(src) > maxHelperLen && idx > 0 {
+				src = src[:idx] + "{ ... }"
+			}
+			helpers = append(helpers, src)
+		}
+	}
+	sort.Strings(tests)
+	return helpers, tests
+}
+
+// IsTestFunc reports whether name follows the go test naming conventions.
+func IsTestFunc(name string) bool {
+	for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+		if name == prefix || (strings.HasPrefix(name, prefix) && !isLower(name[len(prefix)])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
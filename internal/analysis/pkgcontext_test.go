@@ -0,0 +1,153 @@
+package analysis
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFunctionContext(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module demo\n\ngo 1.21\n",
+		"store.go": `package demo
+
+type Address struct{ City string }
+
+type User struct {
+	ID   int
+	Name string
+	Home Address
+}
+
+// NewUser builds a user.
+func NewUser(name string) *User { return &User{Name: name} }
+
+type Store interface {
+	Get(id int) (User, error)
+}
+
+// Lookup fetches a user.
+func Lookup(s Store, id int) (User, error) {
+	return s.Get(id)
+}
+`,
+		"helper_test.go": `package demo
+
+import "testing"
+
+type fakeStore map[int]User
+
+func (f fakeStore) Get(id int) (User, error) { return f[id], nil }
+
+func newFakeStore() fakeStore { return fakeStore{} }
+
+func TestExisting(t *testing.T) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	ctx, err := LoadFunctionContext(filepath.Join(dir, "store.go"), "Lookup")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if ctx.PackageName != "demo" || ctx.ImportPath != "demo" {
+		t.Fatalf("unexpected package: %s %s", ctx.PackageName, ctx.ImportPath)
+	}
+	if !strings.HasPrefix(ctx.Source, "// Lookup fetches a user.") {
+		t.Fatalf("expected source with doc comment, got %q", ctx.Source)
+	}
+	types := strings.Join(ctx.Types, "\n")
+	for _, want := range []string{"type Store interface", "type User struct {\n\tID int\n\tName string\n\tHome Address\n}", "type Address struct {\n\tCity string\n}"} {
+		if !strings.Contains(types, want) {
+			t.Fatalf("expected %q in types:\n%s", want, types)
+		}
+	}
+	if len(ctx.Constructors) != 1 || ctx.Constructors[0] != "func NewUser(name string) *User" {
+		t.Fatalf("unexpected constructors: %v", ctx.Constructors)
+	}
+	if len(ctx.Interfaces) != 0 {
+		t.Fatalf("expected Store to be listed once under types, got interfaces: %v", ctx.Interfaces)
+	}
+	if len(ctx.ExistingTests) != 1 || ctx.ExistingTests[0] != "TestExisting" {
+		t.Fatalf("unexpected existing tests: %v", ctx.ExistingTests)
+	}
+	helpers := strings.Join(ctx.Helpers, "\n")
+	if !strings.Contains(helpers, "func newFakeStore() fakeStore") {
+		t.Fatalf("expected helper source, got:\n%s", helpers)
+	}
+}
+
+func TestPackageExportedFunctions(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module demo\n\ngo 1.21\n",
+		"svc.go": `package demo
+
+type Service struct{}
+
+func (s *Service) Create(name string) error { return nil }
+
+func (s *Service) reset() {}
+
+type cache struct{}
+
+func (c cache) Get() string { return "" }
+
+func Create(name string) string { return name }
+`,
+		"svc_test.go": `package demo
+
+import "testing"
+
+func TestService_Create(t *testing.T) {}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	pkg, err := LoadPackage(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var selectors []string
+	for _, ref := range pkg.ExportedFunctions() {
+		selectors = append(selectors, ref.Selector)
+	}
+	if got := strings.Join(selectors, ","); got != "Service.Create,Create" {
+		t.Fatalf("unexpected exported functions: %s", got)
+	}
+	if tests := pkg.ExistingTests(); len(tests) != 1 || tests[0] != "TestService_Create" {
+		t.Fatalf("unexpected existing tests: %v", tests)
+	}
+
+	ctx, err := pkg.FunctionContext(filepath.Join(dir, "svc.go"), "(*Service).Create")
+	if err != nil {
+		t.Fatalf("function context: %v", err)
+	}
+	if !strings.HasPrefix(ctx.Source, "func (s *Service) Create") {
+		t.Fatalf("expected the method, got %q", ctx.Source)
+	}
+	ctx, err = pkg.FunctionContext(filepath.Join(dir, "svc.go"), "Create")
+	if err != nil {
+		t.Fatalf("function context: %v", err)
+	}
+	if !strings.HasPrefix(ctx.Source, "func Create") {
+		t.Fatalf("expected the plain function, got %q", ctx.Source)
+	}
+}
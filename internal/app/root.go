@@ -52,16 +52,24 @@ func NewRootCommand(deps commands.Dependencies) *cobra.Command {
 
 	root.AddCommand(
 		commands.NewGenTestsCommand(deps),
+		commands.NewGenMockCommand(deps),
+		commands.NewGenMigrationCommand(deps),
+		commands.NewTestGapsCommand(deps),
+		commands.NewExplainTestFailureCommand(deps),
+		commands.NewExplainBuildCommand(deps),
 		commands.NewCommitCommand(deps),
 		commands.NewExplainAnalyzeCommand(deps),
 		commands.NewPProfCommand(deps),
 		commands.NewReviewMigrationCommand(deps),
 		commands.NewCheckContractCommand(deps),
 		commands.NewExplainLogsCommand(deps),
+		commands.NewExplainPanicCommand(deps),
 		commands.NewLintFixesCommand(deps),
 		commands.NewGenK8sCommand(deps),
 		commands.NewIndexSuggestCommand(deps),
 		commands.NewPRReviewCommand(deps),
+		commands.NewPRDescribeCommand(deps),
+		commands.NewChangelogCommand(deps),
 	)
 
 	return root
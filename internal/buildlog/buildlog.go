@@ -0,0 +1,97 @@
+// Package buildlog parses the diagnostics printed by `go build` and `go vet` and
+// groups them by the change that most likely caused them.
+package buildlog
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Diagnostic is one "file:line:col: message" entry.
+type Diagnostic struct {
+	// Package is the import path from the preceding "# pkg" header, if any.
+	Package string
+	File    string
+	Line    int
+	Col     int
+	Message string
+	// Detail holds the indented lines that follow, e.g. "have (string)".
+	Detail []string
+	// Vet reports that the entry came from go vet rather than the compiler.
+	Vet bool
+}
+
+// Location is "file:line:col".
+func (d Diagnostic) Location() string {
+	loc := d.File + ":" + strconv.Itoa(d.Line)
+	if d.Col > 0 {
+		loc += ":" + strconv.Itoa(d.Col)
+	}
+	return loc
+}
+
+// String renders the diagnostic as the toolchain printed it.
+func (d Diagnostic) String() string {
+	s := d.Location() + ": " + d.Message
+	for _, l := range d.Detail {
+		s += "\n\t" + l
+	}
+	return s
+}
+
+var (
+	// api/api.go:10:13: not enough arguments in call to s.Get
+	// vet: api/api.go:10:16: ...
+	diagnosticPattern = regexp.MustCompile(`^(vet: )?((?:[A-Za-z]:)?[^\s:]+\.go):(\d+)(?::(\d+))?: (.+)$`)
+	headerPattern     = regexp.MustCompile(`^# (\S+)`)
+)
+
+// Parse reads build or vet output. Compiler and vet runs usually report the same
+// type errors; duplicates (same file, line and message) are dropped, keeping the
+// first. Lines that are not diagnostics are ignored.
+func Parse(r io.Reader) ([]Diagnostic, error) {
+	var (
+		diags []Diagnostic
+		pkg   string
+		seen  = map[string]bool{}
+		last  = -1 // index of the diagnostic continuation lines belong to
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if m := headerPattern.FindStringSubmatch(line); m != nil {
+			pkg, last = m[1], -1
+			continue
+		}
+		if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "    ") {
+			if last >= 0 {
+				diags[last].Detail = append(diags[last].Detail, strings.TrimSpace(line))
+			}
+			continue
+		}
+		m := diagnosticPattern.FindStringSubmatch(line)
+		if m == nil {
+			last = -1
+			continue
+		}
+		d := Diagnostic{Package: pkg, File: strings.TrimPrefix(m[2], "./"), Message: m[5], Vet: m[1] != ""}
+		d.Line, _ = strconv.Atoi(m[3])
+		d.Col, _ = strconv.Atoi(m[4])
+		key := d.File + ":" + m[3] + ": " + d.Message
+		if seen[key] {
+			last = -1
+			continue
+		}
+		seen[key] = true
+		diags = append(diags, d)
+		last = len(diags) - 1
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return diags, nil
+}
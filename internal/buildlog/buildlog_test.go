@@ -0,0 +1,93 @@
+package buildlog
+
+import (
+	"strings"
+	"testing"
+)
+
+// sampleOutput is go build ./... followed by go vet ./... after Store.Get gained a
+// context parameter.
+const sampleOutput = `# example.com/eb/api
+api/api.go:10:7: assignment mismatch: 1 variable but s.Get returns 2 values
+api/api.go:10:13: not enough arguments in call to s.Get
+	have (string)
+	want (context.Context, string)
+api/api.go:11:18: not enough arguments in call to s.Get
+	have (string)
+	want (context.Context, string)
+api/api.go:13:13: undefined: Missing
+api/api.go:17:2: declared and not used: x
+api/api.go:17:7: assignment mismatch: 1 variable but s.Get returns 2 values
+api/api.go:17:13: not enough arguments in call to s.Get
+	have (string)
+	want (context.Context, string)
+main.go:7:14: fmt.Printf format %s has arg n of wrong type int
+# example.com/eb/api
+vet: api/api.go:10:16: not enough arguments in call to s.Get
+	have (string)
+	want (context.Context, string)
+`
+
+func TestParse(t *testing.T) {
+	diags, err := Parse(strings.NewReader(sampleOutput))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	// Seven compiler errors plus the vet format check; vet's repeat of the type
+	// error is dropped.
+	if len(diags) != 8 {
+		t.Fatalf("expected 8 diagnostics, got %d: %+v", len(diags), diags)
+	}
+	d := diags[1]
+	if d.Package != "example.com/eb/api" || d.Location() != "api/api.go:10:13" || d.Message != "not enough arguments in call to s.Get" || d.Vet {
+		t.Fatalf("unexpected diagnostic %+v", d)
+	}
+	if len(d.Detail) != 2 || d.Detail[1] != "want (context.Context, string)" {
+		t.Fatalf("unexpected detail %q", d.Detail)
+	}
+	if last := diags[7]; last.File != "main.go" || !strings.HasPrefix(last.Message, "fmt.Printf format %s") || len(last.Detail) != 0 {
+		t.Fatalf("unexpected vet diagnostic %+v", last)
+	}
+}
+
+func TestGroupByCause(t *testing.T) {
+	diags, err := Parse(strings.NewReader(sampleOutput))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	groups := GroupByCause(diags)
+	if len(groups) != 4 {
+		t.Fatalf("expected 4 groups, got %+v", groups)
+	}
+	first := groups[0]
+	if first.Cause.Kind != "call" || first.Cause.Symbol != "Get" || len(first.Diagnostics) != 5 {
+		t.Fatalf("unexpected first group %+v", first)
+	}
+	if files := first.Files(); len(files) != 1 || files[0] != "api/api.go" {
+		t.Fatalf("unexpected files %v", files)
+	}
+	if groups[1].Cause.Title != "references to undefined Missing" {
+		t.Fatalf("unexpected second group %+v", groups[1].Cause)
+	}
+}
+
+func TestCauseOf(t *testing.T) {
+	cases := []struct {
+		message, kind, symbol string
+	}{
+		{"too many arguments in call to store.New", "call", "New"},
+		{"assignment mismatch: 2 variables but h.svc.Create returns 1 value", "call", "Create"},
+		{"s.Close() (no value) used as value", "call", "Close"},
+		{"cannot use ctx (variable of interface type context.Context) as string value in argument to s.Get", "call", "Get"},
+		{"s.Flush undefined (type *store.Store has no field or method Flush)", "member", "Store.Flush"},
+		{"cannot use &f (value of type *File) as io.Reader value in assignment: *File does not implement io.Reader (missing method Read)", "interface", "io.Reader.Read"},
+		{"could not import example.com/eb/store (open : no such file)", "import", "example.com/eb/store"},
+		{"missing return", "", ""},
+	}
+	for _, tc := range cases {
+		got := CauseOf(tc.message)
+		if got.Kind != tc.kind || got.Symbol != tc.symbol {
+			t.Fatalf("CauseOf(%q) = %+v, want %s %s", tc.message, got, tc.kind, tc.symbol)
+		}
+	}
+}
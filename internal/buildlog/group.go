@@ -0,0 +1,117 @@
+package buildlog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Cause is what a group of diagnostics has in common.
+type Cause struct {
+	// Kind is "call" (a function's signature changed), "undefined", "member",
+	// "interface", "import" or "" when the message was not recognised.
+	Kind string
+	// Symbol is the function, identifier, member or interface involved: "Get",
+	// "Missing", "Store.Close", "io.Reader.Read".
+	Symbol string
+	// Title describes the cause in one line.
+	Title string
+}
+
+// Group is a set of diagnostics sharing a cause, e.g. the forty call sites broken by
+// one changed signature.
+type Group struct {
+	Cause       Cause
+	Diagnostics []Diagnostic
+}
+
+// Files lists the distinct files the group touches, in order of appearance.
+func (g Group) Files() []string {
+	var files []string
+	seen := map[string]bool{}
+	for _, d := range g.Diagnostics {
+		if !seen[d.File] {
+			seen[d.File] = true
+			files = append(files, d.File)
+		}
+	}
+	return files
+}
+
+type causePattern struct {
+	re    *regexp.Regexp
+	kind  string
+	title string
+	// symbol builds the symbol from the submatches.
+	symbol func(m []string) string
+}
+
+func lastSelector(m []string) string {
+	s := strings.TrimSuffix(m[1], "()")
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+var causePatterns = []causePattern{
+	{regexp.MustCompile(`^(?:not enough|too many) arguments in call to (\S+)`), "call", "calls that no longer match the signature of %s", lastSelector},
+	{regexp.MustCompile(`^assignment mismatch: .* but (\S+?)(?:\(\))? returns? \d+ values?`), "call", "calls that no longer match the signature of %s", lastSelector},
+	{regexp.MustCompile(`^(\S+?)\(.*\) \(no value\) used as value`), "call", "calls that no longer match the signature of %s", lastSelector},
+	{regexp.MustCompile(`^cannot use .* as .* value in argument to (\S+)`), "call", "calls that no longer match the signature of %s", lastSelector},
+	{regexp.MustCompile(`^undefined: (\S+)`), "undefined", "references to undefined %s", func(m []string) string { return m[1] }},
+	{regexp.MustCompile(`^\S+ undefined \(type \*?(?:\[\])?(\S+?) has no field or method (\w+)`), "member", "uses of missing field or method %s", func(m []string) string { return trimPackage(m[1]) + "." + m[2] }},
+	{regexp.MustCompile(`does not implement (\S+) \((?:missing method|wrong type for method) (\w+)\)`), "interface", "types that no longer implement %s", func(m []string) string { return m[1] + "." + m[2] }},
+	{regexp.MustCompile(`^could not import (\S+)`), "import", "packages that fail to import %s", func(m []string) string { return m[1] }},
+}
+
+// trimPackage turns "example.com/x/store.Store" into "Store".
+func trimPackage(typ string) string {
+	if i := strings.LastIndex(typ, "."); i >= 0 {
+		return typ[i+1:]
+	}
+	return typ
+}
+
+// CauseOf classifies a diagnostic message.
+func CauseOf(message string) Cause {
+	for _, p := range causePatterns {
+		if m := p.re.FindStringSubmatch(message); m != nil {
+			symbol := p.symbol(m)
+			return Cause{Kind: p.kind, Symbol: symbol, Title: fmt.Sprintf(p.title, symbol)}
+		}
+	}
+	return Cause{Title: message}
+}
+
+// GroupByCause groups diagnostics by cause, largest group first; ties keep the
+// order in which their first diagnostic was printed. Unrecognised messages group
+// only with identical messages.
+func GroupByCause(diags []Diagnostic) []Group {
+	var groups []*Group
+	byKey := map[string]*Group{}
+	for _, d := range diags {
+		cause := CauseOf(d.Message)
+		key := cause.Kind + "|" + cause.Symbol
+		if cause.Kind == "" {
+			key = "|" + d.Message
+		}
+		g, ok := byKey[key]
+		if !ok {
+			g = &Group{Cause: cause}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.Diagnostics = append(g.Diagnostics, d)
+	}
+
+	out := make([]Group, len(groups))
+	for i, g := range groups {
+		out[i] = *g
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return len(out[i].Diagnostics) > len(out[j].Diagnostics)
+	})
+	return out
+}
@@ -0,0 +1,462 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/git"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// NewChangelogCommand prepends a Keep-a-Changelog release section generated from git history.
+func NewChangelogCommand(deps Dependencies) *cobra.Command {
+	var (
+		from          string
+		to            string
+		out           string
+		version       string
+		model         string
+		includeChores bool
+		noPolish      bool
+		dryRun        bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "changelog",
+		Short: "Generate a Keep-a-Changelog release section from Conventional Commits",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			if from == "" {
+				tag, err := deps.Git.LatestTag(to)
+				if err != nil {
+					return fmt.Errorf("--from not set and no tag reachable from %s: %w", to, err)
+				}
+				from = tag
+			}
+			deps.Logger.Info(cmd, "Reading history from %s to %s. Historians everywhere are taking notes.", from, to)
+			commits, err := deps.Git.Log(from + ".." + to)
+			if err != nil {
+				return err
+			}
+
+			entries := buildChangelogEntries(commits, includeChores)
+			if len(entries) == 0 {
+				return fmt.Errorf("no changelog-worthy Conventional Commits between %s and %s", from, to)
+			}
+			bump := suggestBump(entries)
+			deps.Logger.Info(cmd, "Classified %d of %d commits. Suggested semver bump: %s.", len(entries), len(commits), bump)
+
+			if version == "" {
+				if next, ok := nextVersion(from, bump); ok {
+					version = next
+				} else {
+					version = "Unreleased"
+				}
+			}
+
+			var summary string
+			if !noPolish {
+				ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+				defer cancel()
+
+				modelUse := textutil.Choose(model, deps.Config.ModelGeneral)
+				deps.Logger.Info(cmd, "Asking model %s to turn commit shorthand into prose fit for the public.", modelUse)
+				summary, err = polishChangelogEntries(ctx, deps, modelUse, entries)
+				if err != nil {
+					return err
+				}
+			}
+
+			section := renderChangelogSection(version, time.Now().Format("2006-01-02"), summary, entries)
+			fmt.Fprintf(cmd.ErrOrStderr(), "Suggested version bump: %s (%s)\n", bump, version)
+			if dryRun {
+				_, err = cmd.OutOrStdout().Write([]byte(section))
+				return err
+			}
+
+			existing, err := deps.Files.Read(out)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			if err := deps.Files.WriteFile(out, prependChangelogSection(existing, section)); err != nil {
+				return err
+			}
+			deps.Logger.Info(cmd, "Release notes prepended to %s. Future archaeologists thank you.", out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "Start ref, exclusive (default latest tag)")
+	cmd.Flags().StringVar(&to, "to", "HEAD", "End ref, inclusive")
+	cmd.Flags().StringVar(&out, "out", "CHANGELOG.md", "Changelog file to prepend to")
+	cmd.Flags().StringVar(&version, "version", "", "Release version (default: suggested bump of --from tag)")
+	cmd.Flags().StringVar(&model, "model", "", "Override model (default SHELDON_MODEL)")
+	cmd.Flags().BoolVar(&includeChores, "include-chores", false, "Also list docs/test/chore/ci/build/style commits under Other")
+	cmd.Flags().BoolVar(&noPolish, "no-polish", false, "Skip the LLM and use commit descriptions verbatim")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the section to stdout instead of writing the file")
+	return cmd
+}
+
+// conventionalHeader is a parsed Conventional Commit subject line.
+type conventionalHeader struct {
+	Type        string
+	Scope       string
+	Description string
+	Breaking    bool
+}
+
+var conventionalHeaderPattern = regexp.MustCompile(`^([a-z]+)(?:\(([^)]*)\))?(!)?: (.+)$`)
+
+// parseConventionalHeader splits a commit subject into its parts, using the same
+// validator as llm-commit so both commands agree on what counts as conventional.
+// A `!` before the colon marks a breaking change; ticket prefixes are skipped.
+// The description may start in either case: llm-commit writes it lowercase, but
+// a hand-written "feat: Add X" is still a feature worth listing.
+func parseConventionalHeader(subject string) (conventionalHeader, bool) {
+	candidate := strings.TrimSpace(subject)
+	for candidate != "" {
+		if m := conventionalHeaderPattern.FindStringSubmatch(candidate); m != nil {
+			first, size := utf8.DecodeRuneInString(m[4])
+			normalized := strings.Replace(candidate[:len(candidate)-len(m[4])], "!:", ":", 1) + string(unicode.ToLower(first)) + m[4][size:]
+			if validateConventionalCommit(normalized) {
+				return conventionalHeader{Type: m[1], Scope: m[2], Description: m[4], Breaking: m[3] == "!"}, true
+			}
+		}
+		sep := strings.IndexAny(candidate, " \t")
+		if sep == -1 {
+			break
+		}
+		candidate = strings.TrimSpace(candidate[sep+1:])
+	}
+	return conventionalHeader{}, false
+}
+
+// changelogEntry is one bullet of a release section.
+type changelogEntry struct {
+	ID       int
+	Section  string
+	Scope    string
+	Text     string
+	Hash     string
+	Breaking bool
+}
+
+// Keep-a-Changelog section order.
+var changelogSections = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security", "Other"}
+
+// changelogSectionFor maps a Conventional Commit type to a Keep-a-Changelog section.
+// Conventional Commits has no types for deprecations and removals, so a feat, perf
+// or refactor whose description starts with "deprecate" or "remove"/"drop" goes to
+// Deprecated or Removed. Internal-only types return "" unless includeChores is set.
+func changelogSectionFor(h conventionalHeader, includeChores bool) string {
+	switch h.Type {
+	case "feat", "perf", "refactor":
+		verb, _, _ := strings.Cut(strings.ToLower(h.Description), " ")
+		switch verb {
+		case "deprecate", "deprecates", "deprecated":
+			return "Deprecated"
+		case "remove", "removes", "removed", "drop", "drops", "dropped":
+			return "Removed"
+		}
+		if h.Type == "feat" {
+			return "Added"
+		}
+		return "Changed"
+	case "fix":
+		if strings.Contains(strings.ToLower(h.Scope), "security") {
+			return "Security"
+		}
+		return "Fixed"
+	}
+	if includeChores {
+		return "Other"
+	}
+	return ""
+}
+
+// buildChangelogEntries groups commits into sections, skipping merges and
+// commits that are not Conventional Commits.
+func buildChangelogEntries(commits []git.Commit, includeChores bool) []changelogEntry {
+	var entries []changelogEntry
+	// git log is newest first; changelogs read better oldest first within a section.
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		if len(c.Parents) > 1 {
+			continue
+		}
+		h, ok := parseConventionalHeader(c.Subject)
+		if !ok {
+			continue
+		}
+		breaking := h.Breaking || strings.Contains(c.Body, "BREAKING CHANGE:") || strings.Contains(c.Body, "BREAKING-CHANGE:")
+		section := changelogSectionFor(h, includeChores)
+		if section == "" && !breaking {
+			continue
+		}
+		if section == "" {
+			section = "Changed"
+		}
+		entries = append(entries, changelogEntry{
+			ID:       len(entries) + 1,
+			Section:  section,
+			Scope:    h.Scope,
+			Text:     h.Description,
+			Hash:     shortHash(c.Hash),
+			Breaking: breaking,
+		})
+	}
+	return entries
+}
+
+// suggestBump returns "major", "minor" or "patch" following semver rules.
+func suggestBump(entries []changelogEntry) string {
+	bump := "patch"
+	for _, e := range entries {
+		if e.Breaking {
+			return "major"
+		}
+		if e.Section == "Added" {
+			bump = "minor"
+		}
+	}
+	return bump
+}
+
+var semverPattern = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)`)
+
+// nextVersion applies bump to a vX.Y.Z tag. Pre-1.0 versions bump minor for breaking changes.
+func nextVersion(tag, bump string) (string, bool) {
+	m := semverPattern.FindStringSubmatch(tag)
+	if m == nil {
+		return "", false
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	patch, _ := strconv.Atoi(m[4])
+	switch {
+	case bump == "major" && major > 0:
+		major, minor, patch = major+1, 0, 0
+	case bump == "major" || bump == "minor":
+		minor, patch = minor+1, 0
+	default:
+		patch++
+	}
+	return fmt.Sprintf("%s%d.%d.%d", m[1], major, minor, patch), true
+}
+
+// renderChangelogSection formats a Keep-a-Changelog release section.
+func renderChangelogSection(version, date, breakingSummary string, entries []changelogEntry) string {
+	var b strings.Builder
+	if version == "" || version == "Unreleased" {
+		b.WriteString("## [Unreleased]\n")
+	} else {
+		fmt.Fprintf(&b, "## [%s] - %s\n", strings.TrimPrefix(version, "v"), date)
+	}
+	if breakingSummary != "" {
+		fmt.Fprintf(&b, "\n**Breaking changes:** %s\n", breakingSummary)
+	}
+	for _, section := range changelogSections {
+		var lines []string
+		for _, e := range entries {
+			if e.Section != section {
+				continue
+			}
+			text := e.Text
+			if e.Scope != "" {
+				text = fmt.Sprintf("**%s:** %s", e.Scope, text)
+			}
+			if e.Breaking {
+				text = "**BREAKING** " + text
+			}
+			lines = append(lines, fmt.Sprintf("- %s (%s)", text, e.Hash))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n\n%s\n", section, strings.Join(lines, "\n"))
+	}
+	return b.String()
+}
+
+const changelogPreamble = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/),
+and this project adheres to [Semantic Versioning](https://semver.org/spec/v2.0.0.html).
+`
+
+var (
+	unreleasedHeading = regexp.MustCompile(`(?im)^## \[?unreleased\]?[ \t]*$`)
+	// changelogSectionEnd matches what follows a section: the next release or the
+	// link definitions at the foot of the file.
+	changelogSectionEnd = regexp.MustCompile(`(?m)^(## |\[[^\]]+\]: )`)
+)
+
+// prependChangelogSection inserts section above the newest release of an existing
+// changelog, creating the standard preamble when the file is empty. An Unreleased
+// section stays on top, as Keep a Changelog orders it: a release goes below it, and
+// a new Unreleased section is merged into it so hand-written entries survive.
+func prependChangelogSection(existing, section string) string {
+	if strings.TrimSpace(existing) == "" {
+		return changelogPreamble + "\n" + section
+	}
+	if loc := unreleasedHeading.FindStringIndex(existing); loc != nil {
+		end := len(existing)
+		if next := changelogSectionEnd.FindStringIndex(existing[loc[1]:]); next != nil {
+			end = loc[1] + next[0]
+		}
+		heading, body, _ := strings.Cut(section, "\n")
+		if unreleasedHeading.MatchString(heading) {
+			return existing[:loc[0]] + heading + "\n" + mergeChangelogBodies(body, existing[loc[1]:end]) + "\n" + existing[end:]
+		}
+		return strings.TrimRight(existing[:end], "\n") + "\n\n" + section + "\n" + existing[end:]
+	}
+	if idx := strings.Index(existing, "\n## "); idx >= 0 {
+		return existing[:idx+1] + section + "\n" + existing[idx+1:]
+	}
+	if strings.HasPrefix(existing, "## ") {
+		return section + "\n" + existing
+	}
+	return strings.TrimRight(existing, "\n") + "\n\n" + section
+}
+
+// changelogBlock is the text under one "### " heading of a section; the block
+// before the first heading has an empty heading.
+type changelogBlock struct {
+	Heading string
+	Lines   []string
+}
+
+// splitChangelogBlocks splits a section body at its "### " headings, dropping
+// the blank lines around each block.
+func splitChangelogBlocks(body string) []changelogBlock {
+	blocks := []changelogBlock{{}}
+	for _, line := range strings.Split(body, "\n") {
+		if heading, ok := strings.CutPrefix(line, "### "); ok {
+			blocks = append(blocks, changelogBlock{Heading: strings.TrimSpace(heading)})
+			continue
+		}
+		last := &blocks[len(blocks)-1]
+		last.Lines = append(last.Lines, line)
+	}
+	for i := range blocks {
+		lines := blocks[i].Lines
+		for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+			lines = lines[1:]
+		}
+		for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+			lines = lines[:len(lines)-1]
+		}
+		blocks[i].Lines = lines
+	}
+	return blocks
+}
+
+// mergeChangelogBodies combines a generated Unreleased body with the one already
+// in the file. Each heading keeps the generated lines first, followed by the
+// existing lines that are not among them, so re-running the command does not
+// duplicate entries; headings only the file has follow in their own order.
+func mergeChangelogBodies(generated, existing string) string {
+	blocks := splitChangelogBlocks(generated)
+	index := make(map[string]int, len(blocks))
+	for i, b := range blocks {
+		index[strings.ToLower(b.Heading)] = i
+	}
+	for _, old := range splitChangelogBlocks(existing) {
+		i, ok := index[strings.ToLower(old.Heading)]
+		if !ok {
+			index[strings.ToLower(old.Heading)] = len(blocks)
+			blocks = append(blocks, old)
+			continue
+		}
+		seen := make(map[string]bool, len(blocks[i].Lines))
+		for _, line := range blocks[i].Lines {
+			seen[strings.TrimSpace(line)] = true
+		}
+		for _, line := range old.Lines {
+			if strings.TrimSpace(line) == "" || !seen[strings.TrimSpace(line)] {
+				blocks[i].Lines = append(blocks[i].Lines, line)
+			}
+		}
+	}
+	var b strings.Builder
+	for _, block := range blocks {
+		if len(block.Lines) == 0 {
+			continue
+		}
+		if block.Heading != "" {
+			fmt.Fprintf(&b, "\n### %s\n", block.Heading)
+		}
+		fmt.Fprintf(&b, "\n%s\n", strings.Join(block.Lines, "\n"))
+	}
+	return b.String()
+}
+
+// polishChangelogEntries asks the model to rewrite entries for end users and to
+// summarise breaking changes. Entries the model skips keep their original text.
+func polishChangelogEntries(ctx context.Context, deps Dependencies, model string, entries []changelogEntry) (string, error) {
+	var b strings.Builder
+	b.WriteString(`Polish these changelog entries for end users of the project.
+Rewrite each as one short, past-tense line without the commit type, scope, or trailing period.
+Return exactly one line per entry formatted as "<id>: <polished text>".
+If any entry is marked [BREAKING], finish with one line "SUMMARY: <one or two sentences describing what breaks and how to migrate>".
+Do not add anything else.
+
+`)
+	for _, e := range entries {
+		marker := ""
+		if e.Breaking {
+			marker = " [BREAKING]"
+		}
+		fmt.Fprintf(&b, "%d: [%s]%s %s\n", e.ID, e.Section, marker, e.Text)
+	}
+
+	ans, err := deps.LLM.Generate(ctx, model, b.String())
+	if err != nil {
+		return "", err
+	}
+
+	byID := make(map[int]*changelogEntry, len(entries))
+	for i := range entries {
+		byID[entries[i].ID] = &entries[i]
+	}
+	var summary string
+	for _, line := range strings.Split(normalizeCommitMessage(ans), "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "-* "))
+		if rest, ok := strings.CutPrefix(line, "SUMMARY:"); ok {
+			summary = strings.TrimSpace(rest)
+			continue
+		}
+		idText, text, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(idText))
+		if err != nil {
+			continue
+		}
+		if e, found := byID[id]; found && strings.TrimSpace(text) != "" {
+			e.Text = strings.TrimSuffix(strings.TrimSpace(text), ".")
+		}
+	}
+	return summary, nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
@@ -0,0 +1,107 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/git"
+)
+
+func TestParseConventionalHeader(t *testing.T) {
+	cases := []struct {
+		subject  string
+		typ      string
+		scope    string
+		breaking bool
+		ok       bool
+	}{
+		{"feat(api): add users endpoint", "feat", "api", false, true},
+		{"fix!: drop legacy flag", "fix", "", true, true},
+		{"WIT-12 perf(db): batch inserts", "perf", "db", false, true},
+		{"feat: Add CSV export", "feat", "", false, true},
+		{"Merge branch 'main'", "", "", false, false},
+	}
+	for _, tc := range cases {
+		h, ok := parseConventionalHeader(tc.subject)
+		if ok != tc.ok || h.Type != tc.typ || h.Scope != tc.scope || h.Breaking != tc.breaking {
+			t.Fatalf("%q: unexpected result %#v (ok=%v)", tc.subject, h, ok)
+		}
+	}
+}
+
+func TestBuildChangelogEntriesAndBump(t *testing.T) {
+	commits := []git.Commit{
+		{Hash: "cccccccccc", Subject: "docs: update readme"},
+		{Hash: "bbbbbbbbbb", Subject: "fix(parser): handle empty input", Body: "BREAKING CHANGE: errors are now typed"},
+		{Hash: "aaaaaaaaaa", Subject: "feat: add export"},
+		{Hash: "dddddddddd", Subject: "Merge pull request #1", Parents: []string{"a", "b"}},
+	}
+	entries := buildChangelogEntries(commits, false)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %#v", entries)
+	}
+	if entries[0].Section != "Added" || entries[0].Hash != "aaaaaaa" {
+		t.Fatalf("expected oldest commit first, got %#v", entries[0])
+	}
+	if !entries[1].Breaking || entries[1].Section != "Fixed" {
+		t.Fatalf("expected breaking fix, got %#v", entries[1])
+	}
+	for subject, want := range map[string]string{
+		"feat: deprecate the v1 endpoints":      "Deprecated",
+		"refactor!: remove the legacy exporter": "Removed",
+		"feat(cli): drop --old flag":            "Removed",
+		"fix: remove a stray newline":           "Fixed",
+	} {
+		h, _ := parseConventionalHeader(subject)
+		if got := changelogSectionFor(h, false); got != want {
+			t.Errorf("%q: section %s, want %s", subject, got, want)
+		}
+	}
+	if bump := suggestBump(entries); bump != "major" {
+		t.Fatalf("expected major bump, got %s", bump)
+	}
+	if v, _ := nextVersion("v1.4.2", "major"); v != "v2.0.0" {
+		t.Fatalf("unexpected major version %s", v)
+	}
+	if v, _ := nextVersion("v0.4.2", "major"); v != "v0.5.0" {
+		t.Fatalf("expected pre-1.0 breaking change to bump minor, got %s", v)
+	}
+	if v, _ := nextVersion("1.4.2", "patch"); v != "1.4.3" {
+		t.Fatalf("unexpected patch version %s", v)
+	}
+}
+
+func TestPrependChangelogSection(t *testing.T) {
+	entries := []changelogEntry{{ID: 1, Section: "Added", Scope: "api", Text: "Added export", Hash: "abc1234"}}
+	section := renderChangelogSection("v1.1.0", "2024-05-01", "", entries)
+	want := "## [1.1.0] - 2024-05-01\n\n### Added\n\n- **api:** Added export (abc1234)\n"
+	if section != want {
+		t.Fatalf("unexpected section:\n%s", section)
+	}
+
+	existing := "# Changelog\n\nIntro.\n\n## [1.0.0] - 2024-01-01\n\n### Fixed\n\n- Old fix\n"
+	merged := prependChangelogSection(existing, section)
+	if strings.Index(merged, "## [1.1.0]") > strings.Index(merged, "## [1.0.0]") || !strings.HasPrefix(merged, "# Changelog") {
+		t.Fatalf("expected new section above previous release, got:\n%s", merged)
+	}
+
+	// Keep a Changelog keeps Unreleased on top: a release goes below it and a new
+	// Unreleased section is merged into it, keeping the hand-written entries.
+	existing = "# Changelog\n\n## [Unreleased]\n\n- Pending\n\n### Added\n\n- Hand-written feature\n- **api:** Added export (abc1234)\n\n### Security\n\n- Rotated keys\n\n## [1.0.0] - 2024-01-01\n\n- Old fix\n\n[unreleased]: https://example.com/compare\n"
+	merged = prependChangelogSection(existing, section)
+	if want := "# Changelog\n\n## [Unreleased]\n\n- Pending\n"; !strings.HasPrefix(merged, want) || !strings.Contains(merged, "- Rotated keys\n\n"+section+"\n## [1.0.0]") {
+		t.Fatalf("expected the release below Unreleased, got:\n%s", merged)
+	}
+	unreleased := renderChangelogSection("Unreleased", "", "", entries)
+	merged = prependChangelogSection(existing, unreleased)
+	want = "# Changelog\n\n## [Unreleased]\n\n- Pending\n\n### Added\n\n- **api:** Added export (abc1234)\n- Hand-written feature\n\n### Security\n\n- Rotated keys\n\n## [1.0.0]"
+	if !strings.HasPrefix(merged, want) {
+		t.Fatalf("expected the Unreleased sections merged, got:\n%s", merged)
+	}
+	if !strings.HasSuffix(merged, "[unreleased]: https://example.com/compare\n") {
+		t.Fatalf("expected link definitions kept, got:\n%s", merged)
+	}
+	if fresh := prependChangelogSection("", section); !strings.HasPrefix(fresh, "# Changelog") || !strings.Contains(fresh, section) {
+		t.Fatalf("expected preamble for new changelog, got:\n%s", fresh)
+	}
+}
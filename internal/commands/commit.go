@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/riskiramdan/ShELDon/internal/textutil"
+	"github.com/riskiramdan/ShELDon/internal/unidiff"
 )
 
 // NewCommitCommand generates a Conventional Commit message using LLM support.
@@ -17,6 +18,8 @@ func NewCommitCommand(deps Dependencies) *cobra.Command {
 		model      string
 		prefix     string
 		autoCommit bool
+		split      bool
+		assumeYes  bool
 	)
 
 	cmd := &cobra.Command{
@@ -26,7 +29,13 @@ func NewCommitCommand(deps Dependencies) *cobra.Command {
 			cmd.SilenceUsage = true
 
 			deps.Logger.Info(cmd, "Evaluating your staged diff. Contain your anticipation.")
-			diff, err := deps.Git.Diff("--staged")
+			diffArgs := []string{"--staged"}
+			if split {
+				// The split patches are applied with git apply, which needs the
+				// contents of binary files rather than "Binary files differ".
+				diffArgs = append(diffArgs, "--binary")
+			}
+			diff, err := deps.Git.Diff(diffArgs...)
 			if err != nil {
 				return err
 			}
@@ -34,86 +43,110 @@ func NewCommitCommand(deps Dependencies) *cobra.Command {
 				return errors.New("no staged changes")
 			}
 
-			// Defensive: cap diff size and escape triple backticks to avoid block echoes.
-			const maxDiffLen = 10000
-			if len(diff) > maxDiffLen {
-				diff = diff[len(diff)-maxDiffLen:]
-				deps.Logger.Info(cmd, "Diff truncated to last %d bytes for model input.", maxDiffLen)
+			modelUse := textutil.Choose(model, deps.Config.ModelGeneral)
+			if split {
+				return runSplitCommit(cmd, deps, modelUse, prefix, diff, assumeYes)
 			}
-			diff = strings.ReplaceAll(diff, "```", "`​``") // insert zero-width char to break triple backticks
 
-			basePrompt := fmt.Sprintf(`Write ONLY a single-line Conventional Commit message for the diff below.
-Format exactly as "<type(scope)?: >concise summary in lowercase present tense".
-Keep the line at or below %d characters—be concise instead of adding follow-up text.
-Do not include bullets, explanations, reviews, or multiple lines. Return just the commit header without quotes.`, deps.Config.MaxSummaryLen)
-
-			prompt := basePrompt + "\n\n" + diff
 			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
 			defer cancel()
 
-			modelUse := textutil.Choose(model, deps.Config.ModelGeneral)
-			deps.Logger.Info(cmd, "Summoning model %s to translate chaos into convention.", modelUse)
-
-			// Try up to N times: generate -> normalize -> validate -> if fails, retry with stricter prompt.
-			const maxAttempts = 3
-			var lastCandidate string
-			for attempt := 1; attempt <= maxAttempts; attempt++ {
-				ans, err := deps.LLM.Generate(ctx, modelUse, prompt)
-				if err != nil {
-					return err
-				}
-				candidate := applyPrefix(normalizeCommitMessage(ans), prefix)
-				deps.Logger.Info(cmd, "LLM returned candidate: %s", candidate)
-
-				// Keep only the first line (we want single-line summary for commit header).
-				firstLine := strings.SplitN(candidate, "\n", 2)[0]
-				firstLine = strings.TrimSpace(firstLine)
-
-				// If too long, optionally ask the model to shorten (demonstrated by helper).
-				if len(firstLine) > deps.Config.MaxSummaryLen {
-					deps.Logger.Info(cmd, "Candidate summary too long (%d chars), requesting shortening.", len(firstLine))
-					short, err := shortenSummaryWithLLM(ctx, deps, modelUse, firstLine)
-					if err == nil && short != "" {
-						firstLine = short
-					}
-				}
+			header, err := generateCommitHeader(ctx, cmd, deps, modelUse, prefix, diff)
+			if err != nil {
+				// surface last candidate for manual editing
+				fmt.Fprintln(cmd.OutOrStdout(), header)
+				return err
+			}
 
-				if validateConventionalCommit(firstLine) {
-					// success
-					fmt.Fprintln(cmd.OutOrStdout(), firstLine)
-					deps.Logger.Info(cmd, "Commit message prepared. Praise can be mailed to apartment 4A.")
-					if autoCommit {
-						deps.Logger.Info(cmd, "Executing git commit with the freshly minted prose.")
-						if err := deps.Git.Commit(firstLine); err != nil {
-							return err
-						}
-						deps.Logger.Info(cmd, "Commit recorded. I recommend celebratory string theory.")
-					}
-					return nil
+			fmt.Fprintln(cmd.OutOrStdout(), header)
+			deps.Logger.Info(cmd, "Commit message prepared. Praise can be mailed to apartment 4A.")
+			if autoCommit {
+				deps.Logger.Info(cmd, "Executing git commit with the freshly minted prose.")
+				if err := deps.Git.Commit(header); err != nil {
+					return err
 				}
-
-				deps.Logger.Info(cmd, "Candidate did not match conventional-collected rules (attempt %d).", attempt)
-				lastCandidate = firstLine
-				// make prompt stricter for next attempt
-				prompt = basePrompt + "\n\n" +
-					"The previous candidate was invalid. Produce a single-line Conventional Commit summary only. " +
-					"Use one of the types: feat, fix, docs, style, refactor, perf, test, chore. " +
-					"Example: feat(parser): handle edge case\n\n" + diff
+				deps.Logger.Info(cmd, "Commit recorded. I recommend celebratory string theory.")
 			}
-
-			// all attempts failed -> surface last candidate for manual editing
-			deps.Logger.Info(cmd, "All LLM attempts failed to produce a valid Conventional Commit message.")
-			fmt.Fprintln(cmd.OutOrStdout(), lastCandidate)
-			return errors.New("failed to generate a valid conventional commit message; please edit manually")
+			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&model, "model", "", "Override model (default SHELDON_MODEL)")
 	cmd.Flags().StringVar(&prefix, "prefix", "", "Text prepended to the first line of the generated commit message")
 	cmd.Flags().BoolVar(&autoCommit, "autocommit", false, "If true, automatically run git commit with the generated message")
+	cmd.Flags().BoolVar(&split, "split", false, "Cluster staged hunks into several logical commits")
+	cmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Apply the --split commit plan without asking for confirmation")
 	return cmd
 }
 
+// generateCommitHeader asks the model for a single-line Conventional Commit header,
+// retrying with a stricter prompt until one validates. On failure it returns the last
+// candidate alongside the error so callers can offer it for manual editing.
+func generateCommitHeader(ctx context.Context, cmd *cobra.Command, deps Dependencies, model, prefix, diff string) (string, error) {
+	// Defensive: cap diff size and escape triple backticks to avoid block echoes.
+	const maxDiffLen = 10000
+	if files, err := unidiff.Parse(diff); err == nil && len(files) > 0 {
+		// Budget per hunk so every file keeps a voice; binary blobs collapse to a summary.
+		rendered := unidiff.Render(files, unidiff.RenderOptions{MaxBytes: maxDiffLen})
+		if len(rendered) < len(diff) {
+			deps.Logger.Info(cmd, "Diff condensed from %d to %d bytes for model input.", len(diff), len(rendered))
+		}
+		diff = rendered
+	} else if len(diff) > maxDiffLen {
+		diff = diff[len(diff)-maxDiffLen:]
+		deps.Logger.Info(cmd, "Diff truncated to last %d bytes for model input.", maxDiffLen)
+	}
+	diff = strings.ReplaceAll(diff, "```", "`​``") // insert zero-width char to break triple backticks
+
+	basePrompt := fmt.Sprintf(`Write ONLY a single-line Conventional Commit message for the diff below.
+Format exactly as "<type(scope)?: >concise summary in lowercase present tense".
+Keep the line at or below %d characters—be concise instead of adding follow-up text.
+Do not include bullets, explanations, reviews, or multiple lines. Return just the commit header without quotes.`, deps.Config.MaxSummaryLen)
+
+	prompt := basePrompt + "\n\n" + diff
+	deps.Logger.Info(cmd, "Summoning model %s to translate chaos into convention.", model)
+
+	// Try up to N times: generate -> normalize -> validate -> if fails, retry with stricter prompt.
+	const maxAttempts = 3
+	var lastCandidate string
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		ans, err := deps.LLM.Generate(ctx, model, prompt)
+		if err != nil {
+			return "", err
+		}
+		candidate := applyPrefix(normalizeCommitMessage(ans), prefix)
+		deps.Logger.Info(cmd, "LLM returned candidate: %s", candidate)
+
+		// Keep only the first line (we want single-line summary for commit header).
+		firstLine := strings.SplitN(candidate, "\n", 2)[0]
+		firstLine = strings.TrimSpace(firstLine)
+
+		// If too long, optionally ask the model to shorten (demonstrated by helper).
+		if len(firstLine) > deps.Config.MaxSummaryLen {
+			deps.Logger.Info(cmd, "Candidate summary too long (%d chars), requesting shortening.", len(firstLine))
+			short, err := shortenSummaryWithLLM(ctx, deps, model, firstLine)
+			if err == nil && short != "" {
+				firstLine = short
+			}
+		}
+
+		if validateConventionalCommit(firstLine) {
+			return firstLine, nil
+		}
+
+		deps.Logger.Info(cmd, "Candidate did not match conventional-collected rules (attempt %d).", attempt)
+		lastCandidate = firstLine
+		// make prompt stricter for next attempt
+		prompt = basePrompt + "\n\n" +
+			"The previous candidate was invalid. Produce a single-line Conventional Commit summary only. " +
+			"Use one of the types: feat, fix, docs, style, refactor, perf, test, chore. " +
+			"Example: feat(parser): handle edge case\n\n" + diff
+	}
+
+	deps.Logger.Info(cmd, "All LLM attempts failed to produce a valid Conventional Commit message.")
+	return lastCandidate, errors.New("failed to generate a valid conventional commit message; please edit manually")
+}
+
 func applyPrefix(message, prefix string) string {
 	prefix = strings.TrimSpace(prefix)
 	if prefix == "" || message == "" {
@@ -0,0 +1,309 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/git"
+	"github.com/riskiramdan/ShELDon/internal/unidiff"
+)
+
+// splitUnit is the smallest piece of a staged diff that can move between commits:
+// a single hunk, or a whole file when the change cannot be split (binary, create, delete, rename).
+type splitUnit struct {
+	ID     int
+	Path   string
+	Header string
+	Body   string
+}
+
+// commitGroup is one commit of a split plan.
+type commitGroup struct {
+	Message string
+	Units   []splitUnit
+}
+
+// patch rebuilds a unified diff containing only the group's units, emitting each file header once.
+func (g commitGroup) patch() string {
+	units := append([]splitUnit(nil), g.Units...)
+	sort.SliceStable(units, func(i, j int) bool { return units[i].ID < units[j].ID })
+
+	var b strings.Builder
+	lastHeader := ""
+	for _, u := range units {
+		if u.Header != lastHeader {
+			b.WriteString(u.Header)
+			lastHeader = u.Header
+		}
+		b.WriteString(u.Body)
+	}
+	return b.String()
+}
+
+// files summarises which paths the group touches and how many units each contributes.
+func (g commitGroup) files() []string {
+	counts := map[string]int{}
+	var order []string
+	for _, u := range g.Units {
+		if counts[u.Path] == 0 {
+			order = append(order, u.Path)
+		}
+		counts[u.Path]++
+	}
+	out := make([]string, 0, len(order))
+	for _, p := range order {
+		out = append(out, fmt.Sprintf("%s (%d hunk(s))", p, counts[p]))
+	}
+	return out
+}
+
+// runSplitCommit proposes a multi-commit plan for the staged diff and applies it on confirmation.
+func runSplitCommit(cmd *cobra.Command, deps Dependencies, model, prefix, diff string, assumeYes bool) error {
+	units, err := splitStagedDiff(diff)
+	if err != nil {
+		return err
+	}
+	if len(units) == 0 {
+		return errors.New("no splittable changes found in staged diff")
+	}
+	deps.Logger.Info(cmd, "Dissected the staged diff into %d hunks. Sorting them into a tidier universe.", len(units))
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+	defer cancel()
+
+	clusters := groupUnitsByPackage(units)
+	groups := clusters
+	if len(units) > 1 {
+		prompt := buildSplitPrompt(units, clusters)
+		deps.Logger.Info(cmd, "Asking model %s to find the intent hiding in your hunks.", model)
+		ans, err := deps.LLM.Generate(ctx, model, prompt)
+		if err != nil {
+			return err
+		}
+		if planned := parseSplitPlan(ans, units); len(planned) > 0 {
+			groups = planned
+		} else {
+			deps.Logger.Info(cmd, "Model plan was unusable. Falling back to one commit per package, as any rational being would.")
+		}
+	}
+
+	for i := range groups {
+		msg := applyPrefix(groups[i].Message, prefix)
+		if validateConventionalCommit(msg) && len(msg) <= deps.Config.MaxSummaryLen {
+			groups[i].Message = msg
+			continue
+		}
+		header, err := generateCommitHeader(ctx, cmd, deps, model, prefix, groups[i].patch())
+		if err != nil {
+			return fmt.Errorf("commit %d: %w", i+1, err)
+		}
+		groups[i].Message = header
+	}
+
+	out := cmd.OutOrStdout()
+	for i, g := range groups {
+		fmt.Fprintf(out, "Commit %d: %s\n", i+1, g.Message)
+		for _, f := range g.files() {
+			fmt.Fprintf(out, "  %s\n", f)
+		}
+	}
+
+	if !assumeYes {
+		if !deps.Files.IsInteractive() {
+			deps.Logger.Info(cmd, "Plan printed but not applied. Re-run with --yes to commit it.")
+			return nil
+		}
+		fmt.Fprint(out, "Apply this plan? [y/N] ")
+		answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") && !strings.EqualFold(strings.TrimSpace(answer), "yes") {
+			deps.Logger.Info(cmd, "Plan declined. Your index remains exactly as chaotic as you left it.")
+			return nil
+		}
+	}
+
+	deps.Logger.Info(cmd, "Applying %d commits in sequence. Do not touch the index.", len(groups))
+	if err := applySplitPlan(deps.Git, groups); err != nil {
+		return err
+	}
+	deps.Logger.Info(cmd, "Split complete. %d commits, each more coherent than the last.", len(groups))
+	return nil
+}
+
+// applySplitPlan commits each group in order on top of HEAD. If any step fails the
+// commits made so far are undone and the original index is restored.
+func applySplitPlan(g git.Client, groups []commitGroup) (err error) {
+	head, err := g.RevParse("HEAD")
+	if err != nil {
+		return fmt.Errorf("--split needs an existing HEAD commit: %w", err)
+	}
+	staged, err := g.WriteTree()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+		if rerr := g.ResetSoft(head); rerr != nil {
+			err = fmt.Errorf("%w; additionally failed to reset to %s: %v", err, head, rerr)
+			return
+		}
+		if rerr := g.ReadTree(staged); rerr != nil {
+			err = fmt.Errorf("%w; additionally failed to restore index tree %s: %v", err, staged, rerr)
+		}
+	}()
+
+	if err = g.ReadTree(head); err != nil {
+		return err
+	}
+	for i, group := range groups {
+		if err = g.ApplyCached(group.patch()); err != nil {
+			return fmt.Errorf("apply commit %d (%s): %w", i+1, group.Message, err)
+		}
+		if err = g.Commit(group.Message); err != nil {
+			return fmt.Errorf("commit %d (%s): %w", i+1, group.Message, err)
+		}
+	}
+
+	final, err := g.WriteTree()
+	if err != nil {
+		return err
+	}
+	if final != staged {
+		err = errors.New("split commits do not reproduce the staged tree; rolled back")
+		return err
+	}
+	return nil
+}
+
+// splitStagedDiff breaks `git diff --staged` output into hunk-sized units.
+func splitStagedDiff(diff string) ([]splitUnit, error) {
+	files, err := unidiff.Parse(diff)
+	if err != nil {
+		return nil, err
+	}
+
+	var units []splitUnit
+	for _, f := range files {
+		if !f.Splittable() || len(f.Hunks) == 0 {
+			units = append(units, splitUnit{ID: len(units) + 1, Path: f.Path(), Header: f.Header, Body: strings.TrimPrefix(f.String(), f.Header)})
+			continue
+		}
+		for _, h := range f.Hunks {
+			units = append(units, splitUnit{ID: len(units) + 1, Path: f.Path(), Header: f.Header, Body: h.String()})
+		}
+	}
+	return units, nil
+}
+
+// groupUnitsByPackage clusters units by directory, which is the Go package for .go files.
+func groupUnitsByPackage(units []splitUnit) []commitGroup {
+	index := map[string]int{}
+	var groups []commitGroup
+	for _, u := range units {
+		pkg := path.Dir(u.Path)
+		i, ok := index[pkg]
+		if !ok {
+			i = len(groups)
+			index[pkg] = i
+			groups = append(groups, commitGroup{})
+		}
+		groups[i].Units = append(groups[i].Units, u)
+	}
+	return groups
+}
+
+func buildSplitPrompt(units []splitUnit, clusters []commitGroup) string {
+	const maxHunkLines = 40
+
+	var b strings.Builder
+	b.WriteString(`Group the staged hunks below into logical commits, one concern per commit.
+Hunks are pre-clustered by package; merge or split clusters when the intent differs.
+Return one line per commit formatted exactly as:
+<type(scope)?: summary> | <hunk ids separated by spaces>
+Example: fix(parser): handle empty input | H1 H4
+Every hunk id must appear exactly once. Do not add explanations, bullets or code fences.
+
+Package clusters:
+`)
+	for _, c := range clusters {
+		ids := make([]string, 0, len(c.Units))
+		for _, u := range c.Units {
+			ids = append(ids, "H"+strconv.Itoa(u.ID))
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", path.Dir(c.Units[0].Path), strings.Join(ids, " "))
+	}
+	b.WriteString("\nHunks:\n")
+	for _, u := range units {
+		fmt.Fprintf(&b, "\nH%d %s\n", u.ID, u.Path)
+		lines := strings.Split(strings.TrimRight(u.Body, "\n"), "\n")
+		if u.Body == "" {
+			lines = []string{"(file-level change without textual hunks)"}
+		}
+		if len(lines) > maxHunkLines {
+			lines = append(lines[:maxHunkLines], "... (truncated)")
+		}
+		b.WriteString(strings.ReplaceAll(strings.Join(lines, "\n"), "```", "`​``"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+var hunkIDPattern = regexp.MustCompile(`\bH(\d+)\b`)
+
+// parseSplitPlan reads "<header> | H1 H2" lines. Unknown or duplicate ids are ignored and
+// any hunk the model forgot is placed in a per-package group without a message.
+func parseSplitPlan(answer string, units []splitUnit) []commitGroup {
+	byID := make(map[int]splitUnit, len(units))
+	for _, u := range units {
+		byID[u.ID] = u
+	}
+
+	used := map[int]bool{}
+	var groups []commitGroup
+	for _, line := range strings.Split(answer, "\n") {
+		msg, ids, ok := strings.Cut(line, "|")
+		if !ok {
+			continue
+		}
+		msg = strings.TrimSpace(strings.Trim(strings.TrimSpace(msg), "-*`\""))
+		msg = strings.TrimLeft(msg, "0123456789. ")
+
+		var group commitGroup
+		for _, m := range hunkIDPattern.FindAllStringSubmatch(ids, -1) {
+			id, _ := strconv.Atoi(m[1])
+			u, known := byID[id]
+			if !known || used[id] {
+				continue
+			}
+			used[id] = true
+			group.Units = append(group.Units, u)
+		}
+		if len(group.Units) == 0 {
+			continue
+		}
+		group.Message = msg
+		groups = append(groups, group)
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	var leftover []splitUnit
+	for _, u := range units {
+		if !used[u.ID] {
+			leftover = append(leftover, u)
+		}
+	}
+	return append(groups, groupUnitsByPackage(leftover)...)
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/git"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+const splitFixture = `diff --git a/internal/git/client.go b/internal/git/client.go
+index 1111111..2222222 100644
+--- a/internal/git/client.go
++++ b/internal/git/client.go
+@@ -1,3 +1,4 @@
+ package git
++// one
+
+ import (
+@@ -20,2 +21,3 @@ func x() {
+ 	a()
++	b()
+ }
+diff --git a/README.md b/README.md
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/README.md
+@@ -0,0 +1,2 @@
++# title
++body
+`
+
+func TestSplitStagedDiff(t *testing.T) {
+	units, err := splitStagedDiff(splitFixture)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	if len(units) != 3 {
+		t.Fatalf("expected 3 units, got %d", len(units))
+	}
+	if units[0].Path != "internal/git/client.go" || units[1].Path != "internal/git/client.go" {
+		t.Fatalf("unexpected paths: %q %q", units[0].Path, units[1].Path)
+	}
+	if !strings.HasPrefix(units[1].Body, "@@ -20,2 +21,3 @@") {
+		t.Fatalf("expected second hunk body, got %q", units[1].Body)
+	}
+	if units[2].Path != "README.md" || !strings.Contains(units[2].Header, "new file mode") {
+		t.Fatalf("expected new file to stay atomic, got %#v", units[2])
+	}
+
+	group := commitGroup{Units: []splitUnit{units[1], units[0]}}
+	patch := group.patch()
+	if strings.Count(patch, "diff --git") != 1 {
+		t.Fatalf("expected one file header in patch, got %q", patch)
+	}
+	if strings.Index(patch, "@@ -1,3") > strings.Index(patch, "@@ -20,2") {
+		t.Fatalf("expected hunks in original order, got %q", patch)
+	}
+}
+
+func TestParseSplitPlan(t *testing.T) {
+	units, _ := splitStagedDiff(splitFixture)
+	answer := "Here is the plan:\n1. feat(git): add comment | H1 H9\n- docs: add readme | H3 H1\n"
+	groups := parseSplitPlan(answer, units)
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups (2 planned + 1 leftover), got %d", len(groups))
+	}
+	if groups[0].Message != "feat(git): add comment" || len(groups[0].Units) != 1 {
+		t.Fatalf("unexpected first group: %#v", groups[0])
+	}
+	if groups[1].Message != "docs: add readme" || len(groups[1].Units) != 1 || groups[1].Units[0].ID != 3 {
+		t.Fatalf("unexpected second group: %#v", groups[1])
+	}
+	if groups[2].Message != "" || groups[2].Units[0].ID != 2 {
+		t.Fatalf("expected leftover hunk in unnamed group, got %#v", groups[2])
+	}
+
+	if parseSplitPlan("no plan here", units) != nil {
+		t.Fatalf("expected nil plan for unusable answer")
+	}
+}
+
+func TestApplySplitPlanRollsBackOnFailure(t *testing.T) {
+	units, _ := splitStagedDiff(splitFixture)
+	fake := git.NewFakeClient()
+	fake.Refs["HEAD"] = "base"
+	fake.Tree = "staged-tree"
+	fake.ApplyErr["README.md"] = errors.New("patch does not apply")
+
+	groups := []commitGroup{
+		{Message: "feat(git): add comment", Units: units[:2]},
+		{Message: "docs: add readme", Units: units[2:]},
+	}
+	if err := applySplitPlan(fake, groups); err == nil {
+		t.Fatalf("expected apply failure")
+	}
+	if len(fake.Committed) != 1 {
+		t.Fatalf("expected first commit before failure, got %v", fake.Committed)
+	}
+	if fake.Refs["HEAD"] != "base" || fake.Tree != "staged-tree" {
+		t.Fatalf("expected HEAD and index restored, got HEAD=%s tree=%s", fake.Refs["HEAD"], fake.Tree)
+	}
+}
+
+func TestSplitCommitBinaryFile(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	gitRun := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	gitRun("init", "-q", "-b", "main")
+	gitRun("config", "user.email", "dev@example.com")
+	gitRun("config", "user.name", "Dev")
+	if err := os.WriteFile("main.go", []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun("add", ".")
+	gitRun("commit", "-q", "-m", "init")
+	logo := []byte{0x89, 'P', 'N', 'G', 0, 1, 2, 3, 0, 0xff}
+	if err := os.Mkdir("assets", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("assets", "logo.png"), logo, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("main.go", []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun("add", ".")
+
+	llm := &scriptedLLM{answers: []string{"feat: add main | H1\nchore(assets): add logo | H2\n"}}
+	cmd := NewCommitCommand(Dependencies{
+		Config: &config.Config{MaxSummaryLen: 72},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Git:    git.CLIClient{},
+		Logger: logging.NewSheldonLogger(),
+	})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--split", "--yes"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("split with a binary file: %v", err)
+	}
+	if log := gitRun("log", "--format=%s"); log != "chore(assets): add logo\nfeat: add main\ninit\n" {
+		t.Fatalf("unexpected history:\n%s", log)
+	}
+	if got := gitRun("show", "HEAD:assets/logo.png"); got != string(logo) {
+		t.Fatalf("binary file committed as %q", got)
+	}
+}
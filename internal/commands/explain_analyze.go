@@ -3,34 +3,67 @@ package commands
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/riskiramdan/ShELDon/internal/sqlplan"
 	"github.com/riskiramdan/ShELDon/internal/textutil"
 )
 
-// NewExplainAnalyzeCommand explains PostgreSQL EXPLAIN ANALYZE output.
+// NewExplainAnalyzeCommand explains PostgreSQL, MySQL or SQLite EXPLAIN output.
 func NewExplainAnalyzeCommand(deps Dependencies) *cobra.Command {
 	var (
-		path  string
-		model string
+		path    string
+		before  string
+		after   string
+		dialect string
+		top     int
+		model   string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "explain-analyze",
-		Short: "Explain a PostgreSQL EXPLAIN ANALYZE plan and suggest indexes/rewrite",
+		Short: "Explain an EXPLAIN ANALYZE plan (PostgreSQL, MySQL, SQLite) and suggest indexes/rewrite",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			d, err := sqlplan.ParseDialect(dialect)
+			if err != nil {
+				return err
+			}
+			if before != "" || after != "" {
+				if before == "" || after == "" {
+					return errors.New("--before and --after go together")
+				}
+				return explainPlanChange(cmd, deps, d, before, after, top, model)
+			}
 			if (path == "" || path == "-") && deps.Files.IsInteractive() {
 				return errors.New("no plan provided; pipe EXPLAIN ANALYZE output or use --in <file>")
 			}
 			deps.Logger.Info(cmd, "Acquiring EXPLAIN ANALYZE output from %s. I hope it brought a bibliography.", path)
-			plan, err := deps.Files.Read(path)
+			text, err := deps.Files.Read(path)
 			if err != nil {
 				return err
 			}
-			deps.Logger.Info(cmd, "Digesting a modest %d bytes of planner musings.", len(plan))
+			deps.Logger.Info(cmd, "Digesting a modest %d bytes of planner musings.", len(text))
+
+			var prompt string
+			plan, err := sqlplan.Parse(d, text)
+			if err != nil {
+				deps.Logger.Info(cmd, "Could not parse the plan (%v). Handing the raw text to the model, as in the dark ages.", err)
+				prompt = "Explain the " + d.Name() + " EXPLAIN output below. Give: 1) bottlenecks, 2) missing/misused indexes, 3) rewrite suggestion.\n" + sqlDialectAdvice[d].Indexes + "\n\n" + text
+			} else {
+				hotspots := plan.Hotspots()
+				if top > 0 && len(hotspots) > top {
+					hotspots = hotspots[:top]
+				}
+				writePlanHotspots(cmd.OutOrStdout(), plan, hotspots)
+				prompt = planHotspotPrompt(d, plan, hotspots)
+			}
 
-			prompt := "Explain the PostgreSQL EXPLAIN ANALYZE below. Give: 1) bottlenecks, 2) missing/misused indexes, 3) rewrite suggestion.\n\n" + plan
 			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
 			defer cancel()
 
@@ -41,7 +74,7 @@ func NewExplainAnalyzeCommand(deps Dependencies) *cobra.Command {
 				return err
 			}
 
-			_, err = cmd.OutOrStdout().Write([]byte(ans))
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "\n%s\n", strings.TrimSpace(ans))
 			if err == nil {
 				deps.Logger.Info(cmd, "Diagnosis rendered. If databases could blush, this one just did.")
 			}
@@ -49,7 +82,108 @@ func NewExplainAnalyzeCommand(deps Dependencies) *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVar(&path, "in", "-", "Path to plan file or '-' for stdin")
+	cmd.Flags().StringVar(&path, "in", "-", "Path to plan file (text or JSON) or '-' for stdin")
+	cmd.Flags().StringVar(&dialect, "dialect", "postgres", "Database engine of the plan: postgres, mysql or sqlite")
+	cmd.Flags().StringVar(&before, "before", "", "Plan from before a change; compare it with --after instead of reading --in")
+	cmd.Flags().StringVar(&after, "after", "", "Plan from after the change")
+	cmd.Flags().IntVar(&top, "top", 5, "Hotspot or changed nodes to list and send to the model (0 for all)")
 	cmd.Flags().StringVar(&model, "model", "", "Override model")
 	return cmd
 }
+
+// writePlanHotspots prints the plan totals and one row per hotspot with its notes
+// underneath; the output depends only on the plan.
+func writePlanHotspots(w io.Writer, plan *sqlplan.Plan, hotspots []sqlplan.Hotspot) {
+	switch {
+	case plan.Analyzed():
+		fmt.Fprintf(w, "Execution %.3f ms, planning %.3f ms, %d nodes.\n\n", plan.ExecutionTime, plan.PlanningTime, len(plan.Nodes()))
+		fmt.Fprintf(w, "%-4s  %10s  %6s  %15s  %7s  %s\n", "RANK", "SELF MS", "SHARE", "ROWS ACT/EST", "LOOPS", "NODE")
+	case !plan.Costed():
+		fmt.Fprintf(w, "No cost or timing figures; listing %d nodes with the most problems first.\n\n", len(plan.Nodes()))
+		fmt.Fprintf(w, "%-4s  %s\n", "RANK", "NODE")
+		for i, h := range hotspots {
+			fmt.Fprintf(w, "%-4d  %s (#%d)\n", i+1, h.Node.Label(), h.Node.ID)
+			for _, note := range h.Notes {
+				fmt.Fprintf(w, "%-4s  - %s\n", "", note)
+			}
+		}
+		return
+	default:
+		fmt.Fprintf(w, "No ANALYZE figures; ranking %d nodes by the planner's exclusive cost.\n\n", len(plan.Nodes()))
+		fmt.Fprintf(w, "%-4s  %10s  %6s  %15s  %7s  %s\n", "RANK", "SELF COST", "SHARE", "ROWS EST", "LOOPS", "NODE")
+	}
+	for i, h := range hotspots {
+		n := h.Node
+		rows := fmt.Sprintf("%.0f", n.PlanRows)
+		loops := "-"
+		if n.Analyzed {
+			rows = fmt.Sprintf("%.0f/%.0f", n.ActualRows, n.PlanRows)
+			loops = fmt.Sprintf("%.0f", n.Loops)
+		}
+		fmt.Fprintf(w, "%-4d  %10.3f  %5.1f%%  %15s  %7s  %s (#%d)\n", i+1, h.Self, h.Share*100, rows, loops, n.Label(), n.ID)
+		for _, note := range h.Notes {
+			fmt.Fprintf(w, "%-4s  - %s\n", "", note)
+		}
+	}
+}
+
+// planHotspotPrompt describes only the hotspot nodes: where they sit in the plan,
+// their conditions and the problems found on them.
+func planHotspotPrompt(d sqlplan.Dialect, plan *sqlplan.Plan, hotspots []sqlplan.Hotspot) string {
+	advice := sqlDialectAdvice[d]
+	var b strings.Builder
+	fmt.Fprintf(&b, "These are the most expensive nodes of a %s execution plan, already measured; do not recompute the figures.\n", d.Name())
+	fmt.Fprintf(&b, "For each node explain in one or two sentences why it is slow, then give: 1) index changes, 2) query rewrites, 3) settings or statistics fixes (%s). Skip a section when nothing applies.\n", advice.Tuning)
+	b.WriteString(advice.Indexes + "\n")
+	if plan.Analyzed() {
+		fmt.Fprintf(&b, "\nExecution time %.3f ms, planning time %.3f ms.\n", plan.ExecutionTime, plan.PlanningTime)
+	}
+	for _, h := range hotspots {
+		n := h.Node
+		switch {
+		case plan.Analyzed():
+			fmt.Fprintf(&b, "\nNode #%d: %s, %.3f ms exclusive (%.0f%% of the plan)\n", n.ID, n.Label(), h.Self, h.Share*100)
+		case !plan.Costed():
+			fmt.Fprintf(&b, "\nNode #%d: %s\n", n.ID, n.Label())
+		default:
+			fmt.Fprintf(&b, "\nNode #%d: %s, exclusive cost %.2f (%.0f%% of the plan)\n", n.ID, n.Label(), h.Self, h.Share*100)
+		}
+		writePlanNode(&b, n, h.Notes)
+	}
+	return b.String()
+}
+
+// writePlanNode renders a node's rows, position, conditions and notes for a prompt.
+func writePlanNode(w io.Writer, n *sqlplan.Node, notes []string) {
+	switch {
+	case n.Analyzed:
+		fmt.Fprintf(w, "Rows: %.0f actual per loop over %.0f loops, %.0f estimated\n", n.ActualRows, n.Loops, n.PlanRows)
+	case n.PlanRows > 0:
+		fmt.Fprintf(w, "Rows: %.0f estimated\n", n.PlanRows)
+	}
+	var path []string
+	for p := n.Parent; p != nil; p = p.Parent {
+		path = append([]string{p.Label()}, path...)
+	}
+	if len(path) > 0 {
+		fmt.Fprintf(w, "Under: %s\n", strings.Join(path, " > "))
+	}
+	if len(n.Children) > 0 {
+		var children []string
+		for _, c := range n.Children {
+			children = append(children, c.Label())
+		}
+		fmt.Fprintf(w, "Inputs: %s\n", strings.Join(children, "; "))
+	}
+	keys := make([]string, 0, len(n.Details))
+	for k := range n.Details {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %s\n", k, n.Details[k])
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(w, "Problems: %s\n", strings.Join(notes, "; "))
+	}
+}
@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlplan"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// explainPlanChange aligns two plans of the same query, prints the node-level
+// differences and asks the model whether the change really helped.
+func explainPlanChange(cmd *cobra.Command, deps Dependencies, d sqlplan.Dialect, beforePath, afterPath string, top int, model string) error {
+	var plans [2]*sqlplan.Plan
+	for i, path := range []string{beforePath, afterPath} {
+		text, err := deps.Files.Read(path)
+		if err != nil {
+			return err
+		}
+		plan, err := sqlplan.Parse(d, text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		plans[i] = plan
+	}
+	cmp := sqlplan.Compare(plans[0], plans[1])
+	deps.Logger.Info(cmd, "Aligned %d nodes before and %d after. Let us see whether your index earned its disk space.", len(plans[0].Nodes()), len(plans[1].Nodes()))
+
+	changes := cmp.Changes
+	if top > 0 && len(changes) > top {
+		changes = changes[:top]
+	}
+	findings := cmp.Findings()
+	writePlanChanges(cmd.OutOrStdout(), cmp, findings, changes)
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+	defer cancel()
+	modelUse := textutil.Choose(model, deps.Config.ModelGeneral)
+	deps.Logger.Info(cmd, "Deploying model %s to adjudicate before versus after.", modelUse)
+	ans, err := deps.LLM.Generate(ctx, modelUse, planChangePrompt(d, cmp, findings, changes))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "\n%s\n", strings.TrimSpace(ans))
+	return err
+}
+
+// writePlanChanges prints the findings and one row per changed node; the output
+// depends only on the two plans.
+func writePlanChanges(w io.Writer, cmp *sqlplan.Comparison, findings []string, changes []sqlplan.Change) {
+	for _, f := range findings {
+		fmt.Fprintf(w, "- %s\n", f)
+	}
+	unit := "MS"
+	if !cmp.Analyzed {
+		unit = "COST"
+	}
+	fmt.Fprintf(w, "\n%-8s  %11s  %11s  %10s  %17s  %s\n", "CHANGE", "BEFORE "+unit, "AFTER "+unit, "DELTA", "ROWS BEFORE→AFTER", "NODE")
+	for _, ch := range changes {
+		beforeSelf, afterSelf := "-", "-"
+		if ch.Before != nil {
+			beforeSelf = fmt.Sprintf("%.3f", ch.BeforeSelf)
+		}
+		if ch.After != nil {
+			afterSelf = fmt.Sprintf("%.3f", ch.AfterSelf)
+		}
+		rows := planRows(ch.Before) + "→" + planRows(ch.After)
+		fmt.Fprintf(w, "%-8s  %11s  %11s  %+10.3f  %17s  %s\n", ch.Kind(), beforeSelf, afterSelf, ch.Delta(), rows, ch.Label())
+	}
+}
+
+// planRows is the actual rows per loop, or the estimate without ANALYZE.
+func planRows(n *sqlplan.Node) string {
+	switch {
+	case n == nil:
+		return "-"
+	case n.Analyzed:
+		return fmt.Sprintf("%.0f", n.ActualRows)
+	}
+	return fmt.Sprintf("%.0f", n.PlanRows)
+}
+
+func planChangePrompt(d sqlplan.Dialect, cmp *sqlplan.Comparison, findings []string, changes []sqlplan.Change) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Two %s execution plans of the same query were captured before and after a change (typically a new index). The differences below are already measured; do not recompute them.\n", d.Name())
+	b.WriteString("Say whether the change is a genuine improvement, a regression, or a caching artefact (warm buffers, different data or parameters), citing the figures. Then say what to verify next: e.g. rerun both warm, compare BUFFERS, check the index is used for other parameter values.\n")
+	b.WriteString("\nFindings:\n")
+	for _, f := range findings {
+		fmt.Fprintf(&b, "- %s\n", f)
+	}
+	for _, ch := range changes {
+		fmt.Fprintf(&b, "\n%s (%s, %+.3f", ch.Label(), ch.Kind(), ch.Delta())
+		if cmp.Analyzed {
+			b.WriteString(" ms exclusive)\n")
+		} else {
+			b.WriteString(" exclusive cost)\n")
+		}
+		if ch.Before != nil {
+			fmt.Fprintf(&b, "Before: %s\n", ch.Before.Label())
+			writePlanNode(&b, ch.Before, sqlplan.Notes(ch.Before))
+		}
+		if ch.After != nil {
+			fmt.Fprintf(&b, "After: %s\n", ch.After.Label())
+			writePlanNode(&b, ch.After, sqlplan.Notes(ch.After))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+const analyzedPlan = `Limit  (cost=25000.00..25000.03 rows=10 width=44) (actual time=812.300..812.310 rows=10 loops=1)
+  ->  Sort  (cost=25000.00..25250.00 rows=100 width=44) (actual time=812.290..812.295 rows=10 loops=1)
+        Sort Key: (count(*)) DESC
+        Sort Method: top-N heapsort  Memory: 26kB
+        ->  HashAggregate  (cost=22000.00..23000.00 rows=100 width=44) (actual time=700.100..790.500 rows=50000 loops=1)
+              Group Key: c.name
+              Batches: 5  Memory Usage: 4145kB  Disk Usage: 16824kB
+              ->  Hash Join  (cost=30.50..20000.00 rows=100 width=36) (actual time=1.200..600.000 rows=120000 loops=1)
+                    Hash Cond: (o.customer_id = c.id)
+                    ->  Seq Scan on orders o  (cost=0.00..19000.00 rows=100 width=8) (actual time=0.020..520.000 rows=120000 loops=1)
+                          Filter: (status = 'paid'::text)
+                          Rows Removed by Filter: 2880000
+                    ->  Hash  (cost=18.00..18.00 rows=1000 width=36) (actual time=1.100..1.100 rows=1000 loops=1)
+                          Buckets: 1024  Batches: 1  Memory Usage: 72kB
+                          ->  Seq Scan on customers c  (cost=0.00..18.00 rows=1000 width=36) (actual time=0.010..0.500 rows=1000 loops=1)
+Planning Time: 0.250 ms
+Execution Time: 815.000 ms
+`
+
+func runExplainAnalyze(t *testing.T, plan string, args ...string) (string, *scriptedLLM) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plan.txt")
+	if err := os.WriteFile(path, []byte(plan), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	llm := &scriptedLLM{answers: []string{"Index orders(status)."}}
+	cmd := NewExplainAnalyzeCommand(Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Logger: logging.NewSheldonLogger(),
+	})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(append([]string{"--in", path}, args...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("explain-analyze: %v", err)
+	}
+	if len(llm.prompts) != 1 {
+		t.Fatalf("expected one prompt, got %d", len(llm.prompts))
+	}
+	return out.String(), llm
+}
+
+func TestExplainAnalyzeHotspots(t *testing.T) {
+	got, llm := runExplainAnalyze(t, analyzedPlan, "--top", "2")
+
+	for _, want := range []string{
+		"Execution 815.000 ms, planning 0.250 ms, 7 nodes.",
+		"1        520.000   64.0%       120000/100        1  Seq Scan on orders o (#5)",
+		"      - rows under-estimated 1200x (100 est, 120k actual)",
+		"2        190.500   23.5%        50000/100        1  HashAggregate (#3)",
+		"      - hash spilled in 5 batches",
+		"Index orders(status).",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Hash Join (#4)") {
+		t.Fatalf("--top 2 should list two nodes:\n%s", got)
+	}
+
+	prompt := llm.prompts[0]
+	for _, want := range []string{
+		"Node #5: Seq Scan on orders o, 520.000 ms exclusive (64% of the plan)",
+		"Under: Limit > Sort > HashAggregate > Hash Join",
+		"Filter: (status = 'paid'::text)",
+		"Problems: rows under-estimated 1200x (100 est, 120k actual); filter discards 2.9M rows per loop to keep 120k",
+		"Group Key: c.name",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected %q in prompt:\n%s", want, prompt)
+		}
+	}
+	for _, unwanted := range []string{"customers", "Hash Cond", "Sort Key"} {
+		if strings.Contains(prompt, unwanted) {
+			t.Fatalf("prompt should carry only the top nodes, found %q:\n%s", unwanted, prompt)
+		}
+	}
+}
+
+func TestExplainAnalyzeUnparsedPlan(t *testing.T) {
+	got, llm := runExplainAnalyze(t, "something the planner never said\n")
+	if strings.Contains(got, "RANK") {
+		t.Fatalf("no table expected for an unparsed plan:\n%s", got)
+	}
+	if !strings.Contains(llm.prompts[0], "something the planner never said") {
+		t.Fatalf("expected the raw text in the prompt:\n%s", llm.prompts[0])
+	}
+}
+
+func TestExplainAnalyzeCompare(t *testing.T) {
+	dir := t.TempDir()
+	after := strings.Replace(analyzedPlan,
+		"Seq Scan on orders o  (cost=0.00..19000.00 rows=100 width=8) (actual time=0.020..520.000",
+		"Index Scan using orders_status_idx on orders o  (cost=0.43..900.00 rows=100 width=8) (actual time=0.020..20.000", 1)
+	for name, plan := range map[string]string{"before.txt": analyzedPlan, "after.txt": after} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(plan), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	llm := &scriptedLLM{answers: []string{"A genuine improvement."}}
+	cmd := NewExplainAnalyzeCommand(Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Logger: logging.NewSheldonLogger(),
+	})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--before", filepath.Join(dir, "before.txt"), "--after", filepath.Join(dir, "after.txt"), "--top", "0"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("explain-analyze: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"- 1 relation(s) read with a different access method",
+		"access        520.000       20.000    -500.000      120000→120000  Seq Scan → Index Scan using orders_status_idx on orders o",
+		"A genuine improvement.",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output:\n%s", want, got)
+		}
+	}
+	if len(llm.prompts) != 1 {
+		t.Fatalf("expected one prompt, got %d", len(llm.prompts))
+	}
+	for _, want := range []string{"caching artefact", "Before: Seq Scan on orders o", "After: Index Scan using orders_status_idx on orders o"} {
+		if !strings.Contains(llm.prompts[0], want) {
+			t.Fatalf("expected %q in prompt:\n%s", want, llm.prompts[0])
+		}
+	}
+
+	cmd = NewExplainAnalyzeCommand(Dependencies{Config: &config.Config{}, LLM: llm, Files: system.NewOSFileManager(strings.NewReader("")), Logger: logging.NewSheldonLogger()})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--before", filepath.Join(dir, "before.txt")})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected --before without --after to fail")
+	}
+}
+
+func TestExplainAnalyzeSQLite(t *testing.T) {
+	plan := "QUERY PLAN\n|--SEARCH c USING INTEGER PRIMARY KEY (rowid=?)\n|--SCAN o\n`--USE TEMP B-TREE FOR ORDER BY\n"
+	got, llm := runExplainAnalyze(t, plan, "--dialect", "sqlite", "--top", "2")
+	for _, want := range []string{"No cost or timing figures; listing 4 nodes", "1     SCAN o (#3)\n      - full table scan", "2     USE TEMP B-TREE FOR ORDER BY (#4)"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output:\n%s", want, got)
+		}
+	}
+	prompt := llm.prompts[0]
+	for _, want := range []string{"SQLite execution plan", "holds the database write lock", "Node #3: SCAN o\nUnder: QUERY PLAN\nProblems: full table scan"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected %q in prompt:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "CONCURRENTLY") || strings.Contains(prompt, "SEARCH c") {
+		t.Fatalf("unexpected Postgres advice or non-top node in prompt:\n%s", prompt)
+	}
+}
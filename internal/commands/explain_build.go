@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/analysis"
+	"github.com/riskiramdan/ShELDon/internal/buildlog"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+	"github.com/riskiramdan/ShELDon/internal/unidiff"
+)
+
+// NewExplainBuildCommand explains go build and go vet errors grouped by root cause.
+func NewExplainBuildCommand(deps Dependencies) *cobra.Command {
+	var (
+		in        string
+		pkg       string
+		vet       bool
+		diff      bool
+		maxGroups int
+		model     string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "explain-build",
+		Short: "Group go build / go vet errors by root cause and propose the minimal edit",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			var output string
+			failed := true
+			if in != "" {
+				if in == "-" && deps.Files.IsInteractive() {
+					return errors.New("no build output provided; pipe go build output or omit --in to run it")
+				}
+				text, err := deps.Files.Read(in)
+				if err != nil {
+					return err
+				}
+				output = text
+			} else {
+				output, failed = runBuildChecks(cmd, deps, pkg, vet)
+			}
+
+			diags, err := buildlog.Parse(strings.NewReader(output))
+			if err != nil {
+				return err
+			}
+			if len(diags) == 0 {
+				if !failed {
+					fmt.Fprintln(cmd.OutOrStdout(), "Build and vet are clean.")
+					return nil
+				}
+				return fmt.Errorf("no file:line:col diagnostics found in the output:\n%s", strings.Join(tailLines(strings.Split(strings.TrimSpace(output), "\n"), 20), "\n"))
+			}
+			groups := buildlog.GroupByCause(diags)
+			deps.Logger.Info(cmd, "%d diagnostics, %d root causes. The compiler is merely the messenger.", len(diags), len(groups))
+
+			// With --diff stdout carries only the patch, so it can go straight to git apply.
+			report := cmd.OutOrStdout()
+			if diff {
+				report = cmd.ErrOrStderr()
+			}
+			writeBuildGroups(report, groups)
+
+			var mod *analysis.Module
+			if m, err := analysis.FindModule("."); err != nil {
+				deps.Logger.Info(cmd, "No module here (%v). Proceeding without declarations.", err)
+			} else {
+				mod = &m
+			}
+			modelUse := textutil.Choose(model, deps.Config.ModelCoder)
+			if maxGroups > 0 && len(groups) > maxGroups {
+				deps.Logger.Info(cmd, "Explaining the %d largest of %d root causes. Fix those and rebuild.", maxGroups, len(groups))
+				groups = groups[:maxGroups]
+			}
+			for i, g := range groups {
+				prompt := buildFixPrompt(deps, g, buildDeclarations(deps, mod, g), diff)
+				deps.Logger.Info(cmd, "Consulting model %s on %s (%d of %d).", modelUse, g.Cause.Title, i+1, len(groups))
+				ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+				ans, err := deps.LLM.Generate(ctx, modelUse, prompt)
+				cancel()
+				if err != nil {
+					return err
+				}
+				if !diff {
+					fmt.Fprintf(cmd.OutOrStdout(), "\n### Fix %d: %s\n\n%s\n", i+1, g.Cause.Title, strings.TrimSpace(ans))
+					continue
+				}
+				patch := textutil.NormalizeCode(ans)
+				if files, err := unidiff.Parse(patch); err != nil || len(files) == 0 {
+					deps.Logger.Info(cmd, "Model %s did not produce a usable diff for %s; printing its answer to stderr.", modelUse, g.Cause.Title)
+					fmt.Fprintf(cmd.ErrOrStderr(), "\n%s\n", strings.TrimSpace(ans))
+					continue
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), strings.TrimRight(patch, "\n"))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&in, "in", "", "Read build output from a file or '-' for stdin instead of running go build")
+	cmd.Flags().StringVar(&pkg, "pkg", "./...", "Packages to build and vet")
+	cmd.Flags().BoolVar(&vet, "vet", true, "Also run go vet")
+	cmd.Flags().BoolVar(&diff, "diff", false, "Ask for unified diffs and print only the patch on stdout")
+	cmd.Flags().IntVar(&maxGroups, "max-groups", 5, "Root causes to explain, largest first (0 for all)")
+	cmd.Flags().StringVar(&model, "model", "", "Override model (default SHELDON_MODEL_CODER)")
+	return cmd
+}
+
+// runBuildChecks runs go build and, when asked, go vet, and reports whether either
+// failed. Vet repeats the compiler's type errors; the parser drops the duplicates.
+func runBuildChecks(cmd *cobra.Command, deps Dependencies, pkg string, vet bool) (string, bool) {
+	deps.Logger.Info(cmd, "Running go build %s. Let us see what you broke.", pkg)
+	out, err := deps.Shell.Exec(".", "go", "build", pkg)
+	failed := err != nil
+	if vet {
+		deps.Logger.Info(cmd, "Running go vet %s, for thoroughness.", pkg)
+		vetOut, vetErr := deps.Shell.Exec(".", "go", "vet", pkg)
+		out += "\n" + vetOut
+		failed = failed || vetErr != nil
+	}
+	return out, failed
+}
+
+const (
+	maxGroupListed   = 10
+	maxGroupSites    = 3
+	buildContextSpan = 3
+	maxDeclarations  = 2
+)
+
+// writeBuildGroups prints the root causes and their diagnostics; the output depends
+// only on the build output.
+func writeBuildGroups(w io.Writer, groups []buildlog.Group) {
+	for i, g := range groups {
+		fmt.Fprintf(w, "## %d. %s (%d)\n", i+1, g.Cause.Title, len(g.Diagnostics))
+		for j, d := range g.Diagnostics {
+			if j == maxGroupListed {
+				fmt.Fprintf(w, "- ... and %d more\n", len(g.Diagnostics)-j)
+				break
+			}
+			fmt.Fprintf(w, "- %s: %s\n", d.Location(), d.Message)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// buildDeclarations finds the declarations a call-signature group refers to, with
+// their uncommitted changes when git has any.
+func buildDeclarations(deps Dependencies, mod *analysis.Module, g buildlog.Group) []string {
+	if mod == nil || g.Cause.Kind != "call" {
+		return nil
+	}
+	decls, err := mod.FindFunctions(g.Cause.Symbol)
+	if err != nil || len(decls) > maxDeclarations {
+		// Too many candidates to tell which one changed.
+		return nil
+	}
+	var out []string
+	for _, d := range decls {
+		text := fmt.Sprintf("Declaration of %s (%s:%d):\n%s", d.Selector, displayPath(d.File), d.Line, d.Source)
+		if deps.Git != nil {
+			if changes, err := deps.Git.Diff("HEAD", "--", displayPath(d.File)); err == nil && strings.TrimSpace(changes) != "" {
+				text += "\n\nUncommitted changes to " + displayPath(d.File) + ":\n" + strings.TrimSpace(changes)
+			}
+		}
+		out = append(out, text)
+	}
+	return out
+}
+
+// sourceContext numbers the lines around line and marks it with ">>".
+func sourceContext(src string, line int) string {
+	lines := strings.Split(src, "\n")
+	var b strings.Builder
+	for n := max(1, line-buildContextSpan); n <= min(len(lines), line+buildContextSpan); n++ {
+		marker := "  "
+		if n == line {
+			marker = ">>"
+		}
+		fmt.Fprintf(&b, "%s %4d  %s\n", marker, n, lines[n-1])
+	}
+	return b.String()
+}
+
+func buildFixPrompt(deps Dependencies, g buildlog.Group, declarations []string, diff bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "These %d Go build/vet errors share one root cause: %s.\n", len(g.Diagnostics), g.Cause.Title)
+	if g.Cause.Kind == "call" {
+		b.WriteString("Decide whether the declaration or the call sites are wrong; usually a recent signature change should be propagated to every caller.\n")
+	}
+	if diff {
+		b.WriteString("Respond with only a unified diff (--- a/path, +++ b/path, @@ hunks with 3 lines of context) using the paths shown, fixing every listed error with the smallest change. No prose.\n")
+	} else {
+		b.WriteString("Answer with the root cause in one sentence, then the minimal edit per file as short Go snippets. No refactoring beyond what the errors require.\n")
+	}
+
+	b.WriteString("\nErrors:\n")
+	for i, d := range g.Diagnostics {
+		if i == maxGroupListed {
+			fmt.Fprintf(&b, "... and %d more like these\n", len(g.Diagnostics)-i)
+			break
+		}
+		fmt.Fprintln(&b, d.String())
+	}
+
+	sites := 0
+	seen := map[string]bool{}
+	for _, d := range g.Diagnostics {
+		if sites == maxGroupSites {
+			break
+		}
+		key := fmt.Sprintf("%s:%d", d.File, d.Line)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		src, err := deps.Files.Read(d.File)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:\n%s", key, sourceContext(src, d.Line))
+		sites++
+	}
+	for _, decl := range declarations {
+		fmt.Fprintf(&b, "\n%s\n", decl)
+	}
+	return b.String()
+}
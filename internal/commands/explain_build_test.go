@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/git"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+func TestExplainBuildDiff(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/eb\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+import "context"
+
+type Store struct{ data map[string]int }
+
+// Get now takes a context.
+func (s *Store) Get(ctx context.Context, key string) (int, error) {
+	return s.data[key], nil
+}
+`,
+		"api/api.go": `package api
+
+import (
+	"fmt"
+
+	"example.com/eb/store"
+)
+
+func Handle(s *store.Store) int {
+	v := s.Get("a")
+	w, err := s.Get("b")
+	fmt.Println(w, err)
+	return v + Missing
+}
+
+func Other(s *store.Store) {
+	x := s.Get("c")
+}
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	var n int
+	fmt.Printf("%s\n", n)
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	patch := "--- a/api/api.go\n+++ b/api/api.go\n@@ -10,1 +10,1 @@\n-\tv := s.Get(\"a\")\n+\tv, _ := s.Get(context.TODO(), \"a\")\n"
+	llm := &scriptedLLM{answers: []string{"```diff\n" + patch + "```", "Define Missing."}}
+	gitClient := git.NewFakeClient()
+	gitClient.Diffs["HEAD -- store/store.go"] = "-func (s *Store) Get(key string) (int, error) {\n+func (s *Store) Get(ctx context.Context, key string) (int, error) {"
+	deps := Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Shell:  system.BashShell{},
+		Git:    gitClient,
+		Logger: logging.NewSheldonLogger(),
+	}
+	cmd := NewExplainBuildCommand(deps)
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"--diff", "--max-groups", "2"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	if stdout.String() != patch {
+		t.Fatalf("stdout should hold only the patch, got:\n%s", stdout.String())
+	}
+	for _, want := range []string{"## 1. calls that no longer match the signature of Get (5)", "- main.go:7:14: fmt.Printf format %s", "Define Missing."} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("expected %q on stderr:\n%s", want, stderr.String())
+		}
+	}
+	if len(llm.prompts) != 2 {
+		t.Fatalf("expected a prompt per explained group, got %d", len(llm.prompts))
+	}
+	for _, want := range []string{
+		"want (context.Context, string)",
+		">>   10  \tv := s.Get(\"a\")",
+		"Declaration of Store.Get (store/store.go:8)",
+		"+func (s *Store) Get(ctx context.Context, key string) (int, error) {",
+		"unified diff",
+	} {
+		if !strings.Contains(llm.prompts[0], want) {
+			t.Fatalf("expected %q in prompt:\n%s", want, llm.prompts[0])
+		}
+	}
+}
@@ -3,9 +3,12 @@ package commands
 import (
 	"context"
 	"errors"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/riskiramdan/ShELDon/internal/stackdump"
 	"github.com/riskiramdan/ShELDon/internal/textutil"
 )
 
@@ -30,7 +33,19 @@ func NewExplainLogsCommand(deps Dependencies) *cobra.Command {
 			}
 			deps.Logger.Info(cmd, "Ingested %d bytes of operational angst.", len(logs))
 
-			prompt := "You are an SRE. Diagnose cause and next steps from these logs. Return: Probable cause, Evidence lines, Next 3 commands to run.\n\n" + logs
+			prompt := "You are an SRE. Diagnose cause and next steps from these logs. Return: Probable cause, Evidence lines, Next 3 commands to run.\n\n"
+			if dump, err := stackdump.Parse(strings.NewReader(logs)); err == nil && len(dump.Goroutines) > 0 {
+				recordGOROOT(deps)
+				if dumpExplainsLogs(dump) {
+					deps.Logger.Info(cmd, "These logs end in a goroutine dump. Switching to stack analysis, as any sensible person would.")
+					return explainStackDump(cmd, deps, dump, textutil.Choose(model, deps.Config.ModelReason), defaultMinWaiters)
+				}
+				// The errors are elsewhere; the dump is evidence, summarised.
+				var summary strings.Builder
+				writeDumpSummary(&summary, dump, programGroups(dump), dump.Contentions(defaultMinWaiters))
+				logs = strings.Join(dump.Outside, "\n") + "\n\nThe logs also hold a goroutine dump, summarised:\n" + summary.String()
+			}
+			prompt += logs
 			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
 			defer cancel()
 
@@ -53,3 +68,21 @@ func NewExplainLogsCommand(deps Dependencies) *cobra.Command {
 	cmd.Flags().StringVar(&model, "model", "", "Override model")
 	return cmd
 }
+
+// logErrorLine matches log lines reporting an error.
+var logErrorLine = regexp.MustCompile(`(?i)\b(err|error|errors|fatal|exception|fail|failed|failure)\b`)
+
+// dumpExplainsLogs reports whether a goroutine dump is what the logs are about: the
+// program crashed with it, or no other line reports an error, as when a hung
+// process was sent SIGQUIT.
+func dumpExplainsLogs(dump *stackdump.Dump) bool {
+	if dump.Panicked() || dump.Deadlocked() {
+		return true
+	}
+	for _, line := range dump.Outside {
+		if logErrorLine.MatchString(line) {
+			return false
+		}
+	}
+	return true
+}
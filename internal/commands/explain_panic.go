@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/analysis"
+	"github.com/riskiramdan/ShELDon/internal/stackdump"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// NewExplainPanicCommand analyses panics and goroutine dumps.
+func NewExplainPanicCommand(deps Dependencies) *cobra.Command {
+	var (
+		path       string
+		model      string
+		minWaiters int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "explain-panic",
+		Short: "Explain a Go panic or goroutine dump (SIGQUIT, GOTRACEBACK=all) with the involved code",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if (path == "" || path == "-") && deps.Files.IsInteractive() {
+				return errors.New("no dump provided; use --in <file> or pipe the crash output")
+			}
+			text, err := deps.Files.Read(path)
+			if err != nil {
+				return err
+			}
+			dump, err := stackdump.Parse(strings.NewReader(text))
+			if err != nil {
+				return err
+			}
+			if len(dump.Goroutines) == 0 {
+				return errors.New("no goroutine stacks found; expected panic or SIGQUIT output")
+			}
+			recordGOROOT(deps)
+			return explainStackDump(cmd, deps, dump, textutil.Choose(model, deps.Config.ModelReason), minWaiters)
+		},
+	}
+
+	cmd.Flags().StringVar(&path, "in", "-", "Path to the crash output or '-' for stdin")
+	cmd.Flags().StringVar(&model, "model", "", "Override model (default SHELDON_MODEL_REASON)")
+	cmd.Flags().IntVar(&minWaiters, "min-waiters", defaultMinWaiters, "Goroutines blocked on the same mutex or channel to report a likely deadlock")
+	return cmd
+}
+
+const (
+	defaultMinWaiters = 3
+	maxDumpGroups     = 8
+	maxDumpFrames     = 8
+	maxDumpSources    = 5
+)
+
+// explainStackDump prints a deterministic summary of dump and asks model to
+// explain it with the module source of the involved frames inlined.
+func explainStackDump(cmd *cobra.Command, deps Dependencies, dump *stackdump.Dump, model string, minWaiters int) error {
+	groups := programGroups(dump)
+	contentions := dump.Contentions(minWaiters)
+	deps.Logger.Info(cmd, "%d goroutines, %d distinct stacks outside the runtime. Most of them are, predictably, waiting on each other.", len(dump.Goroutines), len(groups))
+
+	var summary strings.Builder
+	writeDumpSummary(&summary, dump, groups, contentions)
+	fmt.Fprintln(cmd.OutOrStdout(), summary.String())
+
+	var snippets []sourceSnippet
+	if mod, err := analysis.FindModule("."); err != nil {
+		deps.Logger.Info(cmd, "No module here (%v). Explaining from the stacks alone.", err)
+	} else {
+		snippets = dumpSnippets(deps, mod, dump, groups, contentions)
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(`You are debugging a Go program from its crash output. Answer with three sections:
+**What happened:** one or two sentences naming the failing goroutine or the blocked resource.
+**Why:** the root cause, citing file:line from the stacks and the source below; for blocked goroutines, name who holds the lock or should send/receive and why it never does.
+**Fix:** the smallest code change, as a short Go snippet.
+Base the answer on the evidence; if it is insufficient, say which goroutine or code to inspect next.
+
+`)
+	prompt.WriteString(summary.String())
+	prompt.WriteString("\nStacks (runtime frames omitted):\n")
+	for i, g := range groups {
+		if i == maxDumpGroups {
+			fmt.Fprintf(&prompt, "... %d more stacks\n", len(groups)-i)
+			break
+		}
+		writeGroupStack(&prompt, g)
+	}
+	writeSnippets(&prompt, snippets)
+
+	deps.Logger.Info(cmd, "Consulting model %s with %d stacks and %d functions of your code.", model, min(len(groups), maxDumpGroups), len(snippets))
+	ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+	defer cancel()
+	ans, err := deps.LLM.Generate(ctx, model, prompt.String())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), strings.TrimSpace(ans))
+	return nil
+}
+
+// programGroups returns the dump's distinct stacks outside the runtime.
+func programGroups(dump *stackdump.Dump) []*stackdump.Group {
+	var groups []*stackdump.Group
+	for _, g := range dump.Groups() {
+		if !g.Runtime() {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// writeDumpSummary prints the reason, one line per distinct stack and the likely
+// deadlocks; the output depends only on the dump.
+func writeDumpSummary(w io.Writer, dump *stackdump.Dump, groups []*stackdump.Group, contentions []stackdump.Contention) {
+	if len(dump.Reason) > 0 {
+		fmt.Fprintf(w, "%s\n\n", strings.Join(dump.Reason, "\n"))
+	}
+	fmt.Fprintf(w, "%-5s  %-20s  %-10s  %s\n", "COUNT", "STATE", "WAIT", "TOP FRAME")
+	for _, g := range groups {
+		top := g.Top()
+		fmt.Fprintf(w, "%5d  %-20s  %-10s  %s (%s)\n", len(g.Goroutines), g.State, g.MaxWait, top.Func, displayPath(top.Location()))
+	}
+	if dump.Deadlocked() {
+		fmt.Fprintln(w, "\nThe runtime detected a deadlock: every goroutine is blocked.")
+	}
+	for i, c := range contentions {
+		if i == 0 {
+			fmt.Fprintln(w, "\nLikely deadlocks or contention:")
+		}
+		fmt.Fprintf(w, "- %d goroutines in %s on %s at %s (%s), goroutines %s\n",
+			len(c.Goroutines), c.State, c.Resource, c.Site.Func, displayPath(c.Site.Location()), goroutineIDs(c.Goroutines))
+	}
+}
+
+func goroutineIDs(goroutines []*stackdump.Goroutine) string {
+	const maxIDs = 10
+	var ids []string
+	for i, g := range goroutines {
+		if i == maxIDs {
+			ids = append(ids, fmt.Sprintf("and %d more", len(goroutines)-i))
+			break
+		}
+		ids = append(ids, fmt.Sprint(g.ID))
+	}
+	return strings.Join(ids, ", ")
+}
+
+func writeGroupStack(w io.Writer, g *stackdump.Group) {
+	fmt.Fprintf(w, "\n%d goroutine(s) [%s", len(g.Goroutines), g.State)
+	if g.MaxWait != "" {
+		fmt.Fprintf(w, ", %s", g.MaxWait)
+	}
+	fmt.Fprintln(w, "]:")
+	frames := 0
+	for _, f := range g.Frames {
+		if f.Runtime() {
+			continue
+		}
+		if frames == maxDumpFrames {
+			fmt.Fprintln(w, "  ...")
+			break
+		}
+		fmt.Fprintf(w, "  %s\n      %s\n", f.Func, f.Location())
+		frames++
+	}
+	if g.CreatedBy != nil {
+		fmt.Fprintf(w, "  created by %s at %s\n", g.CreatedBy.Func, g.CreatedBy.Location())
+	}
+}
+
+// dumpSnippets extracts the module functions most likely to matter: the stack of
+// the goroutine that panicked, the sites where goroutines pile up, then the top
+// frame of every other stack.
+func dumpSnippets(deps Dependencies, mod analysis.Module, dump *stackdump.Dump, groups []*stackdump.Group, contentions []stackdump.Contention) []sourceSnippet {
+	var frames []stackdump.Frame
+	if dump.Panicked() {
+		frames = append(frames, dump.Goroutines[0].UserFrames()...)
+	}
+	for _, c := range contentions {
+		frames = append(frames, c.Site)
+	}
+	for _, g := range groups {
+		frames = append(frames, g.Top())
+	}
+
+	var snippets []sourceSnippet
+	seen := map[string]bool{}
+	for _, f := range frames {
+		if len(snippets) == maxDumpSources {
+			break
+		}
+		if f.Runtime() {
+			continue
+		}
+		path := mod.SourceFile(f.Package(), f.File)
+		if path == "" {
+			continue
+		}
+		src, err := deps.Files.Read(path)
+		if err != nil {
+			continue
+		}
+		snippet, ok := snippetAt(path, src, f.Line)
+		if !ok || seen[path+"#"+snippet.Function] {
+			continue
+		}
+		seen[path+"#"+snippet.Function] = true
+		snippets = append(snippets, snippet)
+	}
+	return snippets
+}
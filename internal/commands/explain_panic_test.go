@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+// mutexDump is a GOTRACEBACK=all panic of a binary built in /build/sd while four
+// goroutines wait on a mutex Incr never unlocked (trimmed to two of them).
+const mutexDump = `panic: stuck
+
+goroutine 1 [running]:
+main.main()
+	/build/sd/main.go:31 +0x1b8
+
+goroutine 6 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x1022131c070)
+	/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+	/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:46
+example.com/sd/store.(*Store).Get(0x0?, {0x48cce8, 0x1})
+	/build/sd/store/store.go:18 +0x5c
+main.main.func1()
+	/build/sd/main.go:20 +0x2c
+created by main.main in goroutine 1
+	/build/sd/main.go:19 +0xfb
+
+goroutine 7 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x1022131c070)
+	/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+	/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:46
+example.com/sd/store.(*Store).Get(0x0?, {0x48cce8, 0x1})
+	/build/sd/store/store.go:18 +0x5c
+main.main.func1()
+	/build/sd/main.go:20 +0x2c
+created by main.main in goroutine 1
+	/build/sd/main.go:19 +0xfb
+
+goroutine 10 [sleep]:
+time.Sleep(0x34630b8a000)
+	/usr/local/go/src/runtime/time.go:368 +0x165
+main.main.func2()
+	/build/sd/main.go:25 +0x1d
+created by main.main in goroutine 1
+	/build/sd/main.go:24 +0x16e
+`
+
+const mutexMain = `package main
+
+import (
+	"os"
+	"time"
+
+	"example.com/sd/store"
+)
+
+func main() {
+	s := store.New()
+	if len(os.Args) > 1 && os.Args[1] == "panic" {
+		var items []int
+		_ = items[len(os.Args)]
+	}
+	s.Incr("a")
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			s.Get("a")
+			done <- struct{}{}
+		}()
+	}
+	go func() {
+		time.Sleep(time.Hour)
+	}()
+	if len(os.Args) > 1 && os.Args[1] == "deadlock" {
+		<-done
+	}
+	time.Sleep(200 * time.Millisecond)
+	panic("stuck")
+}
+`
+
+func TestExplainPanic(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":         "module example.com/sd\n\ngo 1.21\n",
+		"store/store.go": "package store\n\nimport \"sync\"\n\ntype Store struct {\n\tmu   sync.Mutex\n\tdata map[string]int\n}\n\nfunc New() *Store { return &Store{data: map[string]int{}} }\n\nfunc (s *Store) Incr(key string) {\n\ts.mu.Lock()\n\ts.data[key]++\n}\n\nfunc (s *Store) Get(key string) int {\n\ts.mu.Lock()\n\tdefer s.mu.Unlock()\n\treturn s.data[key]\n}\n",
+		"main.go":        mutexMain,
+		"crash.log":      "level=info msg=started\n" + mutexDump,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	for _, command := range []string{"explain-panic", "explain-logs"} {
+		llm := &scriptedLLM{answers: []string{"**What happened:** Incr never unlocks."}}
+		deps := Dependencies{
+			Config: &config.Config{},
+			LLM:    llm,
+			Files:  system.NewOSFileManager(strings.NewReader("")),
+			Shell:  system.BashShell{},
+			Logger: logging.NewSheldonLogger(),
+		}
+		cmd := NewExplainPanicCommand(deps)
+		if command == "explain-logs" {
+			cmd = NewExplainLogsCommand(deps)
+		}
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs([]string{"--in", "crash.log"})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+
+		got := out.String()
+		for _, want := range []string{"panic: stuck", "    2  sync.Mutex.Lock", "example.com/sd/store.(*Store).Get (/build/sd/store/store.go:18)", "Incr never unlocks"} {
+			if !strings.Contains(got, want) {
+				t.Fatalf("%s: expected %q in output:\n%s", command, want, got)
+			}
+		}
+		if strings.Contains(got, "Likely deadlocks") {
+			t.Fatalf("%s: two waiters are below the default threshold:\n%s", command, got)
+		}
+		if len(llm.prompts) != 1 {
+			t.Fatalf("%s: expected one prompt, got %d", command, len(llm.prompts))
+		}
+		prompt := llm.prompts[0]
+		for _, want := range []string{"Source of Store.Get (store/store.go:18), referenced line: s.mu.Lock()", "Source of main (main.go:31), referenced line: panic(\"stuck\")", "created by main.main at /build/sd/main.go:19"} {
+			if !strings.Contains(prompt, want) {
+				t.Fatalf("%s: expected %q in prompt:\n%s", command, want, prompt)
+			}
+		}
+	}
+}
+
+func TestExplainLogsWithDump(t *testing.T) {
+	quit := strings.Replace(mutexDump, "panic: stuck", "SIGQUIT: quit", 1)
+	for _, tc := range []struct {
+		name, logs string
+		stacks     bool
+	}{
+		{"crash", "level=error msg=\"db: connection refused\"\n" + mutexDump, true},
+		{"hang", "level=info msg=started\n" + quit, true},
+		{"error elsewhere", "level=error msg=\"db: connection refused\"\n" + quit, false},
+	} {
+		path := filepath.Join(t.TempDir(), "app.log")
+		if err := os.WriteFile(path, []byte(tc.logs), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		llm := &scriptedLLM{answers: []string{"diagnosis"}}
+		cmd := NewExplainLogsCommand(Dependencies{
+			Config: &config.Config{},
+			LLM:    llm,
+			Files:  system.NewOSFileManager(strings.NewReader("")),
+			Shell:  system.BashShell{},
+			Logger: logging.NewSheldonLogger(),
+		})
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs([]string{"--in", path})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		prompt := llm.prompts[0]
+		if got := strings.Contains(prompt, "from its crash output"); got != tc.stacks {
+			t.Fatalf("%s: stack analysis %v, want %v:\n%s", tc.name, got, tc.stacks, prompt)
+		}
+		if !tc.stacks {
+			for _, want := range []string{"You are an SRE", "db: connection refused", "goroutine dump, summarised:\nSIGQUIT: quit", "sync.Mutex.Lock"} {
+				if !strings.Contains(prompt, want) {
+					t.Fatalf("%s: expected %q in prompt:\n%s", tc.name, want, prompt)
+				}
+			}
+			if strings.Contains(prompt, "\t/build/sd/main.go") {
+				t.Fatalf("%s: the raw dump should be summarised:\n%s", tc.name, prompt)
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/analysis"
+	"github.com/riskiramdan/ShELDon/internal/testjson"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// NewExplainTestFailureCommand diagnoses failing tests from `go test -json` output.
+func NewExplainTestFailureCommand(deps Dependencies) *cobra.Command {
+	var (
+		file        string
+		model       string
+		maxFailures int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "explain-test-failure",
+		Short: "Explain failing tests from go test -json output (file or stdin)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			text, err := deps.Files.Read(file)
+			if err != nil {
+				return err
+			}
+			report, err := testjson.Parse(strings.NewReader(text))
+			if err != nil {
+				return err
+			}
+			if len(report.Packages) == 0 {
+				return errors.New("no go test -json events found; run go test with -json")
+			}
+			failures := report.Failures()
+			deps.Logger.Info(cmd, "Parsed %d packages; %d failures. Let us assign blame scientifically.", len(report.Packages), len(failures))
+			if len(failures) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No failing tests.")
+				return nil
+			}
+			if maxFailures > 0 && len(failures) > maxFailures {
+				deps.Logger.Info(cmd, "Explaining the first %d of %d failures. The rest can wait their turn.", maxFailures, len(failures))
+				failures = failures[:maxFailures]
+			}
+
+			recordGOROOT(deps)
+			var importPaths []string
+			for _, f := range failures {
+				importPaths = append(importPaths, f.Package.Path)
+			}
+			dirs, err := analysis.PackageDirs(".", importPaths)
+			if err != nil {
+				deps.Logger.Info(cmd, "Cannot locate package sources (%v). Diagnosing from output alone.", err)
+			}
+
+			modelUse := textutil.Choose(model, deps.Config.ModelReason)
+			currentPkg := ""
+			for i, f := range failures {
+				if f.Package.Path != currentPkg {
+					currentPkg = f.Package.Path
+					fmt.Fprintf(cmd.OutOrStdout(), "## %s\n\n", currentPkg)
+				}
+				title := "(package failure)"
+				if f.Test != nil {
+					title = f.Test.Name
+				}
+				keyLines := testjson.KeyLines(f.Output())
+				if len(keyLines) == 0 {
+					keyLines = tailLines(f.Output(), 20)
+				}
+				snippets := failureSnippets(deps, dirs[f.Package.Path], f)
+				fmt.Fprintf(cmd.OutOrStdout(), "### %s\n\n```\n%s\n```\n\n", title, strings.Join(keyLines, "\n"))
+
+				deps.Logger.Info(cmd, "Consulting model %s on %s (%d of %d).", modelUse, f.Name(), i+1, len(failures))
+				ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+				ans, err := deps.LLM.Generate(ctx, modelUse, buildTestFailurePrompt(f, keyLines, snippets))
+				cancel()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\n\n", strings.TrimSpace(ans))
+			}
+			deps.Logger.Info(cmd, "Diagnosis complete. The fix, regrettably, still requires you.")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&file, "file", "-", "go test -json output file or '-' for stdin")
+	cmd.Flags().StringVar(&model, "model", "", "Override model (default SHELDON_MODEL_REASON)")
+	cmd.Flags().IntVar(&maxFailures, "max", 10, "Maximum failures to explain (0 for all)")
+	return cmd
+}
+
+// recordGOROOT asks the go command for its GOROOT so that frames from this
+// machine's standard library are told apart from the program's. Without a go
+// command only versioned installs are recognised.
+func recordGOROOT(deps Dependencies) {
+	if root, err := deps.Shell.Exec(".", "go", "env", "GOROOT"); err == nil {
+		testjson.SetGOROOT(root)
+	}
+}
+
+// sourceSnippet is a function involved in a failure.
+type sourceSnippet struct {
+	Location string
+	Function string
+	Line     string
+	Code     string
+}
+
+const maxFailureSnippets = 3
+
+// failureSnippets extracts the functions containing the first locations mentioned in
+// the failure output, plus the failing test itself when the output never points
+// into it (e.g. a panic deep in the code under test).
+func failureSnippets(deps Dependencies, pkgDir string, f testjson.Failure) []sourceSnippet {
+	var snippets []sourceSnippet
+	seen := map[string]bool{}
+	for _, loc := range testjson.Locations(f.Output()) {
+		if len(snippets) == maxFailureSnippets {
+			break
+		}
+		path, src := readFailureSource(deps, pkgDir, loc.File)
+		if src == "" {
+			continue
+		}
+		snippet, ok := snippetAt(path, src, loc.Line)
+		if !ok || seen[path+"#"+snippet.Function] {
+			continue
+		}
+		seen[path+"#"+snippet.Function] = true
+		snippets = append(snippets, snippet)
+	}
+
+	if f.Test == nil || pkgDir == "" {
+		return snippets
+	}
+	testName := strings.SplitN(f.Test.Name, "/", 2)[0]
+	for _, s := range snippets {
+		if s.Function == testName {
+			return snippets
+		}
+	}
+	testFiles, _ := filepath.Glob(filepath.Join(pkgDir, "*_test.go"))
+	for _, path := range testFiles {
+		src, err := deps.Files.Read(path)
+		if err != nil {
+			continue
+		}
+		if code := textutil.ExtractFunction(src, testName); code != "" {
+			return append(snippets, sourceSnippet{Location: displayPath(path), Function: testName, Code: code})
+		}
+	}
+	return snippets
+}
+
+// snippetAt extracts the function of src enclosing line.
+func snippetAt(path, src string, line int) (sourceSnippet, bool) {
+	fn := textutil.FunctionAt(src, line)
+	if fn == "" {
+		return sourceSnippet{}, false
+	}
+	s := sourceSnippet{
+		Location: fmt.Sprintf("%s:%d", displayPath(path), line),
+		Function: fn,
+		Code:     textutil.ExtractFunction(src, fn),
+	}
+	if lines := strings.Split(src, "\n"); line-1 < len(lines) {
+		s.Line = strings.TrimSpace(lines[line-1])
+	}
+	return s, true
+}
+
+// readFailureSource resolves a file named in test output: absolute paths, paths
+// relative to the working directory, and bare names relative to the package.
+func readFailureSource(deps Dependencies, pkgDir, file string) (string, string) {
+	candidates := []string{file}
+	if !filepath.IsAbs(file) && pkgDir != "" {
+		candidates = append([]string{filepath.Join(pkgDir, filepath.Base(file))}, candidates...)
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if src, err := deps.Files.Read(path); err == nil {
+			return path, src
+		}
+	}
+	return "", ""
+}
+
+func tailLines(lines []string, n int) []string {
+	if len(lines) > n {
+		return lines[len(lines)-n:]
+	}
+	return lines
+}
+
+func buildTestFailurePrompt(f testjson.Failure, keyLines []string, snippets []sourceSnippet) string {
+	var b strings.Builder
+	if f.Test == nil {
+		fmt.Fprintf(&b, "The Go package %s failed outside any single test (build failure, TestMain, timeout or panic).\n", f.Package.Path)
+	} else {
+		fmt.Fprintf(&b, "The Go test %s in package %s failed.\n", f.Test.Name, f.Package.Path)
+		for _, sub := range f.Subtests {
+			fmt.Fprintf(&b, "Failing subtest: %s\n", sub.Name)
+		}
+	}
+	b.WriteString(`Answer with exactly two sections:
+**Root cause:** the most likely cause in 1-3 sentences, citing file:line; say which of test or code under test is wrong.
+**Minimal fix:** the smallest code change that fixes it, as a short Go snippet or unified diff.
+Do not speculate beyond the evidence; if it is insufficient, say what to inspect next.
+
+Key output lines:
+`)
+	b.WriteString(strings.Join(keyLines, "\n"))
+	b.WriteString("\n")
+	writeSnippets(&b, snippets)
+	return b.String()
+}
+
+func writeSnippets(w io.Writer, snippets []sourceSnippet) {
+	for _, s := range snippets {
+		fmt.Fprintf(w, "\nSource of %s (%s)", s.Function, s.Location)
+		if s.Line != "" {
+			fmt.Fprintf(w, ", referenced line: %s", s.Line)
+		}
+		fmt.Fprintf(w, ":\n%s\n", s.Code)
+	}
+}
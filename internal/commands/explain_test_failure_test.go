@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+func TestExplainTestFailure(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module example.com/tj\n\ngo 1.21\n",
+		"calc.go": "package tj\n\nfunc Div(a, b int) int { return a / b }\n\nfunc Sum(xs []int) int {\n\ts := 0\n\tfor i := 1; i < len(xs); i++ {\n\t\ts += xs[i]\n\t}\n\treturn s\n}\n",
+		"calc_test.go": `package tj
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	if got := Sum([]int{1}); got != 1 {
+		t.Errorf("Sum = %d, want 1", got)
+	}
+}
+
+func TestDiv(t *testing.T) {
+	if Div(1, 0) != 0 {
+		t.Fatal("x")
+	}
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	goTest := exec.Command("go", "test", "-json", ".")
+	goTest.Dir = dir
+	run, _ := goTest.Output()
+	runFile := filepath.Join(dir, "run.json")
+	if err := os.WriteFile(runFile, run, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Package sources are resolved from the working directory, as when run in a module.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	llm := &scriptedLLM{answers: []string{"**Root cause:** loop starts at 1.", "**Root cause:** division by zero."}}
+	deps := Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Shell:  system.BashShell{},
+		Logger: logging.NewSheldonLogger(),
+	}
+	cmd := NewExplainTestFailureCommand(deps)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--file", runFile})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{"## example.com/tj", "### TestSum", "calc_test.go:7: Sum = 0, want 1", "### TestDiv", "panic: runtime error: integer divide by zero", "loop starts at 1", "division by zero."} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output:\n%s", want, got)
+		}
+	}
+	if len(llm.prompts) != 2 {
+		t.Fatalf("expected one prompt per failing test, got %d", len(llm.prompts))
+	}
+	if !strings.Contains(llm.prompts[0], "Source of TestSum") {
+		t.Fatalf("expected the test source in the prompt:\n%s", llm.prompts[0])
+	}
+	if !strings.Contains(llm.prompts[1], "Source of Div (") || !strings.Contains(llm.prompts[1], "func Div(a, b int) int { return a / b }") {
+		t.Fatalf("expected the panicking function in the prompt:\n%s", llm.prompts[1])
+	}
+}
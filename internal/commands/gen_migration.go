@@ -0,0 +1,440 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/gomodel"
+	"github.com/riskiramdan/ShELDon/internal/sqllint"
+	"github.com/riskiramdan/ShELDon/internal/sqlmigration"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// NewGenMigrationCommand writes the migration for changed Go model structs.
+func NewGenMigrationCommand(deps Dependencies) *cobra.Command {
+	var (
+		ref          string
+		format       string
+		migrationDir string
+		name         string
+		failOn       string
+		dryRun       bool
+		model        string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "gen-migration [pathspec...]",
+		Short: "Generate a Postgres migration from changes to Go model structs",
+		Long: `Generate a Postgres migration from changes to Go model structs.
+
+Structs with db or gorm tags are read from the Go files changed since --ref
+(default HEAD) and from the working tree, and diffed into a schema delta:
+tables and columns added, dropped or renamed, and changes of type, nullability,
+default, uniqueness and indexes. The model writes safe online DDL for the
+delta, which is reviewed with the review-migration rules before the up and
+down files are written; findings at or above --fail-on stop the write.
+
+The layout and version numbering follow the migrations already in
+--migration-dir; an empty or missing directory gets golang-migrate files.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			threshold, err := severityThreshold(failOn)
+			if err != nil {
+				return err
+			}
+			if deps.Git == nil {
+				return errors.New("gen-migration needs git to read the models at --ref")
+			}
+			if _, err := deps.Git.RevParse(ref); err != nil {
+				return fmt.Errorf("resolve %s: %w", ref, err)
+			}
+
+			existing, err := sqlmigration.Load(migrationDir)
+			switch {
+			case errors.Is(err, fs.ErrNotExist), errors.Is(err, sqlmigration.ErrNoMigrations):
+				existing = nil // a new or empty directory
+			case err != nil:
+				return err
+			}
+			layout, err := migrationLayout(format, existing, migrationDir)
+			if err != nil {
+				return err
+			}
+
+			deps.Logger.Info(cmd, "Diffing your structs against %s. Someone has to read the tags.", ref)
+			before, after, err := changedModels(deps, ref, args)
+			if err != nil {
+				return err
+			}
+			changes := gomodel.Diff(before, after)
+			out := cmd.OutOrStdout()
+			if len(changes) == 0 {
+				fmt.Fprintf(out, "No model changes since %s.\n", ref)
+				return nil
+			}
+			fmt.Fprintf(out, "Schema changes since %s:\n", ref)
+			for _, c := range changes {
+				fmt.Fprintf(out, "- %s\n", c)
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+			defer cancel()
+
+			modelUse := textutil.Choose(model, deps.Config.ModelGeneral)
+			deps.Logger.Info(cmd, "Asking model %s for DDL that does not lock production. A low bar it has tripped over before.", modelUse)
+			ans, err := deps.LLM.Generate(ctx, modelUse, genMigrationPrompt(ref, layout, changes, after))
+			if err != nil {
+				return err
+			}
+			pairs, err := migrationPairs(ans)
+			if err != nil {
+				return err
+			}
+
+			if name == "" {
+				name = migrationName(changes)
+			}
+			files, migrations, err := renderMigrations(layout, existing, migrationDir, identCleaner.ReplaceAllString(strings.ToLower(name), "_"), pairs, time.Now())
+			if err != nil {
+				return err
+			}
+			for _, f := range files {
+				fmt.Fprintf(out, "\n-- %s\n%s", migrationLabel(f.Name), f.Content)
+			}
+
+			counts := reviewGenerated(out, deps, layout, existing, migrationDir, migrations, threshold)
+			if counts.Failing > 0 {
+				return fmt.Errorf("%d findings at or above %s; not writing the migration (fix the SQL or rerun with --fail-on none)", counts.Failing, threshold)
+			}
+			if dryRun {
+				return nil
+			}
+			if err := os.MkdirAll(migrationDir, 0o755); err != nil {
+				return err
+			}
+			for _, f := range files {
+				if err := deps.Files.WriteFile(f.Name, f.Content); err != nil {
+					return fmt.Errorf("write migration: %w", err)
+				}
+				fmt.Fprintf(out, "Wrote %s\n", f.Name)
+			}
+			deps.Logger.Info(cmd, "Migration written. Run it on staging first; I know you won't.")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&ref, "ref", "HEAD", "Git ref holding the models before the change")
+	cmd.Flags().StringVar(&format, "migration", "", "Migration layout: goose or golang-migrate (default: that of --migration-dir, else golang-migrate)")
+	cmd.Flags().StringVar(&migrationDir, "migration-dir", "migrations", "Directory of the migrations")
+	cmd.Flags().StringVar(&name, "name", "", "Migration name (default derived from the changes)")
+	cmd.Flags().StringVar(&failOn, "fail-on", "error", "Refuse to write when a finding reaches this severity: warn, error or none")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print and review the migration without writing it")
+	cmd.Flags().StringVar(&model, "model", "", "Override model")
+	return cmd
+}
+
+// migrationLayout settles the layout to write: the one asked for, which must
+// match the directory's, or the directory's own.
+func migrationLayout(format string, existing *sqlmigration.Set, dir string) (sqlmigration.Format, error) {
+	want := sqlmigration.Format(format)
+	if format != "" && want != sqlmigration.Goose && want != sqlmigration.GolangMigrate {
+		return "", fmt.Errorf("unknown migration format %q (want goose or golang-migrate)", format)
+	}
+	if existing == nil {
+		if want == "" {
+			return sqlmigration.GolangMigrate, nil
+		}
+		return want, nil
+	}
+	if !existing.Format.HasDown() {
+		return "", fmt.Errorf("%s holds %s migrations; gen-migration writes goose or golang-migrate", dir, existing.Format)
+	}
+	if want != "" && want != existing.Format {
+		return "", fmt.Errorf("--migration %s, but %s holds %s migrations", want, dir, existing.Format)
+	}
+	return existing.Format, nil
+}
+
+// changedModels parses the models of the Go files changed since ref, or still
+// untracked, at ref and in the working tree. pathspecs narrows the files.
+func changedModels(deps Dependencies, ref string, pathspecs []string) (before, after []gomodel.Model, err error) {
+	root, err := deps.Git.RepoRoot()
+	if err != nil {
+		return nil, nil, err
+	}
+	specs := pathspecs
+	if len(specs) == 0 {
+		specs = []string{"*.go"}
+	}
+	// Without rename detection a moved file is listed at both paths, so its
+	// models are read at the old path before and the new one after.
+	changed, err := deps.Git.Diff(append([]string{"--name-only", "--no-renames", "-z", ref, "--"}, specs...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	paths := strings.Split(changed, "\x00")
+	entries, err := deps.Git.Status()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Status paths are relative to the root, pathspecs to the working
+	// directory, where git also looks by default.
+	rootSpecs := rootPathspecs(root, pathspecs)
+	for _, e := range entries {
+		if e.Index == '?' && underAny(e.Path, rootSpecs) {
+			paths = append(paths, e.Path)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if seen[p] || !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			continue
+		}
+		seen[p] = true
+		if src, err := deps.Git.Show(ref + ":" + p); err == nil && src != "" {
+			models, err := gomodel.ParseFile(p, src)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s at %s: %w", p, ref, err)
+			}
+			before = append(before, models...)
+		}
+		src, err := deps.Files.Read(filepath.Join(root, filepath.FromSlash(p)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // deleted
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		models, err := gomodel.ParseFile(p, src)
+		if err != nil {
+			return nil, nil, err
+		}
+		after = append(after, models...)
+	}
+	return before, after, nil
+}
+
+// rootPathspecs makes pathspecs given in the working directory relative to the
+// repository root; none means the working directory itself.
+func rootPathspecs(root string, pathspecs []string) []string {
+	prefix := ""
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(root, wd); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			prefix = filepath.ToSlash(rel)
+		}
+	}
+	if len(pathspecs) == 0 {
+		if prefix == "" {
+			return nil
+		}
+		return []string{prefix}
+	}
+	specs := make([]string, len(pathspecs))
+	for i, spec := range pathspecs {
+		specs[i] = path.Join(prefix, filepath.ToSlash(spec))
+	}
+	return specs
+}
+
+// underAny reports whether p, relative to the root, lies in one of the
+// directories or files named by pathspecs, or matches one of their patterns
+// in its directory tree; no pathspecs means everywhere.
+func underAny(p string, pathspecs []string) bool {
+	if len(pathspecs) == 0 {
+		return true
+	}
+	for _, spec := range pathspecs {
+		spec = path.Clean(spec)
+		if spec == "." || p == spec || strings.HasPrefix(p, spec+"/") {
+			return true
+		}
+		dir, pattern := path.Split(spec)
+		if dir != "" && !strings.HasPrefix(p, dir) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, path.Base(p)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// genMigrationPrompt gives the model the schema delta, the models it touches,
+// and the rules its SQL is checked against.
+func genMigrationPrompt(ref string, layout sqlmigration.Format, changes []gomodel.Change, after []gomodel.Model) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Go model structs changed between %s and the working tree, giving this schema delta for PostgreSQL:\n", ref)
+	for _, c := range changes {
+		fmt.Fprintf(&b, "- %s\n", c)
+	}
+	touched := map[string]bool{}
+	for _, c := range changes {
+		touched[c.Table] = true
+	}
+	b.WriteString("\nThe changed models as they are now (<Go T> means the SQL type is yours to choose):\n")
+	for _, m := range after {
+		if !touched[m.Table] {
+			continue
+		}
+		fmt.Fprintf(&b, "%s (%s in %s):\n", m.Table, m.Name, m.File)
+		for _, c := range m.Columns {
+			fmt.Fprintf(&b, "  %s\n", c.Definition())
+		}
+	}
+
+	b.WriteString("\nWrite the migration as safe online DDL for a busy production database. ")
+	b.WriteString("It is checked against these rules and not written if it breaks one at error severity:\n")
+	for _, r := range sqllint.Rules {
+		if r.ID != "MG005" {
+			fmt.Fprintf(&b, "- %s %s (%s): %s\n", r.ID, r.Name, r.Severity, r.Summary)
+		}
+	}
+	switch layout {
+	case sqlmigration.Goose:
+		b.WriteString("\nEach migration becomes a goose file. One that uses CONCURRENTLY is marked NO TRANSACTION; goose runs any other in a transaction.\n")
+	default:
+		b.WriteString("\nEach migration becomes a golang-migrate .up.sql and .down.sql pair. golang-migrate runs a file of more than one statement in a transaction, where CONCURRENTLY fails.\n")
+	}
+	b.WriteString("Answer with one or more migrations in the order they run, each as two ```sql blocks: its up, then the down that reverts it. ")
+	b.WriteString("Split the work into separate migrations where a statement cannot share one, such as an index built CONCURRENTLY, or where a backfill must run between steps. ")
+	b.WriteString("Do not write migration-tool markers. Where a change needs code deployed first, such as a rename or a dropped column, say so in a SQL comment.\n")
+	return b.String()
+}
+
+// sqlPair is the up and down SQL of one migration.
+type sqlPair struct {
+	Up, Down string
+}
+
+// migrationPairs reads the model's ```sql blocks as up and down pairs.
+func migrationPairs(ans string) ([]sqlPair, error) {
+	blocks := sqlFencePattern.FindAllStringSubmatch(ans, -1)
+	if len(blocks) == 0 || len(blocks)%2 != 0 {
+		return nil, fmt.Errorf("expected pairs of ```sql blocks (up, then down) from the model, got %d blocks", len(blocks))
+	}
+	var pairs []sqlPair
+	for i := 0; i < len(blocks); i += 2 {
+		pairs = append(pairs, sqlPair{Up: strings.TrimSpace(blocks[i][1]), Down: strings.TrimSpace(blocks[i+1][1])})
+	}
+	return pairs, nil
+}
+
+// migrationName describes the changes in a few words, such as
+// add_email_to_users or update_orders_users.
+func migrationName(changes []gomodel.Change) string {
+	if len(changes) == 1 {
+		c := changes[0]
+		switch c.Kind {
+		case "create-table", "drop-table":
+			return strings.TrimSuffix(c.Kind, "-table") + "_" + c.Table
+		case "add-column":
+			return "add_" + c.Column.Name + "_to_" + c.Table
+		case "drop-column":
+			return "drop_" + c.Column.Name + "_from_" + c.Table
+		}
+	}
+	var tables []string
+	seen := map[string]bool{}
+	for _, c := range changes {
+		if !seen[c.Table] && len(tables) < 3 {
+			seen[c.Table] = true
+			tables = append(tables, c.Table)
+		}
+	}
+	return "update_" + strings.Join(tables, "_")
+}
+
+var concurrentPattern = regexp.MustCompile(`(?i)\bCONCURRENTLY\b`)
+
+// renderMigrations lays out the pairs as migration files in dir, versioned
+// after the existing migrations: the next number when they are numbered
+// sequentially, else timestamps from at, one second apart.
+func renderMigrations(layout sqlmigration.Format, existing *sqlmigration.Set, dir, name string, pairs []sqlPair, at time.Time) ([]migrationFile, []*sqlmigration.Migration, error) {
+	var (
+		files      []migrationFile
+		migrations []*sqlmigration.Migration
+	)
+	for i, p := range pairs {
+		version, err := migrationVersion(existing, at, i)
+		if err != nil {
+			return nil, nil, err
+		}
+		base := filepath.Join(dir, version+"_"+name)
+		switch layout {
+		case sqlmigration.Goose:
+			var b strings.Builder
+			if concurrentPattern.MatchString(p.Up) || concurrentPattern.MatchString(p.Down) {
+				b.WriteString("-- +goose NO TRANSACTION\n")
+			}
+			fmt.Fprintf(&b, "-- +goose Up\n%s\n\n-- +goose Down\n%s\n", p.Up, p.Down)
+			m := sqlmigration.Parse(base+".sql", b.String())
+			m.Version, m.Name = version, name
+			files = append(files, migrationFile{Name: base + ".sql", Content: b.String()})
+			migrations = append(migrations, m)
+		default:
+			up, down := migrationFile{Name: base + ".up.sql", Content: p.Up + "\n"}, migrationFile{Name: base + ".down.sql", Content: p.Down + "\n"}
+			files = append(files, up, down)
+			migrations = append(migrations, sqlmigration.ParsePair(up.Name, up.Content, down.Name, down.Content))
+		}
+	}
+	return files, migrations, nil
+}
+
+// migrationVersion numbers the i-th new migration.
+func migrationVersion(existing *sqlmigration.Set, at time.Time, i int) (string, error) {
+	if existing != nil && len(existing.Migrations) > 0 {
+		last := existing.Migrations[len(existing.Migrations)-1].Version
+		if last != "" && len(last) < len("20060102150405") {
+			n, err := strconv.ParseUint(last, 10, 64)
+			if err != nil {
+				return "", fmt.Errorf("version %s: %w", last, err)
+			}
+			return fmt.Sprintf("%0*d", len(last), n+1+uint64(i)), nil
+		}
+	}
+	return at.Add(time.Duration(i) * time.Second).UTC().Format("20060102150405"), nil
+}
+
+// reviewGenerated reviews the new migrations as review-migration would, in
+// the company of the existing ones so versions are checked too, and prints the
+// findings.
+func reviewGenerated(w io.Writer, deps Dependencies, layout sqlmigration.Format, existing *sqlmigration.Set, dir string, migrations []*sqlmigration.Migration, threshold sqllint.Severity) gateCounts {
+	set := &sqlmigration.Set{Dir: dir, Format: layout}
+	if existing != nil {
+		set.Migrations = append(set.Migrations, existing.Migrations...)
+	}
+	ours := map[*sqlmigration.Migration]bool{}
+	for _, m := range migrations {
+		ours[m] = true
+		set.Migrations = append(set.Migrations, m)
+	}
+	isNew := func(m *sqlmigration.Migration) bool { return ours[m] }
+
+	var reviewed []reviewedMigration
+	for _, f := range set.Review(sqllint.Options{ColumnUses: columnUses(deps.Git)}, isNew) {
+		if ours[f.Migration] {
+			reviewed = append(reviewed, reviewedMigration{Label: migrationLabel(f.Path), SQL: f.SQL, Findings: f.Findings})
+		}
+	}
+	fmt.Fprintln(w)
+	counts := applyGate(w, reviewed, sqllint.Baseline{}, threshold)
+	if counts.Errors+counts.Warnings == 0 {
+		fmt.Fprintln(w, "review-migration: no findings.")
+	} else {
+		fmt.Fprintf(w, "\nreview-migration: %s\n", counts)
+	}
+	return counts
+}
@@ -0,0 +1,235 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/git"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+const (
+	userModelBefore = "package models\n\ntype User struct {\n\tID int64 `db:\"id\"`\n}\n"
+	userModelAfter  = "package models\n\ntype User struct {\n\tID    int64  `db:\"id\"`\n\tEmail string `db:\"email\"`\n}\n"
+)
+
+// genMigrationRepo lays out a repository whose models/user.go gained a column
+// since HEAD, and a migration directory holding files.
+func genMigrationRepo(t *testing.T, files map[string]string) (root, dir string, client *git.FakeClient) {
+	t.Helper()
+	root = t.TempDir()
+	dir = filepath.Join(root, "migrations")
+	if err := os.MkdirAll(filepath.Join(root, "models"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "models", "user.go"), []byte(userModelAfter), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) > 0 {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client = git.NewFakeClient()
+	client.Root = root
+	client.Refs["HEAD"] = "abc123"
+	client.Diffs["--name-only --no-renames -z HEAD -- *.go"] = "models/user.go\x00"
+	client.Objects["HEAD:models/user.go"] = userModelBefore
+	return root, dir, client
+}
+
+func runGenMigration(client *git.FakeClient, llm *scriptedLLM, args ...string) (string, error) {
+	cmd := NewGenMigrationCommand(Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Git:    client,
+		Logger: logging.NewSheldonLogger(),
+	})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+const safeEmailAnswer = "Add it nullable first.\n```sql\nSET lock_timeout = '5s';\nALTER TABLE users ADD COLUMN email text;\n```\n```sql\nSET lock_timeout = '5s';\nALTER TABLE users DROP COLUMN email;\n```\n"
+
+func TestGenMigration(t *testing.T) {
+	_, dir, client := genMigrationRepo(t, map[string]string{
+		"0001_init.up.sql":   "CREATE TABLE users (id bigint PRIMARY KEY);\n",
+		"0001_init.down.sql": "DROP TABLE users;\n",
+	})
+	llm := &scriptedLLM{answers: []string{safeEmailAnswer}}
+	out, err := runGenMigration(client, llm, "--migration-dir", dir)
+	if err != nil {
+		t.Fatalf("gen-migration: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"Schema changes since HEAD:\n- add column users.email text NOT NULL\n",
+		"review-migration: no findings.\n",
+		"Wrote " + filepath.Join(dir, "0002_add_email_to_users.up.sql") + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output missing %q:\n%s", want, out)
+		}
+	}
+	for _, want := range []string{"- add column users.email text NOT NULL\n", "users (User in models/user.go):\n  id bigint NOT NULL\n  email text NOT NULL\n", "PG004 not-null (error)", "golang-migrate"} {
+		if !strings.Contains(llm.prompts[0], want) {
+			t.Fatalf("prompt missing %q:\n%s", want, llm.prompts[0])
+		}
+	}
+	down, err := os.ReadFile(filepath.Join(dir, "0002_add_email_to_users.down.sql"))
+	if err != nil || string(down) != "SET lock_timeout = '5s';\nALTER TABLE users DROP COLUMN email;\n" {
+		t.Fatalf("down file: %q %v", down, err)
+	}
+}
+
+func TestGenMigrationRefusesUnsafeSQL(t *testing.T) {
+	_, dir, client := genMigrationRepo(t, nil)
+	llm := &scriptedLLM{answers: []string{"```sql\nALTER TABLE users ADD COLUMN email text NOT NULL;\n```\n```sql\n```\n"}}
+	out, err := runGenMigration(client, llm, "--migration-dir", dir)
+	if err == nil || !strings.Contains(err.Error(), "2 findings at or above error; not writing the migration") {
+		t.Fatalf("expected the review to stop the write, got %v\n%s", err, out)
+	}
+	if !strings.Contains(out, ":1: error PG004 not-null: ") || !strings.Contains(out, ":1: error MG001 missing-down: ") {
+		t.Fatalf("findings missing:\n%s", out)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("nothing should be written: %v", err)
+	}
+}
+
+func TestGenMigrationGoose(t *testing.T) {
+	_, dir, client := genMigrationRepo(t, map[string]string{
+		"20240101000000_init.sql": "-- +goose Up\nCREATE TABLE users (id bigint PRIMARY KEY);\n\n-- +goose Down\nDROP TABLE users;\n",
+	})
+	answer := safeEmailAnswer + "```sql\nCREATE INDEX CONCURRENTLY users_email_idx ON users (email);\n```\n```sql\nDROP INDEX CONCURRENTLY users_email_idx;\n```\n"
+	out, err := runGenMigration(client, &scriptedLLM{answers: []string{answer}}, "--migration-dir", dir, "--name", "User Email", "--dry-run")
+	if err != nil {
+		t.Fatalf("gen-migration: %v\n%s", err, out)
+	}
+	// Two migrations a second apart; only the index needs NO TRANSACTION.
+	first := strings.Index(out, "_user_email.sql\n-- +goose Up\nSET lock_timeout")
+	second := strings.Index(out, "_user_email.sql\n-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY")
+	if first < 0 || second < first || !strings.Contains(out, "review-migration: no findings.") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("--dry-run should write nothing, found %d files", len(entries))
+	}
+
+	if _, err := runGenMigration(client, &scriptedLLM{}, "--migration-dir", dir, "--migration", "golang-migrate"); err == nil || !strings.Contains(err.Error(), "holds goose migrations") {
+		t.Fatalf("expected a layout mismatch, got %v", err)
+	}
+}
+
+func TestGenMigrationUnreadableDir(t *testing.T) {
+	root, _, client := genMigrationRepo(t, nil)
+	// A file where the directory should be is neither new nor empty.
+	notDir := filepath.Join(root, "migrations.txt")
+	if err := os.WriteFile(notDir, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	llm := &scriptedLLM{}
+	if _, err := runGenMigration(client, llm, "--migration-dir", notDir); err == nil || !strings.Contains(err.Error(), "not a directory") || len(llm.prompts) != 0 {
+		t.Fatalf("expected the directory error, got %v", err)
+	}
+
+	empty := filepath.Join(root, "empty")
+	if err := os.Mkdir(empty, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runGenMigration(client, &scriptedLLM{answers: []string{safeEmailAnswer}}, "--migration-dir", empty, "--dry-run")
+	if err != nil || !strings.Contains(out, "_add_email_to_users.up.sql\n") {
+		t.Fatalf("an empty directory starts afresh: %v\n%s", err, out)
+	}
+}
+
+func TestGenMigrationMovedFile(t *testing.T) {
+	_, dir, client := genMigrationRepo(t, nil)
+	// git mv user.go models/user.go, then a new field.
+	client.Diffs["--name-only --no-renames -z HEAD -- *.go"] = "models/user.go\x00user.go\x00"
+	delete(client.Objects, "HEAD:models/user.go")
+	client.Objects["HEAD:user.go"] = userModelBefore
+	out, err := runGenMigration(client, &scriptedLLM{answers: []string{safeEmailAnswer}}, "--migration-dir", dir, "--dry-run")
+	if err != nil {
+		t.Fatalf("gen-migration: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Schema changes since HEAD:\n- add column users.email text NOT NULL\n\n") {
+		t.Fatalf("a moved model should diff against its old path:\n%s", out)
+	}
+}
+
+func TestGenMigrationNoChanges(t *testing.T) {
+	_, dir, client := genMigrationRepo(t, nil)
+	client.Objects["HEAD:models/user.go"] = userModelAfter
+	llm := &scriptedLLM{}
+	out, err := runGenMigration(client, llm, "--migration-dir", dir)
+	if err != nil || out != "No model changes since HEAD.\n" || len(llm.prompts) != 0 {
+		t.Fatalf("unexpected result: %v %q", err, out)
+	}
+}
+
+func TestUnderAny(t *testing.T) {
+	for _, tc := range []struct {
+		path  string
+		specs []string
+		want  bool
+	}{
+		{"models/user.go", nil, true},
+		{"models/user.go", []string{"models"}, true},
+		{"models/user.go", []string{"./models/"}, true},
+		{"modelsx/user.go", []string{"models"}, false},
+		{"internal/user.go", []string{"*.go"}, true},
+		{"models/sub/user.go", []string{"models/*.go"}, true},
+		{"other/user.go", []string{"models/*.go"}, false},
+	} {
+		if got := underAny(tc.path, tc.specs); got != tc.want {
+			t.Errorf("underAny(%s, %v) = %v, want %v", tc.path, tc.specs, got, tc.want)
+		}
+	}
+}
+
+func TestGenMigrationFromSubdirectory(t *testing.T) {
+	root, dir, client := genMigrationRepo(t, nil)
+	for name, typ := range map[string]string{"models/extra.go": "Extra", "other/skip.go": "Skip"} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		src := "package x\n\ntype " + typ + " struct {\n\tID int64 `db:\"id\"`\n}\n"
+		if err := os.WriteFile(filepath.Join(root, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Entries = []git.StatusEntry{
+		{Index: '?', Worktree: '?', Path: "models/extra.go"},
+		{Index: '?', Worktree: '?', Path: "other/skip.go"},
+	}
+	// Run from models/: untracked files elsewhere in the repository are out
+	// of scope, as they are for git diff.
+	t.Chdir(filepath.Join(root, "models"))
+	out, err := runGenMigration(client, &scriptedLLM{answers: []string{safeEmailAnswer}}, "--migration-dir", dir, "--dry-run")
+	if err != nil {
+		t.Fatalf("gen-migration: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "- create table extras (id bigint NOT NULL)\n") || strings.Contains(out, "skips") {
+		t.Fatalf("unexpected changes:\n%s", out)
+	}
+
+	if got := rootPathspecs(root, []string{"extra.go", "../other"}); strings.Join(got, ",") != "models/extra.go,other" {
+		t.Fatalf("rootPathspecs = %v", got)
+	}
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/analysis"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// NewGenMockCommand generates a hand-rolled fake for a Go interface.
+func NewGenMockCommand(deps Dependencies) *cobra.Command {
+	var (
+		iface    string
+		out      string
+		pkgName  string
+		typeName string
+		withLLM  bool
+		model    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "gen-mock",
+		Short: "Generate a deterministic fake (func fields plus call recording) for a Go interface",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			if strings.TrimSpace(iface) == "" {
+				return errors.New("--iface is required (e.g. llm.Client or ./internal/git.Client)")
+			}
+
+			deps.Logger.Info(cmd, "Type-checking %s. Interfaces are contracts, and I read contracts in full.", iface)
+			target, err := analysis.LoadInterface(".", iface)
+			if err != nil {
+				return err
+			}
+			deps.Logger.Info(cmd, "Found %s.%s with %d methods.", target.PackagePath, target.Name, target.Type.NumMethods())
+
+			opts, err := fakeOptionsFor(target, out, pkgName, typeName)
+			if err != nil {
+				return err
+			}
+			opts.ModelConstructor = withLLM
+			code, err := analysis.RenderFake(target, opts)
+			if err != nil {
+				return err
+			}
+
+			if withLLM {
+				ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+				defer cancel()
+
+				modelUse := textutil.Choose(model, deps.Config.ModelCoder)
+				deps.Logger.Info(cmd, "Asking model %s for plausible default behaviour. Plausible is the most I expect.", modelUse)
+				code, err = addFakeDefaults(ctx, deps, modelUse, target, code, textutil.Choose(typeName, "Fake"+target.Name))
+				if err != nil {
+					return err
+				}
+			}
+
+			if out == "" || out == "-" {
+				_, err = cmd.OutOrStdout().Write([]byte(code))
+				return err
+			}
+			if err := deps.Files.WriteFile(out, code); err != nil {
+				return err
+			}
+			deps.Logger.Info(cmd, "Fake written to %s. Mockery, in the literal sense.", out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&iface, "iface", "", "Interface as <package>.<Name>: import path, ./relative/dir or bare package name")
+	cmd.Flags().StringVar(&out, "out", "-", "Output file or '-' for stdout")
+	cmd.Flags().StringVar(&pkgName, "package", "", "Package clause of the output (default: the interface's package, or the --out directory name)")
+	cmd.Flags().StringVar(&typeName, "name", "", "Fake type name (default Fake<Name>)")
+	cmd.Flags().BoolVar(&withLLM, "llm", false, "Also ask the model for a New<Fake> constructor with realistic default behaviours")
+	cmd.Flags().StringVar(&model, "model", "", "Override model for --llm (default SHELDON_MODEL_CODER)")
+	return cmd
+}
+
+// fakeOptionsFor decides where the fake lives. Writing to stdout or into the
+// interface's own directory puts it in the interface's package, as with git.FakeClient;
+// any other directory gets a package named after the directory.
+func fakeOptionsFor(target *analysis.Interface, out, pkgName, typeName string) (analysis.FakeOptions, error) {
+	opts := analysis.FakeOptions{TypeName: typeName}
+	samePackage := out == "" || out == "-"
+	outDir := ""
+	if !samePackage {
+		abs, err := filepath.Abs(filepath.Dir(out))
+		if err != nil {
+			return opts, err
+		}
+		outDir = abs
+		samePackage = abs == target.Dir
+	}
+	if samePackage && (pkgName == "" || pkgName == target.PackageName) {
+		opts.Package = target.PackageName
+		opts.PackagePath = target.PackagePath
+		return opts, nil
+	}
+	opts.Package = pkgName
+	if opts.Package == "" {
+		opts.Package = strings.NewReplacer("-", "", ".", "").Replace(filepath.Base(outDir))
+	}
+	if !isIdentifier(opts.Package) {
+		return opts, fmt.Errorf("cannot derive a package name from %s; pass --package", out)
+	}
+	return opts, nil
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func isIdentifier(s string) bool {
+	return identifierPattern.MatchString(s)
+}
+
+// addFakeDefaults asks the model for a constructor that pre-populates the fake's
+// XxxFunc fields and merges it into the generated file. The deterministic fake is
+// kept unchanged; only the constructor and its imports are added.
+func addFakeDefaults(ctx context.Context, deps Dependencies, model string, target *analysis.Interface, code, typeName string) (string, error) {
+	prompt := fmt.Sprintf(`Below is a Go interface and a generated fake for it. Write a constructor
+func New%[1]s() *%[1]s
+that sets every XxxFunc field to a realistic default behaviour for tests: return plausible
+non-zero values, keep simple in-memory state in closures where the interface implies it
+(e.g. a Get returns what a Put stored), and return errors only for obviously invalid input.
+Return a Go file containing "package %[2]s", the imports the constructor needs, and only the
+constructor with a doc comment. Do not redeclare %[1]s or its methods.
+
+Interface (package %[3]s):
+%[4]s
+
+Fake:
+%[5]s`, typeName, textutil.PackageName(code), target.PackagePath, target.String(), code)
+	ans, err := deps.LLM.Generate(ctx, model, prompt)
+	if err != nil {
+		return "", err
+	}
+	merged, _, err := testComposer{Existing: code}.compose(textutil.NormalizeCode(ans))
+	if err != nil {
+		return "", fmt.Errorf("model returned an unusable constructor: %w", err)
+	}
+	return merged, nil
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/analysis"
+)
+
+func TestGenMockWithModelDefaults(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	target, err := analysis.LoadInterface(".", "../llm.Client")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	opts, err := fakeOptionsFor(target, "-", "", "")
+	if err != nil || opts.Package != "llm" || opts.PackagePath != target.PackagePath {
+		t.Fatalf("stdout should target the interface's package, got %+v (%v)", opts, err)
+	}
+	opts, err = fakeOptionsFor(target, filepath.Join(t.TempDir(), "llmfake", "client.go"), "", "")
+	if err != nil || opts.Package != "llmfake" || opts.PackagePath != "" {
+		t.Fatalf("other directories get their own package, got %+v (%v)", opts, err)
+	}
+
+	opts.ModelConstructor = true
+	code, err := analysis.RenderFake(target, opts)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	llm := &scriptedLLM{answers: []string{"```go\npackage llmfake\n\nimport (\n\t\"context\"\n\t\"strings\"\n)\n\n// NewFakeClient echoes prompts.\nfunc NewFakeClient() *FakeClient {\n\treturn &FakeClient{GenerateFunc: func(_ context.Context, _, prompt string) (string, error) {\n\t\treturn strings.ToUpper(prompt), nil\n\t}}\n}\n```"}}
+	merged, err := addFakeDefaults(context.Background(), Dependencies{LLM: llm}, "model", target, code, "FakeClient")
+	if err != nil {
+		t.Fatalf("defaults: %v", err)
+	}
+	for _, want := range []string{"\t\"strings\"\n", "func NewFakeClient() *FakeClient {", "var _ llm.Client = (*FakeClient)(nil)"} {
+		if !strings.Contains(merged, want) {
+			t.Fatalf("expected %q in merged fake:\n%s", want, merged)
+		}
+	}
+	if strings.Contains(merged, "DO NOT EDIT") || !strings.HasPrefix(merged, "// FakeClient was generated by sheldon gen-mock") {
+		t.Fatalf("a fake with a model-written constructor must not be marked generated:\n%s", merged)
+	}
+	if !strings.Contains(llm.prompts[0], "func NewFakeClient() *FakeClient") {
+		t.Fatalf("prompt does not name the constructor:\n%s", llm.prompts[0])
+	}
+}
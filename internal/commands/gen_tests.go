@@ -4,67 +4,329 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/tools/cover"
 
+	"github.com/riskiramdan/ShELDon/internal/analysis"
 	"github.com/riskiramdan/ShELDon/internal/textutil"
 )
 
 // NewGenTestsCommand generates Go tests using an LLM backend.
 func NewGenTestsCommand(deps Dependencies) *cobra.Command {
 	var (
-		file  string
-		fn    string
-		out   string
-		model string
+		file       string
+		fn         string
+		pkgDir     string
+		out        string
+		model      string
+		verify     bool
+		maxRepairs int
+		coverage   bool
+		parallel   int
+		force      bool
+		style      string
+		kind       string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "gen-tests",
-		Short: "Generate Go table-driven tests for a function",
+		Short: "Generate Go table-driven tests for a function, a file or a package",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if strings.TrimSpace(file) == "" || strings.TrimSpace(fn) == "" {
-				return errors.New("--file and --func are required")
+			cmd.SilenceUsage = true
+			if _, ok := testKinds[kind]; !ok {
+				return fmt.Errorf("unknown --kind %q (want unit, fuzz, bench or example)", kind)
 			}
 
-			deps.Logger.Info(cmd, "Initiating surgical extraction of %s from %s. Please refrain from breathing loudly.", fn, file)
-			src, err := deps.Files.Read(file)
+			var (
+				jobs   []genTestJob
+				loaded *analysis.Package
+				err    error
+			)
+			switch {
+			case strings.TrimSpace(pkgDir) != "":
+				if file != "" || fn != "" {
+					return errors.New("--pkg cannot be combined with --file or --func")
+				}
+				jobs, loaded, err = packageTestJobs(cmd, deps, pkgDir, coverage, testKinds[kind])
+			case strings.TrimSpace(file) == "":
+				return errors.New("--file (optionally with --func) or --pkg is required")
+			case strings.TrimSpace(fn) != "":
+				jobs = []genTestJob{{File: file, Selector: textutil.NormalizeSelector(fn)}}
+			default:
+				jobs, err = fileTestJobs(deps, file)
+			}
 			if err != nil {
 				return err
 			}
-
-			code := textutil.ExtractFunction(src, fn)
-			if code == "" {
-				return fmt.Errorf("function %s not found in %s", fn, file)
+			if len(jobs) == 0 {
+				deps.Logger.Info(cmd, "Every exported function already has tests. I have nothing to correct, which is unsettling.")
+				return nil
+			}
+			if out != "" {
+				if len(jobs) > 1 {
+					return errors.New("--out only applies when generating tests for a single function")
+				}
+				jobs[0].Out = out
+			}
+			for i := range jobs {
+				if jobs[i].Out == "" {
+					// Tests must live next to the code they exercise to share its package.
+					jobs[i].Out = defaultTestFile(jobs[i].File, jobs[i].Selector, testKinds[kind].FileSuffix)
+				}
 			}
-			deps.Logger.Info(cmd, "Function located. Astonishing what order can accomplish.")
-
-			prompt := fmt.Sprintf("Write Go table-driven tests for this function. Use testing and testify. Keep names clear.\n\n%s", code)
-			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
-			defer cancel()
 
-			modelToUse := textutil.Choose(model, deps.Config.ModelReason)
-			deps.Logger.Info(cmd, "Consulting model %s via Ollama. Try not to blink.", modelToUse)
-			ans, err := deps.LLM.Generate(ctx, modelToUse, prompt)
+			testStyle, err := resolveTestStyle(style, detectTestStyle(deps, filepath.Dir(jobs[0].File)))
 			if err != nil {
 				return err
 			}
+			deps.Logger.Info(cmd, "Writing %s tests with %s assertions. Consistency is the hobgoblin of superior minds.", kind, testStyle.Assertions)
 
-			if out == "" {
-				out = fmt.Sprintf("%s_test.go", strings.ToLower(fn))
+			opts := genTestOptions{
+				Model:      textutil.Choose(model, deps.Config.ModelReason),
+				Verify:     verify,
+				MaxRepairs: maxRepairs,
+				Force:      force,
+				Kind:       kind,
+				Style:      testStyle,
+				Package:    loaded,
 			}
-			if err := deps.Files.WriteFile(out, textutil.NormalizeCode(ans)); err != nil {
-				return err
+			if len(jobs) == 1 {
+				return runGenTestJob(cmd, deps, opts, jobs[0], &sync.Mutex{}, false)
 			}
-			deps.Logger.Info(cmd, "Tests written to %s. My superiority remains statistically significant.", out)
-			return nil
+			return runGenTestJobs(cmd, deps, opts, jobs, parallel)
 		},
 	}
 
-	cmd.Flags().StringVar(&file, "file", "", "Go source file")
-	cmd.Flags().StringVar(&fn, "func", "", "Function name")
-	cmd.Flags().StringVar(&out, "out", "", "Output test filename (default <func>_test.go)")
+	cmd.Flags().StringVar(&file, "file", "", "Go source file (alone: every exported function in it)")
+	cmd.Flags().StringVar(&fn, "func", "", "Function name or Type.Method selector")
+	cmd.Flags().StringVar(&pkgDir, "pkg", "", "Package directory: generate tests for its untested exported functions")
+	cmd.Flags().StringVar(&out, "out", "", "Output test filename for a single function (default <func>_test.go next to --file)")
 	cmd.Flags().StringVar(&model, "model", "", "Override model (default SHELDON_MODEL_REASON)")
+	cmd.Flags().BoolVar(&verify, "verify", false, "Run go vet and go test on the generated file and ask the model to repair failures")
+	cmd.Flags().IntVar(&maxRepairs, "max-repairs", 3, "Maximum repair rounds with --verify")
+	cmd.Flags().BoolVar(&coverage, "coverage", false, "With --pkg, treat functions with 0% coverage as untested instead of matching Test* names")
+	cmd.Flags().IntVar(&parallel, "parallel", 4, "Functions generated concurrently with --file or --pkg")
+	cmd.Flags().StringVar(&style, "style", "auto", "Test libraries: auto (from go.mod), stdlib, testify or gomock")
+	cmd.Flags().StringVar(&kind, "kind", "unit", "Kind of test: unit, fuzz, bench or example")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing test files instead of merging the new tests into them")
 	return cmd
 }
+
+// genTestOptions carries the settings shared by every job of one invocation.
+type genTestOptions struct {
+	Model      string
+	Verify     bool
+	MaxRepairs int
+	Force      bool
+	Kind       string
+	Style      testStyle
+	// Package is reused for type context when already loaded by --pkg.
+	Package *analysis.Package
+}
+
+// fileTestJobs targets every exported function and method declared in file.
+func fileTestJobs(deps Dependencies, file string) ([]genTestJob, error) {
+	src, err := deps.Files.Read(file)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []genTestJob
+	for _, sel := range textutil.ExportedFunctions(src) {
+		jobs = append(jobs, genTestJob{File: file, Selector: sel})
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no exported functions in %s", file)
+	}
+	return jobs, nil
+}
+
+// packageTestJobs targets the exported functions of the package in dir that have no
+// tests, judged by coverage or by the names of existing tests of kind.
+func packageTestJobs(cmd *cobra.Command, deps Dependencies, dir string, useCoverage bool, kind testKind) ([]genTestJob, *analysis.Package, error) {
+	deps.Logger.Info(cmd, "Loading package %s. Let us see what you neglected to test.", dir)
+	pkg, err := analysis.LoadPackage(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var uncovered map[string]bool
+	if useCoverage {
+		profile := filepath.Join(os.TempDir(), fmt.Sprintf("sheldon-cover-%d.out", os.Getpid()))
+		defer os.Remove(profile)
+		if testOut, err := deps.Shell.Exec(dir, "go", "test", "-count=1", "-coverprofile="+profile, "."); err != nil {
+			return nil, nil, fmt.Errorf("go test -coverprofile: %w\n%s", err, testOut)
+		}
+		profiles, err := cover.ParseProfiles(profile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse coverage profile: %w", err)
+		}
+		gaps, err := analysis.CoverageGaps(dir, profiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		uncovered = uncoveredFunctions(gaps)
+	}
+
+	exported := pkg.ExportedFunctions()
+	untested := untestedFunctions(exported, pkg.ExistingTests(), kind.Prefix, uncovered)
+	deps.Logger.Info(cmd, "%d of %d exported functions in package %s lack tests. Predictable.", len(untested), len(exported), pkg.Name())
+	jobs := make([]genTestJob, 0, len(untested))
+	for _, ref := range untested {
+		jobs = append(jobs, genTestJob{File: ref.File, Selector: ref.Selector})
+	}
+	return jobs, pkg, nil
+}
+
+// runGenTestJobs generates tests for jobs with up to parallel concurrent model calls.
+// Writing and verifying are serialised because jobs share a package: a half-written
+// file from one job would break the build for another.
+func runGenTestJobs(cmd *cobra.Command, deps Dependencies, opts genTestOptions, jobs []genTestJob, parallel int) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	deps.Logger.Info(cmd, "Generating tests for %d functions, %d at a time. Multitasking, done correctly.", len(jobs), parallel)
+
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+		errs    = make([]error, len(jobs))
+		queue   = make(chan int)
+	)
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				errs[i] = runGenTestJob(cmd, deps, opts, jobs[i], &writeMu, true)
+			}
+		}()
+	}
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			fmt.Fprintf(cmd.OutOrStdout(), "ERROR %s: %v\n", jobs[i].Selector, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d functions failed", failed, len(jobs))
+	}
+	return nil
+}
+
+// runGenTestJob generates, writes and optionally verifies tests for one function.
+// writeMu guards the filesystem and go toolchain phase; summary prints an OK line
+// for unverified files so batch runs report every outcome.
+func runGenTestJob(cmd *cobra.Command, deps Dependencies, opts genTestOptions, job genTestJob, writeMu *sync.Mutex, summary bool) error {
+	deps.Logger.Info(cmd, "Initiating surgical extraction of %s from %s. Please refrain from breathing loudly.", job.Selector, job.File)
+	src, err := deps.Files.Read(job.File)
+	if err != nil {
+		return err
+	}
+
+	code := textutil.ExtractFunction(src, job.Selector)
+	if code == "" {
+		return fmt.Errorf("function %s not found in %s", job.Selector, job.File)
+	}
+	deps.Logger.Info(cmd, "Function %s located. Astonishing what order can accomplish.", job.Selector)
+
+	code = functionTestContext(cmd, deps, opts.Package, job.File, job.Selector, src, code)
+	prompt := buildTestPrompt(opts.Kind, opts.Style, job.Selector, code)
+	if job.Cases != "" {
+		prompt += "\n\nInclude at least these cases:\n" + job.Cases
+	}
+	genCtx, cancelGen := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+	defer cancelGen()
+
+	deps.Logger.Info(cmd, "Consulting model %s via Ollama. Try not to blink.", opts.Model)
+	ans, err := deps.LLM.Generate(genCtx, opts.Model, prompt)
+	if err != nil {
+		return err
+	}
+
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	// Repairs get a fresh budget; time spent queued behind other jobs does not count.
+	ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+	defer cancel()
+	out := job.Out
+	composer, err := newTestComposer(deps, out, opts.Force)
+	if err != nil {
+		return err
+	}
+	if composer.Existing != "" {
+		deps.Logger.Info(cmd, "%s already exists. Merging, because unlike some people I respect other people's work.", out)
+	}
+	if !opts.Verify {
+		merged, _, err := composer.compose(textutil.NormalizeCode(ans))
+		if err != nil {
+			if composer.Existing != "" {
+				return fmt.Errorf("cannot merge into %s: %w (use --force to overwrite)", out, err)
+			}
+			// Nothing to protect in a new file; keep the raw answer for the user to fix.
+			merged = textutil.NormalizeCode(ans)
+		}
+		if err := deps.Files.WriteFile(out, merged); err != nil {
+			return err
+		}
+		if summary {
+			fmt.Fprintf(cmd.OutOrStdout(), "OK %s\n", out)
+		}
+		deps.Logger.Info(cmd, "Tests written to %s. My superiority remains statistically significant.", out)
+		return nil
+	}
+
+	verdict, err := verifyGeneratedTests(ctx, cmd, deps, opts.Model, out, textutil.NormalizeCode(ans), code, composer, opts.MaxRepairs)
+	if err != nil {
+		return err
+	}
+	switch {
+	case verdict.Passed:
+		fmt.Fprintf(cmd.OutOrStdout(), "PASS %s\n", out)
+		deps.Logger.Info(cmd, "Tests written to %s and verified. My superiority remains statistically significant.", out)
+		return nil
+	case verdict.Compiled:
+		fmt.Fprintf(cmd.OutOrStdout(), "FAIL %s\n%s", out, verdict.Output)
+		return fmt.Errorf("generated tests in %s compile but do not pass after %d repair rounds", out, opts.MaxRepairs)
+	default:
+		fmt.Fprintf(cmd.OutOrStdout(), "FAIL %s (discarded)\n%s", out, verdict.Output)
+		return fmt.Errorf("generated tests did not compile after %d repair rounds; %s left untouched", opts.MaxRepairs, out)
+	}
+}
+
+// functionTestContext enriches the extracted function with type information from its
+// package, reusing pkg when it is already loaded. When the package cannot be loaded
+// (no module, broken build) the bare function source is used, prefixed with its
+// package clause.
+func functionTestContext(cmd *cobra.Command, deps Dependencies, pkg *analysis.Package, file, fn, src, code string) string {
+	var (
+		fnCtx analysis.FunctionContext
+		err   error
+	)
+	if pkg != nil {
+		fnCtx, err = pkg.FunctionContext(file, fn)
+	} else {
+		fnCtx, err = analysis.LoadFunctionContext(file, fn)
+	}
+	if err != nil {
+		deps.Logger.Info(cmd, "Type information unavailable (%v). Proceeding with the bare function, like an animal.", err)
+		if pkg := textutil.PackageName(src); pkg != "" {
+			return fmt.Sprintf("Package: %s\n\n%s", pkg, code)
+		}
+		return code
+	}
+	deps.Logger.Info(cmd, "Loaded package %s: %d types, %d interfaces, %d helpers of context.",
+		fnCtx.ImportPath, len(fnCtx.Types), len(fnCtx.Interfaces), len(fnCtx.Helpers))
+	return fnCtx.String()
+}
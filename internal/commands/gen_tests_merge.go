@@ -0,0 +1,232 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// testComposer turns generated test code into the content of the output file,
+// merging it into existing hand-written tests rather than replacing them.
+type testComposer struct {
+	// Existing is the current content of the output file; empty for a new file.
+	Existing string
+	// Declared maps a package clause to the top-level names its other files declare,
+	// so foo and foo_test in one directory keep separate namespaces.
+	Declared map[string][]string
+}
+
+// newTestComposer reads out and the other files of its package directory. With
+// force the current content of out is discarded instead of merged.
+func newTestComposer(deps Dependencies, out string, force bool) (testComposer, error) {
+	var c testComposer
+	if existing, err := deps.Files.Read(out); err == nil && !force {
+		c.Existing = existing
+	}
+
+	siblings, err := filepath.Glob(filepath.Join(filepath.Dir(out), "*.go"))
+	if err != nil {
+		return c, err
+	}
+	abs, _ := filepath.Abs(out)
+	c.Declared = map[string][]string{}
+	for _, path := range siblings {
+		if p, _ := filepath.Abs(path); p == abs {
+			continue
+		}
+		src, err := deps.Files.Read(path)
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		c.Declared[file.Name.Name] = append(c.Declared[file.Name.Name], topLevelNames(file)...)
+	}
+	return c, nil
+}
+
+// compose returns the file content to write and the generated code after renaming
+// any top-level names that clash with the existing file or the rest of the package.
+func (c testComposer) compose(generated string) (merged, renamed string, err error) {
+	clause, err := parser.ParseFile(token.NewFileSet(), "generated_test.go", generated, parser.PackageClauseOnly)
+	if err != nil {
+		return "", "", fmt.Errorf("parse generated tests: %w", err)
+	}
+	taken := map[string]bool{}
+	for _, name := range c.Declared[clause.Name.Name] {
+		taken[name] = true
+	}
+	var existing *ast.File
+	fset := token.NewFileSet()
+	if strings.TrimSpace(c.Existing) != "" {
+		existing, err = parser.ParseFile(fset, "existing_test.go", c.Existing, parser.ParseComments)
+		if err != nil {
+			return "", "", fmt.Errorf("parse existing test file: %w", err)
+		}
+		for _, name := range topLevelNames(existing) {
+			taken[name] = true
+		}
+	}
+
+	renamed, err = renameTopLevel(generated, taken)
+	if err != nil {
+		return "", "", err
+	}
+	if existing == nil {
+		formatted, err := format.Source([]byte(renamed))
+		if err != nil {
+			return "", "", fmt.Errorf("format generated tests: %w", err)
+		}
+		return string(formatted), renamed, nil
+	}
+
+	gen, err := parser.ParseFile(token.NewFileSet(), "generated_test.go", renamed, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return "", "", err
+	}
+	if gen.Name.Name != existing.Name.Name {
+		return "", "", fmt.Errorf("generated tests are in package %s but the existing file is in package %s", gen.Name.Name, existing.Name.Name)
+	}
+	for _, spec := range gen.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		astutil.AddNamedImport(fset, existing, name, path)
+	}
+
+	var b bytes.Buffer
+	if err := format.Node(&b, fset, existing); err != nil {
+		return "", "", err
+	}
+	// Everything after the generated import block (declarations and free comments)
+	// is appended verbatim.
+	bodyStart := gen.Name.End()
+	for _, d := range gen.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			bodyStart = gd.End()
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(renamed[bodyStart-gen.FileStart:])
+	formatted, err := format.Source(b.Bytes())
+	if err != nil {
+		return "", "", fmt.Errorf("format merged tests: %w", err)
+	}
+	return string(formatted), renamed, nil
+}
+
+// topLevelNames lists the package-scope identifiers declared in file.
+func topLevelNames(file *ast.File) []string {
+	var names []string
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Name.Name != "init" {
+				names = append(names, d.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, s.Name.Name)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if n.Name != "_" {
+							names = append(names, n.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+// renameTopLevel renames top-level declarations of src found in taken, and every
+// reference to them, by appending the first free numeric suffix: TestCreate becomes
+// TestCreate2. Source text outside the renamed identifiers is left untouched.
+func renameTopLevel(src string, taken map[string]bool) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "generated_test.go", src, parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("parse generated tests: %w", err)
+	}
+
+	declared := map[string]bool{}
+	for _, name := range topLevelNames(file) {
+		declared[name] = true
+	}
+	renames := map[*ast.Object]string{}
+	for _, name := range topLevelNames(file) {
+		if !taken[name] {
+			continue
+		}
+		obj := file.Scope.Lookup(name)
+		if obj == nil {
+			// Functions are resolved in the package scope, not the file scope.
+			obj = findFuncObject(file, name)
+		}
+		if obj == nil {
+			continue
+		}
+		for n := 2; ; n++ {
+			candidate := fmt.Sprintf("%s%d", name, n)
+			if !taken[candidate] && !declared[candidate] {
+				renames[obj] = candidate
+				declared[candidate] = true
+				break
+			}
+		}
+	}
+	if len(renames) == 0 {
+		return src, nil
+	}
+
+	type edit struct {
+		offset int
+		length int
+		text   string
+	}
+	var edits []edit
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Obj == nil {
+			return true
+		}
+		if name, ok := renames[id.Obj]; ok {
+			edits = append(edits, edit{offset: fset.Position(id.Pos()).Offset, length: len(id.Name), text: name})
+		}
+		return true
+	})
+	sort.Slice(edits, func(i, j int) bool { return edits[i].offset > edits[j].offset })
+	out := src
+	for _, e := range edits {
+		out = out[:e.offset] + e.text + out[e.offset+e.length:]
+	}
+	return out, nil
+}
+
+func findFuncObject(file *ast.File, name string) *ast.Object {
+	for _, d := range file.Decls {
+		if fd, ok := d.(*ast.FuncDecl); ok && fd.Recv == nil && fd.Name.Name == name {
+			return fd.Name.Obj
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTestComposerMergesIntoExistingFile(t *testing.T) {
+	existing := `package demo
+
+import "testing"
+
+// TestDouble is hand-written and must survive.
+func TestDouble(t *testing.T) {
+	if Double(2) != 4 {
+		t.Fatal("bad")
+	}
+}
+`
+	generated := `package demo
+
+import (
+	"strings"
+	"testing"
+)
+
+type doubleCase struct{ in, want int }
+
+// TestDouble covers the table.
+func TestDouble(t *testing.T) {
+	for _, tc := range cases() {
+		if got := Double(tc.in); got != tc.want {
+			t.Fatalf("Double(%d) = %d", tc.in, got)
+		}
+	}
+	_ = strings.TrimSpace
+}
+
+func cases() []doubleCase { return []doubleCase{{1, 2}} }
+`
+	c := testComposer{Existing: existing, Declared: map[string][]string{
+		"demo":      {"Double", "cases"},
+		"demo_test": {"doubleCase"},
+	}}
+	merged, renamed, err := c.compose(generated)
+	if err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+	for _, want := range []string{
+		"// TestDouble is hand-written and must survive.\nfunc TestDouble(t *testing.T) {",
+		"// TestDouble covers the table.\nfunc TestDouble2(t *testing.T) {",
+		"for _, tc := range cases2() {",
+		"func cases2() []doubleCase {",
+		"import (\n\t\"strings\"\n\t\"testing\"\n)",
+	} {
+		if !strings.Contains(merged, want) {
+			t.Fatalf("expected %q in merged file:\n%s", want, merged)
+		}
+	}
+	if strings.Count(merged, "package demo") != 1 {
+		t.Fatalf("expected a single package clause:\n%s", merged)
+	}
+	if names := generatedTestNames(renamed); len(names) != 1 || names[0] != "TestDouble2" {
+		t.Fatalf("expected renamed test names, got %v", names)
+	}
+}
+
+func TestTestComposerRejectsPackageMismatch(t *testing.T) {
+	c := testComposer{Existing: "package demo_test\n"}
+	if _, _, err := c.compose("package demo\n\nfunc TestX(t *testing.T) {}\n"); err == nil {
+		t.Fatal("expected an error for mismatched packages")
+	}
+}
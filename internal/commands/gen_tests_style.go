@@ -0,0 +1,151 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// testStyle captures the libraries a module's tests are allowed to use.
+type testStyle struct {
+	// Assertions is "stdlib" or "testify".
+	Assertions string
+	// Mocks is the gomock import path, or "" for hand-written fakes.
+	Mocks string
+	// GoVersion is the go directive of the module, e.g. "1.24".
+	GoVersion string
+}
+
+var gomockModules = []string{"go.uber.org/mock", "github.com/golang/mock"}
+
+// detectTestStyle reads the go.mod governing dir: testify is only used when the module
+// already requires it, and gomock only when a gomock module is required.
+func detectTestStyle(deps Dependencies, dir string) testStyle {
+	style := testStyle{Assertions: "stdlib"}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return style
+	}
+	for {
+		path := filepath.Join(abs, "go.mod")
+		if data, err := deps.Files.ReadFile(path); err == nil {
+			mod, err := modfile.ParseLax(path, data, nil)
+			if err != nil {
+				return style
+			}
+			if mod.Go != nil {
+				style.GoVersion = mod.Go.Version
+			}
+			for _, req := range mod.Require {
+				switch {
+				case req.Mod.Path == "github.com/stretchr/testify":
+					style.Assertions = "testify"
+				case style.Mocks == "" && containsString(gomockModules, req.Mod.Path):
+					style.Mocks = req.Mod.Path + "/gomock"
+				}
+			}
+			return style
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return style
+		}
+		abs = parent
+	}
+}
+
+// resolveTestStyle applies the --style flag on top of the detected style.
+func resolveTestStyle(flag string, detected testStyle) (testStyle, error) {
+	style := detected
+	switch flag {
+	case "", "auto":
+	case "stdlib", "testify":
+		style.Assertions = flag
+		style.Mocks = ""
+	case "gomock":
+		if style.Mocks == "" {
+			style.Mocks = gomockModules[0] + "/gomock"
+		}
+	default:
+		return style, fmt.Errorf("unknown --style %q (want auto, stdlib, testify or gomock)", flag)
+	}
+	return style, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// testKind describes one flavour of generated test function.
+type testKind struct {
+	// Prefix is the function name prefix recognised by go test.
+	Prefix string
+	// FileSuffix distinguishes the default output file from unit tests.
+	FileSuffix string
+}
+
+var testKinds = map[string]testKind{
+	"unit":    {Prefix: "Test"},
+	"fuzz":    {Prefix: "Fuzz", FileSuffix: "_fuzz"},
+	"bench":   {Prefix: "Benchmark", FileSuffix: "_bench"},
+	"example": {Prefix: "Example", FileSuffix: "_example"},
+}
+
+// testFuncName returns the conventional name for a test of kind covering selector:
+// TestParse, FuzzService_Create, ExampleService_Create.
+func testFuncName(kind testKind, selector string) string {
+	return kind.Prefix + strings.ReplaceAll(selector, ".", "_")
+}
+
+// buildTestPrompt assembles the instructions for generating tests of kind in style.
+func buildTestPrompt(kindName string, style testStyle, selector, code string) string {
+	kind := testKinds[kindName]
+	name := testFuncName(kind, selector)
+
+	var b strings.Builder
+	switch kindName {
+	case "fuzz":
+		fmt.Fprintf(&b, "Write a Go fuzz target %s(f *testing.F) for %s.\n", name, selector)
+		b.WriteString("Seed the corpus with f.Add for typical values and edge cases (empty, zero, negative, very long, unicode, invalid input). ")
+		b.WriteString("Fuzz only parameters of types the fuzzer supports (string, []byte, bool, integer and float types) and build other arguments from them. ")
+		b.WriteString("In f.Fuzz assert properties that must hold for every input (no panic, round trips, invariants, error on invalid input) rather than exact outputs.\n")
+	case "bench":
+		fmt.Fprintf(&b, "Write Go benchmarks for %s named %s, with sub-benchmarks via b.Run for representative input sizes.\n", selector, name)
+		b.WriteString("Call b.ReportAllocs() and do all setup before the timed loop. ")
+		if style.GoVersion != "" && semver.Compare("v"+style.GoVersion, "v1.24") >= 0 {
+			b.WriteString("Use `for b.Loop() { ... }` as the timed loop; it times only the loop, so do not call b.ResetTimer().\n")
+		} else {
+			b.WriteString("Use `for i := 0; i < b.N; i++ { ... }` as the timed loop, call b.ResetTimer() just before it and keep results alive with a package-level sink variable.\n")
+		}
+	case "example":
+		fmt.Fprintf(&b, "Write runnable Go example functions for %s named %s (add _suffix variants for further scenarios).\n", selector, name)
+		b.WriteString("Each example must print its results with fmt and end with an exact `// Output:` comment so go test verifies it. Show idiomatic usage, as documentation would.\n")
+	default:
+		fmt.Fprintf(&b, "Write Go table-driven tests for %s named %s. Keep case names clear and cover error paths.\n", selector, name)
+	}
+
+	switch {
+	case kindName == "example":
+		// Examples are documentation; assertions and mocks would only get in the way.
+	case style.Assertions == "testify":
+		b.WriteString("Use github.com/stretchr/testify/require for checks that must stop the test and assert for the rest.\n")
+	default:
+		b.WriteString("Use only the standard library testing package: check with if statements and t.Errorf/t.Fatalf. Do not import testify or any other assertion library.\n")
+	}
+	if style.Mocks != "" && kindName == "unit" {
+		fmt.Fprintf(&b, "For interface dependencies use gomock from %s with gomock.NewController(t) and mocks generated by mockgen in this package (assume Mock<Interface> types exist).\n", style.Mocks)
+	} else if kindName != "example" {
+		b.WriteString("For interface dependencies write small hand-rolled fakes in the test file.\n")
+	}
+	b.WriteString("Return one complete Go test file with its package clause and imports.\n\n")
+	b.WriteString(code)
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+func TestDetectTestStyle(t *testing.T) {
+	dir := t.TempDir()
+	mod := "module example.com/demo\n\ngo 1.24\n\nrequire (\n\tgithub.com/stretchr/testify v1.9.0\n\tgo.uber.org/mock v0.5.0\n)\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "internal", "store")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	deps := Dependencies{Files: system.NewOSFileManager(strings.NewReader(""))}
+
+	style := detectTestStyle(deps, sub)
+	if style.Assertions != "testify" || style.Mocks != "go.uber.org/mock/gomock" || style.GoVersion != "1.24" {
+		t.Fatalf("unexpected style: %+v", style)
+	}
+	forced, err := resolveTestStyle("stdlib", style)
+	if err != nil || forced.Assertions != "stdlib" || forced.Mocks != "" {
+		t.Fatalf("expected --style stdlib to drop testify and gomock, got %+v (%v)", forced, err)
+	}
+	if _, err := resolveTestStyle("ginkgo", style); err == nil {
+		t.Fatal("expected an error for an unknown style")
+	}
+	if got := detectTestStyle(deps, t.TempDir()); got.Assertions != "stdlib" {
+		t.Fatalf("expected stdlib without go.mod, got %+v", got)
+	}
+}
+
+func TestBuildTestPrompt(t *testing.T) {
+	stdlib := testStyle{Assertions: "stdlib", GoVersion: "1.22"}
+	prompt := buildTestPrompt("unit", stdlib, "Service.Create", "func (s *Service) Create() {}")
+	if !strings.Contains(prompt, "TestService_Create") || !strings.Contains(prompt, "Do not import testify") {
+		t.Fatalf("unexpected unit prompt:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "require for checks") {
+		t.Fatalf("stdlib prompt must not ask for testify:\n%s", prompt)
+	}
+
+	prompt = buildTestPrompt("fuzz", testStyle{Assertions: "testify"}, "Parse", "")
+	if !strings.Contains(prompt, "FuzzParse(f *testing.F)") || !strings.Contains(prompt, "f.Add") || !strings.Contains(prompt, "testify/require") {
+		t.Fatalf("unexpected fuzz prompt:\n%s", prompt)
+	}
+
+	prompt = buildTestPrompt("bench", stdlib, "Parse", "")
+	if !strings.Contains(prompt, "BenchmarkParse") || !strings.Contains(prompt, "b.ReportAllocs()") || !strings.Contains(prompt, "b.N") || !strings.Contains(prompt, "b.ResetTimer() just before it") {
+		t.Fatalf("unexpected bench prompt:\n%s", prompt)
+	}
+	if prompt := buildTestPrompt("bench", testStyle{GoVersion: "1.24"}, "Parse", ""); !strings.Contains(prompt, "b.Loop()") || !strings.Contains(prompt, "do not call b.ResetTimer()") {
+		t.Fatalf("expected b.Loop for Go 1.24:\n%s", prompt)
+	}
+
+	prompt = buildTestPrompt("example", stdlib, "Parse", "")
+	if !strings.Contains(prompt, "ExampleParse") || !strings.Contains(prompt, "// Output:") {
+		t.Fatalf("unexpected example prompt:\n%s", prompt)
+	}
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/analysis"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// genTestJob is one function to generate tests for.
+type genTestJob struct {
+	File     string
+	Selector string
+	Out      string
+	// Cases optionally lists the scenarios the tests must cover.
+	Cases string
+}
+
+// defaultTestFile names the test file for selector next to file, e.g. Service.Create
+// in store/service.go becomes store/service_create_test.go, or with suffix "_fuzz"
+// store/service_create_fuzz_test.go.
+func defaultTestFile(file, selector, suffix string) string {
+	name := strings.ToLower(strings.ReplaceAll(textutil.NormalizeSelector(selector), ".", "_"))
+	return filepath.Join(filepath.Dir(file), name+suffix+"_test.go")
+}
+
+// testNameCandidates lists the test names that conventionally cover selector:
+// TestFoo, TestFoo_case, TestType_Method, TestTypeMethod and friends.
+func testNameCandidates(selector string) []string {
+	typ, method, isMethod := strings.Cut(selector, ".")
+	if !isMethod {
+		return []string{typ}
+	}
+	return []string{typ + "_" + method, typ + method, method}
+}
+
+// hasNamedTest reports whether any existing test with prefix (Test, Fuzz, Benchmark
+// or Example) appears to target selector.
+func hasNamedTest(selector string, tests []string, prefix string) bool {
+	candidates := testNameCandidates(selector)
+	for _, name := range tests {
+		rest, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimPrefix(rest, "_")
+		for _, c := range candidates {
+			if rest == c || strings.HasPrefix(rest, c+"_") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// uncoveredFunctions picks the coverage gaps where no statement ran, keyed by
+// "<file basename>:<line>".
+func uncoveredFunctions(gaps []analysis.FuncCoverage) map[string]bool {
+	uncovered := map[string]bool{}
+	for _, g := range gaps {
+		if g.Uncovered == g.Statements {
+			uncovered[fmt.Sprintf("%s:%d", filepath.Base(g.File), g.Line)] = true
+		}
+	}
+	return uncovered
+}
+
+// untestedFunctions filters refs down to those without tests. When coverage is
+// non-nil it decides; otherwise existing test names with prefix are matched against
+// selectors.
+func untestedFunctions(refs []analysis.FuncRef, tests []string, prefix string, coverage map[string]bool) []analysis.FuncRef {
+	var out []analysis.FuncRef
+	for _, ref := range refs {
+		if coverage != nil {
+			if coverage[fmt.Sprintf("%s:%d", filepath.Base(ref.File), ref.Line)] {
+				out = append(out, ref)
+			}
+			continue
+		}
+		if !hasNamedTest(ref.Selector, tests, prefix) {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package commands
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/analysis"
+)
+
+func TestDefaultTestFile(t *testing.T) {
+	got := defaultTestFile(filepath.Join("store", "service.go"), "(*Service).Create", "")
+	if want := filepath.Join("store", "service_create_test.go"); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+	got = defaultTestFile("parse.go", "Parse", "_fuzz")
+	if got != "parse_fuzz_test.go" {
+		t.Fatalf("expected parse_fuzz_test.go, got %s", got)
+	}
+}
+
+func TestUntestedFunctionsByName(t *testing.T) {
+	refs := []analysis.FuncRef{
+		{File: "svc.go", Line: 3, Selector: "Parse"},
+		{File: "svc.go", Line: 9, Selector: "Service.Create"},
+		{File: "svc.go", Line: 12, Selector: "Service.Delete"},
+		{File: "svc.go", Line: 20, Selector: "ParseAll"},
+	}
+	tests := []string{"TestParse_empty", "TestServiceCreate", "BenchmarkParseAll_large"}
+	got := untestedFunctions(refs, tests, "Test", nil)
+	if len(got) != 2 || got[0].Selector != "Service.Delete" || got[1].Selector != "ParseAll" {
+		t.Fatalf("unexpected untested functions: %+v", got)
+	}
+	got = untestedFunctions(refs, tests, "Benchmark", nil)
+	if len(got) != 3 || got[2].Selector != "Service.Delete" {
+		t.Fatalf("unexpected functions without benchmarks: %+v", got)
+	}
+}
+
+func TestUntestedFunctionsByCoverage(t *testing.T) {
+	// Parse is fully covered, so it is not among the gaps.
+	gaps := []analysis.FuncCoverage{
+		{File: "/src/demo/svc.go", Line: 9, Selector: "Service.Create", Statements: 4, Uncovered: 4},
+		{File: "/src/demo/svc.go", Line: 12, Selector: "Service.Delete", Statements: 4, Uncovered: 2},
+	}
+	refs := []analysis.FuncRef{
+		{File: "/src/demo/svc.go", Line: 3, Selector: "Parse"},
+		{File: "/src/demo/svc.go", Line: 9, Selector: "Service.Create"},
+		{File: "/src/demo/svc.go", Line: 12, Selector: "Service.Delete"},
+	}
+	got := untestedFunctions(refs, []string{"TestServiceCreate"}, "Test", uncoveredFunctions(gaps))
+	if len(got) != 1 || got[0].Selector != "Service.Create" {
+		t.Fatalf("unexpected untested functions: %+v", got)
+	}
+}
@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// testVerdict is the outcome of compiling and running a generated test file.
+type testVerdict struct {
+	Compiled bool
+	Passed   bool
+	Output   string
+}
+
+var testFuncPattern = regexp.MustCompile(`(?m)^func ((?:Test|Benchmark|Fuzz|Example)\w*)\(`)
+
+// generatedTestNames lists the top-level test functions declared in code.
+func generatedTestNames(code string) []string {
+	var names []string
+	for _, m := range testFuncPattern.FindAllStringSubmatch(code, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// runRegex builds a `go test -run` pattern matching exactly names.
+func runRegex(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, n := range names {
+		quoted = append(quoted, regexp.QuoteMeta(n))
+	}
+	return "^(" + strings.Join(quoted, "|") + ")$"
+}
+
+// verifyTestFile runs `go vet` and `go test -run` for the generated tests in dir.
+// The file compiled when `go test -c` builds the test binary: its exit status,
+// not its output, decides, so module and toolchain errors count as failures.
+func verifyTestFile(deps Dependencies, dir, code string) testVerdict {
+	names := generatedTestNames(code)
+	if buildOut, err := deps.Shell.Exec(dir, "go", "test", "-c", "-o", os.DevNull, "."); err != nil {
+		return testVerdict{Output: fmt.Sprintf("go test -c:\n%s\n", strings.TrimSpace(buildOut+"\n"+err.Error()))}
+	}
+	pattern := "^$"
+	if len(names) > 0 {
+		pattern = runRegex(names)
+	}
+
+	args := []string{"test", "-count=1", "-run", pattern}
+	for _, n := range names {
+		if strings.HasPrefix(n, "Benchmark") {
+			// One iteration is enough to prove a benchmark runs.
+			args = append(args, "-bench", pattern, "-benchtime=1x")
+			break
+		}
+	}
+	vetOut, vetErr := deps.Shell.Exec(dir, "go", "vet", ".")
+	testOut, testErr := deps.Shell.Exec(dir, "go", append(args, ".")...)
+
+	verdict := testVerdict{
+		Compiled: true,
+		Passed:   vetErr == nil && testErr == nil && len(names) > 0,
+	}
+	var b strings.Builder
+	if vetErr != nil {
+		fmt.Fprintf(&b, "go vet:\n%s\n", strings.TrimSpace(vetOut))
+	}
+	if testErr != nil {
+		fmt.Fprintf(&b, "go test:\n%s\n", strings.TrimSpace(testOut))
+	}
+	if len(names) == 0 {
+		b.WriteString("the file declares no Test functions\n")
+	}
+	verdict.Output = b.String()
+	return verdict
+}
+
+// verifyGeneratedTests writes code to out through composer, then compiles and runs it,
+// asking the model to repair it for up to maxRepairs rounds. Code that never compiles
+// is not kept: the previous content of out is restored, or the file removed if it did
+// not exist.
+func verifyGeneratedTests(ctx context.Context, cmd *cobra.Command, deps Dependencies, model, out, code, source string, composer testComposer, maxRepairs int) (testVerdict, error) {
+	previous, readErr := deps.Files.ReadFile(out)
+	dir := filepath.Dir(out)
+
+	var verdict testVerdict
+	for round := 0; ; round++ {
+		merged, renamed, err := composer.compose(code)
+		if err != nil {
+			// Unparseable output is a compile failure the model can repair.
+			verdict = testVerdict{Output: err.Error() + "\n"}
+		} else {
+			code = renamed
+			if err := deps.Files.WriteFile(out, merged); err != nil {
+				return verdict, err
+			}
+			deps.Logger.Info(cmd, "Compiling and running the generated tests (round %d). The moment of truth, again.", round+1)
+			verdict = verifyTestFile(deps, dir, code)
+		}
+		if verdict.Passed || round >= maxRepairs {
+			break
+		}
+
+		deps.Logger.Info(cmd, "The tests disappoint me. Requesting repair %d of %d.", round+1, maxRepairs)
+		const maxErrorLen = 6000
+		report := verdict.Output
+		if len(report) > maxErrorLen {
+			report = report[:maxErrorLen] + "\n... (truncated)"
+		}
+		prompt := fmt.Sprintf(`The Go test file below does not pass "go vet" and "go test". Fix it.
+Keep the package clause and the tests' intent; only import packages that exist in the standard library or the module.
+Return the complete corrected file only.
+
+Errors:
+%s
+
+Current test file:
+%s
+
+Code under test:
+%s`, report, code, source)
+		ans, err := deps.LLM.Generate(ctx, model, prompt)
+		if err != nil {
+			return verdict, err
+		}
+		code = textutil.NormalizeCode(ans)
+	}
+
+	if !verdict.Compiled {
+		if readErr == nil {
+			if err := deps.Files.WriteFile(out, string(previous)); err != nil {
+				return verdict, err
+			}
+		} else if err := deps.Files.Remove(out); err != nil {
+			return verdict, err
+		}
+	}
+	return verdict, nil
+}
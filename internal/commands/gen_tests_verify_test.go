@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+// scriptedLLM returns canned answers in order and records prompts.
+type scriptedLLM struct {
+	answers []string
+	prompts []string
+}
+
+func (s *scriptedLLM) Generate(_ context.Context, _, prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	if len(s.answers) == 0 {
+		return "", nil
+	}
+	ans := s.answers[0]
+	s.answers = s.answers[1:]
+	return ans, nil
+}
+
+func TestGeneratedTestNames(t *testing.T) {
+	code := "package x\n\nfunc TestA(t *testing.T) {}\nfunc helper() {}\nfunc BenchmarkB(b *testing.B) {}\n"
+	names := generatedTestNames(code)
+	if len(names) != 2 || names[0] != "TestA" || names[1] != "BenchmarkB" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+	if got := runRegex(names); got != "^(TestA|BenchmarkB)$" {
+		t.Fatalf("unexpected regex %q", got)
+	}
+}
+
+func TestVerifyGeneratedTests(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain not available")
+	}
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	writeFile("go.mod", "module example.com/demo\n\ngo 1.21\n")
+	writeFile("demo.go", "package demo\n\nfunc Double(n int) int { return n * 2 }\n")
+
+	broken := "package demo\n\nfunc TestDouble(t *testing.T) {\n\tif Double(2) != 4 {\n\t\tt.Fatal(\"bad\")\n\t}\n}\n"
+	fixed := "package demo\n\nimport \"testing\"\n\n" + strings.TrimPrefix(broken, "package demo\n\n")
+
+	llm := &scriptedLLM{answers: []string{"```go\n" + fixed + "```"}}
+	deps := Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Shell:  system.BashShell{},
+		Logger: logging.NewSheldonLogger(),
+	}
+	out := filepath.Join(dir, "double_test.go")
+
+	verdict, err := verifyGeneratedTests(context.Background(), nil, deps, "model", out, broken, "", testComposer{}, 2)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !verdict.Passed || len(llm.prompts) != 1 || !strings.Contains(llm.prompts[0], "undefined: testing") {
+		t.Fatalf("expected one repair round fed with compiler errors, got %#v prompts=%d", verdict, len(llm.prompts))
+	}
+
+	llm.answers = []string{broken}
+	other := filepath.Join(dir, "other_test.go")
+	verdict, err = verifyGeneratedTests(context.Background(), nil, deps, "model", other, strings.Replace(broken, "TestDouble", "TestOther", 1), "", testComposer{}, 1)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if verdict.Compiled || verdict.Passed {
+		t.Fatalf("expected compile failure, got %#v", verdict)
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Fatalf("expected non-compiling file to be removed, stat err=%v", err)
+	}
+
+	// Outside a module the go command fails before building anything; that is
+	// no compile either.
+	loose := filepath.Join(t.TempDir(), "double_test.go")
+	verdict, err = verifyGeneratedTests(context.Background(), nil, deps, "model", loose, fixed, "", testComposer{}, 0)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if verdict.Compiled || !strings.Contains(verdict.Output, "go.mod") {
+		t.Fatalf("expected a module error to count as a compile failure, got %#v", verdict)
+	}
+	if _, err := os.Stat(loose); !os.IsNotExist(err) {
+		t.Fatalf("expected the file outside a module to be removed, stat err=%v", err)
+	}
+}
@@ -4,46 +4,119 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/riskiramdan/ShELDon/internal/sqlmigration"
+	"github.com/riskiramdan/ShELDon/internal/sqlplan"
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+	"github.com/riskiramdan/ShELDon/internal/sqlworkload"
 	"github.com/riskiramdan/ShELDon/internal/textutil"
 )
 
 // NewIndexSuggestCommand proposes the most impactful index for a query.
 func NewIndexSuggestCommand(deps Dependencies) *cobra.Command {
 	var (
-		schemaCmd string
-		queryFile string
-		model     string
+		schemaPath   string
+		schemaQuery  bool
+		wlQuery      bool
+		schemaCmd    string
+		queryFiles   []string
+		workloadPath string
+		top          int
+		dialect      string
+		migration    string
+		migrationDir string
+		model        string
 	)
 
 	cmd := &cobra.Command{
 		Use:   "index-suggest",
-		Short: "Suggest the single most impactful index for a Postgres query",
+		Short: "Suggest the single most impactful index for a PostgreSQL, MySQL or SQLite query",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if queryFile == "" {
-				return errors.New("--query is required")
+			cmd.SilenceUsage = true
+			if schemaQuery {
+				_, err := fmt.Fprint(cmd.OutOrStdout(), sqlschema.CatalogQuery)
+				return err
 			}
-
-			deps.Logger.Info(cmd, "Loading query from %s. I trust it follows first normal form.", queryFile)
-			query, err := deps.Files.Read(queryFile)
+			if wlQuery {
+				_, err := fmt.Fprintln(cmd.OutOrStdout(), sqlworkload.StatStatementsQuery)
+				return err
+			}
+			switch {
+			case len(queryFiles) == 0 && workloadPath == "":
+				return errors.New("--query or --workload is required")
+			case len(queryFiles) > 0 && workloadPath != "":
+				return errors.New("--query and --workload are mutually exclusive")
+			case workloadPath != "" && schemaPath == "":
+				return errors.New("--workload needs --schema to map query columns to tables")
+			}
+			d, err := sqlplan.ParseDialect(dialect)
 			if err != nil {
 				return err
 			}
-			deps.Logger.Info(cmd, "Query length: %d characters. Suitable for academic peer review.", len(query))
+			if migration != "" && migration != "goose" && migration != "golang-migrate" {
+				return fmt.Errorf("unknown --migration %q (want goose or golang-migrate)", migration)
+			}
+			// The directory is created and read up front so that a bad --migration-dir
+			// fails before the model call, and new versions follow the existing ones.
+			var existing *sqlmigration.Set
+			if migration != "" {
+				if err := os.MkdirAll(migrationDir, 0o755); err != nil {
+					return err
+				}
+				existing, err = sqlmigration.Load(migrationDir)
+				switch {
+				case errors.Is(err, sqlmigration.ErrNoMigrations):
+					existing = nil
+				case err != nil:
+					return err
+				}
+			}
+
+			var schema *sqlschema.Schema
+			if schemaPath != "" {
+				text, err := deps.Files.Read(schemaPath)
+				if err != nil {
+					return err
+				}
+				schema, err = sqlschema.Load(text)
+				if err != nil {
+					return fmt.Errorf("%s: %w", schemaPath, err)
+				}
+				deps.Logger.Info(cmd, "Schema parsed: %d tables. I now know more about your database than HR does about you.", len(schema.Tables))
+			}
 
-			deps.Logger.Info(cmd, "If a schema command exists, I shall execute it with geologic punctuality.")
-			schema, err := deps.Shell.Run(schemaCmd)
-			if err != nil && schemaCmd != "" {
-				// Preserve original behaviour by ignoring failures but surfacing context.
-				fmt.Fprintf(cmd.ErrOrStderr(), "schema command error: %v\n", err)
-				schema = ""
-			} else if schemaCmd != "" {
-				deps.Logger.Info(cmd, "Schema details acquired. I now know more about your database than HR does about you.")
+			var (
+				queries []sqlQuery
+				prompt  string
+			)
+			if workloadPath != "" {
+				w, err := loadWorkload(workloadPath, deps)
+				if err != nil {
+					return err
+				}
+				deps.Logger.Info(cmd, "Workload: %d statements, %d distinct. Index sprawl ends today.", w.Statements, len(w.Queries))
+				rec := sqlworkload.Recommend(schema, w)
+				queries = workloadQueries(w)
+				writeWorkloadReport(cmd.OutOrStdout(), w, rec, top)
+				prompt = workloadPrompt(d, schema, w, rec, top)
+			} else {
+				for _, path := range queryFiles {
+					deps.Logger.Info(cmd, "Loading query from %s. I trust it follows first normal form.", path)
+					text, err := deps.Files.Read(path)
+					if err != nil {
+						return err
+					}
+					queries = append(queries, sqlQuery{Path: path, SQL: text})
+				}
+				prompt = indexQueryPrompt(cmd, deps, d, schema, schemaCmd, queries)
 			}
 
-			prompt := "Suggest the ONE most impactful index for this query. Explain write amplification & size tradeoff.\n\nCurrent schema/indexes (optional):\n" + schema + "\n\nQuery:\n" + query
 			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
 			defer cancel()
 
@@ -54,16 +127,78 @@ func NewIndexSuggestCommand(deps Dependencies) *cobra.Command {
 				return err
 			}
 
-			_, err = cmd.OutOrStdout().Write([]byte(ans))
-			if err == nil {
-				deps.Logger.Info(cmd, "Index advice delivered. Apply it before the optimizer files a complaint.")
+			if _, err := fmt.Fprintf(cmd.OutOrStdout(), "\n%s\n", strings.TrimSpace(ans)); err != nil {
+				return err
+			}
+			suggestions := buildIndexSuggestions(d, schema, suggestedIndexes(ans), queries)
+			writeIndexSuggestions(cmd.OutOrStdout(), suggestions)
+			deps.Logger.Info(cmd, "Index advice delivered. Apply it before the optimizer files a complaint.")
+			if migration == "" {
+				return nil
 			}
-			return err
+			at, written := time.Now(), 0
+			for _, sg := range suggestions {
+				if len(sg.Problems) > 0 {
+					deps.Logger.Info(cmd, "Declining to write a migration for %s: it failed the schema checks, and so would you.", sg.Index.Name)
+					continue
+				}
+				// Distinct versions keep the migrations in suggestion order.
+				version, err := migrationVersion(existing, at, written)
+				if err != nil {
+					return err
+				}
+				written++
+				files, err := indexMigration(migration, d, sg, version)
+				if err != nil {
+					return err
+				}
+				for _, f := range files {
+					path := filepath.Join(migrationDir, f.Name)
+					if err := deps.Files.WriteFile(path, f.Content); err != nil {
+						return fmt.Errorf("write migration: %w", err)
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", path)
+				}
+			}
+			return nil
 		},
 	}
 
+	cmd.Flags().StringVar(&schemaPath, "schema", "", "Schema file: pg_dump --schema-only / .schema / SHOW CREATE TABLE output, or the JSON from --schema-query ('-' for stdin)")
+	cmd.Flags().BoolVar(&schemaQuery, "schema-query", false, "Print the PostgreSQL catalog query whose JSON output --schema accepts, and exit")
 	cmd.Flags().StringVar(&schemaCmd, "schema-cmd", "", "Shell command to print schema/indexes (e.g. `psql -c \\d+ table`)")
-	cmd.Flags().StringVar(&queryFile, "query", "", "Path to SQL file")
+	_ = cmd.Flags().MarkDeprecated("schema-cmd", "use --schema with a pg_dump --schema-only file or the --schema-query JSON export")
+	cmd.Flags().StringArrayVar(&queryFiles, "query", nil, "Path to SQL file (repeatable; suggestions list the queries they serve)")
+	cmd.Flags().StringVar(&workloadPath, "workload", "", "Directory of .sql files or pg_stat_statements CSV export; recommends a minimal index set for all of it (needs --schema)")
+	cmd.Flags().BoolVar(&wlQuery, "workload-query", false, "Print the psql \\copy command that exports pg_stat_statements for --workload, and exit")
+	cmd.Flags().IntVar(&top, "top", 20, "Workload queries to list and send to the model")
+	cmd.Flags().StringVar(&dialect, "dialect", "postgres", "Database engine: postgres, mysql or sqlite")
+	cmd.Flags().StringVar(&migration, "migration", "", "Also write each valid suggestion as a migration: goose or golang-migrate")
+	cmd.Flags().StringVar(&migrationDir, "migration-dir", "migrations", "Directory for --migration files")
 	cmd.Flags().StringVar(&model, "model", "", "Override model")
 	return cmd
 }
+
+// indexQueryPrompt describes the --query files with the parsed schema or, without
+// one, the output of the deprecated --schema-cmd.
+func indexQueryPrompt(cmd *cobra.Command, deps Dependencies, d sqlplan.Dialect, schema *sqlschema.Schema, schemaCmd string, queries []sqlQuery) string {
+	query := queriesText(queries)
+	deps.Logger.Info(cmd, "Query length: %d characters. Suitable for academic peer review.", len(query))
+	if schema != nil {
+		tables := schema.Referenced(query)
+		redundant := schema.Redundant()
+		writeSchemaReport(cmd.OutOrStdout(), schema, tables, redundant)
+		return indexSchemaPrompt(d, queries, tables, redundant)
+	}
+
+	deps.Logger.Info(cmd, "If a schema command exists, I shall execute it with geologic punctuality.")
+	schemaText, err := deps.Shell.Run(schemaCmd)
+	if err != nil && schemaCmd != "" {
+		// Preserve original behaviour by ignoring failures but surfacing context.
+		fmt.Fprintf(cmd.ErrOrStderr(), "schema command error: %v\n", err)
+		schemaText = ""
+	} else if schemaCmd != "" {
+		deps.Logger.Info(cmd, "Schema details acquired. I now know more about your database than HR does about you.")
+	}
+	return "Suggest the ONE most impactful index for " + queriesNoun(d, queries) + ". Explain write amplification & size tradeoff.\n" + sqlDialectAdvice[d].Indexes + " Put the index DDL in a ```sql block, each statement ending with ';'.\n\nCurrent schema/indexes (optional):\n" + schemaText + "\n\nQuery:\n" + query
+}
@@ -0,0 +1,201 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlplan"
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// indexSuggestion is one index from the model's answer, rewritten as DDL that is
+// safe to run on the chosen engine.
+type indexSuggestion struct {
+	Table    string
+	Index    *sqlschema.Index
+	Create   string
+	Rollback string
+	// Problems and Estimate are only set when a schema was given.
+	Problems []sqlschema.Problem
+	Estimate *sqlschema.IndexEstimate
+	// Benefits lists the query files the index can serve and BenefitShare
+	// their fraction of the workload.
+	Benefits     []string
+	BenefitShare float64
+}
+
+// sqlQuery is a query file and its text.
+type sqlQuery struct {
+	Path string
+	SQL  string
+	// Share is the query's fraction of a workload; zero for --query files.
+	Share float64
+}
+
+func buildIndexSuggestions(d sqlplan.Dialect, s *sqlschema.Schema, stmts []string, queries []sqlQuery) []indexSuggestion {
+	var out []indexSuggestion
+	for _, stmt := range stmts {
+		table, ix, ok := sqlschema.ParseIndexDDL(stmt)
+		if !ok {
+			continue
+		}
+		if ix.Name == "" {
+			ix.Name = indexName(table, ix)
+		}
+		sg := indexSuggestion{Table: table, Index: ix}
+		sg.Create, sg.Rollback = onlineIndexDDL(d, stmt, table, ix)
+		if s != nil {
+			sg.Problems = s.Check(table, ix)
+			if t := s.Table(table); t != nil {
+				est := t.EstimateIndex(ix, d == sqlplan.MySQL)
+				sg.Estimate = &est
+			}
+		}
+		for _, q := range queries {
+			if sqlschema.Serves(table, ix, q.SQL) {
+				sg.Benefits = append(sg.Benefits, q.Path)
+				sg.BenefitShare += q.Share
+			}
+		}
+		out = append(out, sg)
+	}
+	return out
+}
+
+var identCleaner = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// indexName follows PostgreSQL's default naming, <table>_<columns>_idx, which
+// the rollback statement needs when the model left the index unnamed.
+func indexName(table string, ix *sqlschema.Index) string {
+	parts := []string{table[strings.LastIndex(table, ".")+1:]}
+	parts = append(parts, ix.Columns...)
+	return strings.Trim(identCleaner.ReplaceAllString(strings.ToLower(strings.Join(parts, "_")), "_"), "_") + "_idx"
+}
+
+var (
+	pgCreateIndexPattern = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:(\S+)\s+)?ON\s+`)
+	mysqlAddIndexPattern = regexp.MustCompile(`(?is)^(ALTER\s+TABLE\s+\S+\s+ADD\s+(?:UNIQUE\s+)?(?:INDEX|KEY))\s+(?:(\S+?)\s*)?\(`)
+	mysqlOnlineOptions   = regexp.MustCompile(`(?i)[,\s]+(?:ALGORITHM|LOCK)\s*=\s*\w+`)
+)
+
+// onlineIndexDDL rewrites the model's statement so it builds without blocking
+// writes where the engine can, names the index, and returns the matching DROP.
+func onlineIndexDDL(d sqlplan.Dialect, stmt, table string, ix *sqlschema.Index) (create, rollback string) {
+	stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+	switch d {
+	case sqlplan.MySQL:
+		stmt = mysqlOnlineOptions.ReplaceAllString(stmt, "")
+		if m := mysqlAddIndexPattern.FindStringSubmatchIndex(stmt); m != nil {
+			stmt = stmt[:m[3]] + " " + ix.Name + " (" + stmt[m[1]:]
+			return stmt + ", ALGORITHM=INPLACE, LOCK=NONE;", fmt.Sprintf("ALTER TABLE %s DROP INDEX %s, ALGORITHM=INPLACE, LOCK=NONE;", table, ix.Name)
+		}
+		return stmt + " ALGORITHM=INPLACE LOCK=NONE;", fmt.Sprintf("DROP INDEX %s ON %s ALGORITHM=INPLACE LOCK=NONE;", ix.Name, table)
+	case sqlplan.SQLite:
+		stmt = createIndexPrefix(stmt, ix, "IF NOT EXISTS ")
+		return stmt + ";", fmt.Sprintf("DROP INDEX IF EXISTS %s;", qualifiedIndex(table, ix))
+	}
+	stmt = createIndexPrefix(stmt, ix, "CONCURRENTLY IF NOT EXISTS ")
+	return stmt + ";", fmt.Sprintf("DROP INDEX CONCURRENTLY IF EXISTS %s;", qualifiedIndex(table, ix))
+}
+
+// createIndexPrefix rewrites "CREATE [UNIQUE] INDEX [name] ON" with the given
+// options and the index name.
+func createIndexPrefix(stmt string, ix *sqlschema.Index, options string) string {
+	m := pgCreateIndexPattern.FindStringSubmatchIndex(stmt)
+	if m == nil {
+		return stmt
+	}
+	prefix := "CREATE INDEX "
+	if m[2] >= 0 {
+		prefix = "CREATE UNIQUE INDEX "
+	}
+	return prefix + options + ix.Name + " ON " + stmt[m[1]:]
+}
+
+// qualifiedIndex puts the index in its table's schema: DROP INDEX resolves
+// unqualified names through the search path.
+func qualifiedIndex(table string, ix *sqlschema.Index) string {
+	if i := strings.LastIndex(table, "."); i >= 0 && !strings.Contains(ix.Name, ".") {
+		return table[:i+1] + ix.Name
+	}
+	return ix.Name
+}
+
+// writeIndexSuggestions prints each suggestion as ready-to-run DDL with its
+// rollback, estimated size, the queries it serves and the schema checks.
+func writeIndexSuggestions(w io.Writer, suggestions []indexSuggestion) {
+	fmt.Fprintln(w, "\nSuggested indexes:")
+	if len(suggestions) == 0 {
+		fmt.Fprintln(w, "- no index statement found in the answer")
+		return
+	}
+	for i, sg := range suggestions {
+		fmt.Fprintf(w, "\n%d. %s on %s\n", i+1, sg.Index.Name, sg.Table)
+		fmt.Fprintf(w, "   Create:   %s\n", sg.Create)
+		fmt.Fprintf(w, "   Rollback: %s\n", sg.Rollback)
+		fmt.Fprintf(w, "   Size:     %s\n", indexSize(sg))
+		benefits := "none of the given queries filter, join or sort on its leading column"
+		switch {
+		case sg.BenefitShare > 0:
+			benefits = fmt.Sprintf("%d queries, %.1f%% of the workload: %s", len(sg.Benefits), sg.BenefitShare*100, strings.Join(sg.Benefits, ", "))
+		case len(sg.Benefits) > 0:
+			benefits = strings.Join(sg.Benefits, ", ")
+		}
+		fmt.Fprintf(w, "   Benefits: %s\n", benefits)
+		switch {
+		case sg.Estimate == nil && sg.Problems == nil:
+			fmt.Fprintln(w, "   Checks:   not validated; pass --schema")
+		case len(sg.Problems) == 0:
+			fmt.Fprintln(w, "   Checks:   ok")
+		default:
+			for _, p := range sg.Problems {
+				fmt.Fprintf(w, "   Problem:  %s: %s\n", p.Kind, p.Message)
+			}
+		}
+	}
+}
+
+func indexSize(sg indexSuggestion) string {
+	switch {
+	case sg.Estimate == nil:
+		return "unknown; pass --schema with row estimates (the --schema-query JSON)"
+	case sg.Estimate.Bytes == 0:
+		return fmt.Sprintf("unknown; the schema has no row estimate for %s (%d B per entry)", sg.Table, sg.Estimate.EntryBytes)
+	}
+	size := fmt.Sprintf("~%s (%.0f rows × %d B per entry)", sqlschema.FormatBytes(sg.Estimate.Bytes), sg.Estimate.Rows, sg.Estimate.EntryBytes)
+	if sg.Index.Where != "" {
+		size += ", less by the rows the WHERE clause excludes"
+	}
+	return size
+}
+
+// migrationFile is a migration file name and its contents.
+type migrationFile struct {
+	Name, Content string
+}
+
+// indexMigration renders a suggestion as migration files for golang-migrate
+// (<version>_<name>.up.sql and .down.sql) or goose (<version>_<name>.sql).
+// Each file holds one statement: PostgreSQL refuses CONCURRENTLY inside a
+// transaction, so goose gets NO TRANSACTION and golang-migrate must not run a
+// multi-statement file.
+func indexMigration(format string, d sqlplan.Dialect, sg indexSuggestion, version string) ([]migrationFile, error) {
+	name := version + "_add_" + identCleaner.ReplaceAllString(strings.ToLower(sg.Index.Name), "_")
+	switch format {
+	case "golang-migrate":
+		return []migrationFile{
+			{Name: name + ".up.sql", Content: sg.Create + "\n"},
+			{Name: name + ".down.sql", Content: sg.Rollback + "\n"},
+		}, nil
+	case "goose":
+		var b strings.Builder
+		if d != sqlplan.SQLite {
+			b.WriteString("-- +goose NO TRANSACTION\n")
+		}
+		fmt.Fprintf(&b, "-- +goose Up\n%s\n\n-- +goose Down\n%s\n", sg.Create, sg.Rollback)
+		return []migrationFile{{Name: name + ".sql", Content: b.String()}}, nil
+	}
+	return nil, fmt.Errorf("unknown migration format %q (want goose or golang-migrate)", format)
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlplan"
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// writeSchemaReport prints what the schema alone says: its size, the tables the
+// query touches and the indexes that are already redundant.
+func writeSchemaReport(w io.Writer, s *sqlschema.Schema, tables []*sqlschema.Table, redundant []sqlschema.Redundancy) {
+	indexes := 0
+	for _, t := range s.Tables {
+		indexes += len(t.Indexes)
+	}
+	names := make([]string, 0, len(tables))
+	for _, t := range tables {
+		names = append(names, t.QualifiedName())
+	}
+	fmt.Fprintf(w, "Schema: %d tables, %d indexes; the query references %s\n", len(s.Tables), indexes, strings.Join(names, ", "))
+	if len(redundant) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Redundant indexes:")
+	for _, r := range redundant {
+		fmt.Fprintf(w, "- %s\n", r)
+	}
+}
+
+func indexSchemaPrompt(d sqlplan.Dialect, queries []sqlQuery, tables []*sqlschema.Table, redundant []sqlschema.Redundancy) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Suggest the ONE most impactful index for %s. Explain write amplification & size tradeoff.\n", queriesNoun(d, queries))
+	b.WriteString(sqlDialectAdvice[d].Indexes + "\n")
+	b.WriteString("Use only the tables and columns listed below, and do not propose an index an existing one already covers. Put the index DDL in a ```sql block, each statement ending with ';'.\n")
+	b.WriteString("\nSchema of the tables the query references:\n")
+	for _, t := range tables {
+		writeSchemaTable(&b, t)
+	}
+	if len(redundant) > 0 {
+		b.WriteString("\nAlready redundant (mention if dropping them offsets the new index):\n")
+		for _, r := range redundant {
+			fmt.Fprintf(&b, "- %s\n", r)
+		}
+	}
+	b.WriteString("\nQuery:\n" + queriesText(queries))
+	return b.String()
+}
+
+func queriesNoun(d sqlplan.Dialect, queries []sqlQuery) string {
+	if len(queries) == 1 {
+		return "this " + d.Name() + " query"
+	}
+	return "these " + d.Name() + " queries together"
+}
+
+// queriesText is the query itself or, for several, each preceded by its file name.
+func queriesText(queries []sqlQuery) string {
+	if len(queries) == 1 {
+		return queries[0].SQL
+	}
+	var b strings.Builder
+	for _, q := range queries {
+		fmt.Fprintf(&b, "-- %s\n%s\n\n", q.Path, strings.TrimSpace(q.SQL))
+	}
+	return b.String()
+}
+
+func writeSchemaTable(b *strings.Builder, t *sqlschema.Table) {
+	b.WriteString("\n" + t.QualifiedName())
+	var size []string
+	if t.Rows > 0 {
+		size = append(size, fmt.Sprintf("~%.0f rows", t.Rows))
+	}
+	if t.Bytes > 0 {
+		size = append(size, sqlschema.FormatBytes(t.Bytes))
+	}
+	if len(size) > 0 {
+		b.WriteString(" (" + strings.Join(size, ", ") + ")")
+	}
+	b.WriteString("\nColumns:\n")
+	for _, c := range t.Columns {
+		fmt.Fprintf(b, "- %s %s", c.Name, c.Type)
+		if c.NotNull {
+			b.WriteString(" NOT NULL")
+		}
+		if st := c.Stats; st != nil {
+			fmt.Fprintf(b, " [distinct ~%.0f, %.0f%% null, correlation %.2f]", st.Distinct(t.Rows), st.NullFrac*100, st.Correlation)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Indexes:\n")
+	if len(t.Indexes) == 0 {
+		b.WriteString("- none\n")
+	}
+	for _, ix := range t.Indexes {
+		fmt.Fprintf(b, "- %s: %s", ix.Name, ix.Definition())
+		if ix.Bytes > 0 {
+			b.WriteString(", " + sqlschema.FormatBytes(ix.Bytes))
+		}
+		b.WriteString("\n")
+	}
+}
+
+var sqlFencePattern = regexp.MustCompile("(?s)```(?:sql)?\\s*\n(.*?)```")
+
+// suggestedIndexes extracts the index statements from the model's answer: from
+// its ```sql blocks or, when it used none, from the whole text.
+func suggestedIndexes(ans string) []string {
+	blocks := []string{ans}
+	if m := sqlFencePattern.FindAllStringSubmatch(ans, -1); m != nil {
+		blocks = blocks[:0]
+		for _, block := range m {
+			blocks = append(blocks, block[1])
+		}
+	}
+	var stmts []string
+	for _, block := range blocks {
+		for _, stmt := range sqlschema.SplitStatements(block) {
+			if _, _, ok := sqlschema.ParseIndexDDL(stmt); ok {
+				stmts = append(stmts, strings.Join(strings.Fields(stmt), " "))
+			}
+		}
+	}
+	return stmts
+}
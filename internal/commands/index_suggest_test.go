@@ -0,0 +1,267 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/sqlplan"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+const indexSchema = `
+CREATE TABLE public.orders (
+    id bigint NOT NULL,
+    customer_id bigint NOT NULL,
+    status text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE TABLE public.customers (
+    id bigint NOT NULL,
+    email text NOT NULL
+);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_pkey PRIMARY KEY (id);
+
+CREATE INDEX orders_status_idx ON public.orders USING btree (status);
+CREATE INDEX orders_status_created_idx ON public.orders USING btree (status, created_at);
+`
+
+const indexAnswer = "Filter and sort come from orders.\n\n```sql\n" +
+	"CREATE INDEX CONCURRENTLY orders_customer_status_idx ON public.orders (customer_id, status);\n" +
+	"CREATE INDEX CONCURRENTLY orders_region_idx ON public.orders (region);\n" +
+	"CREATE INDEX CONCURRENTLY orders_status_only_idx ON public.orders (status);\n" +
+	"```\n"
+
+func TestIndexSuggestSchema(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "schema.sql")
+	queryPath := filepath.Join(dir, "query.sql")
+	if err := os.WriteFile(schemaPath, []byte(indexSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(queryPath, []byte("SELECT * FROM orders WHERE customer_id = $1 AND status = 'paid';"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	llm := &scriptedLLM{answers: []string{indexAnswer}}
+	cmd := NewIndexSuggestCommand(Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Logger: logging.NewSheldonLogger(),
+	})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--schema", schemaPath, "--query", queryPath})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("index-suggest: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"Schema: 2 tables, 3 indexes; the query references public.orders\n",
+		"- orders_status_idx on public.orders is a prefix of orders_status_created_idx",
+		"1. orders_customer_status_idx on public.orders\n" +
+			"   Create:   CREATE INDEX CONCURRENTLY IF NOT EXISTS orders_customer_status_idx ON public.orders (customer_id, status);\n" +
+			"   Rollback: DROP INDEX CONCURRENTLY IF EXISTS public.orders_customer_status_idx;\n" +
+			"   Size:     unknown; the schema has no row estimate for public.orders (52 B per entry)\n" +
+			"   Benefits: " + queryPath + "\n" +
+			"   Checks:   ok\n",
+		"   Problem:  column: column region does not exist on public.orders\n",
+		"   Problem:  covered: already covered by orders_status_idx: btree (status)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+	prompt := llm.prompts[0]
+	for _, want := range []string{
+		"- customer_id bigint NOT NULL\n",
+		"- orders_pkey: PRIMARY KEY (id)\n",
+		"Use only the tables and columns listed below",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "public.customers") {
+		t.Fatalf("prompt should only describe referenced tables:\n%s", prompt)
+	}
+}
+
+func TestOnlineIndexDDL(t *testing.T) {
+	cases := []struct {
+		dialect          sqlplan.Dialect
+		stmt             string
+		create, rollback string
+	}{
+		{sqlplan.Postgres, "create unique index on public.users (lower(email))",
+			"CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS users_lower_email_idx ON public.users (lower(email));",
+			"DROP INDEX CONCURRENTLY IF EXISTS public.users_lower_email_idx;"},
+		{sqlplan.MySQL, "ALTER TABLE orders ADD INDEX (customer_id, status), ALGORITHM=COPY;",
+			"ALTER TABLE orders ADD INDEX orders_customer_id_status_idx (customer_id, status), ALGORITHM=INPLACE, LOCK=NONE;",
+			"ALTER TABLE orders DROP INDEX orders_customer_id_status_idx, ALGORITHM=INPLACE, LOCK=NONE;"},
+		{sqlplan.MySQL, "CREATE INDEX idx_status ON orders (status)",
+			"CREATE INDEX idx_status ON orders (status) ALGORITHM=INPLACE LOCK=NONE;",
+			"DROP INDEX idx_status ON orders ALGORITHM=INPLACE LOCK=NONE;"},
+		{sqlplan.SQLite, "CREATE INDEX idx_status ON orders (status) WHERE status <> 'done'",
+			"CREATE INDEX IF NOT EXISTS idx_status ON orders (status) WHERE status <> 'done';",
+			"DROP INDEX IF EXISTS idx_status;"},
+	}
+	for _, tc := range cases {
+		sgs := buildIndexSuggestions(tc.dialect, nil, []string{tc.stmt}, nil)
+		if len(sgs) != 1 {
+			t.Fatalf("%s: expected one suggestion, got %d", tc.stmt, len(sgs))
+		}
+		if sgs[0].Create != tc.create || sgs[0].Rollback != tc.rollback {
+			t.Fatalf("%s:\ncreate   %s\nrollback %s", tc.stmt, sgs[0].Create, sgs[0].Rollback)
+		}
+	}
+}
+
+const indexCatalog = `{
+  "tables": [{"schema": "public", "table": "orders", "rows": 1200000, "bytes": 188743680}],
+  "columns": [
+    {"schema": "public", "table": "orders", "column": "id", "type": "bigint", "nullable": false},
+    {"schema": "public", "table": "orders", "column": "customer_id", "type": "bigint", "nullable": false},
+    {"schema": "public", "table": "orders", "column": "status", "type": "text", "nullable": false}
+  ],
+  "indexes": [{"schema": "public", "table": "orders", "name": "orders_pkey", "definition": "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id)", "bytes": 26968064}],
+  "stats": [{"schema": "public", "table": "orders", "column": "status", "null_frac": 0, "n_distinct": 4, "correlation": 0.1, "avg_width": 5}]
+}`
+
+func TestIndexSuggestMigration(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"schema.json":     indexCatalog,
+		"by_customer.sql": "SELECT * FROM orders WHERE customer_id = $1 AND status = 'paid'",
+		"by_status.sql":   "SELECT count(*) FROM orders WHERE status = 'paid'",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	answer := "```sql\nCREATE INDEX orders_customer_status_idx ON orders (customer_id, status);\n```"
+	llm := &scriptedLLM{answers: []string{answer, answer}}
+	cmd := NewIndexSuggestCommand(Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Logger: logging.NewSheldonLogger(),
+	})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--schema", filepath.Join(dir, "schema.json"),
+		"--query", filepath.Join(dir, "by_customer.sql"), "--query", filepath.Join(dir, "by_status.sql"),
+		"--migration", "goose", "--migration-dir", filepath.Join(dir, "db", "migrations")})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("index-suggest: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"   Size:     ~35.6 MB (1200000 rows × 28 B per entry)\n",
+		"   Benefits: " + filepath.Join(dir, "by_customer.sql") + "\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+	if !strings.Contains(llm.prompts[0], "-- "+filepath.Join(dir, "by_status.sql")) {
+		t.Fatalf("prompt should name each query file:\n%s", llm.prompts[0])
+	}
+	written, _ := filepath.Glob(filepath.Join(dir, "db", "migrations", "*_add_orders_customer_status_idx.sql"))
+	if len(written) != 1 {
+		t.Fatalf("expected one goose migration, got %v", written)
+	}
+	migration, err := os.ReadFile(written[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-- +goose NO TRANSACTION\n-- +goose Up\n" +
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS orders_customer_status_idx ON orders (customer_id, status);\n\n" +
+		"-- +goose Down\nDROP INDEX CONCURRENTLY IF EXISTS orders_customer_status_idx;\n"
+	if string(migration) != want {
+		t.Fatalf("unexpected migration:\n%s", migration)
+	}
+
+	// A sequentially numbered directory gets the next number, not a timestamp.
+	seq := filepath.Join(dir, "seq")
+	if err := os.MkdirAll(seq, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(seq, "0007_init.sql"), []byte("-- +goose Up\nCREATE TABLE orders (id bigint);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = NewIndexSuggestCommand(Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Logger: logging.NewSheldonLogger(),
+	})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--schema", filepath.Join(dir, "schema.json"),
+		"--query", filepath.Join(dir, "by_customer.sql"), "--migration", "goose", "--migration-dir", seq})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("index-suggest: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(seq, "0008_add_orders_customer_status_idx.sql")); err != nil {
+		t.Fatalf("expected the next sequential version: %v", err)
+	}
+}
+
+func TestIndexSuggestWorkload(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"schema.sql": indexSchema,
+		"workload.csv": "query,calls,total_exec_time\n" +
+			"\"SELECT * FROM orders WHERE customer_id = $1 AND status = $2\",900,4500\n" +
+			"\"SELECT * FROM orders WHERE customer_id = $1 ORDER BY created_at\",300,4000\n" +
+			"\"SELECT * FROM customers WHERE email = $1\",100,1500\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	llm := &scriptedLLM{answers: []string{"```sql\nCREATE INDEX ON public.orders (customer_id, status);\n```"}}
+	cmd := NewIndexSuggestCommand(Dependencies{
+		Config: &config.Config{},
+		LLM:    llm,
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Logger: logging.NewSheldonLogger(),
+	})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--schema", filepath.Join(dir, "schema.sql"), "--workload", filepath.Join(dir, "workload.csv")})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("index-suggest: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"Workload: 3 statements, 3 distinct queries, weighted by total time (10.0 s)\n",
+		"Index set: 2 indexes serve 100.0% of the workload\n",
+		"1. public.orders (customer_id, status): 2 queries, 85.0%  [shared]\n",
+		"2. public.customers (email): 1 queries, 15.0%\n",
+		"   Benefits: 2 queries, 85.0% of the workload: #1 ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+	if !strings.Contains(llm.prompts[0], "Candidate index set derived from the queries' predicates") {
+		t.Fatalf("prompt should carry the candidate index set:\n%s", llm.prompts[0])
+	}
+}
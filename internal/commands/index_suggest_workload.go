@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlplan"
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+	"github.com/riskiramdan/ShELDon/internal/sqlworkload"
+)
+
+// loadWorkload reads a directory of .sql files or a pg_stat_statements CSV.
+func loadWorkload(path string, deps Dependencies) (*sqlworkload.Workload, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return sqlworkload.LoadDir(path)
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".csv") {
+		return nil, fmt.Errorf("--workload takes a directory of .sql files or a pg_stat_statements .csv export, not %s", path)
+	}
+	text, err := deps.Files.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := sqlworkload.ParseStatStatements(text, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+// workloadLabel names a query by its rank and fingerprint, "#3 1f0c9a2b4e7d".
+func workloadLabel(w *sqlworkload.Workload, q *sqlworkload.Query) string {
+	for i, other := range w.Queries {
+		if other == q {
+			return fmt.Sprintf("#%d %s", i+1, q.Fingerprint)
+		}
+	}
+	return q.Fingerprint
+}
+
+// workloadQueries are the workload's queries as the suggestions report which
+// ones an index serves.
+func workloadQueries(w *sqlworkload.Workload) []sqlQuery {
+	queries := make([]sqlQuery, 0, len(w.Queries))
+	for _, q := range w.Queries {
+		queries = append(queries, sqlQuery{Path: workloadLabel(w, q), SQL: q.Normalized, Share: w.Share(q)})
+	}
+	return queries
+}
+
+func workloadBasis(w *sqlworkload.Workload) string {
+	if !w.Timed() {
+		return "calls"
+	}
+	total := 0.0
+	for _, q := range w.Queries {
+		total += q.TotalTime
+	}
+	return fmt.Sprintf("total time (%.1f s)", total/1000)
+}
+
+// writeWorkloadReport prints the heaviest queries and the index set picked from
+// their predicates; the output depends only on the workload and the schema.
+func writeWorkloadReport(w io.Writer, wl *sqlworkload.Workload, rec *sqlworkload.Recommendation, top int) {
+	fmt.Fprintf(w, "Workload: %d statements, %d distinct queries, weighted by %s\n\n", wl.Statements, len(wl.Queries), workloadBasis(wl))
+	fmt.Fprintf(w, "%-4s  %6s  %8s  %9s  %-12s  %s\n", "RANK", "SHARE", "CALLS", "MEAN MS", "FINGERPRINT", "QUERY")
+	for i, q := range wl.Queries {
+		if top > 0 && i >= top {
+			fmt.Fprintf(w, "... %d more\n", len(wl.Queries)-top)
+			break
+		}
+		mean := "-"
+		if q.TotalTime > 0 {
+			mean = fmt.Sprintf("%.3f", q.MeanTime())
+		}
+		fmt.Fprintf(w, "%-4d  %5.1f%%  %8.0f  %9s  %-12s  %s\n", i+1, wl.Share(q)*100, q.Calls, mean, q.Fingerprint, truncateQuery(q.Normalized, 100))
+	}
+
+	fmt.Fprintf(w, "\nIndex set: %d indexes serve %.1f%% of the workload\n", len(rec.Picks), rec.Share*100)
+	for i, p := range rec.Picks {
+		shared := ""
+		if p.Shared() {
+			shared = "  [shared]"
+		}
+		fmt.Fprintf(w, "%d. %s (%s): %d queries, %.1f%%%s\n", i+1, p.Table.QualifiedName(), strings.Join(p.Columns, ", "), len(p.Queries), p.Share*100, shared)
+		fmt.Fprintf(w, "   %s\n", strings.Join(workloadLabels(wl, p.Queries), ", "))
+	}
+	if len(rec.Existing) > 0 {
+		fmt.Fprintf(w, "Already served by existing indexes: %s\n", strings.Join(workloadLabels(wl, rec.Existing), ", "))
+	}
+	if len(rec.Unindexed) > 0 {
+		fmt.Fprintf(w, "No filter, join or sort on a known table: %s\n", strings.Join(workloadLabels(wl, rec.Unindexed), ", "))
+	}
+}
+
+func workloadLabels(w *sqlworkload.Workload, queries []*sqlworkload.Query) []string {
+	labels := make([]string, 0, len(queries))
+	for _, q := range queries {
+		labels = append(labels, workloadLabel(w, q))
+	}
+	return labels
+}
+
+func truncateQuery(q string, n int) string {
+	if len(q) <= n {
+		return q
+	}
+	return q[:n-3] + "..."
+}
+
+func workloadPrompt(d sqlplan.Dialect, s *sqlschema.Schema, wl *sqlworkload.Workload, rec *sqlworkload.Recommendation, top int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Recommend a minimal set of indexes for this whole %s workload rather than one index per query. Prefer one index that serves several queries through its leading columns over several narrow ones, and weigh each index's write amplification and size against the share of the workload it serves.\n", d.Name())
+	b.WriteString(sqlDialectAdvice[d].Indexes + "\n")
+	b.WriteString("Use only the tables and columns listed below, and do not propose an index an existing one already covers. Put the index DDL in a ```sql block, each statement ending with ';'.\n")
+
+	queries := wl.Queries
+	if top > 0 && len(queries) > top {
+		queries = queries[:top]
+	}
+	fmt.Fprintf(&b, "\nWorkload: %d distinct queries weighted by %s; the heaviest %d:\n", len(wl.Queries), workloadBasis(wl), len(queries))
+	var texts []string
+	for _, q := range queries {
+		fmt.Fprintf(&b, "%s, %.1f%% of the workload, %.0f calls", workloadLabel(wl, q), wl.Share(q)*100, q.Calls)
+		if q.TotalTime > 0 {
+			fmt.Fprintf(&b, ", mean %.3f ms", q.MeanTime())
+		}
+		fmt.Fprintf(&b, ":\n%s\n", q.Normalized)
+		texts = append(texts, q.Normalized)
+	}
+
+	b.WriteString("\nCandidate index set derived from the queries' predicates (review it: merge, reorder or drop entries, and say why):\n")
+	for i, p := range rec.Picks {
+		fmt.Fprintf(&b, "%d. %s (%s) serves %s (%.1f%%)\n", i+1, p.Table.QualifiedName(), strings.Join(p.Columns, ", "), strings.Join(workloadLabels(wl, p.Queries), ", "), p.Share*100)
+	}
+	if len(rec.Picks) == 0 {
+		b.WriteString("- none\n")
+	}
+	if len(rec.Existing) > 0 {
+		fmt.Fprintf(&b, "Already served by existing indexes: %s\n", strings.Join(workloadLabels(wl, rec.Existing), ", "))
+	}
+
+	b.WriteString("\nSchema of the tables the workload references:\n")
+	for _, t := range s.Referenced(strings.Join(texts, "\n")) {
+		writeSchemaTable(&b, t)
+	}
+	if redundant := s.Redundant(); len(redundant) > 0 {
+		b.WriteString("\nAlready redundant (mention if dropping them offsets the new indexes):\n")
+		for _, r := range redundant {
+			fmt.Fprintf(&b, "- %s\n", r)
+		}
+	}
+	return b.String()
+}
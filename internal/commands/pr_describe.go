@@ -0,0 +1,292 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/riskiramdan/ShELDon/internal/git"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+	"github.com/riskiramdan/ShELDon/internal/unidiff"
+)
+
+// prTemplateCandidates lists the locations GitHub checks for a pull request template.
+var prTemplateCandidates = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+}
+
+// NewPRDescribeCommand writes a PR title and body for the current branch.
+func NewPRDescribeCommand(deps Dependencies) *cobra.Command {
+	var (
+		base     string
+		template string
+		out      string
+		model    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "pr-describe",
+		Short: "Generate a PR title and description from the branch commits and diff",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			mergeBase, err := deps.Git.MergeBase(base, "HEAD")
+			if err != nil {
+				return err
+			}
+			deps.Logger.Info(cmd, "Merge base with %s is %s. Establishing the historical record.", base, shortHash(mergeBase))
+
+			commits, err := deps.Git.Log(mergeBase + "..HEAD")
+			if err != nil {
+				return err
+			}
+			diff, err := deps.Git.Diff(mergeBase + "..HEAD")
+			if err != nil {
+				return err
+			}
+			if strings.TrimSpace(diff) == "" {
+				return errors.New("no diff vs base")
+			}
+			files, err := unidiff.Parse(diff)
+			if err != nil {
+				return err
+			}
+			deps.Logger.Info(cmd, "Catalogued %d commits touching %d files.", len(commits), len(files))
+
+			var sections []templateSection
+			if template != "" {
+				sections, err = loadPRTemplate(deps, template)
+				if err != nil {
+					return err
+				}
+				deps.Logger.Info(cmd, "Template with %d sections located. Filling in the bureaucracy.", len(sections))
+			}
+
+			const maxDescribeDiffLen = 16000
+			changeContext := describeContext(commits, files, unidiff.Render(files, unidiff.RenderOptions{MaxBytes: maxDescribeDiffLen}))
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+			defer cancel()
+
+			modelUse := textutil.Choose(model, deps.Config.ModelReason)
+			deps.Logger.Info(cmd, "Commissioning model %s to write prose reviewers might actually read.", modelUse)
+			title, err := deps.LLM.Generate(ctx, modelUse, "Write a single-line pull request title (at most 72 characters) for the change below. Return only the title.\n\n"+changeContext)
+			if err != nil {
+				return err
+			}
+			title = strings.Trim(strings.SplitN(normalizeCommitMessage(title), "\n", 2)[0], "\"`# ")
+
+			var body string
+			if len(sections) == 0 {
+				body, err = deps.LLM.Generate(ctx, modelUse, defaultPRBodyPrompt+"\n\n"+changeContext)
+				if err != nil {
+					return err
+				}
+				body = strings.TrimSpace(textutil.NormalizeCode(body))
+			} else {
+				body, err = fillPRTemplate(ctx, cmd, deps, modelUse, sections, changeContext)
+				if err != nil {
+					return err
+				}
+			}
+
+			description := fmt.Sprintf("# %s\n\n%s\n", title, body)
+			if out == "" || out == "-" {
+				_, err = cmd.OutOrStdout().Write([]byte(description))
+				if err == nil {
+					deps.Logger.Info(cmd, "PR description delivered. Reviewers have lost their last excuse.")
+				}
+				return err
+			}
+			if err := deps.Files.WriteFile(out, description); err != nil {
+				return err
+			}
+			deps.Logger.Info(cmd, "PR description written to %s. Reviewers have lost their last excuse.", out)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&base, "base", "origin/main", "Base ref the branch will merge into")
+	cmd.Flags().StringVar(&template, "template", "", "PR template to fill section by section ('auto' to detect .github/pull_request_template.md and friends)")
+	cmd.Flags().StringVar(&out, "out", "-", "Output file or '-' for stdout")
+	cmd.Flags().StringVar(&model, "model", "", "Override model (default SHELDON_MODEL_REASON)")
+	return cmd
+}
+
+const defaultPRBodyPrompt = `Write a pull request description in Markdown for the change below with exactly these sections:
+## Summary (2-3 sentences on what changes)
+## Motivation (why the change is needed; infer from commit messages, do not invent tickets)
+## Changes (one ### subsection per area listed under CHANGED AREAS, with bullets)
+## Testing (how the change was or should be verified; mention added or missing tests)
+## Risk (what could break, rollout or migration concerns, and how to roll back)
+Do not include a title. Do not wrap the answer in code fences.`
+
+// templateSection is one heading of a PR template and the guidance beneath it.
+type templateSection struct {
+	Heading  string
+	Guidance string
+}
+
+var (
+	htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+	// atxHeading is a Markdown heading line: one to six #s and a space, so #123
+	// issue references and #hashtags are body text.
+	atxHeading = regexp.MustCompile(`^#{1,6}[ \t]`)
+)
+
+// parseTemplateSections splits a Markdown template on its headings. Text before the
+// first heading becomes a section with an empty heading; # lines in fenced code
+// blocks are not headings.
+func parseTemplateSections(text string) []templateSection {
+	var (
+		sections []templateSection
+		current  templateSection
+		body     []string
+	)
+	flush := func() {
+		current.Guidance = strings.TrimSpace(strings.Join(body, "\n"))
+		if current.Heading != "" || current.Guidance != "" {
+			sections = append(sections, current)
+		}
+		body = nil
+	}
+	inFence := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if !inFence && atxHeading.MatchString(line) {
+			flush()
+			current = templateSection{Heading: strings.TrimSpace(line)}
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return sections
+}
+
+func loadPRTemplate(deps Dependencies, template string) ([]templateSection, error) {
+	if template != "auto" {
+		text, err := deps.Files.Read(template)
+		if err != nil {
+			return nil, err
+		}
+		return parseTemplateSections(text), nil
+	}
+
+	root, err := deps.Git.RepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range prTemplateCandidates {
+		if text, err := deps.Files.Read(filepath.Join(root, candidate)); err == nil {
+			return parseTemplateSections(text), nil
+		}
+	}
+	return nil, fmt.Errorf("no pull request template found under %s", root)
+}
+
+// fillPRTemplate asks the model for each template section in turn so long templates
+// are not truncated or reordered.
+func fillPRTemplate(ctx context.Context, cmd *cobra.Command, deps Dependencies, model string, sections []templateSection, changeContext string) (string, error) {
+	parts := make([]string, 0, len(sections))
+	for _, s := range sections {
+		if s.Heading == "" {
+			// Preamble text is kept as-is, minus authoring comments.
+			if text := strings.TrimSpace(htmlComment.ReplaceAllString(s.Guidance, "")); text != "" {
+				parts = append(parts, text)
+			}
+			continue
+		}
+		deps.Logger.Info(cmd, "Filling template section %q.", strings.TrimLeft(s.Heading, "# "))
+		prompt := fmt.Sprintf(`Fill in the %q section of a pull request description for the change below.
+Template guidance for this section (may contain instructions in HTML comments and checklists):
+%s
+
+Rules: return only the section content without the heading; keep any checklist items from the guidance and tick ("- [x]") only those the change clearly satisfies; write "N/A" if the section does not apply; do not invent ticket numbers or test results.
+
+%s`, strings.TrimLeft(s.Heading, "# "), textutil.Choose(s.Guidance, "(none)"), changeContext)
+		ans, err := deps.LLM.Generate(ctx, model, prompt)
+		if err != nil {
+			return "", err
+		}
+		content := strings.TrimSpace(textutil.NormalizeCode(ans))
+		// Models like to repeat the heading; drop it.
+		if first, rest, ok := strings.Cut(content, "\n"); ok && strings.TrimSpace(first) == s.Heading {
+			content = strings.TrimSpace(rest)
+		}
+		parts = append(parts, s.Heading+"\n\n"+content)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// describeContext assembles commits, changed areas and the budgeted diff for prompts.
+func describeContext(commits []git.Commit, files []unidiff.File, rendered string) string {
+	var b strings.Builder
+	b.WriteString("COMMITS (oldest first):\n")
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		fmt.Fprintf(&b, "- %s %s\n", shortHash(c.Hash), c.Subject)
+		if body := strings.TrimSpace(c.Body); body != "" {
+			for _, line := range strings.Split(body, "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+	}
+	b.WriteString("\nCHANGED AREAS:\n")
+	for _, area := range changeAreas(files) {
+		fmt.Fprintf(&b, "- %s\n", area)
+	}
+	b.WriteString("\nDIFF:\n")
+	b.WriteString(strings.ReplaceAll(rendered, "```", "`​``"))
+	return b.String()
+}
+
+// changeAreas summarises files by directory, largest change first.
+func changeAreas(files []unidiff.File) []string {
+	type area struct {
+		dir            string
+		files          int
+		added, removed int
+	}
+	byDir := map[string]*area{}
+	for _, f := range files {
+		dir := path.Dir(f.Path())
+		a, ok := byDir[dir]
+		if !ok {
+			a = &area{dir: dir}
+			byDir[dir] = a
+		}
+		a.files++
+		a.added += f.Added()
+		a.removed += f.Removed()
+	}
+	areas := make([]*area, 0, len(byDir))
+	for _, a := range byDir {
+		areas = append(areas, a)
+	}
+	sort.Slice(areas, func(i, j int) bool {
+		if ci, cj := areas[i].added+areas[i].removed, areas[j].added+areas[j].removed; ci != cj {
+			return ci > cj
+		}
+		return areas[i].dir < areas[j].dir
+	})
+	out := make([]string, 0, len(areas))
+	for _, a := range areas {
+		out = append(out, fmt.Sprintf("%s: %d file(s), +%d -%d", a.dir, a.files, a.added, a.removed))
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/unidiff"
+)
+
+func TestParseTemplateSections(t *testing.T) {
+	template := `<!-- Thanks for contributing! -->
+
+## Description
+<!-- What does this PR do? -->
+
+## Checklist
+- [ ] Tests added
+- [ ] Docs updated
+#123 is not a heading
+` + "```bash\n# nor is a shell comment\n```\n"
+	sections := parseTemplateSections(template)
+	if len(sections) != 3 {
+		t.Fatalf("expected preamble + 2 sections, got %#v", sections)
+	}
+	if sections[0].Heading != "" || sections[1].Heading != "## Description" {
+		t.Fatalf("unexpected headings: %#v", sections)
+	}
+	if sections[2].Guidance != "- [ ] Tests added\n- [ ] Docs updated\n#123 is not a heading\n```bash\n# nor is a shell comment\n```" {
+		t.Fatalf("unexpected checklist guidance: %q", sections[2].Guidance)
+	}
+}
+
+func TestChangeAreas(t *testing.T) {
+	files := []unidiff.File{
+		{NewPath: "internal/git/client.go", Hunks: []unidiff.Hunk{{Lines: []unidiff.Line{{Kind: unidiff.Added}, {Kind: unidiff.Added}}}}},
+		{NewPath: "internal/git/fake.go", Hunks: []unidiff.Hunk{{Lines: []unidiff.Line{{Kind: unidiff.Removed}}}}},
+		{NewPath: "README.md", Hunks: []unidiff.Hunk{{Lines: []unidiff.Line{{Kind: unidiff.Added}}}}},
+	}
+	areas := changeAreas(files)
+	want := []string{"internal/git: 2 file(s), +2 -1", ".: 1 file(s), +1 -0"}
+	if len(areas) != len(want) || areas[0] != want[0] || areas[1] != want[1] {
+		t.Fatalf("unexpected areas: %#v", areas)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/riskiramdan/ShELDon/internal/textutil"
+	"github.com/riskiramdan/ShELDon/internal/unidiff"
 )
 
 // NewPRReviewCommand runs an LLM-powered review for the current branch diff.
@@ -34,7 +35,16 @@ func NewPRReviewCommand(deps Dependencies) *cobra.Command {
 				return errors.New("no diff vs base")
 			}
 
-			prompt := "Code review with 5 sections: Correctness, Complexity, Style, Tests, Security. Be specific, cite file:line. Keep under 200 lines.\n\n" + diff
+			files, err := unidiff.Parse(diff)
+			if err != nil {
+				return err
+			}
+			deps.Logger.Info(cmd, "Parsed %d changed files. Binary blobs will be mercifully summarised.", len(files))
+
+			const maxReviewDiffLen = 24000
+			rendered := unidiff.Render(files, unidiff.RenderOptions{MaxBytes: maxReviewDiffLen, LineNumbers: true})
+			prompt := "Code review with 5 sections: Correctness, Complexity, Style, Tests, Security. Be specific, cite file:line. Keep under 200 lines.\n" +
+				"Each file starts with a === line; kept lines are prefixed with their line number in the new file, so cite those numbers.\n\n" + rendered
 			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
 			defer cancel()
 
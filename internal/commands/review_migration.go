@@ -3,53 +3,466 @@ package commands
 import (
 	"context"
 	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/riskiramdan/ShELDon/internal/git"
+	"github.com/riskiramdan/ShELDon/internal/sqllint"
+	"github.com/riskiramdan/ShELDon/internal/sqlmigration"
 	"github.com/riskiramdan/ShELDon/internal/textutil"
 )
 
 // NewReviewMigrationCommand reviews SQL migrations for safety.
 func NewReviewMigrationCommand(deps Dependencies) *cobra.Command {
 	var (
-		path  string
-		model string
+		path          string
+		dir           string
+		since         string
+		failOn        string
+		baselinePath  string
+		writeBaseline bool
+		model         string
+		explain       bool
+		listRules     bool
 	)
 
 	cmd := &cobra.Command{
 		Use:   "review-migration",
 		Short: "Review a Postgres migration for safety/downtime risks",
+		Long: `Review a Postgres migration for safety/downtime risks.
+
+Findings come from fixed rules with stable IDs (see --rules), so the same
+migration always gets the same findings. The model only explains each finding
+and suggests the safer alternative; pass --explain=false to skip it.
+
+In CI, --dir with --since reviews every migration added since a git ref and
+--fail-on makes findings fail the build, as does finding no new migrations. A
+"-- sheldon:ignore RULE reason" comment suppresses a rule for the statement it
+sits on or precedes, and --baseline skips findings accepted earlier with
+--write-baseline.
+
+Directories in goose, golang-migrate and atlas layouts are understood: up and
+down are reviewed together, the down is checked against the up, and new
+migrations numbered below ones already in history are reported. With neither
+--in nor --dir, the schema paths of sqlc.yaml are reviewed.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if (path == "" || path == "-") && deps.Files.IsInteractive() {
-				return errors.New("no migration provided; supply --in <file> or pipe SQL text")
+			cmd.SilenceUsage = true
+			if listRules {
+				writeRules(cmd.OutOrStdout())
+				return nil
 			}
-			deps.Logger.Info(cmd, "Opening SQL migration %s. May the DDL be ever in your favor.", path)
-			sql, err := deps.Files.Read(path)
+			threshold, err := severityThreshold(failOn)
 			if err != nil {
 				return err
 			}
-			deps.Logger.Info(cmd, "Catalogued %d characters of schema meddling.", len(sql))
+			if since != "" && dir == "" {
+				return errors.New("--since needs --dir")
+			}
+			if writeBaseline && baselinePath == "" {
+				return errors.New("--write-baseline needs --baseline <file>")
+			}
 
-			prompt := "Review this Postgres migration for safety and downtime risk. Flag: full table rewrites, enum pitfalls, blocking DDL. Provide safer alternatives.\n\n" + sql
-			ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
-			defer cancel()
+			var (
+				sets []*sqlmigration.Set
+				// isNew marks the migrations added since --since, or not yet
+				// committed; nil when git cannot tell.
+				isNew func(m *sqlmigration.Migration) bool
+			)
+			out := cmd.OutOrStdout()
+			switch {
+			case dir != "":
+				set, err := sqlmigration.Load(dir)
+				if err != nil {
+					return err
+				}
+				var added map[string]bool
+				if since != "" {
+					if added, err = newMigrationFiles(deps, dir, since); err != nil {
+						return err
+					}
+					if len(added) == 0 {
+						if threshold > 0 {
+							// A gate that reviewed nothing must not pass quietly:
+							// a wrong --dir or ref looks just like this.
+							return fmt.Errorf("no migrations in %s were added since %s, so --fail-on has nothing to check; drop --fail-on for changes without migrations", dir, since)
+						}
+						fmt.Fprintf(out, "No new migrations in %s since %s.\n", dir, since)
+						return nil
+					}
+				} else {
+					added = uncommittedFiles(deps)
+				}
+				if added != nil {
+					isNew = addedMigration(added)
+				}
+				sets = append(sets, set)
+			case path != "" && path != "-":
+				set, err := sqlmigration.LoadFile(path)
+				if err != nil {
+					return err
+				}
+				sets = append(sets, set)
+			case !deps.Files.IsInteractive():
+				sql, err := deps.Files.Read("-")
+				if err != nil {
+					return err
+				}
+				m := sqlmigration.Parse("-", sql)
+				sets = append(sets, &sqlmigration.Set{Format: m.Format, Migrations: []*sqlmigration.Migration{m}})
+			default:
+				config := sqlmigration.FindSqlcConfig(".")
+				if config == "" {
+					return errors.New("no migration provided; supply --in <file>, --dir <dir> or pipe SQL text")
+				}
+				schemas, err := sqlmigration.SqlcSchemas(config)
+				if err != nil {
+					return err
+				}
+				deps.Logger.Info(cmd, "No migration named, so %s it is: %d schema paths.", config, len(schemas))
+				for _, schema := range schemas {
+					var set *sqlmigration.Set
+					if info, err := os.Stat(schema); err == nil && info.IsDir() {
+						set, err = sqlmigration.Load(schema)
+						if err != nil {
+							return err
+						}
+					} else if set, err = sqlmigration.LoadFile(schema); err != nil {
+						return err
+					}
+					sets = append(sets, set)
+				}
+				if added := uncommittedFiles(deps); added != nil {
+					isNew = addedMigration(added)
+				}
+			}
 
-			modelUse := textutil.Choose(model, deps.Config.ModelGeneral)
-			deps.Logger.Info(cmd, "Consulting model %s for a pre-flight safety inspection.", modelUse)
-			ans, err := deps.LLM.Generate(ctx, modelUse, prompt)
-			if err != nil {
-				return err
+			opts := sqllint.Options{ColumnUses: columnUses(deps.Git)}
+			var migrations []reviewedMigration
+			for _, set := range sets {
+				if set.Dir != "" && len(set.Migrations) > 1 {
+					fmt.Fprintf(out, "%s: %s layout, %d migrations\n", migrationLabel(set.Dir), set.Format, len(set.Migrations))
+				}
+				for _, f := range set.Review(opts, isNew) {
+					if since != "" && !isNew(f.Migration) {
+						continue
+					}
+					deps.Logger.Info(cmd, "Reviewed SQL migration %s. May the DDL be ever in your favor.", f.Path)
+					migrations = append(migrations, reviewedMigration{Label: migrationLabel(f.Path), SQL: f.SQL, Findings: f.Findings})
+				}
 			}
 
-			_, err = cmd.OutOrStdout().Write([]byte(ans))
-			if err == nil {
-				deps.Logger.Info(cmd, "Migration risk report delivered. Proceed, cautiously, if at all.")
+			baseline := sqllint.Baseline{}
+			if baselinePath != "" && !writeBaseline {
+				text, err := deps.Files.Read(baselinePath)
+				if err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+				if baseline, err = sqllint.ParseBaseline(text); err != nil {
+					return fmt.Errorf("%s: %w", baselinePath, err)
+				}
+			}
+			if writeBaseline {
+				accepted := 0
+				for _, m := range migrations {
+					for _, f := range m.Findings {
+						if f.Ignored == "" {
+							baseline.Add(m.Label, f)
+							accepted++
+						}
+					}
+				}
+				if err := deps.Files.WriteFile(baselinePath, sqllint.FormatBaseline(baseline)); err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "Wrote %d findings to %s.\n", accepted, baselinePath)
+				return nil
+			}
+
+			counts := applyGate(out, migrations, baseline, threshold)
+			if counts.Errors+counts.Warnings == 0 {
+				fmt.Fprintf(out, "No findings.")
+				if counts.Ignored+counts.Baselined > 0 {
+					fmt.Fprintf(out, " (%d ignored, %d in baseline)", counts.Ignored, counts.Baselined)
+				}
+				fmt.Fprintln(out)
+			} else {
+				fmt.Fprintf(out, "\n%s\n", counts)
+			}
+
+			// The gate is settled before the model is asked, so an unreachable
+			// model cannot decide a CI run.
+			var gateErr error
+			if counts.Failing > 0 {
+				gateErr = fmt.Errorf("%d findings at or above %s", counts.Failing, threshold)
+				fmt.Fprintf(out, "Gate: %v.\n", gateErr)
+			} else if threshold > 0 {
+				fmt.Fprintf(out, "Gate: passed (--fail-on %s).\n", threshold)
+			}
+
+			if counts.Errors+counts.Warnings > 0 && explain {
+				ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+				defer cancel()
+
+				modelUse := textutil.Choose(model, deps.Config.ModelGeneral)
+				deps.Logger.Info(cmd, "Consulting model %s to explain %d findings to the uninitiated.", modelUse, counts.Errors+counts.Warnings)
+				ans, err := deps.LLM.Generate(ctx, modelUse, reviewMigrationPrompt(migrations, baseline))
+				switch {
+				case err != nil && threshold > 0:
+					// In gate mode the explanation is a courtesy.
+					fmt.Fprintf(cmd.ErrOrStderr(), "warning: no explanation from the model: %v\n", err)
+				case err != nil:
+					return err
+				default:
+					if _, err := fmt.Fprintf(out, "\n%s\n", strings.TrimSpace(ans)); err != nil {
+						return err
+					}
+				}
 			}
-			return err
+
+			if gateErr != nil {
+				return gateErr
+			}
+			deps.Logger.Info(cmd, "Migration risk report delivered. Proceed, cautiously, if at all.")
+			return nil
 		},
 	}
 
 	cmd.Flags().StringVar(&path, "in", "-", "Path to SQL file or '-' for stdin")
+	cmd.Flags().StringVar(&dir, "dir", "", "Review every .sql migration in this directory instead of --in")
+	cmd.Flags().StringVar(&since, "since", "", "With --dir, review only migrations added since this git ref (e.g. origin/main)")
+	cmd.Flags().StringVar(&failOn, "fail-on", "", "Exit non-zero when a finding reaches this severity: warn or error")
+	cmd.Flags().StringVar(&baselinePath, "baseline", "", "File of accepted findings that no longer count")
+	cmd.Flags().BoolVar(&writeBaseline, "write-baseline", false, "Accept all current findings into --baseline and exit")
 	cmd.Flags().StringVar(&model, "model", "", "Override model")
+	cmd.Flags().BoolVar(&explain, "explain", true, "Ask the model to explain each finding and suggest the safer SQL")
+	cmd.Flags().BoolVar(&listRules, "rules", false, "List the rules and exit")
 	return cmd
 }
+
+func writeRules(w io.Writer) {
+	for _, r := range sqllint.Rules {
+		fmt.Fprintf(w, "%s  %-5s  %-26s  %s\n", r.ID, r.Severity, r.Name, r.Summary)
+	}
+}
+
+// reviewMigrationPrompt asks the model to explain the findings that stand,
+// neither ignored nor in the baseline.
+func reviewMigrationPrompt(migrations []reviewedMigration, baseline sqllint.Baseline) string {
+	var b strings.Builder
+	b.WriteString("A rules engine reviewed these Postgres migrations and reported the findings below. ")
+	b.WriteString("For each finding, in the same order and headed by its file, rule ID and line, explain in two or three sentences why it is risky on a busy production database, ")
+	b.WriteString("then give the safer alternative as ready-to-run SQL in a ```sql block, split into separate migrations where needed. ")
+	b.WriteString("Do not add, drop or re-rank findings, and do not review anything else.\n")
+	for _, m := range migrations {
+		var standing []sqllint.Finding
+		for _, f := range m.Findings {
+			if f.Ignored == "" && !baseline.Has(m.Label, f) {
+				standing = append(standing, f)
+			}
+		}
+		if len(standing) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\nFindings in %s:\n", m.Label)
+		for _, f := range standing {
+			fmt.Fprintf(&b, "- line %s\n", f)
+			if f.Statement != "" {
+				fmt.Fprintf(&b, "  Statement: %s\n", f.Statement)
+			}
+		}
+		fmt.Fprintf(&b, "\n%s:\n```sql\n%s\n```\n", m.Label, strings.TrimSpace(m.SQL))
+	}
+	return b.String()
+}
+
+// maxColumnUses caps the places listed for a dropped column.
+const maxColumnUses = 5
+
+// columnUses finds Go code that still uses a column of the table: a db or gorm
+// tagged field of the struct mapped to the table, or a string literal naming
+// the column on a line that also names the table, which is where SQL lives. A
+// column name alone, say in a json tag of an unrelated struct, is not a use, so
+// the finding stays a warning. Without git, or when grep fails, it reports
+// nothing rather than guessing.
+func columnUses(client git.Client) func(table, column string) []string {
+	if client == nil {
+		return nil
+	}
+	files := map[string][]mappedField{} // by file, parsed once
+	return func(table, column string) []string {
+		matches, err := client.Grep(column, "*.go")
+		if err != nil {
+			return nil
+		}
+		if i := strings.LastIndexByte(table, '.'); i >= 0 {
+			table = table[i+1:] // the struct maps the table without its schema
+		}
+		var uses []string
+		for _, m := range matches {
+			if !quotedWord(m.Text, column) {
+				continue
+			}
+			if !containsWord(strings.ToLower(m.Text), strings.ToLower(table)) && !mapsColumn(files, m, table, column) {
+				continue
+			}
+			uses = append(uses, fmt.Sprintf("%s:%d", m.Path, m.Line))
+			if len(uses) == maxColumnUses {
+				break
+			}
+		}
+		return uses
+	}
+}
+
+// mappedField is a struct field tagged with the column it maps.
+type mappedField struct {
+	Line   int
+	Column string
+	Struct string
+	// Table is what the struct's TableName method returns; empty without one.
+	Table string
+}
+
+// mapsColumn reports whether the grep match is the db or gorm tagged field
+// that maps column in a struct for table.
+func mapsColumn(files map[string][]mappedField, m git.GrepMatch, table, column string) bool {
+	fields, ok := files[m.Path]
+	if !ok {
+		fields = mappedFields(m.Path)
+		files[m.Path] = fields
+	}
+	for _, f := range fields {
+		if f.Line != m.Line || !strings.EqualFold(f.Column, column) {
+			continue
+		}
+		if f.Table != "" {
+			return strings.EqualFold(f.Table, table)
+		}
+		return namesTable(f.Struct, table)
+	}
+	return false
+}
+
+// mappedFields lists the fields of file's structs whose db tag or gorm column
+// option names a column; nil when the file cannot be read or parsed.
+func mappedFields(file string) []mappedField {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	tables := map[string]string{}
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Name.Name != "TableName" || fd.Recv == nil || fd.Body == nil || len(fd.Body.List) != 1 {
+			continue
+		}
+		ret, ok := fd.Body.List[0].(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			continue
+		}
+		recv := fd.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if id, ok := recv.(*ast.Ident); ok {
+			if lit, ok := ret.Results[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				tables[id.Name], _ = strconv.Unquote(lit.Value)
+			}
+		}
+	}
+	var fields []mappedField
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		for _, field := range st.Fields.List {
+			if field.Tag == nil {
+				continue
+			}
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				continue
+			}
+			column, _, _ := strings.Cut(reflect.StructTag(tag).Get("db"), ",")
+			for _, opt := range strings.Split(reflect.StructTag(tag).Get("gorm"), ";") {
+				if name, ok := strings.CutPrefix(strings.TrimSpace(opt), "column:"); ok {
+					column = name
+				}
+			}
+			if column != "" && column != "-" {
+				fields = append(fields, mappedField{Line: fset.Position(field.Pos()).Line, Column: column, Struct: spec.Name.Name, Table: tables[spec.Name.Name]})
+			}
+		}
+		return false
+	})
+	return fields
+}
+
+// namesTable reports whether a struct named name maps table by the usual
+// convention, its name in the plural: User to users, OrderItem to order_items.
+func namesTable(name, table string) bool {
+	t, n := strings.ReplaceAll(strings.ToLower(table), "_", ""), strings.ToLower(name)
+	return t == n || t == n+"s" || t == n+"es" || strings.HasSuffix(n, "y") && t == n[:len(n)-1]+"ies"
+}
+
+// quotedWord reports whether word appears, as a whole word and ignoring case,
+// between double quotes or backticks on the line.
+func quotedWord(line, word string) bool {
+	lower, word := strings.ToLower(line), strings.ToLower(word)
+	var quote byte
+	start := 0
+	for i := 0; i < len(lower); i++ {
+		c := lower[i]
+		switch {
+		case quote == 0 && (c == '"' || c == '`'):
+			quote, start = c, i+1
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			if containsWord(lower[start:i], word) {
+				return true
+			}
+			quote = 0
+		}
+	}
+	return quote != 0 && containsWord(lower[start:], word)
+}
+
+func containsWord(s, word string) bool {
+	for from := 0; ; {
+		i := strings.Index(s[from:], word)
+		if i < 0 {
+			return false
+		}
+		i += from
+		end := i + len(word)
+		if (i == 0 || !isWordByte(s[i-1])) && (end == len(s) || !isWordByte(s[end])) {
+			return true
+		}
+		from = i + 1
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z'
+}
@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqllint"
+	"github.com/riskiramdan/ShELDon/internal/sqlmigration"
+)
+
+// reviewedMigration is one migration file and its findings.
+type reviewedMigration struct {
+	// Label names the file in reports and baselines: the path relative to the
+	// working directory, or "<stdin>".
+	Label    string
+	SQL      string
+	Findings []sqllint.Finding
+}
+
+// severityThreshold parses --fail-on; zero means the gate is off.
+func severityThreshold(failOn string) (sqllint.Severity, error) {
+	switch strings.ToLower(failOn) {
+	case "", "none":
+		return 0, nil
+	case "warn", "warning":
+		return sqllint.Warn, nil
+	case "error":
+		return sqllint.Error, nil
+	}
+	return 0, fmt.Errorf("unknown --fail-on %q; use warn or error", failOn)
+}
+
+// newMigrationFiles returns the absolute paths of the .sql files in dir added
+// since ref: committed, staged or still untracked.
+func newMigrationFiles(deps Dependencies, dir, ref string) (map[string]bool, error) {
+	if deps.Git == nil {
+		return nil, errors.New("--since needs git")
+	}
+	root, err := deps.Git.RepoRoot()
+	if err != nil {
+		return nil, err
+	}
+	absDir := absPath(dir)
+	relDir, err := filepath.Rel(root, absDir)
+	if err != nil || strings.HasPrefix(relDir, "..") {
+		return nil, fmt.Errorf("%s is not inside the repository at %s", dir, root)
+	}
+	relDir = filepath.ToSlash(relDir)
+
+	// git resolves pathspecs against the working directory and prints paths
+	// relative to the root; :(top) anchors relDir at the root too.
+	added, err := deps.Git.Diff("--name-only", "-z", "--diff-filter=A", ref, "--", ":(top)"+relDir)
+	if err != nil {
+		return nil, err
+	}
+	paths := strings.Split(added, "\x00")
+	entries, err := deps.Git.Status()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Index == '?' {
+			paths = append(paths, e.Path)
+		}
+	}
+	files := map[string]bool{}
+	for _, p := range paths {
+		if p != "" && path.Dir(p) == relDir && strings.EqualFold(path.Ext(p), ".sql") {
+			files[filepath.Join(absDir, path.Base(p))] = true
+		}
+	}
+	return files, nil
+}
+
+// uncommittedFiles returns the absolute paths of files that are untracked or
+// newly staged, or nil when git cannot tell.
+func uncommittedFiles(deps Dependencies) map[string]bool {
+	if deps.Git == nil {
+		return nil
+	}
+	root, err := deps.Git.RepoRoot()
+	if err != nil || root == "" {
+		return nil
+	}
+	entries, err := deps.Git.Status()
+	if err != nil {
+		return nil
+	}
+	files := map[string]bool{}
+	for _, e := range entries {
+		if e.Index == '?' || e.Index == 'A' {
+			files[filepath.Join(root, filepath.FromSlash(e.Path))] = true
+		}
+	}
+	return files
+}
+
+// addedMigration reports whether either file of a migration is in added.
+func addedMigration(added map[string]bool) func(m *sqlmigration.Migration) bool {
+	return func(m *sqlmigration.Migration) bool {
+		return added[absPath(m.UpPath)] || m.DownPath != "" && added[absPath(m.DownPath)]
+	}
+}
+
+func absPath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
+
+// migrationLabel is path relative to the working directory, with forward
+// slashes, so baselines match whichever way the file was named.
+func migrationLabel(file string) string {
+	if file == "" || file == "-" {
+		return "<stdin>"
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				file = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(file))
+}
+
+// gateCounts tallies findings for the summary and the --fail-on gate.
+type gateCounts struct {
+	Errors, Warnings, Ignored, Baselined, Failing int
+}
+
+// applyGate prints the findings that stand and counts the rest; threshold zero
+// fails nothing.
+func applyGate(w io.Writer, migrations []reviewedMigration, baseline sqllint.Baseline, threshold sqllint.Severity) gateCounts {
+	var c gateCounts
+	for _, m := range migrations {
+		for _, f := range m.Findings {
+			switch {
+			case f.Ignored != "":
+				c.Ignored++
+				continue
+			case baseline.Has(m.Label, f):
+				c.Baselined++
+				continue
+			case f.Severity == sqllint.Error:
+				c.Errors++
+			default:
+				c.Warnings++
+			}
+			if threshold > 0 && f.Severity >= threshold {
+				c.Failing++
+			}
+			fmt.Fprintf(w, "%s:%s\n", m.Label, f)
+			if f.Statement != "" {
+				fmt.Fprintf(w, "    %s\n", truncateQuery(f.Statement, 160))
+			}
+		}
+	}
+	return c
+}
+
+func (c gateCounts) String() string {
+	s := fmt.Sprintf("%d findings: %d errors, %d warnings", c.Errors+c.Warnings, c.Errors, c.Warnings)
+	if c.Ignored > 0 || c.Baselined > 0 {
+		s += fmt.Sprintf(" (%d ignored, %d in baseline)", c.Ignored, c.Baselined)
+	}
+	return s
+}
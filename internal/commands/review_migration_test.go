@@ -0,0 +1,337 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/config"
+	"github.com/riskiramdan/ShELDon/internal/git"
+	"github.com/riskiramdan/ShELDon/internal/logging"
+	"github.com/riskiramdan/ShELDon/internal/system"
+)
+
+const riskyMigration = `-- Tokens replace nicknames.
+ALTER TABLE users ADD COLUMN token uuid DEFAULT gen_random_uuid();
+CREATE INDEX users_email_idx ON users (email);
+ALTER TABLE users DROP COLUMN nickname;
+`
+
+func TestReviewMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "001_users.sql")
+	if err := os.WriteFile(path, []byte(riskyMigration), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Only the field of the struct mapped to users is a use; the profile's
+	// json tag and the comment are not.
+	model := filepath.Join(t.TempDir(), "user.go")
+	src := "package user\n\ntype User struct {\n\tID       int64  `db:\"id\"`\n\tNickname string `db:\"nickname\"`\n}\n\ntype Profile struct {\n\tNickname string `json:\"nickname\"`\n}\n"
+	if err := os.WriteFile(model, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitClient := git.NewFakeClient()
+	gitClient.Greps["nickname"] = []git.GrepMatch{
+		{Path: model, Line: 5, Text: "\tNickname string `db:\"nickname\"`"},
+		{Path: model, Line: 9, Text: "\tNickname string `json:\"nickname\"`"},
+		{Path: "internal/user/doc.go", Line: 3, Text: "// nickname is optional"},
+	}
+	llm := &scriptedLLM{answers: []string{"PG001 line 2: backfill in batches.\n"}}
+	run := func(args ...string) string {
+		cmd := NewReviewMigrationCommand(Dependencies{
+			Config: &config.Config{},
+			LLM:    llm,
+			Files:  system.NewOSFileManager(strings.NewReader("")),
+			Git:    gitClient,
+			Logger: logging.NewSheldonLogger(),
+		})
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("review-migration %v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	got := run("--in", path)
+	for _, want := range []string{
+		path + ":2: error PG001 volatile-default: ",
+		path + ":2: warn PG008 lock-timeout: ",
+		path + ":3: error PG002 index-not-concurrent: ",
+		path + ":4: error PG006 drop-column: dropping users.nickname while the code still mentions it (" + model + ":5)",
+		"    CREATE INDEX users_email_idx ON users (email)\n",
+		"4 findings: 3 errors, 1 warnings\n",
+		"PG001 line 2: backfill in batches.\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+	if len(llm.prompts) != 1 || !strings.Contains(llm.prompts[0], "Do not add, drop or re-rank findings") || !strings.Contains(llm.prompts[0], "- line 3: error PG002") {
+		t.Fatalf("unexpected prompt: %v", llm.prompts)
+	}
+
+	// A column name in an unrelated struct keeps the finding a warning.
+	gitClient.Greps["nickname"] = gitClient.Greps["nickname"][1:]
+	if got := run("--in", path, "--explain=false"); !strings.Contains(got, path+":4: warn PG006 drop-column: ") {
+		t.Fatalf("an unrelated use should not make PG006 an error:\n%s", got)
+	}
+	gitClient.Greps["nickname"] = []git.GrepMatch{{Path: "internal/user/repo.go", Line: 40, Text: `db.Exec("UPDATE users SET nickname = $1", n)`}}
+	if got := run("--in", path, "--explain=false"); !strings.Contains(got, "error PG006 drop-column: dropping users.nickname while the code still mentions it (internal/user/repo.go:40)") {
+		t.Fatalf("SQL naming the table and column is a use:\n%s", got)
+	}
+
+	safe := filepath.Join(t.TempDir(), "002_safe.sql")
+	if err := os.WriteFile(safe, []byte("CREATE INDEX CONCURRENTLY users_email_idx ON users (email);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := run("--in", safe); got != "No findings.\n" || len(llm.prompts) != 1 {
+		t.Fatalf("a clean migration should not reach the model: %q", got)
+	}
+	if got := run("--rules"); !strings.Contains(got, "PG007  warn   enum-change") {
+		t.Fatalf("rules missing from listing:\n%s", got)
+	}
+}
+
+func TestQuotedWord(t *testing.T) {
+	cases := []struct {
+		line string
+		want bool
+	}{
+		{"\tNickname string `db:\"nickname\"`", true},
+		{`rows, err := db.Query("SELECT id, nickname FROM users")`, true},
+		{"// nickname is optional", false},
+		{`u.Nickname = "x"`, false},
+		{`q := "SELECT nicknames FROM users"`, false},
+	}
+	for _, tc := range cases {
+		if got := quotedWord(tc.line, "nickname"); got != tc.want {
+			t.Errorf("quotedWord(%q) = %v, want %v", tc.line, got, tc.want)
+		}
+	}
+}
+
+func TestReviewMigrationGate(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "migrations")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"001_old.sql":   "ALTER TABLE users ALTER COLUMN id TYPE bigint;\n",
+		"002_index.sql": "SET lock_timeout = '5s';\nCREATE INDEX users_email_idx ON users (email);\nALTER TABLE users DROP COLUMN bio;\n",
+		"003_rename.sql": "SET lock_timeout = '5s';\n" +
+			"-- sheldon:ignore PG005 nothing reads the old name since v2.3\n" +
+			"ALTER TABLE users RENAME COLUMN name TO full_name;\n" +
+			"ALTER TABLE users RENAME COLUMN city TO town; -- sheldon:ignore rename\n",
+	}
+	for name, sql := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sql), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitClient := git.NewFakeClient()
+	gitClient.Root = root
+	gitClient.Diffs["--name-only -z --diff-filter=A origin/main -- :(top)migrations"] = "migrations/002_index.sql\x00"
+	gitClient.Entries = []git.StatusEntry{{Index: '?', Worktree: '?', Path: "migrations/003_rename.sql"}}
+	run := func(args ...string) (string, error) {
+		cmd := NewReviewMigrationCommand(Dependencies{
+			Config: &config.Config{},
+			LLM:    &scriptedLLM{},
+			Files:  system.NewOSFileManager(strings.NewReader("")),
+			Git:    gitClient,
+			Logger: logging.NewSheldonLogger(),
+		})
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(append([]string{"--explain=false"}, args...))
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	// The ignore on line 4 gives no reason, so it suppresses nothing.
+	got, err := run("--dir", dir, "--since", "origin/main", "--fail-on", "error")
+	if err == nil || err.Error() != "2 findings at or above error" {
+		t.Fatalf("expected the gate to fail on the index and the rename, got %v:\n%s", err, got)
+	}
+	for _, want := range []string{
+		"002_index.sql:2: error PG002 index-not-concurrent: ",
+		"002_index.sql:3: warn PG006 drop-column: ",
+		"003_rename.sql:4: error PG005 rename: renaming users.city to town",
+		"3 findings: 2 errors, 1 warnings (1 ignored, 0 in baseline)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "001_old.sql") {
+		t.Fatalf("a migration older than the ref was reviewed:\n%s", got)
+	}
+
+	baselinePath := filepath.Join(root, "baseline.txt")
+	if got, err := run("--dir", dir, "--since", "origin/main", "--baseline", baselinePath, "--write-baseline"); err != nil || !strings.Contains(got, "Wrote 3 findings to "+baselinePath) {
+		t.Fatalf("write baseline: %v\n%s", err, got)
+	}
+	got, err = run("--dir", dir, "--since", "origin/main", "--baseline", baselinePath, "--fail-on", "warn")
+	if err != nil || !strings.Contains(got, "No findings. (1 ignored, 3 in baseline)\n") {
+		t.Fatalf("baselined findings should pass: %v\n%s", err, got)
+	}
+	got, err = run("--dir", dir, "--baseline", baselinePath, "--fail-on", "warn")
+	if err == nil || !strings.Contains(got, "001_old.sql:1: error PG003 alter-column-type") || !strings.Contains(got, "001_old.sql:1: warn PG008") {
+		t.Fatalf("findings outside the baseline should fail: %v\n%s", err, got)
+	}
+
+	// An unreachable model neither fails a passing gate nor hides a failing one.
+	warnOnly := filepath.Join(root, "warn_only.sql")
+	if err := os.WriteFile(warnOnly, []byte("SET lock_timeout = '5s';\nALTER TABLE users DROP COLUMN bio;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	explain := func(args ...string) (string, string, error) {
+		cmd := NewReviewMigrationCommand(Dependencies{
+			Config: &config.Config{},
+			LLM:    unreachableLLM{},
+			Files:  system.NewOSFileManager(strings.NewReader("")),
+			Git:    gitClient,
+			Logger: logging.NewSheldonLogger(),
+		})
+		var out, errOut bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&errOut)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), errOut.String(), err
+	}
+	got, stderr, err := explain("--in", warnOnly, "--fail-on", "error")
+	if err != nil || !strings.Contains(got, "Gate: passed (--fail-on error).") || !strings.Contains(stderr, "warning: no explanation from the model: model unreachable") {
+		t.Fatalf("a passing gate should survive the model: %v\n%s\n%s", err, got, stderr)
+	}
+	if _, _, err := explain("--in", warnOnly, "--fail-on", "warn"); err == nil || err.Error() != "1 findings at or above warn" {
+		t.Fatalf("the gate's error should win over the model's: %v", err)
+	}
+	if _, _, err := explain("--in", warnOnly); err == nil || err.Error() != "model unreachable" {
+		t.Fatalf("without a gate the model's error stands: %v", err)
+	}
+}
+
+// unreachableLLM fails every call, as a model behind a CI firewall would.
+type unreachableLLM struct{}
+
+func (unreachableLLM) Generate(context.Context, string, string) (string, error) {
+	return "", errors.New("model unreachable")
+}
+
+func TestReviewMigrationLayout(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "db", "migrations")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"0001_users.up.sql":    "CREATE TABLE users (id bigint);\n",
+		"0001_users.down.sql":  "DROP TABLE users;\n",
+		"0003_tags.up.sql":     "CREATE TABLE tags (id bigint);\n",
+		"0003_tags.down.sql":   "DROP TABLE tags;\n",
+		"0002_email.up.sql":    "SET lock_timeout = '5s';\nALTER TABLE users ADD COLUMN email text;\n",
+		"0002_email.down.sql":  "SELECT 1;\n",
+		"0004_avatar.up.sql":   "SET lock_timeout = '5s';\nALTER TABLE users ADD COLUMN avatar text;\n",
+		"0004_avatar.down.sql": "SET lock_timeout = '5s';\nALTER TABLE users DROP COLUMN avatar;\n",
+	}
+	for name, sql := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sql), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitClient := git.NewFakeClient()
+	gitClient.Root = root
+	gitClient.Diffs["--name-only -z --diff-filter=A main -- :(top)db/migrations"] = "db/migrations/0002_email.up.sql\x00db/migrations/0002_email.down.sql\x00db/migrations/0004_avatar.up.sql\x00db/migrations/0004_avatar.down.sql\x00"
+	cmd := NewReviewMigrationCommand(Dependencies{
+		Config: &config.Config{},
+		LLM:    &scriptedLLM{},
+		Files:  system.NewOSFileManager(strings.NewReader("")),
+		Git:    gitClient,
+		Logger: logging.NewSheldonLogger(),
+	})
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--dir", dir, "--since", "main", "--explain=false", "--fail-on", "error"})
+	err := cmd.Execute()
+	got := out.String()
+	if err == nil || err.Error() != "1 findings at or above error" {
+		t.Fatalf("expected the out-of-order version to fail the gate, got %v:\n%s", err, got)
+	}
+	for _, want := range []string{
+		"golang-migrate layout, 4 migrations\n",
+		"0002_email.up.sql:1: error MG003 out-of-order: version 0002 is new but lower than 0003 (0003_tags.up.sql)",
+		"0002_email.up.sql:2: warn MG002 down-incomplete: the down migration does not revert this; expected ALTER TABLE users DROP COLUMN email\n",
+		"2 findings: 1 errors, 1 warnings\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "0003_tags.up.sql:1:") || strings.Contains(got, "0004_avatar.") {
+		t.Fatalf("only new migrations with findings should be listed:\n%s", got)
+	}
+}
+
+func TestReviewMigrationSinceFromSubdirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	root := t.TempDir()
+	dir := filepath.Join(root, "svc", "migrations")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	gitRun := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", root, "-c", "user.email=dev@example.com", "-c", "user.name=Dev"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	gitRun("init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "001_init.sql"), []byte("CREATE TABLE users (id bigint);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun("add", ".")
+	gitRun("commit", "-q", "-m", "init")
+	if err := os.WriteFile(filepath.Join(dir, "002_index.sql"), []byte("SET lock_timeout = '5s';\nCREATE INDEX users_id_idx ON users (id);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun("add", ".")
+	gitRun("commit", "-q", "-m", "index")
+
+	// Run from svc/, as a service's CI job would.
+	t.Chdir(filepath.Join(root, "svc"))
+	run := func(since string) (string, error) {
+		cmd := NewReviewMigrationCommand(Dependencies{
+			Config: &config.Config{},
+			LLM:    &scriptedLLM{},
+			Files:  system.NewOSFileManager(strings.NewReader("")),
+			Git:    git.CLIClient{},
+			Logger: logging.NewSheldonLogger(),
+		})
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs([]string{"--dir", "migrations", "--since", since, "--fail-on", "error", "--explain=false"})
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	got, err := run("HEAD~1")
+	if err == nil || err.Error() != "1 findings at or above error" || !strings.Contains(got, "002_index.sql:2: error PG002") || strings.Contains(got, "001_init.sql") {
+		t.Fatalf("expected the new migration reviewed, got %v:\n%s", err, got)
+	}
+	if got, err := run("HEAD"); err == nil || !strings.Contains(err.Error(), "nothing to check") {
+		t.Fatalf("a gate with nothing to review should fail, got %v:\n%s", err, got)
+	}
+}
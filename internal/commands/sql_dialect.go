@@ -0,0 +1,27 @@
+package commands
+
+import "github.com/riskiramdan/ShELDon/internal/sqlplan"
+
+// sqlAdvice is the engine-specific guidance added to SQL prompts, so index types
+// and online DDL match the database the plan or query came from.
+type sqlAdvice struct {
+	// Indexes describes the index features and DDL the engine offers.
+	Indexes string
+	// Tuning names the statistics and settings worth suggesting.
+	Tuning string
+}
+
+var sqlDialectAdvice = map[sqlplan.Dialect]sqlAdvice{
+	sqlplan.Postgres: {
+		Indexes: "Write indexes as CREATE INDEX CONCURRENTLY (it does not block writes but cannot run inside a transaction). B-tree by default; consider partial indexes (WHERE), covering indexes (INCLUDE), expression indexes, GIN for jsonb, arrays and full text, and BRIN for large append-only tables.",
+		Tuning:  "ANALYZE, per-column statistics targets, extended statistics (CREATE STATISTICS) for correlated columns, work_mem for sorts and hashes that spill to disk",
+	},
+	sqlplan.MySQL: {
+		Indexes: "Write InnoDB indexes as ALTER TABLE ... ADD INDEX ..., ALGORITHM=INPLACE, LOCK=NONE so the build is online; every secondary index implicitly ends with the primary key. There are no partial indexes and no INCLUDE: use composite covering indexes, functional indexes (8.0.13+), prefix indexes for long strings and FULLTEXT for text search. For very large tables mention gh-ost or pt-online-schema-change.",
+		Tuning:  "ANALYZE TABLE, histograms (ANALYZE TABLE ... UPDATE HISTOGRAM ON col), sort_buffer_size and tmp_table_size for filesorts and temporary tables",
+	},
+	sqlplan.SQLite: {
+		Indexes: "Write plain CREATE INDEX statements. SQLite supports partial (WHERE) and expression indexes but has no online build: CREATE INDEX holds the database write lock until it finishes. A covering index avoids the table lookup; WITHOUT ROWID tables are clustered on their primary key.",
+		Tuning:  "ANALYZE to populate sqlite_stat1 (or PRAGMA optimize), so the planner stops building automatic indexes and temp b-trees per query",
+	},
+}
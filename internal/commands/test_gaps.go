@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/tools/cover"
+
+	"github.com/riskiramdan/ShELDon/internal/analysis"
+	"github.com/riskiramdan/ShELDon/internal/textutil"
+)
+
+// NewTestGapsCommand ranks uncovered functions and suggests test cases for them.
+func NewTestGapsCommand(deps Dependencies) *cobra.Command {
+	var (
+		pkgPattern string
+		profile    string
+		top        int
+		model      string
+		write      bool
+		verify     bool
+		maxRepairs int
+		style      string
+		parallel   int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "test-gaps",
+		Short: "Rank uncovered functions from a coverage profile and suggest the tests that would cover them",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+
+			data, err := loadCoverProfile(cmd, deps, pkgPattern, profile)
+			if err != nil {
+				return err
+			}
+			profiles, err := cover.ParseProfilesFromReader(bytes.NewReader(data))
+			if err != nil {
+				return fmt.Errorf("parse coverage profile: %w", err)
+			}
+			gaps, err := analysis.CoverageGaps(".", profiles)
+			if err != nil {
+				return err
+			}
+			deps.Logger.Info(cmd, "%d functions have statements no test ever executed. I am not surprised, merely disappointed.", len(gaps))
+			if len(gaps) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No coverage gaps.")
+				return nil
+			}
+			if top > 0 && len(gaps) > top {
+				gaps = gaps[:top]
+			}
+			writeGapTable(cmd.OutOrStdout(), gaps)
+
+			modelUse := textutil.Choose(model, deps.Config.ModelReason)
+			jobs := make([]genTestJob, 0, len(gaps))
+			for i, gap := range gaps {
+				deps.Logger.Info(cmd, "Asking model %s how to reach the dark corners of %s (%d of %d).", modelUse, gap.Selector, i+1, len(gaps))
+				ctx, cancel := context.WithTimeout(cmd.Context(), deps.Config.Timeout)
+				cases, err := suggestGapCases(ctx, deps, modelUse, gap)
+				cancel()
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "\n## %s (%s:%d)\n\n%s\n", gap.Selector, displayPath(gap.File), gap.Line, cases)
+				jobs = append(jobs, genTestJob{
+					File:     gap.File,
+					Selector: gap.Selector,
+					Out:      defaultTestFile(gap.File, gap.Selector, ""),
+					Cases:    cases,
+				})
+			}
+			if !write {
+				return nil
+			}
+
+			testStyle, err := resolveTestStyle(style, detectTestStyle(deps, "."))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout())
+			return runGenTestJobs(cmd, deps, genTestOptions{
+				Model:      modelUse,
+				Verify:     verify,
+				MaxRepairs: maxRepairs,
+				Kind:       "unit",
+				Style:      testStyle,
+			}, jobs, parallel)
+		},
+	}
+
+	cmd.Flags().StringVar(&pkgPattern, "pkg", "./...", "Packages to run go test -coverprofile on")
+	cmd.Flags().StringVar(&profile, "profile", "", "Use an existing coverage profile instead of running go test")
+	cmd.Flags().IntVar(&top, "top", 10, "Number of gaps to analyse (0 for all)")
+	cmd.Flags().StringVar(&model, "model", "", "Override model (default SHELDON_MODEL_REASON)")
+	cmd.Flags().BoolVar(&write, "write", false, "Generate tests for the suggested cases with the gen-tests writer (merging into existing files)")
+	cmd.Flags().BoolVar(&verify, "verify", false, "With --write, compile and run the tests and ask the model to repair failures")
+	cmd.Flags().IntVar(&maxRepairs, "max-repairs", 3, "With --verify, maximum repair rounds")
+	cmd.Flags().StringVar(&style, "style", "auto", "With --write, test libraries: auto (from go.mod), stdlib, testify or gomock")
+	cmd.Flags().IntVar(&parallel, "parallel", 4, "With --write, functions generated concurrently")
+	return cmd
+}
+
+// loadCoverProfile reads profile, or runs go test on pattern to produce one. Failing
+// tests do not stop the analysis as long as a profile was written.
+func loadCoverProfile(cmd *cobra.Command, deps Dependencies, pattern, profile string) ([]byte, error) {
+	if profile != "" {
+		return deps.Files.ReadFile(profile)
+	}
+	tmp := filepath.Join(os.TempDir(), fmt.Sprintf("sheldon-gaps-%d.out", os.Getpid()))
+	defer os.Remove(tmp)
+
+	deps.Logger.Info(cmd, "Running go test -coverprofile on %s. Measuring your diligence, objectively.", pattern)
+	out, testErr := deps.Shell.Exec(".", "go", "test", "-count=1", "-coverprofile="+tmp, pattern)
+	data, err := deps.Files.ReadFile(tmp)
+	if err != nil {
+		if testErr != nil {
+			return nil, fmt.Errorf("go test -coverprofile: %w\n%s", testErr, out)
+		}
+		return nil, err
+	}
+	if testErr != nil {
+		deps.Logger.Info(cmd, "Some tests fail; their packages may report less coverage than they have. Fix them first, ideally.")
+	}
+	return data, nil
+}
+
+// writeGapTable prints the ranked gaps; the output depends only on the profile.
+func writeGapTable(w io.Writer, gaps []analysis.FuncCoverage) {
+	fmt.Fprintf(w, "%-4s  %7s  %9s  %6s  %3s  %s\n", "RANK", "SCORE", "UNCOVERED", "COVER", "CC", "FUNCTION")
+	for i, g := range gaps {
+		fmt.Fprintf(w, "%-4d  %7d  %4d/%-4d  %5.1f%%  %3d  %s (%s:%d)\n",
+			i+1, g.Score(), g.Uncovered, g.Statements, g.Percent(), g.Complexity, g.Selector, displayPath(g.File), g.Line)
+	}
+}
+
+func displayPath(file string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return file
+}
+
+// suggestGapCases asks the model for the concrete cases that would execute the
+// function's uncovered lines.
+func suggestGapCases(ctx context.Context, deps Dependencies, model string, gap analysis.FuncCoverage) (string, error) {
+	prompt := fmt.Sprintf(`The Go function %s below is only %.0f%% covered by tests. Lines marked ">>" never ran.
+List the specific test cases that would execute the marked lines, one per bullet:
+"- <case name>: <inputs and setup> -> <expected result>". Name the marked line numbers each case reaches.
+Only propose cases that are reachable through the function's parameters, receiver and dependencies; no prose before or after the list.
+
+%s`, gap.Selector, gap.Percent(), gap.AnnotatedSource())
+	ans, err := deps.LLM.Generate(ctx, model, prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(textutil.NormalizeCode(ans)), nil
+}
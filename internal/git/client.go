@@ -2,14 +2,31 @@ package git
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os/exec"
+	"strings"
 )
 
 // Client abstracts interaction with the git binary.
 type Client interface {
 	Diff(args ...string) (string, error)
 	Commit(message string) error
+	ApplyCached(patch string) error
+	WriteTree() (string, error)
+	ReadTree(treeish string) error
+	RevParse(ref string) (string, error)
+	ResetSoft(ref string) error
+	StagedFiles() ([]FileChange, error)
+	Status() ([]StatusEntry, error)
+	Log(revRange string, paths ...string) ([]Commit, error)
+	Show(object string) (string, error)
+	MergeBase(a, b string) (string, error)
+	Blame(path string, start, end int) ([]BlameLine, error)
+	CurrentBranch() (string, error)
+	RepoRoot() (string, error)
+	LatestTag(ref string) (string, error)
+	Grep(word string, pathspecs ...string) ([]GrepMatch, error)
 }
 
 // CLIClient runs git commands via the local binary.
@@ -39,3 +56,140 @@ func (CLIClient) Commit(message string) error {
 	}
 	return nil
 }
+
+// ApplyCached applies a patch to the index only, leaving the working tree alone.
+func (CLIClient) ApplyCached(patch string) error {
+	_, err := run(patch, "apply", "--cached", "-")
+	return err
+}
+
+// WriteTree records the current index as a tree object and returns its hash.
+func (CLIClient) WriteTree() (string, error) {
+	out, err := run("", "write-tree")
+	return strings.TrimSpace(out), err
+}
+
+// ReadTree replaces the index with the given tree-ish.
+func (CLIClient) ReadTree(treeish string) error {
+	_, err := run("", "read-tree", treeish)
+	return err
+}
+
+// RevParse resolves a ref to its full object name.
+func (CLIClient) RevParse(ref string) (string, error) {
+	out, err := run("", "rev-parse", "--verify", ref)
+	return strings.TrimSpace(out), err
+}
+
+// ResetSoft moves HEAD to ref without touching the index or working tree.
+func (CLIClient) ResetSoft(ref string) error {
+	_, err := run("", "reset", "--soft", ref)
+	return err
+}
+
+// StagedFiles lists files changed in the index relative to HEAD, with rename detection.
+func (CLIClient) StagedFiles() ([]FileChange, error) {
+	out, err := run("", "diff", "--cached", "--name-status", "-M", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return parseNameStatus(out)
+}
+
+// Status returns `git status --porcelain` entries for the working tree and index.
+func (CLIClient) Status() ([]StatusEntry, error) {
+	out, err := run("", "status", "--porcelain=v1", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(out)
+}
+
+// Log returns commits in revRange (e.g. "v1.0.0..HEAD"), newest first, optionally limited to paths.
+func (CLIClient) Log(revRange string, paths ...string) ([]Commit, error) {
+	args := []string{"log", logFormat}
+	if revRange != "" {
+		args = append(args, revRange)
+	}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	out, err := run("", args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseLog(out)
+}
+
+// Show returns `git show` output for an object, e.g. a commit or "<ref>:<path>".
+func (CLIClient) Show(object string) (string, error) {
+	return run("", "show", "--no-color", object)
+}
+
+// MergeBase returns the best common ancestor of a and b.
+func (CLIClient) MergeBase(a, b string) (string, error) {
+	out, err := run("", "merge-base", a, b)
+	return strings.TrimSpace(out), err
+}
+
+// Blame attributes lines start..end (1-based, inclusive) of path; zero bounds blame the whole file.
+func (CLIClient) Blame(path string, start, end int) ([]BlameLine, error) {
+	args := []string{"blame", "--line-porcelain"}
+	if start > 0 && end >= start {
+		args = append(args, "-L", fmt.Sprintf("%d,%d", start, end))
+	}
+	out, err := run("", append(args, "--", path)...)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out)
+}
+
+// CurrentBranch returns the checked-out branch name, or "HEAD" when detached.
+func (CLIClient) CurrentBranch() (string, error) {
+	out, err := run("", "rev-parse", "--abbrev-ref", "HEAD")
+	return strings.TrimSpace(out), err
+}
+
+// RepoRoot returns the absolute path of the working tree's top-level directory.
+func (CLIClient) RepoRoot() (string, error) {
+	out, err := run("", "rev-parse", "--show-toplevel")
+	return strings.TrimSpace(out), err
+}
+
+// LatestTag returns the most recent tag reachable from ref.
+func (CLIClient) LatestTag(ref string) (string, error) {
+	out, err := run("", "describe", "--tags", "--abbrev=0", ref)
+	return strings.TrimSpace(out), err
+}
+
+// Grep finds tracked files containing word as a whole word, case-insensitively,
+// limited to the pathspecs; no match is not an error.
+func (CLIClient) Grep(word string, pathspecs ...string) ([]GrepMatch, error) {
+	args := append([]string{"grep", "-n", "-z", "-I", "-w", "-i", "-F", "-e", word, "--"}, pathspecs...)
+	out, err := run("", args...)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseGrep(out)
+}
+
+// run executes git with optional stdin and returns stdout.
+func run(stdin string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return string(out), nil
+}
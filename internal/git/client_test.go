@@ -0,0 +1,111 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	out := "M\x00a.go\x00R087\x00old.go\x00new.go\x00A\x00dir/with space.go\x00"
+	changes, err := parseNameStatus(out)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d", len(changes))
+	}
+	if changes[1].Status != 'R' || changes[1].OrigPath != "old.go" || changes[1].Path != "new.go" {
+		t.Fatalf("unexpected rename: %#v", changes[1])
+	}
+	if changes[2].Path != "dir/with space.go" {
+		t.Fatalf("unexpected path: %q", changes[2].Path)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	out := "M  staged.go\x00 M dirty.go\x00R  new.go\x00old.go\x00?? untracked.go\x00"
+	entries, err := parseStatus(out)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	if !entries[0].Staged() || entries[1].Staged() || entries[3].Staged() {
+		t.Fatalf("unexpected staged flags: %#v", entries)
+	}
+	if entries[2].OrigPath != "old.go" {
+		t.Fatalf("expected rename origin, got %#v", entries[2])
+	}
+}
+
+func TestCLIClientAgainstRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git binary not available")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"config", "user.email", "dev@example.com"},
+		{"config", "user.name", "Dev"},
+	} {
+		if _, err := run("", args...); err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := run("", "add", "a.txt"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	client := CLIClient{}
+	staged, err := client.StagedFiles()
+	if err != nil || len(staged) != 1 || staged[0].Status != 'A' {
+		t.Fatalf("unexpected staged files %#v (%v)", staged, err)
+	}
+	if err := client.Commit("feat: first\n\nbody text"); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	commits, err := client.Log("HEAD")
+	if err != nil {
+		t.Fatalf("log: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Subject != "feat: first" || commits[0].Body != "body text" || commits[0].Author != "Dev" {
+		t.Fatalf("unexpected log: %#v", commits)
+	}
+
+	blame, err := client.Blame("a.txt", 2, 2)
+	if err != nil {
+		t.Fatalf("blame: %v", err)
+	}
+	if len(blame) != 1 || blame[0].Line != 2 || blame[0].Text != "two" || blame[0].Hash != commits[0].Hash {
+		t.Fatalf("unexpected blame: %#v", blame)
+	}
+
+	show, err := client.Show("HEAD:a.txt")
+	if err != nil || show != "one\ntwo\n" {
+		t.Fatalf("unexpected show %q (%v)", show, err)
+	}
+	if base, err := client.MergeBase("HEAD", "main"); err != nil || base != commits[0].Hash {
+		t.Fatalf("unexpected merge base %q (%v)", base, err)
+	}
+	if branch, err := client.CurrentBranch(); err != nil || branch != "main" {
+		t.Fatalf("unexpected branch %q (%v)", branch, err)
+	}
+	if root, err := client.RepoRoot(); err != nil || filepath.Base(root) != filepath.Base(dir) {
+		t.Fatalf("unexpected root %q (%v)", root, err)
+	}
+	if matches, err := client.Grep("TWO", "*.txt"); err != nil || len(matches) != 1 || matches[0].Path != "a.txt" || matches[0].Line != 2 {
+		t.Fatalf("unexpected grep %#v (%v)", matches, err)
+	}
+	if matches, err := client.Grep("three"); err != nil || len(matches) != 0 {
+		t.Fatalf("expected no matches, got %#v (%v)", matches, err)
+	}
+}
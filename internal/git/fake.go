@@ -0,0 +1,186 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FakeClient is an in-memory Client for tests. Populate the fields that the code
+// under test reads; calls that mutate state are recorded for later assertions.
+type FakeClient struct {
+	// Diffs maps space-joined Diff arguments (e.g. "--staged") to their output.
+	Diffs map[string]string
+	// Objects maps Show arguments (e.g. "HEAD:go.mod") to their output.
+	Objects map[string]string
+	// Refs maps ref names to hashes for RevParse; ResetSoft updates "HEAD".
+	Refs map[string]string
+	// MergeBases maps "a...b" to the merge base hash.
+	MergeBases map[string]string
+	// Blames maps file paths to their full blame.
+	Blames map[string][]BlameLine
+	// Logs maps revision ranges to commits; the "" key answers any unlisted range.
+	Logs map[string][]Commit
+	// Greps maps lower-cased Grep words to their matches, whatever the pathspecs.
+	Greps map[string][]GrepMatch
+
+	Staged  []FileChange
+	Entries []StatusEntry
+	Branch  string
+	Root    string
+	// Tag is returned by LatestTag; empty means no tag is reachable.
+	Tag string
+
+	// Tree is the current index tree returned by WriteTree and replaced by ReadTree.
+	Tree string
+	// ApplyErr, when set, is returned by ApplyCached for patches containing the key.
+	ApplyErr map[string]error
+	// CommitErr, when non-nil, is returned by Commit.
+	CommitErr error
+
+	Applied   []string
+	Committed []string
+}
+
+// NewFakeClient returns an empty FakeClient with all maps initialised.
+func NewFakeClient() *FakeClient {
+	return &FakeClient{
+		Diffs:      map[string]string{},
+		Objects:    map[string]string{},
+		Refs:       map[string]string{},
+		MergeBases: map[string]string{},
+		Blames:     map[string][]BlameLine{},
+		Logs:       map[string][]Commit{},
+		Greps:      map[string][]GrepMatch{},
+		ApplyErr:   map[string]error{},
+		Branch:     "main",
+	}
+}
+
+// Diff returns the canned output for args.
+func (f *FakeClient) Diff(args ...string) (string, error) {
+	return f.Diffs[strings.Join(args, " ")], nil
+}
+
+// Commit records the message and advances the fake HEAD.
+func (f *FakeClient) Commit(message string) error {
+	if f.CommitErr != nil {
+		return f.CommitErr
+	}
+	f.Committed = append(f.Committed, message)
+	f.Refs["HEAD"] = fmt.Sprintf("fake%036d", len(f.Committed))
+	return nil
+}
+
+// ApplyCached records the patch, failing when it contains a key of ApplyErr.
+func (f *FakeClient) ApplyCached(patch string) error {
+	for key, err := range f.ApplyErr {
+		if strings.Contains(patch, key) {
+			return err
+		}
+	}
+	f.Applied = append(f.Applied, patch)
+	return nil
+}
+
+// WriteTree returns the current fake index tree.
+func (f *FakeClient) WriteTree() (string, error) {
+	return f.Tree, nil
+}
+
+// ReadTree replaces the fake index tree.
+func (f *FakeClient) ReadTree(treeish string) error {
+	f.Tree = treeish
+	return nil
+}
+
+// RevParse resolves ref from Refs.
+func (f *FakeClient) RevParse(ref string) (string, error) {
+	if hash, ok := f.Refs[ref]; ok {
+		return hash, nil
+	}
+	return "", fmt.Errorf("unknown revision %s", ref)
+}
+
+// ResetSoft points the fake HEAD at ref.
+func (f *FakeClient) ResetSoft(ref string) error {
+	f.Refs["HEAD"] = ref
+	return nil
+}
+
+// StagedFiles returns Staged.
+func (f *FakeClient) StagedFiles() ([]FileChange, error) {
+	return f.Staged, nil
+}
+
+// Status returns Entries.
+func (f *FakeClient) Status() ([]StatusEntry, error) {
+	return f.Entries, nil
+}
+
+// Log returns the commits registered for revRange; path filtering is not simulated.
+func (f *FakeClient) Log(revRange string, paths ...string) ([]Commit, error) {
+	if commits, ok := f.Logs[revRange]; ok {
+		return commits, nil
+	}
+	return f.Logs[""], nil
+}
+
+// Show returns the canned output for object.
+func (f *FakeClient) Show(object string) (string, error) {
+	if out, ok := f.Objects[object]; ok {
+		return out, nil
+	}
+	return "", fmt.Errorf("fatal: bad object %s", object)
+}
+
+// MergeBase returns the canned merge base for a...b.
+func (f *FakeClient) MergeBase(a, b string) (string, error) {
+	if hash, ok := f.MergeBases[a+"..."+b]; ok {
+		return hash, nil
+	}
+	return "", fmt.Errorf("no merge base for %s and %s", a, b)
+}
+
+// Blame returns the requested line range from Blames.
+func (f *FakeClient) Blame(path string, start, end int) ([]BlameLine, error) {
+	lines, ok := f.Blames[path]
+	if !ok {
+		return nil, fmt.Errorf("no such path %s", path)
+	}
+	if start <= 0 || end < start {
+		return lines, nil
+	}
+	var out []BlameLine
+	for _, l := range lines {
+		if l.Line >= start && l.Line <= end {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+// CurrentBranch returns Branch.
+func (f *FakeClient) CurrentBranch() (string, error) {
+	return f.Branch, nil
+}
+
+// RepoRoot returns Root.
+func (f *FakeClient) RepoRoot() (string, error) {
+	return f.Root, nil
+}
+
+// LatestTag returns Tag.
+func (f *FakeClient) LatestTag(ref string) (string, error) {
+	if f.Tag == "" {
+		return "", fmt.Errorf("no tags reachable from %s", ref)
+	}
+	return f.Tag, nil
+}
+
+// Grep returns the canned matches for word.
+func (f *FakeClient) Grep(word string, pathspecs ...string) ([]GrepMatch, error) {
+	return f.Greps[strings.ToLower(word)], nil
+}
+
+var _ Client = (*FakeClient)(nil)
+var _ Client = CLIClient{}
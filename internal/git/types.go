@@ -0,0 +1,216 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileChange is one entry of `git diff --name-status`.
+type FileChange struct {
+	// Status is the single-letter change kind: A, M, D, R, C, T or U.
+	Status   byte
+	Path     string
+	OrigPath string // set for renames and copies
+}
+
+// StatusEntry is one entry of `git status --porcelain`.
+type StatusEntry struct {
+	Index    byte // staged state, ' ' when unchanged
+	Worktree byte // unstaged state, ' ' when unchanged
+	Path     string
+	OrigPath string
+}
+
+// Staged reports whether the entry has changes recorded in the index.
+func (e StatusEntry) Staged() bool {
+	return e.Index != ' ' && e.Index != '?' && e.Index != '!'
+}
+
+// Commit is a parsed `git log` entry.
+type Commit struct {
+	Hash    string
+	Parents []string
+	Author  string
+	Email   string
+	Date    time.Time
+	Subject string
+	Body    string
+}
+
+// BlameLine attributes a single source line to the commit that last touched it.
+type BlameLine struct {
+	Line    int
+	Hash    string
+	Author  string
+	Time    time.Time
+	Summary string
+	Text    string
+}
+
+// GrepMatch is one line of `git grep` output.
+type GrepMatch struct {
+	Path string
+	Line int
+	Text string
+}
+
+// Log format: fields separated by 0x1f, records terminated by 0x1e.
+const logFormat = "--format=%H%x1f%P%x1f%an%x1f%ae%x1f%aI%x1f%s%x1f%b%x1e"
+
+func parseLog(out string) ([]Commit, error) {
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+		fields := strings.SplitN(record, "\x1f", 7)
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("unexpected git log record: %q", record)
+		}
+		date, err := time.Parse(time.RFC3339, fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("parse commit date %q: %w", fields[4], err)
+		}
+		commits = append(commits, Commit{
+			Hash:    fields[0],
+			Parents: strings.Fields(fields[1]),
+			Author:  fields[2],
+			Email:   fields[3],
+			Date:    date,
+			Subject: fields[5],
+			Body:    strings.TrimSpace(fields[6]),
+		})
+	}
+	return commits, nil
+}
+
+// parseNameStatus reads `git diff --name-status -z` output.
+func parseNameStatus(out string) ([]FileChange, error) {
+	tokens := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	var changes []FileChange
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] == "" {
+			continue
+		}
+		change := FileChange{Status: tokens[i][0]}
+		switch change.Status {
+		case 'R', 'C':
+			if i+2 >= len(tokens) {
+				return nil, fmt.Errorf("truncated name-status entry %q", tokens[i])
+			}
+			change.OrigPath, change.Path = tokens[i+1], tokens[i+2]
+			i += 2
+		default:
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("truncated name-status entry %q", tokens[i])
+			}
+			change.Path = tokens[i+1]
+			i++
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// parseStatus reads `git status --porcelain=v1 -z` output.
+func parseStatus(out string) ([]StatusEntry, error) {
+	tokens := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	var entries []StatusEntry
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok == "" {
+			continue
+		}
+		if len(tok) < 4 {
+			return nil, fmt.Errorf("unexpected status entry %q", tok)
+		}
+		entry := StatusEntry{Index: tok[0], Worktree: tok[1], Path: tok[3:]}
+		if entry.Index == 'R' || entry.Index == 'C' {
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("truncated status entry %q", tok)
+			}
+			entry.OrigPath = tokens[i+1]
+			i++
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseBlame reads `git blame --line-porcelain` output.
+func parseBlame(out string) ([]BlameLine, error) {
+	var (
+		lines   []BlameLine
+		current BlameLine
+	)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if strings.HasPrefix(text, "\t") {
+			current.Text = text[1:]
+			lines = append(lines, current)
+			current = BlameLine{}
+			continue
+		}
+		key, value, _ := strings.Cut(text, " ")
+		switch key {
+		case "author":
+			current.Author = value
+		case "author-time":
+			secs, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse blame author-time %q: %w", value, err)
+			}
+			current.Time = time.Unix(secs, 0).UTC()
+		case "summary":
+			current.Summary = value
+		default:
+			if current.Hash == "" && len(key) >= 40 && isHex(key) {
+				fields := strings.Fields(value)
+				if len(fields) < 2 {
+					return nil, fmt.Errorf("unexpected blame header %q", text)
+				}
+				n, err := strconv.Atoi(fields[1])
+				if err != nil {
+					return nil, fmt.Errorf("parse blame line number %q: %w", fields[1], err)
+				}
+				current.Hash, current.Line = key, n
+			}
+		}
+	}
+	return lines, scanner.Err()
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseGrep reads `git grep -n -z` output: path, line and text separated by NULs.
+func parseGrep(out string) ([]GrepMatch, error) {
+	var matches []GrepMatch
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\x00", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected git grep line %q", line)
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected git grep line %q", line)
+		}
+		matches = append(matches, GrepMatch{Path: fields[0], Line: n, Text: fields[2]})
+	}
+	return matches, nil
+}
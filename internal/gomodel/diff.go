@@ -0,0 +1,178 @@
+package gomodel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change is one schema change between two versions of the models. Kind is one
+// of create-table, drop-table, rename-table, add-column, drop-column,
+// rename-column, alter-type, set-not-null, drop-not-null, set-default,
+// drop-default, add-unique, drop-unique, add-index and drop-index.
+type Change struct {
+	Kind  string
+	Table string
+	// OldTable is the table's name before a rename-table.
+	OldTable string
+	// Model is the new model, or the old one of a drop-table.
+	Model *Model
+	// Column is the new column, or the old one of a drop-column; Old is the
+	// column before the change.
+	Column *Column
+	Old    *Column
+}
+
+func (c Change) String() string {
+	col := ""
+	if c.Column != nil {
+		col = c.Table + "." + c.Column.Name
+	}
+	switch c.Kind {
+	case "create-table":
+		defs := make([]string, len(c.Model.Columns))
+		for i, column := range c.Model.Columns {
+			defs[i] = column.Definition()
+		}
+		return fmt.Sprintf("create table %s (%s)", c.Table, strings.Join(defs, ", "))
+	case "drop-table":
+		return "drop table " + c.Table
+	case "rename-table":
+		return fmt.Sprintf("rename table %s to %s", c.OldTable, c.Table)
+	case "add-column":
+		return fmt.Sprintf("add column %s.%s", c.Table, c.Column.Definition())
+	case "drop-column":
+		return "drop column " + col
+	case "rename-column":
+		return fmt.Sprintf("rename column %s.%s to %s", c.Table, c.Old.Name, c.Column.Name)
+	case "alter-type":
+		return fmt.Sprintf("change type of %s from %s to %s", col, typeOf(c.Old), typeOf(c.Column))
+	case "set-not-null":
+		return fmt.Sprintf("make %s NOT NULL", col)
+	case "drop-not-null":
+		return fmt.Sprintf("make %s nullable", col)
+	case "set-default":
+		return fmt.Sprintf("set default of %s to %s", col, c.Column.Default)
+	case "drop-default":
+		return fmt.Sprintf("drop default of %s (was %s)", col, c.Old.Default)
+	case "add-unique":
+		return "add unique index on " + col
+	case "drop-unique":
+		return "drop unique index on " + col
+	case "add-index":
+		return "add index on " + col
+	case "drop-index":
+		return "drop index on " + col
+	}
+	return c.Kind + " " + c.Table
+}
+
+func typeOf(c *Column) string {
+	if c.Type == "" {
+		return "<Go " + c.GoType + ">"
+	}
+	return c.Type
+}
+
+// Diff lists the changes that turn the models before into the models after.
+// Models match by package and type name, then by table; columns match by Go
+// field, so a changed tag is a rename. Changes come in table order.
+func Diff(before, after []Model) []Change {
+	oldByKey := map[string]*Model{}
+	for i := range before {
+		oldByKey[before[i].Key()] = &before[i]
+	}
+	matched := map[*Model]bool{}
+	pairs := map[*Model]*Model{} // new to old
+	for i := range after {
+		if o := oldByKey[after[i].Key()]; o != nil {
+			pairs[&after[i]], matched[o] = o, true
+		}
+	}
+	// A model moved to another package keeps its table.
+	for i := range after {
+		if pairs[&after[i]] != nil {
+			continue
+		}
+		for j := range before {
+			if !matched[&before[j]] && before[j].Table == after[i].Table {
+				pairs[&after[i]], matched[&before[j]] = &before[j], true
+				break
+			}
+		}
+	}
+
+	var changes []Change
+	for i := range after {
+		m := &after[i]
+		o := pairs[m]
+		if o == nil {
+			changes = append(changes, Change{Kind: "create-table", Table: m.Table, Model: m})
+			continue
+		}
+		if o.Table != m.Table {
+			changes = append(changes, Change{Kind: "rename-table", Table: m.Table, OldTable: o.Table, Model: m})
+		}
+		changes = append(changes, diffColumns(o, m)...)
+	}
+	for i := range before {
+		if !matched[&before[i]] {
+			changes = append(changes, Change{Kind: "drop-table", Table: before[i].Table, Model: &before[i]})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Table < changes[j].Table
+	})
+	return changes
+}
+
+func diffColumns(o, m *Model) []Change {
+	var changes []Change
+	add := func(kind string, col, old *Column) {
+		changes = append(changes, Change{Kind: kind, Table: m.Table, Model: m, Column: col, Old: old})
+	}
+	for i := range m.Columns {
+		col := &m.Columns[i]
+		was := o.Column(col.Field)
+		if was == nil {
+			add("add-column", col, nil)
+			continue
+		}
+		if was.Name != col.Name {
+			add("rename-column", col, was)
+		}
+		if typeOf(was) != typeOf(col) {
+			add("alter-type", col, was)
+		}
+		switch {
+		case col.NotNull && !was.NotNull:
+			add("set-not-null", col, was)
+		case !col.NotNull && was.NotNull:
+			add("drop-not-null", col, was)
+		}
+		switch {
+		case col.Default != "" && col.Default != was.Default:
+			add("set-default", col, was)
+		case col.Default == "" && was.Default != "":
+			add("drop-default", col, was)
+		}
+		switch {
+		case col.Unique && !was.Unique:
+			add("add-unique", col, was)
+		case !col.Unique && was.Unique:
+			add("drop-unique", col, was)
+		}
+		switch {
+		case col.Index && !was.Index:
+			add("add-index", col, was)
+		case !col.Index && was.Index:
+			add("drop-index", col, was)
+		}
+	}
+	for i := range o.Columns {
+		if m.Column(o.Columns[i].Field) == nil {
+			add("drop-column", &o.Columns[i], nil)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,412 @@
+// Package gomodel reads database models from Go source: structs whose fields
+// carry db (sqlx and friends) or gorm tags. Two versions of the models, say
+// at a git ref and in the working tree, diff into the schema changes between
+// them.
+package gomodel
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Model is a struct mapped to a table.
+type Model struct {
+	// Package is the slash-separated directory of the file, which tells apart
+	// types of the same name.
+	Package string
+	Name    string
+	File    string
+	Table   string
+	Columns []Column
+}
+
+// Key identifies the model across versions of the source.
+func (m *Model) Key() string {
+	return m.Package + "." + m.Name
+}
+
+// Column returns the column of the Go field, or nil.
+func (m *Model) Column(field string) *Column {
+	for i := range m.Columns {
+		if m.Columns[i].Field == field {
+			return &m.Columns[i]
+		}
+	}
+	return nil
+}
+
+// Column is a struct field mapped to a column.
+type Column struct {
+	// Field is the Go field, with the embedded struct it came from when it was
+	// promoted, such as "Model.ID".
+	Field  string
+	Name   string
+	GoType string
+	// Type is the PostgreSQL type: the gorm type option, or the usual mapping
+	// of the Go type; empty when neither says.
+	Type string
+	// NotNull holds when the Go type cannot hold NULL, gorm says not null, or
+	// the column is the primary key.
+	NotNull    bool
+	PrimaryKey bool
+	Default    string
+	Unique     bool
+	Index      bool
+}
+
+// Definition renders the column as in CREATE TABLE, with the Go type standing
+// in for an unknown SQL type.
+func (c Column) Definition() string {
+	typ := c.Type
+	if typ == "" {
+		typ = "<Go " + c.GoType + ">"
+	}
+	parts := []string{c.Name, typ}
+	if c.PrimaryKey {
+		parts = append(parts, "PRIMARY KEY")
+	} else if c.NotNull {
+		parts = append(parts, "NOT NULL")
+	}
+	if c.Default != "" {
+		parts = append(parts, "DEFAULT "+c.Default)
+	}
+	if c.Unique {
+		parts = append(parts, "UNIQUE")
+	}
+	return strings.Join(parts, " ")
+}
+
+// gormModel is what embedding gorm.Model adds.
+var gormModel = []Column{
+	{Field: "Model.ID", Name: "id", GoType: "uint", Type: "bigint", NotNull: true, PrimaryKey: true},
+	{Field: "Model.CreatedAt", Name: "created_at", GoType: "time.Time", Type: "timestamptz", NotNull: true},
+	{Field: "Model.UpdatedAt", Name: "updated_at", GoType: "time.Time", Type: "timestamptz", NotNull: true},
+	{Field: "Model.DeletedAt", Name: "deleted_at", GoType: "gorm.DeletedAt", Type: "timestamptz", Index: true},
+}
+
+// sqlTypes maps Go types to PostgreSQL as gorm and hand-written schemas
+// usually do; pointers and sql.Null types are nullable versions of the same.
+var sqlTypes = map[string]string{
+	"bool":            "boolean",
+	"int":             "bigint",
+	"int64":           "bigint",
+	"uint":            "bigint",
+	"uint64":          "bigint",
+	"int32":           "integer",
+	"uint32":          "integer",
+	"int16":           "smallint",
+	"uint16":          "smallint",
+	"int8":            "smallint",
+	"uint8":           "smallint",
+	"float32":         "real",
+	"float64":         "double precision",
+	"string":          "text",
+	"[]byte":          "bytea",
+	"time.Time":       "timestamptz",
+	"uuid.UUID":       "uuid",
+	"json.RawMessage": "jsonb",
+	"decimal.Decimal": "numeric",
+	"sql.NullString":  "text",
+	"sql.NullInt64":   "bigint",
+	"sql.NullInt32":   "integer",
+	"sql.NullInt16":   "smallint",
+	"sql.NullBool":    "boolean",
+	"sql.NullFloat64": "double precision",
+	"sql.NullTime":    "timestamptz",
+	"gorm.DeletedAt":  "timestamptz",
+}
+
+// structDecl is a struct type of the file before its fields are resolved.
+type structDecl struct {
+	name  string
+	typ   *ast.StructType
+	table string // from a TableName method
+}
+
+// ParseFile reads the models of one Go file; file is its slash-separated path
+// in the repository. A struct is a model when a field has a db or gorm tag, or
+// it embeds gorm.Model. Its table comes from a TableName method returning a
+// string literal, or else gorm's convention: the plural of the snake-cased
+// type name. Fields of embedded structs declared in the same file are
+// promoted; gorm associations (slices and the file's other models) are not
+// columns.
+func ParseFile(file, src string) ([]Model, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), file, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+	structs := map[string]*structDecl{}
+	var order []string
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if st, ok := ts.Type.(*ast.StructType); ok {
+				structs[ts.Name.Name] = &structDecl{name: ts.Name.Name, typ: st}
+				order = append(order, ts.Name.Name)
+			}
+		}
+	}
+	for _, decl := range f.Decls {
+		if name, table, ok := tableNameMethod(decl); ok && structs[name] != nil {
+			structs[name].table = table
+		}
+	}
+
+	isModel := map[string]bool{}
+	for _, name := range order {
+		isModel[name] = tagged(structs[name].typ)
+	}
+	// A struct other structs embed, such as a shared Base, is not a table.
+	for _, name := range order {
+		for _, field := range structs[name].typ.Fields.List {
+			_, embedded := gormOptions(tagOf(field).Get("gorm"))["embedded"]
+			if len(field.Names) == 0 || embedded {
+				delete(isModel, strings.TrimPrefix(types.ExprString(field.Type), "*"))
+			}
+		}
+	}
+	var models []Model
+	for _, name := range order {
+		if !isModel[name] {
+			continue
+		}
+		s := structs[name]
+		m := Model{Package: path.Dir(file), Name: name, File: file, Table: s.table}
+		if m.Table == "" {
+			m.Table = plural(snake(name))
+		}
+		m.Columns = columns(s.typ, structs, isModel, "", "", map[string]bool{name: true})
+		models = append(models, m)
+	}
+	return models, nil
+}
+
+// tagged reports whether a struct looks like a model.
+func tagged(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if types.ExprString(field.Type) == "gorm.Model" {
+			return true
+		}
+		if field.Tag != nil {
+			tag := tagOf(field)
+			if _, ok := tag.Lookup("db"); ok {
+				return true
+			}
+			if _, ok := tag.Lookup("gorm"); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// columns resolves the fields of st. prefix is the gorm embeddedPrefix and
+// owner the embedded field the columns are promoted from; seen guards against
+// recursive embedding.
+func columns(st *ast.StructType, structs map[string]*structDecl, isModel map[string]bool, prefix, owner string, seen map[string]bool) []Column {
+	dbOnly := !usesGorm(st)
+	var out []Column
+	for _, field := range st.Fields.List {
+		goType := types.ExprString(field.Type)
+		tag := tagOf(field)
+		db, hasDB := tag.Lookup("db")
+		db, _, _ = strings.Cut(db, ",")
+		opts := gormOptions(tag.Get("gorm"))
+		if db == "-" || opts["-"] != "" {
+			continue
+		}
+
+		if len(field.Names) == 0 {
+			// Embedded: gorm.Model, or a struct of the same file.
+			base := strings.TrimPrefix(goType, "*")
+			if base == "gorm.Model" {
+				out = append(out, gormModel...)
+				continue
+			}
+			if s := structs[base]; s != nil && !seen[base] {
+				seen[base] = true
+				out = append(out, columns(s.typ, structs, isModel, prefix+opts["embeddedprefix"], joinField(owner, base), seen)...)
+				delete(seen, base)
+			}
+			continue
+		}
+
+		for _, ident := range field.Names {
+			if !ident.IsExported() || (dbOnly && !hasDB) {
+				continue
+			}
+			if _, ok := opts["embedded"]; ok {
+				if s := structs[strings.TrimPrefix(goType, "*")]; s != nil && !seen[s.name] {
+					seen[s.name] = true
+					out = append(out, columns(s.typ, structs, isModel, prefix+opts["embeddedprefix"], joinField(owner, ident.Name), seen)...)
+					delete(seen, s.name)
+				}
+				continue
+			}
+			if association(goType, isModel) {
+				continue
+			}
+			col := Column{Field: joinField(owner, ident.Name), GoType: goType, Default: opts["default"]}
+			switch {
+			case opts["column"] != "":
+				col.Name = opts["column"]
+			case hasDB && db != "":
+				col.Name = db
+			default:
+				col.Name = snake(ident.Name)
+			}
+			col.Name = prefix + col.Name
+			nullable := strings.HasPrefix(goType, "*") || strings.HasPrefix(goType, "sql.Null") || goType == "gorm.DeletedAt" || goType == "[]byte" || goType == "json.RawMessage"
+			col.Type = sqlTypes[strings.TrimPrefix(goType, "*")]
+			if t := opts["type"]; t != "" {
+				col.Type = t
+			}
+			_, pk := opts["primarykey"]
+			_, notNull := opts["not null"]
+			col.PrimaryKey = pk
+			col.NotNull = pk || notNull || !nullable
+			_, col.Unique = opts["unique"]
+			if _, ok := opts["uniqueindex"]; ok {
+				col.Unique = true
+			}
+			_, col.Index = opts["index"]
+			out = append(out, col)
+		}
+	}
+	return out
+}
+
+func joinField(owner, name string) string {
+	if owner == "" {
+		return name
+	}
+	return owner + "." + name
+}
+
+// usesGorm reports whether the struct maps every exported field, as gorm does,
+// rather than only the db-tagged ones, as sqlx does.
+func usesGorm(st *ast.StructType) bool {
+	for _, field := range st.Fields.List {
+		if types.ExprString(field.Type) == "gorm.Model" {
+			return true
+		}
+		if _, ok := tagOf(field).Lookup("gorm"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// association reports whether a field is a gorm relation rather than a column.
+func association(goType string, isModel map[string]bool) bool {
+	if strings.HasPrefix(goType, "[]") && goType != "[]byte" {
+		return true
+	}
+	if strings.HasPrefix(goType, "map[") {
+		return true
+	}
+	return isModel[strings.TrimPrefix(goType, "*")]
+}
+
+func tagOf(field *ast.Field) reflect.StructTag {
+	if field.Tag == nil {
+		return ""
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(tag)
+}
+
+// gormOptions reads a gorm tag, such as "column:email;not null;index", into
+// lower-cased keys and their values; flags map to "" and "-" to "-".
+func gormOptions(tag string) map[string]string {
+	opts := map[string]string{}
+	for _, part := range strings.Split(tag, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, "-") {
+			opts["-"] = "-"
+			continue
+		}
+		key, value, _ := strings.Cut(part, ":")
+		key = strings.ToLower(strings.Join(strings.Fields(key), " "))
+		if key == "primary_key" {
+			key = "primarykey"
+		}
+		opts[key] = strings.TrimSpace(value)
+	}
+	return opts
+}
+
+// tableNameMethod matches `func (T) TableName() string { return "name" }`.
+func tableNameMethod(decl ast.Decl) (typeName, table string, ok bool) {
+	fn, isFunc := decl.(*ast.FuncDecl)
+	if !isFunc || fn.Recv == nil || fn.Name.Name != "TableName" || fn.Body == nil || len(fn.Recv.List) != 1 {
+		return "", "", false
+	}
+	recv := fn.Recv.List[0].Type
+	if star, isStar := recv.(*ast.StarExpr); isStar {
+		recv = star.X
+	}
+	ident, isIdent := recv.(*ast.Ident)
+	if !isIdent {
+		return "", "", false
+	}
+	for _, stmt := range fn.Body.List {
+		ret, isRet := stmt.(*ast.ReturnStmt)
+		if !isRet || len(ret.Results) != 1 {
+			continue
+		}
+		if lit, isLit := ret.Results[0].(*ast.BasicLit); isLit && lit.Kind == token.STRING {
+			if s, err := strconv.Unquote(lit.Value); err == nil {
+				return ident.Name, s, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// snake converts a Go name to snake_case, keeping initialisms together:
+// UserID is user_id and HTTPServer http_server.
+func snake(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// plural forms English plurals the simple way gorm's default does for the
+// common cases.
+func plural(word string) string {
+	switch {
+	case strings.HasSuffix(word, "y") && len(word) > 1 && !strings.ContainsRune("aeiou", rune(word[len(word)-2])):
+		return word[:len(word)-1] + "ies"
+	case strings.HasSuffix(word, "s"), strings.HasSuffix(word, "x"), strings.HasSuffix(word, "ch"), strings.HasSuffix(word, "sh"):
+		return word + "es"
+	}
+	return word + "s"
+}
@@ -0,0 +1,142 @@
+package gomodel
+
+import (
+	"strings"
+	"testing"
+)
+
+const userV1 = `package models
+
+import "gorm.io/gorm"
+
+type Base struct {
+	ID        int64 ` + "`gorm:\"primaryKey\"`" + `
+	CreatedAt time.Time
+}
+
+type User struct {
+	Base
+	Email  string  ` + "`gorm:\"column:mail;uniqueIndex\"`" + `
+	Name   *string
+	Age    int32
+	Orders []Order
+	secret string
+}
+
+type Order struct {
+	gorm.Model
+	UserID int64 ` + "`gorm:\"index\"`" + `
+}
+
+type Account struct {
+	ID      int64          ` + "`db:\"id\"`" + `
+	Balance sql.NullInt64  ` + "`db:\"balance\"`" + `
+	Scratch string
+}
+
+func (Account) TableName() string { return "ledger_accounts" }
+`
+
+const userV2 = `package models
+
+import "gorm.io/gorm"
+
+type Base struct {
+	ID        int64 ` + "`gorm:\"primaryKey\"`" + `
+	CreatedAt time.Time
+}
+
+type User struct {
+	Base
+	Email    string ` + "`gorm:\"column:email;uniqueIndex\"`" + `
+	Name     string ` + "`gorm:\"default:''\"`" + `
+	Age      int64
+	Nickname *string ` + "`gorm:\"type:varchar(40)\"`" + `
+	Orders   []Order
+}
+
+type Account struct {
+	ID      int64         ` + "`db:\"id\"`" + `
+	Balance sql.NullInt64 ` + "`db:\"balance\"`" + `
+	Scratch string
+}
+
+func (Account) TableName() string { return "accounts" }
+
+type Category struct {
+	Slug string ` + "`db:\"slug\"`" + `
+}
+`
+
+func TestParseFile(t *testing.T) {
+	models, err := ParseFile("internal/models/user.go", userV1)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var got []string
+	for _, m := range models {
+		var cols []string
+		for _, c := range m.Columns {
+			cols = append(cols, c.Definition())
+		}
+		got = append(got, m.Table+"("+strings.Join(cols, ", ")+")")
+	}
+	want := []string{
+		// Base is only embedded; Orders is an association and secret unexported.
+		"users(id bigint PRIMARY KEY, created_at timestamptz NOT NULL, mail text NOT NULL UNIQUE, name text, age integer NOT NULL)",
+		"orders(id bigint PRIMARY KEY, created_at timestamptz NOT NULL, updated_at timestamptz NOT NULL, deleted_at timestamptz, user_id bigint NOT NULL)",
+		// Only the db-tagged fields of an sqlx struct are columns.
+		"ledger_accounts(id bigint NOT NULL, balance bigint)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if models[0].Package != "internal/models" || models[1].Column("UserID") == nil || !models[1].Column("UserID").Index {
+		t.Fatalf("unexpected models: %+v", models)
+	}
+
+	if _, err := ParseFile("bad.go", "package"); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before, err := ParseFile("models/user.go", userV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := ParseFile("models/user.go", userV2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range Diff(before, after) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"rename table ledger_accounts to accounts",
+		"create table categories (slug text NOT NULL)",
+		"drop table orders",
+		"rename column users.mail to email",
+		"make users.name NOT NULL",
+		"set default of users.name to ''",
+		"change type of users.age from integer to bigint",
+		"add column users.nickname varchar(40)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSnakeAndPlural(t *testing.T) {
+	for in, want := range map[string]string{"UserID": "user_id", "HTTPServer": "http_server", "OrderItem2": "order_item2", "ID": "id"} {
+		if got := snake(in); got != want {
+			t.Errorf("snake(%s) = %s, want %s", in, got, want)
+		}
+	}
+	for in, want := range map[string]string{"category": "categories", "day": "days", "box": "boxes", "user": "users"} {
+		if got := plural(in); got != want {
+			t.Errorf("plural(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -17,6 +18,8 @@ type Logger interface {
 
 // SheldonLogger renders progress updates with Sheldon's unique flair.
 type SheldonLogger struct {
+	// mu serialises lines from concurrent workers and guards rng.
+	mu       sync.Mutex
 	rng      *rand.Rand
 	identity []string
 	prefix   []string
@@ -84,6 +87,8 @@ func (l *SheldonLogger) Info(cmd *cobra.Command, format string, args ...interfac
 	}
 	writer := cmd.ErrOrStderr()
 	msg := fmt.Sprintf(format, args...)
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	prefix := l.prefix[l.rng.Intn(len(l.prefix))]
 	l.animate(writer)
 	lead := "Sheldon Cooper"
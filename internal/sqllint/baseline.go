@@ -0,0 +1,59 @@
+package sqllint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Baseline holds findings accepted in earlier runs, keyed by file, rule and
+// Finding.Fingerprint, so they no longer count against a CI gate.
+type Baseline map[string]bool
+
+func baselineKey(path string, f Finding) string {
+	return path + "\t" + f.Rule.ID + "\t" + f.Fingerprint()
+}
+
+// ParseBaseline reads a baseline written by FormatBaseline. Blank lines and
+// lines starting with # are skipped.
+func ParseBaseline(text string) (Baseline, error) {
+	b := Baseline{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("baseline line %d: expected path, rule and fingerprint separated by tabs", i+1)
+		}
+		b[strings.Join(fields[:3], "\t")] = true
+	}
+	return b, nil
+}
+
+// Has reports whether the baseline accepts the finding in the file at path.
+func (b Baseline) Has(path string, f Finding) bool {
+	return b[baselineKey(path, f)]
+}
+
+// Add accepts the finding in the file at path.
+func (b Baseline) Add(path string, f Finding) {
+	b[baselineKey(path, f)] = true
+}
+
+// FormatBaseline writes one "path<TAB>rule<TAB>fingerprint" line per accepted
+// finding, sorted so the file diffs cleanly.
+func FormatBaseline(b Baseline) string {
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var s strings.Builder
+	s.WriteString("# sheldon review-migration baseline: path, rule, fingerprint\n")
+	for _, k := range keys {
+		s.WriteString(k + "\n")
+	}
+	return s.String()
+}
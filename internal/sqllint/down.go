@@ -0,0 +1,210 @@
+package sqllint
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+var (
+	dropTablePattern  = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(.+?)(?:\s+(?:CASCADE|RESTRICT))?$`)
+	createTypePattern = regexp.MustCompile(`(?i)^CREATE\s+TYPE\s+(` + name + `)`)
+	dropTypePattern   = regexp.MustCompile(`(?is)^DROP\s+TYPE\s+(?:IF\s+EXISTS\s+)?(.+?)(?:\s+(?:CASCADE|RESTRICT))?$`)
+	dropConstraint    = regexp.MustCompile(`(?is)^DROP\s+CONSTRAINT\s+(?:IF\s+EXISTS\s+)?("[^"]+"|[\w$]+)`)
+	columnListPattern = regexp.MustCompile(`^\s*\(([^)]*)\)`)
+	dropNotNull       = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?("[^"]+"|[\w$]+)\s+DROP\s+NOT\s+NULL\b`)
+)
+
+// change is one effect of a statement, such as "add-column:users.email".
+type change struct {
+	kind, key string
+}
+
+// undo lists the changes of which any one in the down migration reverts an up
+// migration's change; none means the change cannot be undone.
+type undo struct {
+	changes []change
+	// expect describes the missing statement.
+	expect string
+}
+
+// CheckDown reports the changes of an up migration that its down migration
+// does not revert, on the lines of the up statements. It matches statements by
+// kind and name only: that a down migration drops the column an up migration
+// adds says nothing of whether the down restores the old data.
+func CheckDown(up, down string) []Finding {
+	have := map[change]bool{}
+	for _, st := range sqlschema.Statements(down) {
+		for _, c := range changes(st.Text) {
+			have[c] = true
+		}
+	}
+	var findings []Finding
+	for _, st := range sqlschema.Statements(up) {
+		for _, c := range changes(st.Text) {
+			u, ok := undoOf(c)
+			if !ok {
+				continue
+			}
+			if len(u.changes) == 0 {
+				findings = append(findings, NewFinding("MG002", st.Line, strings.Join(strings.Fields(st.Text), " "), u.expect))
+				continue
+			}
+			reverted := false
+			for _, want := range u.changes {
+				reverted = reverted || have[want]
+			}
+			if !reverted {
+				findings = append(findings, NewFinding("MG002", st.Line, strings.Join(strings.Fields(st.Text), " "), "the down migration does not revert this; expected "+u.expect))
+			}
+		}
+	}
+	return findings
+}
+
+// LintDown lints a down migration like Lint, except that dropping a column its
+// up migration adds is the point of the down and not reported.
+func LintDown(up, down string, opts Options) []Finding {
+	added := map[string]bool{}
+	for _, st := range sqlschema.Statements(up) {
+		for _, c := range changes(st.Text) {
+			if c.kind == "add-column" {
+				added[c.key] = true
+			}
+		}
+	}
+	var out []Finding
+	for _, f := range Lint(down, opts) {
+		if f.Rule.ID == "PG006" && dropsOnly(f.Statement, added) {
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// dropsOnly reports whether every column the statement drops is in added.
+func dropsOnly(statement string, added map[string]bool) bool {
+	for _, c := range changes(statement) {
+		if c.kind == "drop-column" && !added[c.key] {
+			return false
+		}
+	}
+	return true
+}
+
+// changes lists what a statement does, in terms CheckDown can match.
+func changes(text string) []change {
+	if m := createTablePattern.FindStringSubmatch(text); m != nil {
+		return []change{{"create-table", tableKey(m[1])}}
+	}
+	if m := dropTablePattern.FindStringSubmatch(text); m != nil {
+		var out []change
+		for _, t := range sqlschema.SplitTopLevel(m[1]) {
+			out = append(out, change{"drop-table", tableKey(t)})
+		}
+		return out
+	}
+	if m := createIndexPattern.FindStringSubmatch(text); m != nil && m[2] != "" {
+		return []change{{"create-index", tableKey(m[2])}}
+	}
+	if m := dropIndexPattern.FindStringSubmatch(text); m != nil {
+		return []change{{"drop-index", tableKey(m[2])}}
+	}
+	if m := createTypePattern.FindStringSubmatch(text); m != nil {
+		return []change{{"create-type", tableKey(m[1])}}
+	}
+	if m := dropTypePattern.FindStringSubmatch(text); m != nil {
+		var out []change
+		for _, t := range sqlschema.SplitTopLevel(m[1]) {
+			out = append(out, change{"drop-type", tableKey(t)})
+		}
+		return out
+	}
+	if m := alterTypePattern.FindStringSubmatch(text); m != nil && strings.EqualFold(strings.Join(strings.Fields(m[2]), " "), "ADD VALUE") {
+		return []change{{"add-value", tableKey(m[1])}}
+	}
+	m := alterTablePattern.FindStringSubmatch(text)
+	if m == nil {
+		return nil
+	}
+	table := tableKey(m[1])
+	var out []change
+	for _, action := range sqlschema.SplitTopLevel(m[2]) {
+		action = strings.TrimSpace(action)
+		switch {
+		case addConstraint.MatchString(action):
+			if c := addConstraint.FindStringSubmatch(action); c[1] != "" {
+				// Dropping a column the constraint covers drops it too.
+				key := table + "." + strings.ToLower(unquote(c[1]))
+				if cols := columnListPattern.FindStringSubmatch(c[3]); cols != nil && !strings.EqualFold(c[2], "CHECK") && !strings.EqualFold(c[2], "EXCLUDE") {
+					key += "|" + strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(cols[1], `"`, "")), ""))
+				}
+				out = append(out, change{"add-constraint", key})
+			}
+		case dropConstraint.MatchString(action):
+			out = append(out, change{"drop-constraint", table + "." + strings.ToLower(unquote(dropConstraint.FindStringSubmatch(action)[1]))})
+		case addColumnPattern.MatchString(action):
+			out = append(out, change{"add-column", table + "." + strings.ToLower(unquote(addColumnPattern.FindStringSubmatch(action)[1]))})
+		case alterColumnType.MatchString(action):
+			out = append(out, change{"alter-type", table + "." + strings.ToLower(unquote(alterColumnType.FindStringSubmatch(action)[1]))})
+		case setNotNull.MatchString(action):
+			out = append(out, change{"set-not-null", table + "." + strings.ToLower(unquote(setNotNull.FindStringSubmatch(action)[1]))})
+		case dropNotNull.MatchString(action):
+			out = append(out, change{"drop-not-null", table + "." + strings.ToLower(unquote(dropNotNull.FindStringSubmatch(action)[1]))})
+		case dropColumn.MatchString(action):
+			out = append(out, change{"drop-column", table + "." + strings.ToLower(unquote(dropColumn.FindStringSubmatch(action)[1]))})
+		case renameColumn.MatchString(action):
+			c := renameColumn.FindStringSubmatch(action)
+			out = append(out, change{"rename-column", table + "." + strings.ToLower(unquote(c[1])) + ">" + strings.ToLower(unquote(c[2]))})
+		case renameTable.MatchString(action):
+			out = append(out, change{"rename-table", table + ">" + tableKey(renameTable.FindStringSubmatch(action)[1])})
+		}
+	}
+	return out
+}
+
+// undoOf says what reverts c; false means c needs no undoing.
+func undoOf(c change) (undo, bool) {
+	table, column, _ := strings.Cut(c.key, ".")
+	dropTable := change{"drop-table", table}
+	switch c.kind {
+	case "create-table":
+		return undo{[]change{dropTable}, "DROP TABLE " + table}, true
+	case "create-index":
+		return undo{[]change{{"drop-index", c.key}}, "DROP INDEX " + c.key}, true
+	case "create-type":
+		return undo{[]change{{"drop-type", c.key}}, "DROP TYPE " + c.key}, true
+	case "add-column":
+		return undo{[]change{{"drop-column", c.key}, dropTable}, "ALTER TABLE " + table + " DROP COLUMN " + column}, true
+	case "add-constraint":
+		constraint, cols, _ := strings.Cut(column, "|")
+		u := undo{[]change{{"drop-constraint", table + "." + constraint}, dropTable}, "ALTER TABLE " + table + " DROP CONSTRAINT " + constraint}
+		for _, col := range strings.Split(cols, ",") {
+			if col != "" {
+				u.changes = append(u.changes, change{"drop-column", table + "." + col})
+			}
+		}
+		return u, true
+	case "alter-type":
+		return undo{[]change{{"alter-type", c.key}}, "ALTER TABLE " + table + " ALTER COLUMN " + column + " TYPE <old type>"}, true
+	case "set-not-null":
+		return undo{[]change{{"drop-not-null", c.key}, {"drop-column", c.key}, dropTable}, "ALTER TABLE " + table + " ALTER COLUMN " + column + " DROP NOT NULL"}, true
+	case "drop-column":
+		return undo{[]change{{"add-column", c.key}}, "ALTER TABLE " + table + " ADD COLUMN " + column + " (its data is gone either way)"}, true
+	case "drop-table":
+		return undo{[]change{{"create-table", c.key}}, "CREATE TABLE " + c.key + " (its data is gone either way)"}, true
+	case "drop-index":
+		return undo{[]change{{"create-index", c.key}}, "CREATE INDEX " + c.key}, true
+	case "rename-column":
+		from, to, _ := strings.Cut(column, ">")
+		return undo{[]change{{"rename-column", table + "." + to + ">" + from}}, "ALTER TABLE " + table + " RENAME COLUMN " + to + " TO " + from}, true
+	case "rename-table":
+		from, to, _ := strings.Cut(c.key, ">")
+		return undo{[]change{{"rename-table", to + ">" + from}}, "ALTER TABLE " + to + " RENAME TO " + from}, true
+	case "add-value":
+		return undo{expect: "PostgreSQL cannot drop a value from enum " + c.key + "; the down migration would have to recreate the type"}, true
+	}
+	return undo{}, false
+}
@@ -0,0 +1,77 @@
+package sqllint
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// Ignore is a "-- sheldon:ignore RULE[,RULE...] reason" comment. On the lines
+// of a statement it suppresses that statement's findings; on a line of its own
+// between statements, those of the statement that follows. Findings about the
+// whole file, which have no statement, are suppressed by a comment anywhere.
+type Ignore struct {
+	Line int
+	// Rules are IDs or names as written.
+	Rules  []string
+	Reason string
+}
+
+var ignorePattern = regexp.MustCompile(`--\s*sheldon:ignore\s+([\w,-]+)\s*(.*)$`)
+
+// Ignores finds the sheldon:ignore comments of a migration.
+func Ignores(sql string) []Ignore {
+	var out []Ignore
+	scanner := bufio.NewScanner(strings.NewReader(sql))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		m := ignorePattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		out = append(out, Ignore{Line: line, Rules: strings.Split(strings.Trim(m[1], ","), ","), Reason: strings.TrimSpace(m[2])})
+	}
+	return out
+}
+
+// Matches reports whether the comment names r and gives a reason; an ignore
+// without a reason suppresses nothing.
+func (ig Ignore) Matches(r Rule) bool {
+	if ig.Reason == "" {
+		return false
+	}
+	for _, id := range ig.Rules {
+		if strings.EqualFold(id, r.ID) || strings.EqualFold(id, r.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyIgnores sets Ignored on the findings of sql that its sheldon:ignore
+// comments suppress.
+func ApplyIgnores(sql string, findings []Finding) {
+	ignores := Ignores(sql)
+	if len(ignores) == 0 {
+		return
+	}
+	// Each statement owns the lines after the previous one ends, up to its end.
+	owner := map[int][2]int{}
+	prevEnd := 0
+	for _, st := range sqlschema.Statements(sql) {
+		owner[st.Line] = [2]int{prevEnd + 1, st.EndLine}
+		prevEnd = st.EndLine
+	}
+	for i := range findings {
+		f := &findings[i]
+		span, ok := owner[f.Line]
+		for _, ig := range ignores {
+			if (f.Statement == "" || ok && ig.Line >= span[0] && ig.Line <= span[1]) && ig.Matches(f.Rule) {
+				f.Ignored = ig.Reason
+				break
+			}
+		}
+	}
+}
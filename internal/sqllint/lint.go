@@ -0,0 +1,303 @@
+package sqllint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// Options tunes Lint.
+type Options struct {
+	// ColumnUses, when set, returns where code still mentions a column that the
+	// migration drops; any use raises the drop-column finding to an error.
+	ColumnUses func(table, column string) []string
+	// Transaction says the migration tool runs the whole file in a transaction,
+	// as goose and atlas do by default.
+	Transaction bool
+	// State, when set, carries NOT NULL checks from one migration to the
+	// next, so a SET NOT NULL after a check validated by an earlier migration
+	// is not reported. Lint the migrations in version order.
+	State *State
+}
+
+// State is what earlier migrations of a set established for later ones.
+type State struct {
+	// checks maps NOT VALID "col IS NOT NULL" constraints to "table.column";
+	// validated holds the "table.column" pairs whose check was validated.
+	checks    map[string]string
+	validated map[string]bool
+}
+
+// NewState returns the state of an empty database.
+func NewState() *State {
+	return &State{checks: map[string]string{}, validated: map[string]bool{}}
+}
+
+const name = `(?:"[^"]+"|[\w$]+)(?:\.(?:"[^"]+"|[\w$]+))?`
+
+var (
+	beginPattern       = regexp.MustCompile(`(?i)^(?:BEGIN|START\s+TRANSACTION)\b`)
+	endPattern         = regexp.MustCompile(`(?i)^(?:COMMIT|END|ROLLBACK)\b`)
+	lockTimeoutPattern = regexp.MustCompile(`(?i)^SET\s+(?:LOCAL\s+|SESSION\s+)?lock_timeout\b`)
+	createTablePattern = regexp.MustCompile(`(?i)^CREATE\s+(?:(?:GLOBAL|LOCAL)\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?(` + name + `)`)
+	createIndexPattern = regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:(` + name + `)\s+)?ON\s+(?:ONLY\s+)?(` + name + `)`)
+	dropIndexPattern   = regexp.MustCompile(`(?i)^DROP\s+INDEX\s+(CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?(` + name + `)`)
+	alterTablePattern  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(` + name + `)\s+(.+)$`)
+	alterTypePattern   = regexp.MustCompile(`(?is)^ALTER\s+TYPE\s+(` + name + `)\s+(ADD\s+VALUE|RENAME\s+VALUE)\b(.*)$`)
+	otherLockPattern   = regexp.MustCompile(`(?i)^(?:DROP\s+TABLE|TRUNCATE|CREATE\s+(?:OR\s+REPLACE\s+)?TRIGGER|REINDEX|VACUUM\s+FULL|LOCK\s+TABLE)\b`)
+
+	addColumnPattern   = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?("[^"]+"|[\w$]+)\s+(.*)$`)
+	addConstraint      = regexp.MustCompile(`(?is)^ADD\s+(?:CONSTRAINT\s+("[^"]+"|[\w$]+)\s+)?(PRIMARY\s+KEY|UNIQUE|FOREIGN\s+KEY|CHECK|EXCLUDE)\b(.*)$`)
+	alterColumnType    = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?("[^"]+"|[\w$]+)\s+(?:SET\s+DATA\s+)?TYPE\s+(.+)$`)
+	setNotNull         = regexp.MustCompile(`(?is)^ALTER\s+(?:COLUMN\s+)?("[^"]+"|[\w$]+)\s+SET\s+NOT\s+NULL\b`)
+	dropColumn         = regexp.MustCompile(`(?is)^DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?("[^"]+"|[\w$]+)`)
+	renameColumn       = regexp.MustCompile(`(?is)^RENAME\s+(?:COLUMN\s+)?("[^"]+"|[\w$]+)\s+TO\s+("[^"]+"|[\w$]+)$`)
+	renameTable        = regexp.MustCompile(`(?is)^RENAME\s+TO\s+("[^"]+"|[\w$]+)$`)
+	validateConstraint = regexp.MustCompile(`(?is)^VALIDATE\s+CONSTRAINT\s+("[^"]+"|[\w$]+)`)
+	notNullCheck       = regexp.MustCompile(`(?is)^\(\s*\(?\s*("[^"]+"|[\w$]+)\s*\)?\s+IS\s+NOT\s+NULL\s*\)`)
+
+	defaultPattern  = regexp.MustCompile(`(?is)\bDEFAULT\s+(.+?)(?:\s+(?:NOT\s+NULL|NULL|CONSTRAINT|CHECK|REFERENCES|UNIQUE|PRIMARY|GENERATED|COLLATE)\b|$)`)
+	volatileCall    = regexp.MustCompile(`(?i)\b(random|gen_random_uuid|uuid_generate_v\w+|clock_timestamp|timeofday|nextval)\s*\(`)
+	serialType      = regexp.MustCompile(`(?i)^(?:small|big)?serial\b|^serial[248]\b`)
+	generatedStored = regexp.MustCompile(`(?i)\bGENERATED\s+(?:ALWAYS|BY\s+DEFAULT)\s+AS\s+(?:IDENTITY\b|\(.*\)\s*STORED\b)`)
+	notNullPattern  = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	notValidPattern = regexp.MustCompile(`(?i)\bNOT\s+VALID\b`)
+	usingIndex      = regexp.MustCompile(`(?i)\bUSING\s+INDEX\s+("[^"]+"|[\w$]+)`)
+	positionPattern = regexp.MustCompile(`(?i)\b(BEFORE|AFTER)\s+'`)
+)
+
+// linter carries what earlier statements established.
+type linter struct {
+	opts     Options
+	findings []Finding
+	inTx     bool
+	lockSet  bool
+	warned   bool // lock-timeout reported once per file
+	// created are tables created by this migration, which nothing else can be
+	// using yet; keys are lower-cased bare names.
+	created map[string]bool
+	*State
+}
+
+// Lint checks the statements of a PostgreSQL migration in order. Findings a
+// sheldon:ignore comment suppresses are returned with Ignored set.
+func Lint(sql string, opts Options) []Finding {
+	l := &linter{opts: opts, inTx: opts.Transaction, created: map[string]bool{}, State: opts.State}
+	if l.State == nil {
+		l.State = NewState()
+	}
+	for _, st := range sqlschema.Statements(sql) {
+		l.statement(st)
+	}
+	ApplyIgnores(sql, l.findings)
+	return l.findings
+}
+
+func (l *linter) add(id string, st sqlschema.Statement, format string, args ...any) {
+	r := rule(id)
+	l.findings = append(l.findings, Finding{Rule: r, Severity: r.Severity, Line: st.Line, Statement: strings.Join(strings.Fields(st.Text), " "), Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) statement(st sqlschema.Statement) {
+	text := st.Text
+	switch {
+	case beginPattern.MatchString(text):
+		l.inTx = true
+		return
+	case endPattern.MatchString(text):
+		l.inTx = false
+		return
+	case lockTimeoutPattern.MatchString(text):
+		l.lockSet = true
+		return
+	}
+
+	if m := createTablePattern.FindStringSubmatch(text); m != nil {
+		l.created[tableKey(m[1])] = true
+		return
+	}
+	if m := createIndexPattern.FindStringSubmatch(text); m != nil {
+		table := m[3]
+		switch {
+		case m[1] != "" && l.inTx:
+			l.add("PG011", st, "CREATE INDEX CONCURRENTLY on %s is inside a transaction block and will fail; run it on its own, outside a transaction (goose: -- +goose NO TRANSACTION; atlas: -- atlas:txmode none; golang-migrate: one statement per file)", table)
+		case m[1] == "" && !l.created[tableKey(table)]:
+			l.lockTimeout(st, table)
+			l.add("PG002", st, "CREATE INDEX on %s blocks writes to the table until the build finishes; use CREATE INDEX CONCURRENTLY outside a transaction", table)
+		}
+		return
+	}
+	if m := dropIndexPattern.FindStringSubmatch(text); m != nil {
+		switch {
+		case m[1] != "" && l.inTx:
+			l.add("PG011", st, "DROP INDEX CONCURRENTLY %s is inside a transaction block and will fail; run it on its own, outside a transaction", m[2])
+		case m[1] == "":
+			l.lockTimeout(st, m[2])
+			l.add("PG009", st, "DROP INDEX %s takes an ACCESS EXCLUSIVE lock on its table; use DROP INDEX CONCURRENTLY", m[2])
+		}
+		return
+	}
+	if m := alterTypePattern.FindStringSubmatch(text); m != nil {
+		l.alterType(st, m[1], strings.ToUpper(strings.Join(strings.Fields(m[2]), " ")), m[3])
+		return
+	}
+	if m := alterTablePattern.FindStringSubmatch(text); m != nil {
+		table := m[1]
+		if l.created[tableKey(table)] {
+			return
+		}
+		l.lockTimeout(st, table)
+		for _, action := range sqlschema.SplitTopLevel(m[2]) {
+			l.alterTable(st, table, strings.TrimSpace(action))
+		}
+		return
+	}
+	if otherLockPattern.MatchString(text) {
+		l.lockTimeout(st, "")
+	}
+}
+
+// lockTimeout reports the first lock-taking statement that runs without a
+// lock_timeout.
+func (l *linter) lockTimeout(st sqlschema.Statement, table string) {
+	if l.lockSet || l.warned {
+		return
+	}
+	l.warned = true
+	on := ""
+	if table != "" {
+		on = " on " + table
+	}
+	l.add("PG008", st, "this statement takes a lock%s with no lock_timeout set; start the migration with SET lock_timeout = '5s' (and retry) so it fails fast instead of queueing all traffic behind it", on)
+}
+
+func (l *linter) alterTable(st sqlschema.Statement, table, action string) {
+	if m := addConstraint.FindStringSubmatch(action); m != nil {
+		l.addConstraint(st, table, m[1], strings.ToUpper(strings.Join(strings.Fields(m[2]), " ")), m[3])
+		return
+	}
+	if m := addColumnPattern.FindStringSubmatch(action); m != nil {
+		l.addColumn(st, table, unquote(m[1]), m[2])
+		return
+	}
+	if m := alterColumnType.FindStringSubmatch(action); m != nil {
+		l.add("PG003", st, "changing %s.%s to %s rewrites the table and its indexes under an ACCESS EXCLUSIVE lock; add a new column, backfill it in batches and switch over", table, unquote(m[1]), strings.TrimSpace(strings.SplitN(m[2], " USING ", 2)[0]))
+		return
+	}
+	if m := setNotNull.FindStringSubmatch(action); m != nil {
+		col := unquote(m[1])
+		if !l.validated[tableKey(table)+"."+strings.ToLower(col)] {
+			l.add("PG004", st, "SET NOT NULL on %s.%s scans the table under an ACCESS EXCLUSIVE lock; first ADD CONSTRAINT ... CHECK (%s IS NOT NULL) NOT VALID, then VALIDATE CONSTRAINT, then SET NOT NULL", table, col, col)
+		}
+		return
+	}
+	if m := dropColumn.FindStringSubmatch(action); m != nil && !strings.HasPrefix(strings.ToUpper(action), "DROP CONSTRAINT") {
+		l.dropColumn(st, table, unquote(m[1]))
+		return
+	}
+	if m := renameColumn.FindStringSubmatch(action); m != nil && !strings.EqualFold(m[1], "CONSTRAINT") {
+		l.add("PG005", st, "renaming %s.%s to %s breaks code still using the old name; add the new column, write to both, backfill, move readers, then drop the old one", table, unquote(m[1]), unquote(m[2]))
+		return
+	}
+	if m := renameTable.FindStringSubmatch(action); m != nil {
+		l.add("PG005", st, "renaming table %s to %s breaks code still using the old name; rename it and leave a view under the old name until every reader has moved", table, unquote(m[1]))
+		return
+	}
+	if m := validateConstraint.FindStringSubmatch(action); m != nil {
+		if col, ok := l.checks[strings.ToLower(unquote(m[1]))]; ok {
+			l.validated[col] = true
+		}
+	}
+}
+
+func (l *linter) addColumn(st sqlschema.Statement, table, col, def string) {
+	typ := strings.Fields(def + " ")[0]
+	var volatile string
+	switch {
+	case serialType.MatchString(typ):
+		volatile = "a " + strings.ToLower(typ) + " column fills every row from a sequence"
+	case generatedStored.MatchString(def):
+		volatile = "a stored generated or identity column is computed for every row"
+	default:
+		if m := defaultPattern.FindStringSubmatch(def); m != nil {
+			if fn := volatileCall.FindStringSubmatch(m[1]); fn != nil {
+				volatile = "DEFAULT " + strings.TrimSpace(m[1]) + " is volatile (" + strings.ToLower(fn[1]) + "), so every row gets its own value"
+			}
+		}
+	}
+	if volatile != "" {
+		l.add("PG001", st, "adding %s.%s rewrites the table under an ACCESS EXCLUSIVE lock: %s; add the column without a default, set the default, and backfill in batches", table, col, volatile)
+	}
+	if notNullPattern.MatchString(def) && !defaultPattern.MatchString(def) && !generatedStored.MatchString(def) && !serialType.MatchString(typ) {
+		l.add("PG004", st, "adding %s.%s as NOT NULL without a DEFAULT fails as soon as the table has a row; add it nullable, backfill, then enforce NOT NULL with a validated CHECK", table, col)
+	}
+}
+
+func (l *linter) addConstraint(st sqlschema.Statement, table, constraint, kind, rest string) {
+	constraint = unquote(constraint)
+	switch kind {
+	case "PRIMARY KEY", "UNIQUE":
+		if !usingIndex.MatchString(rest) {
+			l.add("PG002", st, "ADD %s on %s builds its index while blocking writes; CREATE UNIQUE INDEX CONCURRENTLY first, then ADD CONSTRAINT ... %s USING INDEX", kind, table, kind)
+		}
+	case "FOREIGN KEY", "CHECK":
+		if notValidPattern.MatchString(rest) {
+			if m := notNullCheck.FindStringSubmatch(strings.TrimSpace(rest)); kind == "CHECK" && m != nil && constraint != "" {
+				l.checks[strings.ToLower(constraint)] = tableKey(table) + "." + strings.ToLower(unquote(m[1]))
+			}
+			return
+		}
+		what := "constraint"
+		if constraint != "" {
+			what = constraint
+		}
+		l.add("PG010", st, "ADD %s %s on %s checks every existing row while holding its lock; add it NOT VALID, then VALIDATE CONSTRAINT in a separate statement", kind, what, table)
+	}
+}
+
+func (l *linter) dropColumn(st sqlschema.Statement, table, col string) {
+	var uses []string
+	if l.opts.ColumnUses != nil {
+		uses = l.opts.ColumnUses(table, col)
+	}
+	if len(uses) == 0 {
+		l.add("PG006", st, "dropping %s.%s breaks any code still reading or writing it; deploy code that no longer uses the column first", table, col)
+		return
+	}
+	r := rule("PG006")
+	shown := uses
+	if len(shown) > 3 {
+		shown = append(shown[:3:3], fmt.Sprintf("and %d more", len(uses)-3))
+	}
+	l.findings = append(l.findings, Finding{Rule: r, Severity: Error, Line: st.Line, Statement: strings.Join(strings.Fields(st.Text), " "),
+		Message: fmt.Sprintf("dropping %s.%s while the code still mentions it (%s); remove those uses and deploy before dropping", table, col, strings.Join(shown, ", "))})
+}
+
+func (l *linter) alterType(st sqlschema.Statement, typ, action, rest string) {
+	switch {
+	case action == "RENAME VALUE":
+		l.add("PG007", st, "renaming a value of enum %s breaks code and stored queries using the old label; add the new value, migrate rows, and stop using the old one", typ)
+	case positionPattern.MatchString(rest):
+		l.add("PG007", st, "ADD VALUE ... %s changes the sort order of enum %s, so ORDER BY and range comparisons on it change meaning; append new values at the end unless the order is intended", strings.ToUpper(positionPattern.FindStringSubmatch(rest)[1]), typ)
+	case l.inTx:
+		l.add("PG007", st, "a value added to enum %s inside a transaction cannot be used until the transaction commits (and before PostgreSQL 12 ADD VALUE cannot run in a transaction at all); add it in its own migration", typ)
+	}
+}
+
+// tableKey is the lower-cased bare name of a possibly qualified table.
+func tableKey(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 && strings.Count(name[:i], `"`)%2 == 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(unquote(name))
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+package sqllint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func ids(findings []Finding) string {
+	var out []string
+	for _, f := range findings {
+		out = append(out, f.Rule.ID)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestLint(t *testing.T) {
+	const lockTimeout = "SET lock_timeout = '5s';\n"
+	cases := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{"volatile default", lockTimeout + "ALTER TABLE users ADD COLUMN token uuid DEFAULT gen_random_uuid();", "PG001"},
+		{"constant default", lockTimeout + "ALTER TABLE users ADD COLUMN active boolean NOT NULL DEFAULT true;", ""},
+		{"serial", lockTimeout + "ALTER TABLE users ADD COLUMN seq bigserial;", "PG001"},
+		{"index", lockTimeout + "CREATE INDEX users_email_idx ON users (email);", "PG002"},
+		{"concurrent index", "CREATE INDEX CONCURRENTLY users_email_idx ON users (email);", ""},
+		{"concurrent index in transaction", "BEGIN;\nCREATE INDEX CONCURRENTLY users_email_idx ON users (email);\nCOMMIT;", "PG011"},
+		{"unique constraint", lockTimeout + "ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);", "PG002"},
+		{"unique using index", lockTimeout + "ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE USING INDEX users_email_idx;", ""},
+		{"alter type", lockTimeout + "ALTER TABLE users ALTER COLUMN id TYPE bigint;", "PG003"},
+		{"set not null", lockTimeout + "ALTER TABLE users ALTER COLUMN email SET NOT NULL;", "PG004"},
+		{"set not null after validated check", lockTimeout + `ALTER TABLE users ADD CONSTRAINT users_email_nn CHECK (email IS NOT NULL) NOT VALID;
+ALTER TABLE users VALIDATE CONSTRAINT users_email_nn;
+ALTER TABLE users ALTER COLUMN email SET NOT NULL;`, ""},
+		{"set not null before validation", lockTimeout + `ALTER TABLE users ADD CONSTRAINT users_email_nn CHECK (email IS NOT NULL) NOT VALID;
+ALTER TABLE users ALTER COLUMN email SET NOT NULL;`, "PG004"},
+		{"add not null column", lockTimeout + "ALTER TABLE users ADD COLUMN tenant_id bigint NOT NULL;", "PG004"},
+		{"rename column", lockTimeout + "ALTER TABLE users RENAME COLUMN email TO email_address;", "PG005"},
+		{"rename table", lockTimeout + "ALTER TABLE users RENAME TO accounts;", "PG005"},
+		{"drop column", lockTimeout + "ALTER TABLE users DROP COLUMN nickname;", "PG006"},
+		{"enum reorder", "ALTER TYPE status ADD VALUE 'paused' BEFORE 'done';", "PG007"},
+		{"enum append", "ALTER TYPE status ADD VALUE 'paused';", ""},
+		{"enum in transaction", "BEGIN;\nALTER TYPE status ADD VALUE 'paused';\nCOMMIT;", "PG007"},
+		{"missing lock timeout", "ALTER TABLE users ADD COLUMN bio text;\nALTER TABLE users ADD COLUMN avatar text;", "PG008"},
+		{"drop index", lockTimeout + "DROP INDEX users_email_idx;", "PG009"},
+		{"foreign key", lockTimeout + "ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id);", "PG010"},
+		{"foreign key not valid", lockTimeout + "ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id) NOT VALID;", ""},
+		{"several actions", lockTimeout + "ALTER TABLE users ADD COLUMN ref uuid DEFAULT gen_random_uuid(), ALTER COLUMN id TYPE bigint;", "PG001,PG003"},
+		{"new table", `CREATE TABLE things (id bigint);
+CREATE INDEX things_id_idx ON things (id);
+ALTER TABLE things ADD COLUMN token uuid DEFAULT gen_random_uuid(), ALTER COLUMN id SET NOT NULL;`, ""},
+	}
+	for _, tc := range cases {
+		if got := ids(Lint(tc.sql, Options{})); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLintLinesAndUses(t *testing.T) {
+	sql := `-- +goose Up
+SET lock_timeout = '5s';
+
+ALTER TABLE public.users
+  DROP COLUMN nickname;
+`
+	uses := func(table, column string) []string {
+		if table != "public.users" || column != "nickname" {
+			t.Fatalf("unexpected lookup %s.%s", table, column)
+		}
+		return []string{"internal/user/repo.go:12", "internal/user/repo.go:40", "cmd/api/main.go:7", "web/api.go:3"}
+	}
+	findings := Lint(sql, Options{ColumnUses: uses})
+	if len(findings) != 1 {
+		t.Fatalf("expected one finding, got %v", findings)
+	}
+	f := findings[0]
+	if f.Line != 4 || f.Severity != Error || f.Rule.Name != "drop-column" {
+		t.Fatalf("unexpected finding %s", f)
+	}
+	if !strings.Contains(f.Message, "internal/user/repo.go:12, internal/user/repo.go:40, cmd/api/main.go:7, and 1 more") {
+		t.Fatalf("uses missing from message: %s", f.Message)
+	}
+	if f.Statement != "ALTER TABLE public.users DROP COLUMN nickname" {
+		t.Fatalf("unexpected statement %q", f.Statement)
+	}
+}
+
+func TestLintState(t *testing.T) {
+	const lock = "SET lock_timeout = '5s';\n"
+	steps := []string{
+		lock + "ALTER TABLE users ADD CONSTRAINT users_email_nn CHECK (email IS NOT NULL) NOT VALID;",
+		lock + "ALTER TABLE users VALIDATE CONSTRAINT users_email_nn;",
+		lock + "ALTER TABLE users ALTER COLUMN email SET NOT NULL;",
+	}
+	state := NewState()
+	for i, sql := range steps {
+		if got := ids(Lint(sql, Options{State: state})); got != "" {
+			t.Fatalf("step %d: unexpected findings %s", i+1, got)
+		}
+	}
+	if got := ids(Lint(steps[2], Options{})); got != "PG004" {
+		t.Fatalf("without the earlier migrations SET NOT NULL should be reported, got %q", got)
+	}
+}
+
+func TestRuleByID(t *testing.T) {
+	if r, ok := RuleByID("pg002"); !ok || r.Name != "index-not-concurrent" {
+		t.Fatalf("lookup by ID failed: %+v", r)
+	}
+	if r, ok := RuleByID("Lock-Timeout"); !ok || r.ID != "PG008" {
+		t.Fatalf("lookup by name failed: %+v", r)
+	}
+	if _, ok := RuleByID("PG999"); ok {
+		t.Fatal("unknown rule found")
+	}
+}
+
+func TestIgnoresAndBaseline(t *testing.T) {
+	sql := `SET lock_timeout = '5s';
+-- sheldon:ignore PG002,drop-column the table has 40 rows
+CREATE INDEX t_a_idx ON t (a);
+ALTER TABLE t
+  DROP COLUMN b; -- sheldon:ignore PG006 unused since v3
+ALTER TABLE t DROP COLUMN c; -- sheldon:ignore PG006
+DROP INDEX t_a_idx;`
+	var got []string
+	findings := Lint(sql, Options{})
+	for _, f := range findings {
+		got = append(got, f.Rule.ID+"="+f.Ignored)
+	}
+	want := "PG002=the table has 40 rows,PG006=unused since v3,PG006=,PG009="
+	if strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+
+	b := Baseline{}
+	b.Add("migrations/001.sql", findings[3])
+	parsed, err := ParseBaseline(FormatBaseline(b))
+	if err != nil {
+		t.Fatalf("parse baseline: %v", err)
+	}
+	// The fingerprint ignores where the statement sits.
+	moved := Lint("SET lock_timeout = '1s';\n\nDROP  INDEX t_a_idx;", Options{})
+	if len(moved) != 1 || !parsed.Has("migrations/001.sql", moved[0]) || parsed.Has("migrations/002.sql", moved[0]) {
+		t.Fatalf("baseline lookup failed: %v", moved)
+	}
+	if _, err := ParseBaseline("just-a-path\n"); err == nil {
+		t.Fatal("expected an error for a malformed baseline line")
+	}
+}
+
+func TestCheckDown(t *testing.T) {
+	up := `CREATE TABLE tags (id bigint PRIMARY KEY);
+ALTER TABLE users ADD COLUMN tag_id bigint, ADD CONSTRAINT users_tag_fk FOREIGN KEY (tag_id) REFERENCES tags (id) NOT VALID;
+CREATE INDEX CONCURRENTLY users_tag_idx ON users (tag_id);
+ALTER TABLE users RENAME COLUMN name TO full_name;
+ALTER TYPE status ADD VALUE 'paused';
+ALTER TABLE users DROP COLUMN legacy;`
+	down := `DROP INDEX CONCURRENTLY IF EXISTS users_tag_idx;
+ALTER TABLE users DROP COLUMN tag_id;
+DROP TABLE IF EXISTS tags CASCADE;`
+	var got []string
+	for _, f := range CheckDown(up, down) {
+		got = append(got, fmt.Sprintf("%d %s", f.Line, f.Rule.ID))
+	}
+	// Dropping tag_id drops its foreign key too; the rename, the enum value and
+	// the dropped column are not reverted.
+	want := "4 MG002,5 MG002,6 MG002"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("got %s, want %s", strings.Join(got, ","), want)
+	}
+	if f := CheckDown("ALTER TABLE users RENAME COLUMN name TO full_name;", "ALTER TABLE users RENAME COLUMN full_name TO name;"); len(f) != 0 {
+		t.Fatalf("a reversed rename should pass: %v", f)
+	}
+}
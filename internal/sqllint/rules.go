@@ -0,0 +1,111 @@
+// Package sqllint checks PostgreSQL migrations against fixed rules for DDL that
+// takes long locks, rewrites tables, breaks the code still running against the
+// old schema or fails on existing rows, and for down migrations that do not
+// undo their up migration. The same migration always gets the same findings;
+// explaining them is left to the caller.
+package sqllint
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Severity ranks a finding.
+type Severity int
+
+// Severities, least severe first.
+const (
+	Warn Severity = iota + 1
+	Error
+)
+
+// String is "warn" or "error".
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warn"
+}
+
+// Rule is a check with a stable ID for reports, suppressions and baselines.
+type Rule struct {
+	// ID is "PG001"; Name is the readable alias, "volatile-default".
+	ID       string
+	Name     string
+	Severity Severity
+	// Summary states the risk in one line.
+	Summary string
+}
+
+// Rules lists every check in ID order.
+var Rules = []Rule{
+	{"PG001", "volatile-default", Error, "ADD COLUMN with a volatile DEFAULT, serial, identity or stored generated column rewrites the whole table under an ACCESS EXCLUSIVE lock"},
+	{"PG002", "index-not-concurrent", Error, "CREATE INDEX, or ADD PRIMARY KEY/UNIQUE without USING INDEX, on an existing table blocks writes until the index is built"},
+	{"PG003", "alter-column-type", Error, "ALTER COLUMN ... TYPE rewrites the table and its indexes under an ACCESS EXCLUSIVE lock, and breaks code expecting the old type"},
+	{"PG004", "not-null", Error, "SET NOT NULL scans the table under an ACCESS EXCLUSIVE lock unless a validated CHECK (col IS NOT NULL) exists; ADD COLUMN NOT NULL without DEFAULT fails on any existing row"},
+	{"PG005", "rename", Error, "renaming a column or table breaks every running instance of the code that uses the old name"},
+	{"PG006", "drop-column", Warn, "dropping a column breaks code that still reads or writes it; an error when the code still mentions it"},
+	{"PG007", "enum-change", Warn, "ALTER TYPE ... ADD VALUE BEFORE/AFTER changes the sort order of an enum, RENAME VALUE breaks code using the old label, and a new value cannot be used in the transaction that adds it"},
+	{"PG008", "lock-timeout", Warn, "DDL that takes a table lock runs without SET lock_timeout, so waiting behind a long query stalls all traffic to the table"},
+	{"PG009", "drop-index-not-concurrent", Warn, "DROP INDEX without CONCURRENTLY blocks reads and writes of the table while it waits for its lock"},
+	{"PG010", "constraint-not-valid", Warn, "ADD FOREIGN KEY or CHECK without NOT VALID scans the whole table under lock; add it NOT VALID and VALIDATE CONSTRAINT separately"},
+	{"PG011", "concurrent-in-transaction", Error, "CREATE/DROP INDEX CONCURRENTLY cannot run inside a transaction block, including the one a migration tool wraps the file in"},
+	{"MG001", "missing-down", Error, "an up migration has no down migration, or an empty one, so it cannot be rolled back"},
+	{"MG002", "down-incomplete", Warn, "the down migration does not undo every change of the up migration, or the change cannot be undone"},
+	{"MG003", "out-of-order", Error, "a new migration's version is lower than one already in history; golang-migrate never applies it and goose refuses to without allow-missing"},
+	{"MG004", "duplicate-version", Error, "two migrations share a version number"},
+	{"MG005", "atlas-sum", Error, "a migration file is not listed in atlas.sum, so atlas refuses to run the directory until atlas migrate hash is rerun"},
+	{"MG006", "missing-up", Error, "a down migration has no up migration, usually because their names differ, so the up it belongs to has no rollback"},
+}
+
+// RuleByID finds a rule by ID or name, ignoring case.
+func RuleByID(id string) (Rule, bool) {
+	for _, r := range Rules {
+		if strings.EqualFold(r.ID, id) || strings.EqualFold(r.Name, id) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func rule(id string) Rule {
+	r, ok := RuleByID(id)
+	if !ok {
+		panic("sqllint: unknown rule " + id)
+	}
+	return r
+}
+
+// NewFinding reports rule id, which must exist, at line; it is how checks
+// outside this package, such as those of a migration layout, report findings.
+func NewFinding(id string, line int, statement, message string) Finding {
+	r := rule(id)
+	return Finding{Rule: r, Severity: r.Severity, Line: line, Statement: statement, Message: message}
+}
+
+// Finding is a rule violated by one statement.
+type Finding struct {
+	Rule     Rule
+	Severity Severity
+	// Line is where the statement starts, 1-based.
+	Line      int
+	Statement string
+	Message   string
+	// Ignored is the reason given by a sheldon:ignore comment that suppresses
+	// the finding; empty when it stands.
+	Ignored string
+}
+
+// Fingerprint identifies the finding by rule and statement, so it survives
+// edits that only move the statement to another line.
+func (f Finding) Fingerprint() string {
+	h := fnv.New64a()
+	h.Write([]byte(f.Rule.ID + "\x00" + strings.ToLower(f.Statement)))
+	return fmt.Sprintf("%016x", h.Sum64())[:12]
+}
+
+// String is "12: error PG002 index-not-concurrent: <message>".
+func (f Finding) String() string {
+	return fmt.Sprintf("%d: %s %s %s: %s", f.Line, f.Severity, f.Rule.ID, f.Rule.Name, f.Message)
+}
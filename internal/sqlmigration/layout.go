@@ -0,0 +1,286 @@
+// Package sqlmigration reads directories of SQL migrations in the layouts of
+// goose, golang-migrate and atlas, including those sqlc.yaml points at, and
+// reviews each migration's up and down together: both directions are linted,
+// the down is checked against the up, and versions are checked for duplicates
+// and for new migrations numbered below ones already applied.
+package sqlmigration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// Format is a migration tool's directory layout.
+type Format string
+
+// Formats. Plain is a directory of .sql files with no down migrations.
+const (
+	Goose         Format = "goose"
+	GolangMigrate Format = "golang-migrate"
+	Atlas         Format = "atlas"
+	Plain         Format = "plain"
+)
+
+// HasDown reports whether the format keeps down migrations; atlas computes
+// them from the schema instead.
+func (f Format) HasDown() bool {
+	return f == Goose || f == GolangMigrate
+}
+
+// Migration is one version of a migration directory.
+type Migration struct {
+	Format  Format
+	Version string
+	Name    string
+	// UpPath holds the up migration and DownPath the down migration: the same
+	// file for goose, empty when there is none.
+	UpPath   string
+	DownPath string
+	// Up and Down are the SQL of each direction. In a goose file the lines of
+	// the other direction are blanked, so line numbers match the file.
+	Up   string
+	Down string
+	// UpTx and DownTx say the tool runs that direction in a transaction.
+	UpTx   bool
+	DownTx bool
+}
+
+// Set is a migration directory.
+type Set struct {
+	Dir        string
+	Format     Format
+	Migrations []*Migration
+	// Sum lists the files in atlas.sum; nil when the directory has none.
+	Sum map[string]bool
+}
+
+// ErrNoMigrations is returned by Load for a directory with no .sql files.
+var ErrNoMigrations = errors.New("no .sql migrations")
+
+var (
+	fileNamePattern = regexp.MustCompile(`^(\d+)(?:_(.*?))?(\.up|\.down)?\.sql$`)
+	gooseMarker     = regexp.MustCompile(`(?im)^\s*--\s*\+goose\s+(Up|Down|NO\s+TRANSACTION)\b`)
+	atlasNoTx       = regexp.MustCompile(`(?im)^\s*--\s*atlas:txmode\s+none\b`)
+)
+
+// Load reads the migrations of dir and detects its layout: .up.sql and
+// .down.sql pairs are golang-migrate, an atlas.sum file is atlas, and
+// "-- +goose Up" markers are goose.
+func Load(dir string) (*Set, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &Set{Dir: dir, Format: Plain}
+	files := map[string]string{}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			continue
+		}
+		if name == "atlas.sum" {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			s.Format, s.Sum = Atlas, parseSum(string(data))
+			continue
+		}
+		if !strings.EqualFold(filepath.Ext(name), ".sql") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		files[name] = string(data)
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w in %s", ErrNoMigrations, dir)
+	}
+	sort.Strings(names)
+	if s.Format != Atlas {
+		for _, name := range names {
+			switch {
+			case strings.HasSuffix(name, ".up.sql") || strings.HasSuffix(name, ".down.sql"):
+				s.Format = GolangMigrate
+			case s.Format == Plain && gooseMarker.MatchString(files[name]):
+				s.Format = Goose
+			}
+		}
+	}
+
+	byVersion := map[string]*Migration{}
+	for _, name := range names {
+		m := fileNamePattern.FindStringSubmatch(name)
+		if m == nil {
+			continue // not a versioned migration, such as a seed file
+		}
+		path := filepath.Join(dir, name)
+		if s.Format != GolangMigrate {
+			s.Migrations = append(s.Migrations, parse(s.Format, path, m[1], m[2], files[name]))
+			continue
+		}
+		mig := byVersion[m[1]+"_"+m[2]]
+		if mig == nil {
+			mig = &Migration{Format: GolangMigrate, Version: m[1], Name: m[2]}
+			byVersion[m[1]+"_"+m[2]] = mig
+			s.Migrations = append(s.Migrations, mig)
+		}
+		if m[3] == ".down" {
+			mig.DownPath, mig.Down, mig.DownTx = path, files[name], implicitTx(files[name])
+		} else {
+			mig.UpPath, mig.Up, mig.UpTx = path, files[name], implicitTx(files[name])
+		}
+	}
+	sort.SliceStable(s.Migrations, func(i, j int) bool {
+		return CompareVersions(s.Migrations[i].Version, s.Migrations[j].Version) < 0
+	})
+	return s, nil
+}
+
+// LoadFile reads a single migration, with its .down.sql sibling when path is
+// a golang-migrate .up.sql file. The layout comes from the file name and
+// markers, and from an atlas.sum next to it.
+func LoadFile(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	format := Plain
+	switch {
+	case strings.HasSuffix(name, ".up.sql"):
+		format = GolangMigrate
+	case gooseMarker.MatchString(string(data)):
+		format = Goose
+	default:
+		if _, err := os.Stat(filepath.Join(dir, "atlas.sum")); err == nil {
+			format = Atlas
+		}
+	}
+	var version, title string
+	if m := fileNamePattern.FindStringSubmatch(name); m != nil {
+		version, title = m[1], m[2]
+	}
+	mig := parse(format, path, version, title, string(data))
+	if format == GolangMigrate {
+		down := strings.TrimSuffix(path, ".up.sql") + ".down.sql"
+		if data, err := os.ReadFile(down); err == nil {
+			mig.DownPath, mig.Down, mig.DownTx = down, string(data), implicitTx(string(data))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return &Set{Dir: dir, Format: format, Migrations: []*Migration{mig}}, nil
+}
+
+// Parse makes a migration of SQL read from elsewhere, such as stdin; only
+// goose markers are recognised.
+func Parse(path, sql string) *Migration {
+	format := Plain
+	if gooseMarker.MatchString(sql) {
+		format = Goose
+	}
+	return parse(format, path, "", "", sql)
+}
+
+// ParsePair makes a golang-migrate migration of an up and down not yet
+// written, with the version and name of the up file's name.
+func ParsePair(upPath, up, downPath, down string) *Migration {
+	var version, name string
+	if m := fileNamePattern.FindStringSubmatch(filepath.Base(upPath)); m != nil {
+		version, name = m[1], m[2]
+	}
+	m := parse(GolangMigrate, upPath, version, name, up)
+	m.DownPath, m.Down, m.DownTx = downPath, down, implicitTx(down)
+	return m
+}
+
+func parse(format Format, path, version, name, sql string) *Migration {
+	m := &Migration{Format: format, Version: version, Name: name, UpPath: path, Up: sql}
+	switch format {
+	case Goose:
+		m.DownPath = path
+		m.Up, m.Down = gooseSections(sql)
+		m.UpTx = !strings.Contains(strings.ToUpper(sql), "+GOOSE NO TRANSACTION")
+		m.DownTx = m.UpTx
+	case Atlas:
+		m.UpTx = !atlasNoTx.MatchString(sql)
+	case GolangMigrate:
+		m.UpTx = implicitTx(sql)
+	}
+	return m
+}
+
+// gooseSections splits a goose file into its Up and Down sections, blanking
+// the lines of the other so both keep the file's line numbers. Lines before the
+// first marker count as Up.
+func gooseSections(sql string) (up, down string) {
+	lines := strings.Split(sql, "\n")
+	upLines := make([]string, len(lines))
+	downLines := make([]string, len(lines))
+	inDown := false
+	for i, line := range lines {
+		if m := gooseMarker.FindStringSubmatch(line); m != nil {
+			switch strings.ToLower(m[1]) {
+			case "up":
+				inDown = false
+			case "down":
+				inDown = true
+			}
+		}
+		if inDown {
+			downLines[i] = line
+		} else {
+			upLines[i] = line
+		}
+	}
+	return strings.Join(upLines, "\n"), strings.Join(downLines, "\n")
+}
+
+// implicitTx reports whether golang-migrate's PostgreSQL driver runs the file
+// in a transaction: it sends the whole file as one query, which PostgreSQL runs
+// in an implicit transaction when it holds more than one statement.
+func implicitTx(sql string) bool {
+	return len(sqlschema.Statements(sql)) > 1
+}
+
+// parseSum reads the file names listed in atlas.sum.
+func parseSum(text string) map[string]bool {
+	sum := map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.HasPrefix(fields[1], "h1:") {
+			sum[fields[0]] = true
+		}
+	}
+	return sum
+}
+
+// CompareVersions orders numeric versions by value, so "10" follows "9" and
+// "0002" equals "2"; it returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	switch {
+	case len(a) != len(b):
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,226 @@
+package sqlmigration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/sqllint"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// summary renders findings as "file:line:ID" for comparison.
+func summary(files []File) string {
+	var out []string
+	for _, f := range files {
+		for _, finding := range f.Findings {
+			out = append(out, fmt.Sprintf("%s:%d:%s", filepath.Base(f.Path), finding.Line, finding.Rule.ID))
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+const lockTimeout = "SET lock_timeout = '5s';\n"
+
+func TestGolangMigrate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"0001_users.up.sql":     "CREATE TABLE users (id bigint PRIMARY KEY);\n",
+		"0001_users.down.sql":   "DROP TABLE users;\n",
+		"0002_email.up.sql":     lockTimeout + "ALTER TABLE users ADD COLUMN email text;\nCREATE INDEX CONCURRENTLY users_email_idx ON users (email);\n",
+		"0002_email.down.sql":   "DROP INDEX CONCURRENTLY users_email_idx;\n",
+		"0010_rename.up.sql":    lockTimeout + "ALTER TABLE users RENAME COLUMN email TO mail; -- sheldon:ignore PG005 no readers yet\n",
+		"0003_no_down.up.sql":   "SELECT 1;\n",
+		"README.md":             "not a migration",
+		"seed_fixtures.sql":     "INSERT INTO users VALUES (1);",
+		"0010_rename.down.sql":  "",
+		"0004_dupe.up.sql":      "SELECT 2;\n",
+		"0004_dupe.down.sql":    "SELECT 3;\n",
+		"004_dupe_too.up.sql":   "SELECT 4;\n",
+		"004_dupe_too.down.sql": "SELECT 5;\n",
+		"0005_orphan.down.sql":  "SELECT 6;\n",
+	})
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if s.Format != GolangMigrate || len(s.Migrations) != 7 || s.Migrations[6].Version != "0010" {
+		t.Fatalf("unexpected set: %s, %d migrations", s.Format, len(s.Migrations))
+	}
+
+	// 0002 is new but numbered below 0010, which is already in history.
+	isNew := func(m *Migration) bool { return m.Version == "0002" }
+	files := s.Review(sqllint.Options{}, isNew)
+	got := summary(files)
+	for _, want := range []string{
+		// The up file runs in an implicit transaction, so CONCURRENTLY fails;
+		// its down does not drop the column.
+		"0002_email.up.sql:1:MG003 0002_email.up.sql:2:MG002 0002_email.up.sql:3:PG011",
+		"0003_no_down.up.sql:1:MG001",
+		"004_dupe_too.up.sql:1:MG004",
+		"0005_orphan.down.sql:1:MG006",
+		"0010_rename.up.sql:1:MG001",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("findings missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "0001_users.up.sql") || strings.Contains(got, "0002_email.down.sql") {
+		t.Fatalf("unexpected findings:\n%s", got)
+	}
+	for _, f := range files {
+		if filepath.Base(f.Path) == "0010_rename.up.sql" && (len(f.Findings) != 2 || f.Findings[1].Ignored != "no readers yet") {
+			t.Fatalf("the ignore comment should suppress the rename: %+v", f.Findings)
+		}
+	}
+}
+
+func TestGoose(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"20240101000000_tags.sql": `-- +goose Up
+CREATE TABLE tags (id bigint);
+
+-- +goose Down
+DROP TABLE tags;
+`,
+		"20240102000000_index.sql": `-- +goose Up
+CREATE INDEX CONCURRENTLY tags_id_idx ON tags (id);
+-- +goose Down
+DROP INDEX tags_id_idx;
+`,
+	})
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if s.Format != Goose || len(s.Migrations) != 2 {
+		t.Fatalf("unexpected set: %s, %d migrations", s.Format, len(s.Migrations))
+	}
+	files := s.Review(sqllint.Options{}, nil)
+	// Goose runs the file in a transaction unless told otherwise; the down is
+	// linted on its own lines of the same file.
+	if got := summary(files); got != "20240101000000_tags.sql:5:PG008 20240102000000_index.sql:2:PG011 20240102000000_index.sql:4:PG008 20240102000000_index.sql:4:PG009" {
+		t.Fatalf("unexpected findings: %s", got)
+	}
+	if files[1].SQL != "-- +goose Up\nCREATE INDEX CONCURRENTLY tags_id_idx ON tags (id);\n-- +goose Down\nDROP INDEX tags_id_idx;\n" {
+		t.Fatalf("the file should be reassembled: %q", files[1].SQL)
+	}
+
+	noTx := Parse("-", "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY a_idx ON a (id);\n-- +goose Down\nDROP INDEX CONCURRENTLY a_idx;\n")
+	set := &Set{Format: Goose, Migrations: []*Migration{noTx}}
+	if got := summary(set.Review(sqllint.Options{}, nil)); got != "" {
+		t.Fatalf("NO TRANSACTION should allow CONCURRENTLY: %s", got)
+	}
+}
+
+func TestAtlas(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"atlas.sum":                  "h1:abc=\n20240101000000_init.sql h1:def=\n",
+		"20240101000000_init.sql":    "CREATE TABLE a (id bigint);\n",
+		"20240102000000_columns.sql": "-- atlas:txmode none\nCREATE INDEX CONCURRENTLY a_id_idx ON a (id);\n",
+	})
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := summary(s.Review(sqllint.Options{}, nil)); s.Format != Atlas || got != "20240102000000_columns.sql:1:MG005" {
+		t.Fatalf("unexpected %s findings: %s", s.Format, got)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"5_add.up.sql":   lockTimeout + "ALTER TABLE a ADD COLUMN b text;\n",
+		"5_add.down.sql": lockTimeout + "ALTER TABLE a DROP COLUMN b;\nDROP INDEX a_c_idx;\n",
+	})
+	s, err := LoadFile(filepath.Join(dir, "5_add.up.sql"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	// Dropping the column the up adds is what the down is for.
+	files := s.Review(sqllint.Options{}, nil)
+	if len(files) != 2 || summary(files) != "5_add.down.sql:3:PG009" {
+		t.Fatalf("the down file should be reviewed with the up: %d files, %s", len(files), summary(files))
+	}
+}
+
+func TestSqlcSchemas(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"sqlc.yaml": `version: "2"
+sql:
+  - engine: "postgresql"
+    queries: "query.sql"
+    schema: "db/migrations" # goose
+  - engine: postgresql
+    schema:
+      - schema/base.sql
+      - 'schema/extra'
+  - schema: [db/migrations, other]
+`,
+		"sqlc.json": `{"version": "1", "packages": [{"name": "db", "schema": ["a", "b"]}, {"schema": "c"}]}`,
+	})
+	got, err := SqlcSchemas(filepath.Join(dir, "sqlc.yaml"))
+	if err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	want := []string{"db/migrations", "schema/base.sql", "schema/extra", "other"}
+	for i := range want {
+		want[i] = filepath.Join(dir, want[i])
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	got, err = SqlcSchemas(filepath.Join(dir, "sqlc.json"))
+	if err != nil || len(got) != 3 || got[2] != filepath.Join(dir, "c") {
+		t.Fatalf("json: %v %v", got, err)
+	}
+	if FindSqlcConfig(dir) != filepath.Join(dir, "sqlc.yaml") {
+		t.Fatal("sqlc.yaml not found")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{{"9", "10", -1}, {"0002", "2", 0}, {"20240102000000", "20240101000000", 1}} {
+		if got := CompareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareVersions(%s, %s) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestNotNullAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"0001_check.up.sql":      lockTimeout + "ALTER TABLE users ADD CONSTRAINT users_email_nn CHECK (email IS NOT NULL) NOT VALID;\n",
+		"0001_check.down.sql":    lockTimeout + "ALTER TABLE users DROP CONSTRAINT users_email_nn;\n",
+		"0002_validate.up.sql":   lockTimeout + "ALTER TABLE users VALIDATE CONSTRAINT users_email_nn;\n",
+		"0002_validate.down.sql": "SELECT 1;\n",
+		"0003_enforce.up.sql":    lockTimeout + "ALTER TABLE users ALTER COLUMN email SET NOT NULL;\nALTER TABLE users ALTER COLUMN name SET NOT NULL;\n",
+		"0003_enforce.down.sql":  lockTimeout + "ALTER TABLE users ALTER COLUMN email DROP NOT NULL;\nALTER TABLE users ALTER COLUMN name DROP NOT NULL;\n",
+	})
+	s, err := Load(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	// The check added NOT VALID and validated by earlier migrations covers
+	// email; name has none.
+	if got := summary(s.Review(sqllint.Options{}, nil)); got != "0003_enforce.up.sql:3:PG004" {
+		t.Fatalf("unexpected findings: %s", got)
+	}
+}
@@ -0,0 +1,133 @@
+package sqlmigration
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqllint"
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// File is a migration file and its findings.
+type File struct {
+	Path      string
+	SQL       string
+	Findings  []sqllint.Finding
+	Migration *Migration
+}
+
+// Review lints both directions of every migration, checks each down against
+// its up, and checks versions and atlas.sum. isNew tells the migrations added
+// since the point of comparison, so out-of-order versions can be found; nil
+// skips that check. The migrations are linted in version order, carrying
+// validated NOT NULL checks forward. Findings about a whole file are on line 1
+// with no statement.
+func (s *Set) Review(opts sqllint.Options, isNew func(m *Migration) bool) []File {
+	extra := map[*Migration][]sqllint.Finding{}
+	seen := map[string]*Migration{}
+	var newest *Migration // newest migration already in history
+	for _, m := range s.Migrations {
+		if m.Version == "" {
+			continue
+		}
+		if first, ok := seen[strings.TrimLeft(m.Version, "0")]; ok {
+			extra[m] = append(extra[m], sqllint.NewFinding("MG004", 1, "", fmt.Sprintf("version %s is also used by %s", m.Version, filepath.Base(first.path()))))
+		} else {
+			seen[strings.TrimLeft(m.Version, "0")] = m
+		}
+		if isNew != nil && !isNew(m) && (newest == nil || CompareVersions(m.Version, newest.Version) > 0) {
+			newest = m
+		}
+		if s.Sum != nil && !s.Sum[filepath.Base(m.UpPath)] {
+			extra[m] = append(extra[m], sqllint.NewFinding("MG005", 1, "", fmt.Sprintf("%s is not in atlas.sum; run atlas migrate hash", filepath.Base(m.UpPath))))
+		}
+	}
+	if newest != nil {
+		for _, m := range s.Migrations {
+			if m.Version != "" && isNew(m) && CompareVersions(m.Version, newest.Version) < 0 {
+				extra[m] = append(extra[m], sqllint.NewFinding("MG003", 1, "", fmt.Sprintf("version %s is new but lower than %s (%s), which is already in history; renumber it above %s", m.Version, newest.Version, filepath.Base(newest.UpPath), newest.Version)))
+			}
+		}
+	}
+
+	// A check validated by one migration lets a later one SET NOT NULL.
+	if opts.State == nil {
+		opts.State = sqllint.NewState()
+	}
+	var files []File
+	for _, m := range s.Migrations {
+		files = append(files, review(m, opts, extra[m])...)
+	}
+	return files
+}
+
+// review lints one migration, returning its up file and, when separate, its
+// down file.
+func review(m *Migration, opts sqllint.Options, extra []sqllint.Finding) []File {
+	// Only the ups carry state forward: a down runs to leave the set.
+	upOpts, downOpts := opts, opts
+	upOpts.Transaction, downOpts.Transaction = m.UpTx, m.DownTx
+	downOpts.State = nil
+	if m.UpPath == "" {
+		// A down migration whose up is missing: report it and lint it on its own.
+		down := append(extra, sqllint.NewFinding("MG006", 1, "", fmt.Sprintf("no %s, so nothing applies what this down migration reverts", filepath.Base(strings.TrimSuffix(m.DownPath, ".down.sql")+".up.sql"))))
+		sqllint.ApplyIgnores(m.Down, down)
+		down = append(down, sqllint.Lint(m.Down, downOpts)...)
+		sortFindings(down)
+		return []File{{Path: m.DownPath, SQL: m.Down, Findings: down, Migration: m}}
+	}
+	up := append(extra, sqllint.Lint(m.Up, upOpts)...)
+
+	var down []sqllint.Finding
+	if m.Format.HasDown() {
+		if len(sqlschema.Statements(m.Down)) == 0 {
+			up = append(up, sqllint.NewFinding("MG001", 1, "", missingDown(m)))
+		} else {
+			up = append(up, sqllint.CheckDown(m.Up, m.Down)...)
+			down = sqllint.LintDown(m.Up, m.Down, downOpts)
+		}
+	}
+	sqllint.ApplyIgnores(m.Up, up)
+
+	sql := m.Up
+	if m.Format == Goose {
+		// Both sections are one file: put the blanked lines back together.
+		upLines, downLines := strings.Split(m.Up, "\n"), strings.Split(m.Down, "\n")
+		for i := range upLines {
+			upLines[i] += downLines[i]
+		}
+		sql = strings.Join(upLines, "\n")
+		up = append(up, down...)
+		down = nil
+	}
+	sortFindings(up)
+	files := []File{{Path: m.UpPath, SQL: sql, Findings: up, Migration: m}}
+	if m.DownPath != "" && m.DownPath != m.UpPath {
+		sortFindings(down)
+		files = append(files, File{Path: m.DownPath, SQL: m.Down, Findings: down, Migration: m})
+	}
+	return files
+}
+
+// path is the file of the migration's up, or of its down when it has none.
+func (m *Migration) path() string {
+	if m.UpPath == "" {
+		return m.DownPath
+	}
+	return m.UpPath
+}
+
+func missingDown(m *Migration) string {
+	if m.Format == Goose {
+		return "the file has no -- +goose Down section, or an empty one, so it cannot be rolled back"
+	}
+	return fmt.Sprintf("no %s, or it is empty, so the migration cannot be rolled back", filepath.Base(strings.TrimSuffix(m.UpPath, ".up.sql")+".down.sql"))
+}
+
+func sortFindings(findings []sqllint.Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+}
@@ -0,0 +1,141 @@
+package sqlmigration
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SqlcConfigs are the file names sqlc reads its configuration from.
+var SqlcConfigs = []string{"sqlc.yaml", "sqlc.yml", "sqlc.json"}
+
+// FindSqlcConfig returns the sqlc configuration in dir, or "" when there is none.
+func FindSqlcConfig(dir string) string {
+	for _, name := range SqlcConfigs {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+var (
+	schemaKeyPattern  = regexp.MustCompile(`^\s*(?:-\s+)?schema:\s*(.*)$`)
+	listItemPattern   = regexp.MustCompile(`^(\s*)-\s+(.+)$`)
+	yamlCommentSuffix = regexp.MustCompile(`\s+#.*$`)
+)
+
+// SqlcSchemas lists the schema paths of every package in a sqlc configuration,
+// version 1 or 2, resolved against its directory. They are migration
+// directories or single schema files. YAML is read only as far as the schema
+// keys go: a scalar, a flow list or a block list.
+func SqlcSchemas(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var schemas []string
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		schemas = jsonSchemas(doc)
+	} else {
+		schemas = yamlSchemas(string(data))
+	}
+	if len(schemas) == 0 {
+		return nil, fmt.Errorf("%s lists no schema", path)
+	}
+	dir := filepath.Dir(path)
+	seen := map[string]bool{}
+	var out []string
+	for _, s := range schemas {
+		if !filepath.IsAbs(s) {
+			s = filepath.Join(dir, s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func yamlSchemas(text string) []string {
+	lines := strings.Split(text, "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		m := schemaKeyPattern.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		value := strings.TrimSpace(yamlCommentSuffix.ReplaceAllString(m[1], ""))
+		switch {
+		case strings.HasPrefix(value, "["):
+			for _, item := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if item = yamlScalar(item); item != "" {
+					out = append(out, item)
+				}
+			}
+		case value != "":
+			out = append(out, yamlScalar(value))
+		default:
+			// Items of a block list sit at least as deep as the key.
+			keyCol := strings.Index(lines[i], "schema:")
+			for i+1 < len(lines) {
+				item := listItemPattern.FindStringSubmatch(lines[i+1])
+				if item == nil || len(item[1]) < keyCol {
+					break
+				}
+				out = append(out, yamlScalar(yamlCommentSuffix.ReplaceAllString(item[2], "")))
+				i++
+			}
+		}
+	}
+	return out
+}
+
+func yamlScalar(s string) string {
+	return strings.Trim(strings.TrimSpace(s), `"'`)
+}
+
+// jsonSchemas collects every "schema" value in the document.
+func jsonSchemas(v any) []string {
+	var out []string
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := v[key]
+			if key != "schema" {
+				out = append(out, jsonSchemas(value)...)
+				continue
+			}
+			switch value := value.(type) {
+			case string:
+				out = append(out, value)
+			case []any:
+				for _, item := range value {
+					if s, ok := item.(string); ok {
+						out = append(out, s)
+					}
+				}
+			}
+		}
+	case []any:
+		for _, item := range v {
+			out = append(out, jsonSchemas(item)...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,237 @@
+package sqlplan
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Change pairs a node of the before plan with its counterpart in the after plan.
+// Before is nil for nodes only the after plan has, After for nodes it dropped.
+type Change struct {
+	Before, After *Node
+	// BeforeSelf and AfterSelf are exclusive time in ms, or exclusive cost when
+	// either plan lacks ANALYZE figures.
+	BeforeSelf, AfterSelf float64
+}
+
+// Delta is AfterSelf minus BeforeSelf; negative is faster.
+func (c Change) Delta() float64 {
+	return c.AfterSelf - c.BeforeSelf
+}
+
+// Kind is "added", "removed", "access" (a relation is read differently, e.g. Seq
+// Scan to Index Scan), "operator" (another node type in the same place) or "same".
+func (c Change) Kind() string {
+	switch {
+	case c.Before == nil:
+		return "added"
+	case c.After == nil:
+		return "removed"
+	case c.Before.Relation != "" && AccessMethod(c.Before) != AccessMethod(c.After):
+		return "access"
+	case c.Before.Type != c.After.Type:
+		return "operator"
+	}
+	return "same"
+}
+
+// Label describes the node, "before → after" when its access method or type
+// changed.
+func (c Change) Label() string {
+	switch {
+	case c.Before == nil:
+		return c.After.Label()
+	case c.After == nil:
+		return c.Before.Label()
+	case c.Kind() == "access":
+		return AccessMethod(c.Before) + " → " + AccessMethod(c.After) + " on " + relationLabel(c.After)
+	case c.Kind() == "operator":
+		return c.Before.Label() + " → " + c.After.Label()
+	}
+	return c.After.Label()
+}
+
+// AccessMethod is how a node reads its relation: "Seq Scan", "Index Scan using
+// orders_pkey" or, for bitmap scans, the heap scan with the indexes of its bitmap
+// children.
+func AccessMethod(n *Node) string {
+	method := n.Type
+	var indexes []string
+	if n.Index != "" {
+		indexes = append(indexes, n.Index)
+	}
+	if n.Type == "Bitmap Heap Scan" {
+		for _, c := range n.Children {
+			c.Walk(func(b *Node) {
+				if b.Index != "" {
+					indexes = append(indexes, b.Index)
+				}
+			})
+		}
+	}
+	if len(indexes) > 0 {
+		method += " using " + strings.Join(indexes, ", ")
+	}
+	return method
+}
+
+func relationLabel(n *Node) string {
+	if n.Alias != "" && n.Alias != n.Relation {
+		return n.Relation + " " + n.Alias
+	}
+	return n.Relation
+}
+
+// Comparison is the node-level difference between two plans of the same query.
+type Comparison struct {
+	Before, After *Plan
+	// Analyzed reports that both plans carry ANALYZE figures, so Self values are
+	// milliseconds rather than cost.
+	Analyzed bool
+	// Changes lists every node of either plan, largest absolute Delta first.
+	Changes []Change
+}
+
+// Compare aligns the two plan trees. Nodes reading a relation pair by relation
+// and alias, so a Seq Scan that became an Index Scan stays one change; other
+// nodes pair by type, in plan order, and what is left pairs by position under
+// already paired parents.
+func Compare(before, after *Plan) *Comparison {
+	c := &Comparison{Before: before, After: after, Analyzed: before.Analyzed() && after.Analyzed()}
+	bNodes, aNodes := before.Nodes(), after.Nodes()
+	pairOf := map[*Node]*Node{} // after node -> before node
+	taken := map[*Node]bool{}   // paired before nodes
+
+	byKey := map[string][]*Node{}
+	for _, n := range bNodes {
+		byKey[alignKey(n)] = append(byKey[alignKey(n)], n)
+	}
+	for _, n := range aNodes {
+		key := alignKey(n)
+		if candidates := byKey[key]; len(candidates) > 0 {
+			pairOf[n], taken[candidates[0]] = candidates[0], true
+			byKey[key] = candidates[1:]
+		}
+	}
+	// Nodes come depth first, so a parent is paired before its children.
+	for _, n := range aNodes {
+		if pairOf[n] != nil || n.Relation != "" {
+			continue
+		}
+		var candidate *Node
+		if n.Parent == nil {
+			candidate = before.Root
+		} else if p := pairOf[n.Parent]; p != nil {
+			if i := childIndex(n); i < len(p.Children) {
+				candidate = p.Children[i]
+			}
+		}
+		if candidate != nil && !taken[candidate] && candidate.Relation == "" {
+			pairOf[n], taken[candidate] = candidate, true
+		}
+	}
+
+	for _, n := range aNodes {
+		ch := Change{After: n, AfterSelf: c.self(n)}
+		if b := pairOf[n]; b != nil {
+			ch.Before, ch.BeforeSelf = b, c.self(b)
+		}
+		c.Changes = append(c.Changes, ch)
+	}
+	for _, n := range bNodes {
+		if !taken[n] {
+			c.Changes = append(c.Changes, Change{Before: n, BeforeSelf: c.self(n)})
+		}
+	}
+	sort.SliceStable(c.Changes, func(i, j int) bool {
+		return math.Abs(c.Changes[i].Delta()) > math.Abs(c.Changes[j].Delta())
+	})
+	return c
+}
+
+func alignKey(n *Node) string {
+	switch {
+	case n.Relation != "":
+		return "relation " + n.Relation + " " + n.Alias
+	case n.Index != "":
+		return "index " + n.Index
+	}
+	return "type " + n.Type + " " + n.SubplanName
+}
+
+func childIndex(n *Node) int {
+	for i, c := range n.Parent.Children {
+		if c == n {
+			return i
+		}
+	}
+	return 0
+}
+
+func (c *Comparison) self(n *Node) float64 {
+	if c.Analyzed {
+		return n.ExclusiveTime()
+	}
+	return n.ExclusiveCost()
+}
+
+// ShapeChanged reports whether any node was added, removed or changed type or
+// access method.
+func (c *Comparison) ShapeChanged() bool {
+	for _, ch := range c.Changes {
+		if ch.Kind() != "same" {
+			return true
+		}
+	}
+	return false
+}
+
+// Thresholds for the comparison findings.
+const (
+	significantChange = 0.2
+	coldReadFactor    = 10
+)
+
+// Findings states what the figures alone say about the change, in a fixed order:
+// the overall time, access method changes, and the signs of a caching artefact or
+// of comparing different queries.
+func (c *Comparison) Findings() []string {
+	var findings []string
+	before, after := c.Before, c.After
+	if c.Analyzed && before.ExecutionTime > 0 && after.ExecutionTime > 0 {
+		ratio := after.ExecutionTime / before.ExecutionTime
+		findings = append(findings, fmt.Sprintf("execution time %.3f → %.3f ms (%+.0f%%)", before.ExecutionTime, after.ExecutionTime, (ratio-1)*100))
+	} else if !c.Analyzed && before.Costed() && after.Costed() {
+		findings = append(findings, fmt.Sprintf("total cost %.2f → %.2f (no ANALYZE figures on both sides)", before.Root.TotalCost, after.Root.TotalCost))
+	}
+
+	access := 0
+	for _, ch := range c.Changes {
+		if ch.Kind() == "access" {
+			access++
+		}
+	}
+	if access > 0 {
+		findings = append(findings, fmt.Sprintf("%d relation(s) read with a different access method", access))
+	}
+
+	if !c.Analyzed {
+		return findings
+	}
+	bRead, aRead := before.Root.SharedRead, after.Root.SharedRead
+	faster := before.ExecutionTime > 0 && after.ExecutionTime < before.ExecutionTime*(1-significantChange)
+	switch {
+	case !c.ShapeChanged() && faster && aRead < bRead:
+		findings = append(findings, fmt.Sprintf("the plan is unchanged and %s fewer blocks were read from disk (%s → %s): most likely a warm cache, not the change", formatCount(float64(bRead-aRead)), formatCount(float64(bRead)), formatCount(float64(aRead))))
+	case !c.ShapeChanged() && faster:
+		findings = append(findings, "the plan is unchanged: the difference is caching, load or noise rather than the change")
+	case c.ShapeChanged() && bRead > 0 && aRead*coldReadFactor < bRead:
+		findings = append(findings, fmt.Sprintf("the before run read %s blocks from disk and the after run %s: rerun both with a warm cache to separate the plan change from caching", formatCount(float64(bRead)), formatCount(float64(aRead))))
+	}
+	if before.Root.ActualRows != after.Root.ActualRows {
+		findings = append(findings, fmt.Sprintf("the plans returned different row counts (%.0f → %.0f): check they are for the same query and data", before.Root.ActualRows, after.Root.ActualRows))
+	}
+	return findings
+}
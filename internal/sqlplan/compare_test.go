@@ -0,0 +1,122 @@
+package sqlplan
+
+import (
+	"strings"
+	"testing"
+)
+
+// indexedPlan is textPlan's query after CREATE INDEX ON orders (status), run warm.
+const indexedPlan = `Limit  (cost=3000.00..3000.03 rows=10 width=44) (actual time=95.300..95.310 rows=10 loops=1)
+  Buffers: shared hit=700
+  ->  Sort  (cost=3000.00..3250.00 rows=100 width=44) (actual time=95.290..95.295 rows=10 loops=1)
+        Sort Key: (count(*)) DESC
+        Sort Method: top-N heapsort  Memory: 26kB
+        Buffers: shared hit=700
+        ->  HashAggregate  (cost=2000.00..2500.00 rows=100 width=44) (actual time=60.000..90.000 rows=50000 loops=1)
+              Group Key: c.name
+              Batches: 1  Memory Usage: 8209kB
+              Buffers: shared hit=700
+              ->  Hash Join  (cost=30.50..1800.00 rows=120000 width=36) (actual time=1.200..40.000 rows=120000 loops=1)
+                    Hash Cond: (o.customer_id = c.id)
+                    Buffers: shared hit=700
+                    ->  Bitmap Heap Scan on orders o  (cost=20.00..1500.00 rows=120000 width=8) (actual time=2.000..25.000 rows=120000 loops=1)
+                          Recheck Cond: (status = 'paid'::text)
+                          Heap Blocks: exact=500
+                          Buffers: shared hit=600
+                          ->  Bitmap Index Scan on orders_status_idx  (cost=0.00..20.00 rows=120000 width=0) (actual time=1.500..1.500 rows=120000 loops=1)
+                                Index Cond: (status = 'paid'::text)
+                                Buffers: shared hit=100
+                    ->  Hash  (cost=18.00..18.00 rows=1000 width=36) (actual time=1.100..1.100 rows=1000 loops=1)
+                          Buckets: 1024  Batches: 1  Memory Usage: 72kB
+                          Buffers: shared hit=100
+                          ->  Seq Scan on customers c  (cost=0.00..18.00 rows=1000 width=36) (actual time=0.010..0.500 rows=1000 loops=1)
+                                Buffers: shared hit=100
+Planning Time: 0.400 ms
+Execution Time: 96.000 ms
+`
+
+func mustParse(t *testing.T, text string) *Plan {
+	t.Helper()
+	plan, err := ParsePostgres(text)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return plan
+}
+
+func TestCompareAccessMethodChange(t *testing.T) {
+	cmp := Compare(mustParse(t, textPlan), mustParse(t, indexedPlan))
+	if !cmp.Analyzed || !cmp.ShapeChanged() || len(cmp.Changes) != 8 {
+		t.Fatalf("unexpected comparison: analyzed=%v shape=%v changes=%d", cmp.Analyzed, cmp.ShapeChanged(), len(cmp.Changes))
+	}
+	orders := cmp.Changes[0]
+	if orders.Kind() != "access" || orders.Label() != "Seq Scan → Bitmap Heap Scan using orders_status_idx on orders o" || !near(orders.Delta(), -496.5) {
+		t.Fatalf("unexpected top change %s %q %v", orders.Kind(), orders.Label(), orders.Delta())
+	}
+	agg := cmp.Changes[1]
+	if agg.Kind() != "same" || agg.Before.Type != "HashAggregate" || !near(agg.Delta(), -140.5) {
+		t.Fatalf("unexpected second change %s %q %v", agg.Kind(), agg.Label(), agg.Delta())
+	}
+	var added []string
+	for _, ch := range cmp.Changes {
+		if ch.Kind() == "added" {
+			added = append(added, ch.Label())
+		}
+	}
+	if len(added) != 1 || added[0] != "Bitmap Index Scan on orders_status_idx" {
+		t.Fatalf("unexpected added nodes %q", added)
+	}
+
+	want := []string{
+		"execution time 815.000 → 96.000 ms (-88%)",
+		"1 relation(s) read with a different access method",
+		"the before run read 40k blocks from disk and the after run 0: rerun both with a warm cache to separate the plan change from caching",
+	}
+	if got := cmp.Findings(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected findings %q", got)
+	}
+}
+
+func TestCompareWarmCache(t *testing.T) {
+	before, after := mustParse(t, textPlan), mustParse(t, textPlan)
+	after.ExecutionTime = 300
+	after.Root.SharedHit, after.Root.SharedRead = 40120, 0
+	cmp := Compare(before, after)
+	if cmp.ShapeChanged() {
+		t.Fatal("identical plans should align node for node")
+	}
+	findings := cmp.Findings()
+	if len(findings) != 2 || !strings.Contains(findings[1], "most likely a warm cache") {
+		t.Fatalf("unexpected findings %q", findings)
+	}
+}
+
+func TestCompareOperatorChange(t *testing.T) {
+	before := mustParse(t, `Hash Join  (cost=30.50..20000.00 rows=100 width=36)
+  ->  Seq Scan on orders o  (cost=0.00..19000.00 rows=100 width=8)
+  ->  Hash  (cost=18.00..18.00 rows=1000 width=36)
+        ->  Seq Scan on customers c  (cost=0.00..18.00 rows=1000 width=36)
+`)
+	after := mustParse(t, `Nested Loop  (cost=0.43..900.00 rows=100 width=36)
+  ->  Index Scan using orders_status_idx on orders o  (cost=0.43..80.00 rows=100 width=8)
+  ->  Index Scan using customers_pkey on customers c  (cost=0.28..8.00 rows=1 width=36)
+`)
+	cmp := Compare(before, after)
+	if cmp.Analyzed {
+		t.Fatal("plain EXPLAIN compares cost")
+	}
+	kinds := map[string]string{}
+	for _, ch := range cmp.Changes {
+		kinds[ch.Label()] = ch.Kind()
+	}
+	for label, kind := range map[string]string{
+		"Hash Join → Nested Loop":                                   "operator",
+		"Seq Scan → Index Scan using orders_status_idx on orders o": "access",
+		"Seq Scan → Index Scan using customers_pkey on customers c": "access",
+		"Hash": "removed",
+	} {
+		if kinds[label] != kind {
+			t.Fatalf("expected %q to be %s, got %v", label, kind, kinds)
+		}
+	}
+}
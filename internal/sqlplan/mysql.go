@@ -0,0 +1,430 @@
+package sqlplan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseMySQL parses MySQL EXPLAIN FORMAT=JSON (both the classic query_block layout
+// and the explain_json_format_version=2 tree) and the EXPLAIN ANALYZE /
+// FORMAT=TREE text, with or without the mysql client's table borders and
+// "EXPLAIN:" prefix.
+func ParseMySQL(text string) (*Plan, error) {
+	text = stripMySQLClient(text)
+	if strings.HasPrefix(strings.TrimSpace(text), "{") || strings.HasPrefix(strings.TrimSpace(text), "[") {
+		return parseMySQLJSON(text)
+	}
+	return parseMySQLTree(text)
+}
+
+var mysqlBorder = regexp.MustCompile(`^(?:\+-+\+|\*+ \d+\. row \*+|\| EXPLAIN\s*\||\d+ rows? in set.*)$`)
+
+// stripMySQLClient removes what the mysql client prints around a single-column
+// result in table (-t) or vertical (\G) mode.
+func stripMySQLClient(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if mysqlBorder.MatchString(strings.TrimSpace(line)) {
+			continue
+		}
+		line = strings.TrimPrefix(strings.TrimPrefix(line, "| "), "EXPLAIN: ")
+		line = strings.TrimRight(strings.TrimSuffix(strings.TrimRight(line, " "), "|"), " ")
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// JSON
+
+func parseMySQLJSON(text string) (*Plan, error) {
+	var m map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &m); err != nil {
+		var list []map[string]any
+		if err2 := json.Unmarshal([]byte(strings.TrimSpace(text)), &list); err2 != nil || len(list) == 0 {
+			return nil, fmt.Errorf("parse FORMAT=JSON plan: %w", err)
+		}
+		m = list[0]
+	}
+	var root *Node
+	switch {
+	case m["query_block"] != nil:
+		qb, _ := m["query_block"].(map[string]any)
+		root = mysqlQueryBlock(qb)
+	case m["operation"] != nil:
+		root = mysqlV2Node(m)
+	default:
+		return nil, errors.New("parse FORMAT=JSON plan: neither \"query_block\" nor \"operation\" found")
+	}
+	p := &Plan{Root: root}
+	if root.Analyzed {
+		p.ExecutionTime = root.TotalTime()
+	}
+	p.link()
+	return p, nil
+}
+
+// mysqlOperations are the keys of a classic query block that wrap further
+// operations, outermost first, with the node type they become.
+var mysqlOperations = []struct{ key, typ string }{
+	{"ordering_operation", "Sort"},
+	{"grouping_operation", "Group"},
+	{"duplicates_removal", "Duplicate removal"},
+	{"windowing", "Window"},
+	{"buffer_result", "Buffer result"},
+}
+
+// mysqlAccessTypes maps the classic access_type to the wording EXPLAIN ANALYZE uses.
+var mysqlAccessTypes = map[string]string{
+	"ALL":             "Table scan",
+	"index":           "Index scan",
+	"range":           "Index range scan",
+	"ref":             "Index lookup",
+	"ref_or_null":     "Index lookup",
+	"eq_ref":          "Single-row index lookup",
+	"const":           "Constant row",
+	"system":          "Constant row",
+	"index_merge":     "Index merge",
+	"fulltext":        "Full-text index search",
+	"unique_subquery": "Single-row index lookup",
+	"index_subquery":  "Index lookup",
+}
+
+func mysqlQueryBlock(qb map[string]any) *Node {
+	n := &Node{Type: "Query block", Text: fmt.Sprintf("Query block #%v", qb["select_id"]), Details: map[string]string{}}
+	if ci, ok := qb["cost_info"].(map[string]any); ok {
+		n.TotalCost = mysqlNum(ci["query_cost"])
+	}
+	n.Children = mysqlOperationChildren(qb)
+	for _, key := range []string{"attached_subqueries", "optimized_away_subqueries"} {
+		n.Children = append(n.Children, mysqlSubqueries(qb[key])...)
+	}
+	n.TotalCost = max(n.TotalCost, childCost(n))
+	return n
+}
+
+// mysqlOperationChildren returns the operation a block or operation object wraps.
+func mysqlOperationChildren(m map[string]any) []*Node {
+	for _, op := range mysqlOperations {
+		inner, ok := m[op.key].(map[string]any)
+		if !ok {
+			continue
+		}
+		n := &Node{Type: op.typ, Text: op.typ, Details: map[string]string{}}
+		if ci, ok := inner["cost_info"].(map[string]any); ok {
+			n.TotalCost = mysqlNum(ci["sort_cost"])
+		}
+		if inner["using_filesort"] == true {
+			n.Warnings = append(n.Warnings, "filesort")
+		}
+		if inner["using_temporary_table"] == true {
+			n.Warnings = append(n.Warnings, "temporary table")
+		}
+		n.Children = mysqlOperationChildren(inner)
+		n.TotalCost += childCost(n)
+		return []*Node{n}
+	}
+	if t, ok := m["table"].(map[string]any); ok {
+		return []*Node{mysqlTable(t)}
+	}
+	if loop, ok := m["nested_loop"].([]any); ok {
+		n := &Node{Type: "Nested loop", Text: "Nested loop", Details: map[string]string{}}
+		for _, item := range loop {
+			if im, ok := item.(map[string]any); ok {
+				n.Children = append(n.Children, mysqlOperationChildren(im)...)
+			}
+		}
+		n.TotalCost = childCost(n)
+		return []*Node{n}
+	}
+	if u, ok := m["union_result"].(map[string]any); ok {
+		n := &Node{Type: "Union", Text: "Union", Details: map[string]string{}}
+		if specs, ok := u["query_specifications"].([]any); ok {
+			for _, s := range specs {
+				if sm, ok := s.(map[string]any); ok {
+					if qb, ok := sm["query_block"].(map[string]any); ok {
+						n.Children = append(n.Children, mysqlQueryBlock(qb))
+					}
+				}
+			}
+		}
+		n.TotalCost = childCost(n)
+		return []*Node{n}
+	}
+	return nil
+}
+
+func mysqlSubqueries(v any) []*Node {
+	list, _ := v.([]any)
+	var nodes []*Node
+	for _, item := range list {
+		im, _ := item.(map[string]any)
+		qb, ok := im["query_block"].(map[string]any)
+		if !ok {
+			continue
+		}
+		n := mysqlQueryBlock(qb)
+		n.Relationship = "SubPlan"
+		if im["dependent"] == true {
+			n.Warnings = append(n.Warnings, "dependent subquery re-run for every outer row")
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+func mysqlTable(t map[string]any) *Node {
+	access := mysqlStr(t["access_type"])
+	typ, ok := mysqlAccessTypes[access]
+	if !ok {
+		typ = access
+	}
+	if t["using_index"] == true && strings.Contains(strings.ToLower(typ), "index") {
+		typ = "Covering " + strings.ToLower(typ[:1]) + typ[1:]
+	}
+	n := &Node{
+		Type:     typ,
+		Relation: mysqlStr(t["table_name"]),
+		Index:    mysqlStr(t["key"]),
+		PlanRows: mysqlNum(t["rows_examined_per_scan"]),
+		Details:  map[string]string{},
+	}
+	if strings.HasPrefix(n.Relation, "<") {
+		// <derived2>, <subquery2>, <union1,2>: temporary tables, not relations.
+		n.Text, n.Relation = typ+" on "+n.Relation, ""
+	} else {
+		n.Text = typ + " on " + n.Relation
+		if n.Index != "" {
+			n.Text += " using " + n.Index
+		}
+	}
+	if ci, ok := t["cost_info"].(map[string]any); ok {
+		n.TotalCost = mysqlNum(ci["read_cost"]) + mysqlNum(ci["eval_cost"])
+	}
+	for key, detail := range map[string]string{
+		"attached_condition":     "Filter",
+		"index_condition":        "Index Cond",
+		"possible_keys":          "Possible Keys",
+		"used_key_parts":         "Key Parts",
+		"ref":                    "Ref",
+		"filtered":               "Filtered %",
+		"rows_produced_per_join": "Rows Produced",
+	} {
+		if v := mysqlDetail(t[key]); v != "" {
+			n.Details[detail] = v
+		}
+	}
+	if t["using_join_buffer"] != nil {
+		n.Warnings = append(n.Warnings, "join buffer ("+mysqlStr(t["using_join_buffer"])+"), no usable index for the join")
+	}
+	if mat, ok := t["materialized_from_subquery"].(map[string]any); ok {
+		if qb, ok := mat["query_block"].(map[string]any); ok {
+			n.Children = append(n.Children, mysqlQueryBlock(qb))
+		}
+	}
+	n.TotalCost += childCost(n)
+	return n
+}
+
+// mysqlV2Node converts a node of the explain_json_format_version=2 tree, whose
+// "operation" is the EXPLAIN ANALYZE line.
+func mysqlV2Node(m map[string]any) *Node {
+	n := mysqlTreeLabel(mysqlStr(m["operation"]))
+	if t := mysqlStr(m["table_name"]); t != "" && n.Relation != "" {
+		n.Relation, n.Alias = t, mysqlStr(m["alias"])
+	}
+	if idx := mysqlStr(m["index_name"]); idx != "" {
+		n.Index = idx
+	}
+	if c := mysqlStr(m["condition"]); c != "" && n.Details["Filter"] == "" && n.Relation == "" {
+		n.Details["Condition"] = c
+	}
+	n.TotalCost = mysqlNum(m["estimated_total_cost"])
+	n.PlanRows = mysqlNum(m["estimated_rows"])
+	if loops, ok := m["actual_loops"]; ok {
+		n.Analyzed = true
+		n.Loops = mysqlNum(loops)
+		n.NeverExecuted = n.Loops == 0
+		n.ActualStartup = mysqlNum(m["actual_first_row_ms"])
+		n.ActualTotal = mysqlNum(m["actual_last_row_ms"])
+		n.ActualRows = mysqlNum(m["actual_rows"])
+	}
+	if inputs, ok := m["inputs"].([]any); ok {
+		for _, in := range inputs {
+			if im, ok := in.(map[string]any); ok {
+				n.Children = append(n.Children, mysqlV2Node(im))
+			}
+		}
+	}
+	mysqlFilterRemoved(n)
+	return n
+}
+
+func childCost(n *Node) float64 {
+	total := 0.0
+	for _, c := range n.Children {
+		total += c.TotalCost
+	}
+	return total
+}
+
+// mysqlNum reads numbers, which the classic format writes as strings ("12.50").
+func mysqlNum(v any) float64 {
+	switch x := v.(type) {
+	case float64:
+		return x
+	case string:
+		f, _ := strconv.ParseFloat(x, 64)
+		return f
+	}
+	return 0
+}
+
+func mysqlStr(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func mysqlDetail(v any) string {
+	if list, ok := v.([]any); ok {
+		parts := make([]string, len(list))
+		for i, p := range list {
+			parts[i] = mysqlStr(p)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return mysqlStr(v)
+}
+
+// Tree
+
+var (
+	// -> Index lookup on c using idx (id=o.customer_id)  (cost=0.35 rows=1) (actual time=0.001..0.001 rows=1 loops=120)
+	mysqlTreePattern = regexp.MustCompile(`^(\s*)-> (.+?)(?:\s+\(cost=([\d.e+]+) rows=([\d.e+]+)\))?(?:\s+\((?:actual time=([\d.]+)\.\.([\d.]+) rows=([\d.e+]+) loops=(\d+)|(never executed))\))?$`)
+	// Table scan on o, Index range scan on o using idx over (...), Single-row index lookup on c using PRIMARY (id=...)
+	mysqlAccessPattern = regexp.MustCompile(`^(.*?(?:[Ss]can|lookup|search|row)) (?:on|from) (\S+)(?: using (\S+?))?(?:,? with index condition: (.+)| over \((.*)\)| \((.*)\))?$`)
+)
+
+func parseMySQLTree(text string) (*Plan, error) {
+	type frame struct {
+		indent int
+		node   *Node
+	}
+	var (
+		stack []frame
+		root  *Node
+	)
+	for _, line := range strings.Split(text, "\n") {
+		m := mysqlTreePattern.FindStringSubmatch(strings.TrimRight(line, " "))
+		if m == nil {
+			continue
+		}
+		n := mysqlTreeLabel(m[2])
+		n.TotalCost, _ = strconv.ParseFloat(m[3], 64)
+		n.PlanRows, _ = strconv.ParseFloat(m[4], 64)
+		switch {
+		case m[9] != "":
+			n.Analyzed, n.NeverExecuted = true, true
+		case m[8] != "":
+			n.Analyzed = true
+			n.ActualStartup, _ = strconv.ParseFloat(m[5], 64)
+			n.ActualTotal, _ = strconv.ParseFloat(m[6], 64)
+			n.ActualRows, _ = strconv.ParseFloat(m[7], 64)
+			n.Loops, _ = strconv.ParseFloat(m[8], 64)
+		}
+		indent := len(m[1])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			if root != nil {
+				// A second top-level plan (e.g. several statements); keep the first.
+				break
+			}
+			root = n
+		} else {
+			parent := stack[len(stack)-1].node
+			parent.Children = append(parent.Children, n)
+		}
+		stack = append(stack, frame{indent, n})
+	}
+	if root == nil {
+		return nil, errors.New("parse plan: no \"-> \" plan lines found; expected EXPLAIN ANALYZE or FORMAT=TREE output")
+	}
+	root.Walk(mysqlFilterRemoved)
+	p := &Plan{Root: root}
+	if root.Analyzed {
+		p.ExecutionTime = root.TotalTime()
+	}
+	p.link()
+	return p, nil
+}
+
+// mysqlTreeKeys maps "Kind: argument" node prefixes to the Details key of the
+// argument.
+var mysqlTreeKeys = map[string]string{
+	"Filter":          "Filter",
+	"Sort":            "Sort Key",
+	"Limit":           "Limit",
+	"Group aggregate": "Aggregates",
+	"Aggregate":       "Aggregates",
+}
+
+// mysqlTreeLabel turns an EXPLAIN ANALYZE line (without figures) into a node.
+func mysqlTreeLabel(label string) *Node {
+	n := &Node{Type: label, Text: label, Details: map[string]string{}}
+	if m := mysqlAccessPattern.FindStringSubmatch(label); m != nil {
+		n.Type, n.Relation, n.Index = m[1], m[2], m[3]
+		if cond := m[4] + m[5] + m[6]; cond != "" {
+			n.Details["Index Cond"] = cond
+		}
+		n.Text = n.Type + " on " + n.Relation
+		if n.Index != "" {
+			n.Text += " using " + n.Index
+		}
+		if strings.HasPrefix(n.Relation, "<") {
+			n.Relation = ""
+		}
+		return n
+	}
+	if kind, arg, ok := strings.Cut(label, ": "); ok && !strings.Contains(kind, "(") {
+		n.Type, n.Text = kind, kind
+		key, ok := mysqlTreeKeys[kind]
+		if !ok {
+			key = kind
+		}
+		n.Details[key] = arg
+		return n
+	}
+	if kind, cond, ok := strings.Cut(label, " ("); ok && strings.HasSuffix(cond, ")") {
+		n.Type, n.Text = kind, kind
+		n.Details["Condition"] = strings.TrimSuffix(cond, ")")
+	}
+	if strings.Contains(label, "temporary table") {
+		n.Warnings = append(n.Warnings, "temporary table")
+	}
+	if strings.Contains(label, "dependent") {
+		n.Warnings = append(n.Warnings, "dependent subquery re-run for every outer row")
+	}
+	return n
+}
+
+// mysqlFilterRemoved records on Filter nodes how many rows per loop the filter
+// dropped, which MySQL shows only as the difference to the child's rows.
+func mysqlFilterRemoved(n *Node) {
+	if n.Type != "Filter" || !n.Analyzed || len(n.Children) != 1 {
+		return
+	}
+	if removed := n.Children[0].TotalRows() - n.TotalRows(); removed > 0 {
+		n.Details["Rows Removed by Filter"] = strconv.FormatFloat(removed/n.loops(), 'f', 0, 64)
+	}
+}
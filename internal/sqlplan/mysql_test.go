@@ -0,0 +1,197 @@
+package sqlplan
+
+import (
+	"strings"
+	"testing"
+)
+
+// mysqlJSON is MySQL 8.0 EXPLAIN FORMAT=JSON of a grouped join that scans orders.
+const mysqlJSON = `{
+  "query_block": {
+    "select_id": 1,
+    "cost_info": {
+      "query_cost": "14562.40"
+    },
+    "ordering_operation": {
+      "using_filesort": true,
+      "grouping_operation": {
+        "using_temporary_table": true,
+        "using_filesort": false,
+        "nested_loop": [
+          {
+            "table": {
+              "table_name": "o",
+              "access_type": "ALL",
+              "possible_keys": [
+                "customer_id"
+              ],
+              "rows_examined_per_scan": 99538,
+              "rows_produced_per_join": 9953,
+              "filtered": "10.00",
+              "cost_info": {
+                "read_cost": "9055.96",
+                "eval_cost": "995.38",
+                "prefix_cost": "10051.34",
+                "data_read_per_join": "1M"
+              },
+              "used_columns": [
+                "id",
+                "customer_id",
+                "status"
+              ],
+              "attached_condition": "((` + "`shop`.`o`.`status` = 'paid') and (`shop`.`o`.`customer_id` is not null" + `))"
+            }
+          },
+          {
+            "table": {
+              "table_name": "c",
+              "access_type": "eq_ref",
+              "possible_keys": [
+                "PRIMARY"
+              ],
+              "key": "PRIMARY",
+              "used_key_parts": [
+                "id"
+              ],
+              "key_length": "4",
+              "ref": [
+                "shop.o.customer_id"
+              ],
+              "rows_examined_per_scan": 1,
+              "rows_produced_per_join": 9953,
+              "filtered": "100.00",
+              "cost_info": {
+                "read_cost": "2488.45",
+                "eval_cost": "995.38",
+                "prefix_cost": "13535.17",
+                "data_read_per_join": "2M"
+              },
+              "used_columns": [
+                "id",
+                "name"
+              ]
+            }
+          }
+        ]
+      }
+    }
+  }
+}`
+
+// mysqlTree is EXPLAIN ANALYZE of the same query as the mysql client prints it
+// with \G.
+const mysqlTree = `*************************** 1. row ***************************
+EXPLAIN: -> Sort: cnt DESC  (actual time=612.905..612.917 rows=200 loops=1)
+    -> Table scan on <temporary>  (actual time=612.712..612.776 rows=200 loops=1)
+        -> Aggregate using temporary table  (actual time=612.708..612.708 rows=200 loops=1)
+            -> Nested loop inner join  (cost=13535.17 rows=9953) (actual time=0.118..580.402 rows=24862 loops=1)
+                -> Filter: ((o.status = 'paid') and (o.customer_id is not null))  (cost=10051.34 rows=9953) (actual time=0.092..540.330 rows=24862 loops=1)
+                    -> Table scan on o  (cost=10051.34 rows=99538) (actual time=0.088..520.061 rows=100000 loops=1)
+                -> Single-row index lookup on c using PRIMARY (id=o.customer_id)  (cost=0.25 rows=1) (actual time=0.001..0.001 rows=1 loops=24862)
+
+1 row in set (0.62 sec)
+`
+
+// mysqlV2 is EXPLAIN FORMAT=JSON with explain_json_format_version=2 (MySQL 8.3+).
+const mysqlV2 = `{
+  "query": "/* select#1 */ select ...",
+  "inputs": [
+    {
+      "operation": "Index lookup on o using idx_status (status='paid')",
+      "table_name": "orders",
+      "alias": "o",
+      "index_name": "idx_status",
+      "access_type": "index",
+      "estimated_rows": 24862,
+      "estimated_total_cost": 2740.5
+    }
+  ],
+  "operation": "Aggregate: count(0)",
+  "access_type": "aggregate",
+  "estimated_rows": 1,
+  "estimated_total_cost": 5226.7
+}`
+
+func TestParseMySQLJSON(t *testing.T) {
+	plan, err := ParseMySQL(mysqlJSON)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var labels []string
+	for _, n := range plan.Nodes() {
+		labels = append(labels, n.Label())
+	}
+	want := "Query block #1|Sort|Group|Nested loop|Table scan on o|Single-row index lookup on c using PRIMARY"
+	if strings.Join(labels, "|") != want {
+		t.Fatalf("unexpected nodes %q", labels)
+	}
+	scan := plan.Nodes()[4]
+	if scan.Relation != "o" || scan.PlanRows != 99538 || !near(scan.TotalCost, 10051.34) || scan.Details["Filtered %"] != "10.00" || !strings.Contains(scan.Details["Filter"], "'paid'") {
+		t.Fatalf("unexpected scan %+v", scan)
+	}
+	if !near(plan.Root.TotalCost, 14562.40) {
+		t.Fatalf("unexpected query cost %v", plan.Root.TotalCost)
+	}
+	hotspots := plan.Hotspots()
+	if hotspots[0].Node != scan {
+		t.Fatalf("expected the table scan first, got %s", hotspots[0].Node.Label())
+	}
+	for _, h := range hotspots {
+		if h.Node.Type == "Sort" && (len(h.Notes) != 1 || h.Notes[0] != "filesort") {
+			t.Fatalf("unexpected sort notes %q", h.Notes)
+		}
+		if h.Node.Type == "Group" && (len(h.Notes) != 1 || h.Notes[0] != "temporary table") {
+			t.Fatalf("unexpected group notes %q", h.Notes)
+		}
+	}
+}
+
+func TestParseMySQLTree(t *testing.T) {
+	plan, err := ParseMySQL(mysqlTree)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	nodes := plan.Nodes()
+	if len(nodes) != 7 || !plan.Analyzed() || !near(plan.ExecutionTime, 612.917) {
+		t.Fatalf("unexpected plan: %d nodes, %v ms", len(nodes), plan.ExecutionTime)
+	}
+	if tmp := nodes[1]; tmp.Relation != "" || tmp.Label() != "Table scan on <temporary>" {
+		t.Fatalf("a temporary table is not a relation: %+v", tmp)
+	}
+	filter := nodes[4]
+	if filter.Type != "Filter" || filter.Details["Rows Removed by Filter"] != "75138" {
+		t.Fatalf("unexpected filter %+v", filter)
+	}
+	lookup := nodes[6]
+	if lookup.Type != "Single-row index lookup" || lookup.Relation != "c" || lookup.Index != "PRIMARY" || lookup.Details["Index Cond"] != "id=o.customer_id" || lookup.Loops != 24862 {
+		t.Fatalf("unexpected lookup %+v", lookup)
+	}
+
+	hotspots := plan.Hotspots()
+	if hotspots[0].Node.Label() != "Table scan on o" || !near(hotspots[0].Self, 520.061) {
+		t.Fatalf("unexpected top hotspot %s %v", hotspots[0].Node.Label(), hotspots[0].Self)
+	}
+	if got := hotspots[1]; got.Node != nodes[2] || len(got.Notes) != 1 || got.Notes[0] != "temporary table" {
+		t.Fatalf("unexpected second hotspot %s %q", got.Node.Label(), got.Notes)
+	}
+	if got := Notes(lookup); len(got) != 1 || got[0] != "25k loops (25k rows total)" {
+		t.Fatalf("unexpected lookup notes %q", got)
+	}
+}
+
+func TestParseMySQLJSONv2(t *testing.T) {
+	plan, err := ParseMySQL("| EXPLAIN |\n| " + mysqlV2 + " |\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if plan.Root.Type != "Aggregate" || plan.Root.Details["Aggregates"] != "count(0)" || len(plan.Root.Children) != 1 {
+		t.Fatalf("unexpected root %+v", plan.Root)
+	}
+	lookup := plan.Root.Children[0]
+	if lookup.Relation != "orders" || lookup.Alias != "o" || lookup.Index != "idx_status" || lookup.Label() != "Index lookup on o using idx_status" || lookup.PlanRows != 24862 {
+		t.Fatalf("unexpected lookup %+v", lookup)
+	}
+	if _, err := ParseMySQL("id\tselect_type\ttable\n1\tSIMPLE\to\n"); err == nil {
+		t.Fatal("the traditional tabular EXPLAIN is not supported")
+	}
+}
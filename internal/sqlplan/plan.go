@@ -0,0 +1,399 @@
+// Package sqlplan parses database execution plans into a tree of nodes and derives
+// the numbers that locate a slow query's hotspot: exclusive time, row
+// misestimation, buffer hit ratio, loop multiplication and spills to disk.
+package sqlplan
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Node is one operator of a plan. Times are in milliseconds and, like rows, per
+// loop as the database reports them; use the methods for totals.
+type Node struct {
+	// Type is the operator, e.g. "Seq Scan", "Hash Join", "Sort".
+	Type     string
+	Relation string
+	Alias    string
+	Index    string
+	// Relationship is how the node feeds its parent: "Outer", "Inner", "InitPlan",
+	// "SubPlan"; SubplanName names InitPlans, SubPlans and CTEs.
+	Relationship string
+	SubplanName  string
+
+	StartupCost float64
+	TotalCost   float64
+	PlanRows    float64
+
+	// Analyzed reports that actual figures are present (EXPLAIN ANALYZE).
+	Analyzed bool
+	// NeverExecuted marks nodes the executor skipped.
+	NeverExecuted bool
+	ActualStartup float64
+	ActualTotal   float64
+	ActualRows    float64
+	Loops         float64
+
+	// Block counts, inclusive of children as the database reports them.
+	SharedHit     int64
+	SharedRead    int64
+	SharedDirtied int64
+	SharedWritten int64
+	TempRead      int64
+	TempWritten   int64
+
+	// SortSpaceType is "Memory" or "Disk"; SortSpaceKB is the space used.
+	SortMethod    string
+	SortSpaceType string
+	SortSpaceKB   int64
+	// HashBatches above one means a hash or hash aggregate spilled to disk.
+	HashBatches int64
+	// DiskKB is disk used by hash aggregates (Postgres 13+ "Disk Usage").
+	DiskKB int64
+
+	// Details holds the remaining "Key: value" properties: Filter, Index Cond,
+	// Sort Key, Hash Cond, Rows Removed by Filter and so on.
+	Details map[string]string
+	// Text is the node as the engine names it when that differs from the
+	// PostgreSQL pattern, e.g. MySQL's "Index lookup on c using PRIMARY" or
+	// SQLite's "SEARCH c USING INTEGER PRIMARY KEY".
+	Text string
+	// Warnings are problems the engine reports on the node itself, such as
+	// MySQL's filesort or SQLite's temp b-tree.
+	Warnings []string
+
+	Children []*Node
+	Parent   *Node
+	// ID numbers nodes in depth-first order from 1.
+	ID int
+}
+
+// Label is "Seq Scan on orders o", "Index Scan using orders_pkey on orders" or
+// "Bitmap Index Scan on orders_pkey".
+func (n *Node) Label() string {
+	label := n.Type
+	switch {
+	case n.Text != "":
+		label = n.Text
+	case n.Index != "" && n.Relation == "":
+		label += " on " + n.Index
+	case n.Index != "":
+		label += " using " + n.Index
+	}
+	if n.Relation != "" && n.Text == "" {
+		label += " on " + n.Relation
+		if n.Alias != "" && n.Alias != n.Relation {
+			label += " " + n.Alias
+		}
+	}
+	if n.SubplanName != "" {
+		label = n.SubplanName + ": " + label
+	}
+	return label
+}
+
+// loops returns the loop count, treating an unreported count as one.
+func (n *Node) loops() float64 {
+	if n.Loops < 1 {
+		return 1
+	}
+	return n.Loops
+}
+
+// TotalTime is the time spent in the node and its children over all loops.
+func (n *Node) TotalTime() float64 {
+	return n.ActualTotal * n.loops()
+}
+
+// ExclusiveTime is TotalTime minus the TotalTime of the children, never negative.
+// Parallel workers and shared InitPlans can make the subtraction overshoot.
+func (n *Node) ExclusiveTime() float64 {
+	t := n.TotalTime()
+	for _, c := range n.Children {
+		t -= c.TotalTime()
+	}
+	return math.Max(t, 0)
+}
+
+// ExclusiveCost is the planner's cost of the node alone, for plans without ANALYZE.
+func (n *Node) ExclusiveCost() float64 {
+	c := n.TotalCost
+	for _, child := range n.Children {
+		c -= child.TotalCost
+	}
+	return math.Max(c, 0)
+}
+
+// TotalRows is the number of rows the node produced over all loops.
+func (n *Node) TotalRows() float64 {
+	return n.ActualRows * n.loops()
+}
+
+// Misestimate is how far the planner's row estimate was off, as a factor of at
+// least 1, and whether it under-estimated. Zero actual rows count as one; nodes
+// without an estimate (MySQL prints none for some) report 1.
+func (n *Node) Misestimate() (factor float64, under bool) {
+	if !n.Analyzed || n.NeverExecuted || n.PlanRows == 0 {
+		return 1, false
+	}
+	actual, est := math.Max(n.ActualRows, 1), math.Max(n.PlanRows, 1)
+	if actual >= est {
+		return actual / est, true
+	}
+	return est / actual, false
+}
+
+// ExclusiveBlocks returns the shared hit and read blocks of the node alone.
+func (n *Node) ExclusiveBlocks() (hit, read int64) {
+	hit, read = n.SharedHit, n.SharedRead
+	for _, c := range n.Children {
+		hit -= c.SharedHit
+		read -= c.SharedRead
+	}
+	return max(hit, 0), max(read, 0)
+}
+
+// HitRatio is the share of the node's own shared blocks found in cache, and false
+// when the node touched no blocks or BUFFERS was not requested.
+func (n *Node) HitRatio() (float64, bool) {
+	hit, read := n.ExclusiveBlocks()
+	if hit+read == 0 {
+		return 0, false
+	}
+	return float64(hit) / float64(hit+read), true
+}
+
+// Spilled reports whether a sort, hash or aggregate in this node went to disk.
+func (n *Node) Spilled() bool {
+	return n.SortSpaceType == "Disk" || n.HashBatches > 1 || n.DiskKB > 0 || n.exclusiveTempWritten() > 0
+}
+
+func (n *Node) exclusiveTempWritten() int64 {
+	w := n.TempWritten
+	for _, c := range n.Children {
+		w -= c.TempWritten
+	}
+	return max(w, 0)
+}
+
+// Walk visits n and its descendants depth first.
+func (n *Node) Walk(fn func(*Node)) {
+	fn(n)
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Plan is a parsed execution plan.
+type Plan struct {
+	Root *Node
+	// PlanningTime and ExecutionTime are in milliseconds; zero when not reported.
+	PlanningTime  float64
+	ExecutionTime float64
+}
+
+// Analyzed reports whether the plan carries actual run-time figures.
+func (p *Plan) Analyzed() bool {
+	return p.Root != nil && p.Root.Analyzed
+}
+
+// Costed reports whether the plan carries planner cost estimates; SQLite's
+// EXPLAIN QUERY PLAN has none.
+func (p *Plan) Costed() bool {
+	for _, n := range p.Nodes() {
+		if n.TotalCost > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Nodes lists every node depth first.
+func (p *Plan) Nodes() []*Node {
+	var nodes []*Node
+	if p.Root != nil {
+		p.Root.Walk(func(n *Node) { nodes = append(nodes, n) })
+	}
+	return nodes
+}
+
+// link sets Parent and ID on every node.
+func (p *Plan) link() {
+	id := 0
+	var visit func(n, parent *Node)
+	visit = func(n, parent *Node) {
+		id++
+		n.ID, n.Parent = id, parent
+		for _, c := range n.Children {
+			visit(c, n)
+		}
+	}
+	if p.Root != nil {
+		visit(p.Root, nil)
+	}
+}
+
+// Thresholds above which a node property is worth flagging.
+const (
+	misestimateFactor = 10
+	lowHitRatio       = 0.9
+	manyLoops         = 1000
+	removedRowsFactor = 10
+)
+
+// Hotspot is a node ranked by its share of the plan's time (or cost, without
+// ANALYZE) with the problems found on it.
+type Hotspot struct {
+	Node *Node
+	// Self is exclusive time in ms, or exclusive cost without ANALYZE.
+	Self float64
+	// Share is Self as a fraction of the whole plan.
+	Share float64
+	Notes []string
+}
+
+// Hotspots ranks nodes by exclusive time (exclusive cost for plain EXPLAIN), most
+// expensive first; ties keep plan order. Plans with neither rank the nodes with
+// the most notes first.
+func (p *Plan) Hotspots() []Hotspot {
+	nodes := p.Nodes()
+	if len(nodes) == 0 {
+		return nil
+	}
+	analyzed := p.Analyzed()
+	total := 0.0
+	for _, n := range nodes {
+		if analyzed {
+			total += n.ExclusiveTime()
+		} else {
+			total += n.ExclusiveCost()
+		}
+	}
+	hotspots := make([]Hotspot, 0, len(nodes))
+	for _, n := range nodes {
+		h := Hotspot{Node: n, Notes: Notes(n)}
+		if analyzed {
+			h.Self = n.ExclusiveTime()
+		} else {
+			h.Self = n.ExclusiveCost()
+		}
+		if total > 0 {
+			h.Share = h.Self / total
+		}
+		hotspots = append(hotspots, h)
+	}
+	if total == 0 {
+		sort.SliceStable(hotspots, func(i, j int) bool { return len(hotspots[i].Notes) > len(hotspots[j].Notes) })
+		return hotspots
+	}
+	sort.SliceStable(hotspots, func(i, j int) bool { return hotspots[i].Self > hotspots[j].Self })
+	return hotspots
+}
+
+// Notes describes what is wrong with n, in a fixed order.
+func Notes(n *Node) []string {
+	var notes []string
+	if n.NeverExecuted {
+		return []string{"never executed"}
+	}
+	if factor, under := n.Misestimate(); factor >= misestimateFactor {
+		dir := "over"
+		if under {
+			dir = "under"
+		}
+		notes = append(notes, fmt.Sprintf("rows %s-estimated %.0fx (%s est, %s actual)", dir, factor, formatCount(n.PlanRows), formatCount(n.ActualRows)))
+	}
+	if n.Loops >= manyLoops {
+		notes = append(notes, fmt.Sprintf("%s loops (%s rows total)", formatCount(n.Loops), formatCount(n.TotalRows())))
+	}
+	if removed := parseCount(n.Details["Rows Removed by Filter"]); removed > 0 && removed >= removedRowsFactor*math.Max(n.ActualRows, 1) {
+		notes = append(notes, fmt.Sprintf("filter discards %s rows per loop to keep %s", formatCount(removed), formatCount(n.ActualRows)))
+	}
+	if ratio, ok := n.HitRatio(); ok && ratio < lowHitRatio {
+		_, read := n.ExclusiveBlocks()
+		notes = append(notes, fmt.Sprintf("cache hit %.0f%% (%s blocks read)", ratio*100, formatCount(float64(read))))
+	}
+	switch {
+	case n.SortSpaceType == "Disk":
+		notes = append(notes, fmt.Sprintf("sort spilled %s to disk", formatKB(n.SortSpaceKB)))
+	case n.HashBatches > 1:
+		notes = append(notes, fmt.Sprintf("hash spilled in %d batches", n.HashBatches))
+	case n.DiskKB > 0:
+		notes = append(notes, fmt.Sprintf("spilled %s to disk", formatKB(n.DiskKB)))
+	case n.Spilled():
+		notes = append(notes, fmt.Sprintf("wrote %s temp blocks", formatCount(float64(n.exclusiveTempWritten()))))
+	}
+	return append(notes, n.Warnings...)
+}
+
+func parseCount(s string) float64 {
+	var f float64
+	fmt.Sscanf(strings.TrimSpace(s), "%g", &f)
+	return f
+}
+
+func formatCount(f float64) string {
+	switch {
+	case f >= 1e9:
+		return fmt.Sprintf("%.1fG", f/1e9)
+	case f >= 1e6:
+		return fmt.Sprintf("%.1fM", f/1e6)
+	case f >= 1e4:
+		return fmt.Sprintf("%.0fk", f/1e3)
+	}
+	return fmt.Sprintf("%.0f", f)
+}
+
+func formatKB(kb int64) string {
+	if kb >= 1024 {
+		return fmt.Sprintf("%.1fMB", float64(kb)/1024)
+	}
+	return fmt.Sprintf("%dkB", kb)
+}
+
+// Dialect is the database engine a plan comes from.
+type Dialect string
+
+// Supported dialects.
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+// ParseDialect validates a --dialect value.
+func ParseDialect(name string) (Dialect, error) {
+	switch d := Dialect(strings.ToLower(strings.TrimSpace(name))); d {
+	case Postgres, MySQL, SQLite:
+		return d, nil
+	case "postgresql", "pg":
+		return Postgres, nil
+	case "mariadb":
+		return MySQL, nil
+	}
+	return "", fmt.Errorf("unknown dialect %q; expected postgres, mysql or sqlite", name)
+}
+
+// Name is the engine's display name.
+func (d Dialect) Name() string {
+	switch d {
+	case MySQL:
+		return "MySQL"
+	case SQLite:
+		return "SQLite"
+	}
+	return "PostgreSQL"
+}
+
+// Parse parses EXPLAIN output of the given dialect.
+func Parse(d Dialect, text string) (*Plan, error) {
+	switch d {
+	case MySQL:
+		return ParseMySQL(text)
+	case SQLite:
+		return ParseSQLite(text)
+	}
+	return ParsePostgres(text)
+}
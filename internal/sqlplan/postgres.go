@@ -0,0 +1,321 @@
+package sqlplan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParsePostgres parses PostgreSQL EXPLAIN output in the default text format or
+// FORMAT JSON, as printed by psql (header, "(n rows)" footer and "+" continuation
+// markers included) or by a driver.
+func ParsePostgres(text string) (*Plan, error) {
+	text = stripPsql(text)
+	if strings.HasPrefix(strings.TrimSpace(text), "[") || strings.HasPrefix(strings.TrimSpace(text), "{") {
+		return parsePostgresJSON(text)
+	}
+	return parsePostgresText(text)
+}
+
+var psqlFooter = regexp.MustCompile(`^\(\d+ rows?\)$`)
+
+// stripPsql removes the decoration psql adds around a single-column result.
+func stripPsql(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "QUERY PLAN", psqlFooter.MatchString(trimmed):
+			continue
+		case trimmed != "" && strings.Trim(trimmed, "-+") == "":
+			continue
+		}
+		out = append(out, strings.TrimSuffix(strings.TrimRight(line, " "), "+"))
+	}
+	return strings.Join(out, "\n")
+}
+
+// JSON
+
+type pgJSONPlan struct {
+	Plan          map[string]any `json:"Plan"`
+	PlanningTime  float64        `json:"Planning Time"`
+	ExecutionTime float64        `json:"Execution Time"`
+}
+
+func parsePostgresJSON(text string) (*Plan, error) {
+	var plans []pgJSONPlan
+	if err := json.Unmarshal([]byte(text), &plans); err != nil {
+		var single pgJSONPlan
+		if err2 := json.Unmarshal([]byte(text), &single); err2 != nil {
+			return nil, fmt.Errorf("parse FORMAT JSON plan: %w", err)
+		}
+		plans = []pgJSONPlan{single}
+	}
+	if len(plans) == 0 || plans[0].Plan == nil {
+		return nil, errors.New("parse FORMAT JSON plan: no \"Plan\" object")
+	}
+	p := &Plan{Root: pgJSONNode(plans[0].Plan), PlanningTime: plans[0].PlanningTime, ExecutionTime: plans[0].ExecutionTime}
+	p.link()
+	return p, nil
+}
+
+// pgJSONDetails are the string-valued properties kept in Node.Details.
+var pgJSONDetails = []string{"Filter", "Index Cond", "Recheck Cond", "Hash Cond", "Merge Cond", "Join Filter", "Sort Key", "Group Key", "Strategy", "Join Type", "Scan Direction", "Rows Removed by Filter", "Rows Removed by Index Recheck", "Rows Removed by Join Filter", "Heap Fetches", "Workers Planned", "Workers Launched", "CTE Name", "Function Name"}
+
+func pgJSONNode(m map[string]any) *Node {
+	n := &Node{
+		Type:          str(m["Node Type"]),
+		Relation:      str(m["Relation Name"]),
+		Alias:         str(m["Alias"]),
+		Index:         str(m["Index Name"]),
+		Relationship:  str(m["Parent Relationship"]),
+		SubplanName:   str(m["Subplan Name"]),
+		StartupCost:   num(m["Startup Cost"]),
+		TotalCost:     num(m["Total Cost"]),
+		PlanRows:      num(m["Plan Rows"]),
+		SharedHit:     int64(num(m["Shared Hit Blocks"])),
+		SharedRead:    int64(num(m["Shared Read Blocks"])),
+		SharedDirtied: int64(num(m["Shared Dirtied Blocks"])),
+		SharedWritten: int64(num(m["Shared Written Blocks"])),
+		TempRead:      int64(num(m["Temp Read Blocks"])),
+		TempWritten:   int64(num(m["Temp Written Blocks"])),
+		SortMethod:    str(m["Sort Method"]),
+		SortSpaceType: str(m["Sort Space Type"]),
+		SortSpaceKB:   int64(num(m["Sort Space Used"])),
+		HashBatches:   int64(num(m["Hash Batches"])),
+		DiskKB:        int64(num(m["Disk Usage"])),
+		Details:       map[string]string{},
+	}
+	if b := int64(num(m["HashAgg Batches"])); b > n.HashBatches {
+		n.HashBatches = b
+	}
+	if loops, ok := m["Actual Loops"]; ok {
+		n.Analyzed = true
+		n.Loops = num(loops)
+		n.NeverExecuted = n.Loops == 0
+		n.ActualStartup = num(m["Actual Startup Time"])
+		n.ActualTotal = num(m["Actual Total Time"])
+		n.ActualRows = num(m["Actual Rows"])
+	}
+	if n.Type == "ModifyTable" {
+		n.Type = str(m["Operation"])
+	}
+	if n.Type == "Aggregate" && str(m["Strategy"]) == "Hashed" {
+		n.Type = "HashAggregate"
+	}
+	for _, key := range pgJSONDetails {
+		switch v := m[key].(type) {
+		case string:
+			n.Details[key] = v
+		case float64:
+			n.Details[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case []any:
+			parts := make([]string, len(v))
+			for i, p := range v {
+				parts[i] = fmt.Sprint(p)
+			}
+			n.Details[key] = strings.Join(parts, ", ")
+		}
+	}
+	if children, ok := m["Plans"].([]any); ok {
+		for _, c := range children {
+			if cm, ok := c.(map[string]any); ok {
+				n.Children = append(n.Children, pgJSONNode(cm))
+			}
+		}
+	}
+	return n
+}
+
+func str(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+func num(v any) float64 {
+	f, _ := v.(float64)
+	return f
+}
+
+// Text
+
+var (
+	// Seq Scan on orders o  (cost=0.00..1693.00 rows=100 width=8) (actual time=0.010..12.345 rows=99 loops=1)
+	pgNodePattern = regexp.MustCompile(`^(?:->\s+)?(.+?)\s+(?:\(cost=([\d.]+)\.\.([\d.]+) rows=(\d+) width=\d+\))?\s*(?:\((?:actual (?:time=([\d.]+)\.\.([\d.]+) )?rows=([\d.]+) loops=(\d+)|(never executed))\))?$`)
+	pgTimePattern = regexp.MustCompile(`^(Planning|Execution) [Tt]ime: ([\d.]+) ms$`)
+	// Buffers: shared hit=10 read=5 dirtied=1 written=2, temp read=1 written=2
+	pgBufferPattern = regexp.MustCompile(`(shared|local|temp) ((?:(?:hit|read|dirtied|written)=\d+ ?)+)`)
+	pgSortPattern   = regexp.MustCompile(`^Sort Method: (.+?)\s+(Memory|Disk): (\d+)kB`)
+	pgBatchPattern  = regexp.MustCompile(`Batches: (\d+)`)
+	pgDiskPattern   = regexp.MustCompile(`Disk Usage: (\d+)kB`)
+	pgSubplanLabel  = regexp.MustCompile(`^(InitPlan|SubPlan|CTE) .+$`)
+	// Sections after the plan whose details must not attach to its last node.
+	pgTrailerPattern = regexp.MustCompile(`^(Planning:|JIT:|Triggers:|Trigger .+: time=)`)
+)
+
+func parsePostgresText(text string) (*Plan, error) {
+	type frame struct {
+		indent int
+		node   *Node
+	}
+	var (
+		p       = &Plan{}
+		stack   []frame
+		current *Node
+		subplan string // "InitPlan 1 (returns $0)" label waiting for its node
+	)
+	for _, raw := range strings.Split(text, "\n") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		line := strings.TrimSpace(raw)
+
+		if m := pgTimePattern.FindStringSubmatch(line); m != nil {
+			v, _ := strconv.ParseFloat(m[2], 64)
+			if m[1] == "Planning" {
+				p.PlanningTime = v
+			} else {
+				p.ExecutionTime = v
+			}
+			continue
+		}
+		isChild := strings.HasPrefix(line, "->")
+		if m := pgNodePattern.FindStringSubmatch(line); m != nil && (isChild || p.Root == nil) && (m[2] != "" || m[8] != "" || m[9] != "") {
+			n := pgTextNode(m)
+			n.SubplanName, subplan = subplan, ""
+			if isChild {
+				indent += strings.Index(line, "->")
+			}
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				if p.Root != nil {
+					return nil, fmt.Errorf("parse plan: unexpected node %q at the top level", line)
+				}
+				p.Root = n
+			} else {
+				parent := stack[len(stack)-1].node
+				parent.Children = append(parent.Children, n)
+			}
+			stack = append(stack, frame{indent: indent, node: n})
+			current = n
+			continue
+		}
+		if pgTrailerPattern.MatchString(line) {
+			current = nil
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if pgSubplanLabel.MatchString(line) {
+			subplan = line
+			continue
+		}
+		pgTextDetail(current, line)
+	}
+	if p.Root == nil {
+		return nil, errors.New("parse plan: no plan nodes found; expected EXPLAIN output")
+	}
+	p.link()
+	return p, nil
+}
+
+func pgTextNode(m []string) *Node {
+	n := &Node{Details: map[string]string{}}
+	n.Type, n.Index, n.Relation, n.Alias = splitPgLabel(m[1])
+	n.StartupCost, _ = strconv.ParseFloat(m[2], 64)
+	n.TotalCost, _ = strconv.ParseFloat(m[3], 64)
+	n.PlanRows, _ = strconv.ParseFloat(m[4], 64)
+	switch {
+	case m[9] != "":
+		n.Analyzed, n.NeverExecuted = true, true
+	case m[8] != "":
+		n.Analyzed = true
+		n.ActualStartup, _ = strconv.ParseFloat(m[5], 64)
+		n.ActualTotal, _ = strconv.ParseFloat(m[6], 64)
+		n.ActualRows, _ = strconv.ParseFloat(m[7], 64)
+		n.Loops, _ = strconv.ParseFloat(m[8], 64)
+	}
+	return n
+}
+
+// splitPgLabel splits "Index Only Scan Backward using idx on orders o".
+func splitPgLabel(label string) (typ, index, relation, alias string) {
+	typ = label
+	if i := strings.Index(typ, " on "); i >= 0 {
+		rel := strings.Fields(typ[i+4:])
+		typ = typ[:i]
+		if len(rel) > 0 {
+			relation = rel[0]
+		}
+		if len(rel) > 1 {
+			alias = rel[1]
+		}
+	}
+	if i := strings.Index(typ, " using "); i >= 0 {
+		index = strings.TrimSpace(typ[i+7:])
+		typ = typ[:i]
+	}
+	typ = strings.TrimSpace(typ)
+	// "Bitmap Index Scan on idx" names an index, not a relation.
+	if typ == "Bitmap Index Scan" && index == "" {
+		index, relation, alias = relation, "", ""
+	}
+	// "CTE Scan on x" has no " using "; "Function Scan on generate_series g" neither.
+	return typ, index, relation, alias
+}
+
+func pgTextDetail(n *Node, line string) {
+	key, value, ok := strings.Cut(line, ": ")
+	if !ok {
+		return
+	}
+	switch key {
+	case "Buffers":
+		for _, m := range pgBufferPattern.FindAllStringSubmatch(value, -1) {
+			for _, kv := range strings.Fields(m[2]) {
+				k, v, _ := strings.Cut(kv, "=")
+				count, _ := strconv.ParseInt(v, 10, 64)
+				switch m[1] + " " + k {
+				case "shared hit":
+					n.SharedHit = count
+				case "shared read":
+					n.SharedRead = count
+				case "shared dirtied":
+					n.SharedDirtied = count
+				case "shared written":
+					n.SharedWritten = count
+				case "temp read":
+					n.TempRead = count
+				case "temp written":
+					n.TempWritten = count
+				}
+			}
+		}
+		return
+	case "Sort Method":
+		if m := pgSortPattern.FindStringSubmatch(line); m != nil {
+			n.SortMethod, n.SortSpaceType = m[1], m[2]
+			n.SortSpaceKB, _ = strconv.ParseInt(m[3], 10, 64)
+		}
+		return
+	case "Buckets", "Batches":
+		// "Buckets: 1024  Batches: 4  Memory Usage: 100kB", "Batches: 5  Memory Usage: 4145kB  Disk Usage: 10000kB"
+		if m := pgBatchPattern.FindStringSubmatch(line); m != nil {
+			n.HashBatches, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+		if m := pgDiskPattern.FindStringSubmatch(line); m != nil {
+			n.DiskKB, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+		return
+	}
+	n.Details[key] = value
+}
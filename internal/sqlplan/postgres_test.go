@@ -0,0 +1,225 @@
+package sqlplan
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// textPlan is psql output of EXPLAIN (ANALYZE, BUFFERS) for a top-10 report over a
+// badly estimated join whose aggregate spills to disk.
+const textPlan = `                                                              QUERY PLAN
+---------------------------------------------------------------------------------------------------------------------------------------
+ Limit  (cost=25000.00..25000.03 rows=10 width=44) (actual time=812.300..812.310 rows=10 loops=1)
+   Buffers: shared hit=120 read=40000, temp read=2100 written=2110
+   ->  Sort  (cost=25000.00..25250.00 rows=100 width=44) (actual time=812.290..812.295 rows=10 loops=1)
+         Sort Key: (count(*)) DESC
+         Sort Method: top-N heapsort  Memory: 26kB
+         Buffers: shared hit=120 read=40000, temp read=2100 written=2110
+         ->  HashAggregate  (cost=22000.00..23000.00 rows=100 width=44) (actual time=700.100..790.500 rows=50000 loops=1)
+               Group Key: c.name
+               Batches: 5  Memory Usage: 4145kB  Disk Usage: 16824kB
+               Buffers: shared hit=120 read=40000, temp read=2100 written=2110
+               ->  Hash Join  (cost=30.50..20000.00 rows=100 width=36) (actual time=1.200..600.000 rows=120000 loops=1)
+                     Hash Cond: (o.customer_id = c.id)
+                     Buffers: shared hit=120 read=40000
+                     ->  Seq Scan on orders o  (cost=0.00..19000.00 rows=100 width=8) (actual time=0.020..520.000 rows=120000 loops=1)
+                           Filter: (status = 'paid'::text)
+                           Rows Removed by Filter: 2880000
+                           Buffers: shared hit=20 read=40000
+                     ->  Hash  (cost=18.00..18.00 rows=1000 width=36) (actual time=1.100..1.100 rows=1000 loops=1)
+                           Buckets: 1024  Batches: 1  Memory Usage: 72kB
+                           Buffers: shared hit=100
+                           ->  Seq Scan on customers c  (cost=0.00..18.00 rows=1000 width=36) (actual time=0.010..0.500 rows=1000 loops=1)
+                                 Buffers: shared hit=100
+ Planning:
+   Buffers: shared hit=12
+ Planning Time: 0.250 ms
+ Execution Time: 815.000 ms
+(26 rows)
+`
+
+// jsonPlan is EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) of a nested loop probing an
+// index once per outer row.
+const jsonPlan = `[
+  {
+    "Plan": {
+      "Node Type": "Nested Loop",
+      "Parallel Aware": false,
+      "Join Type": "Inner",
+      "Startup Cost": 0.43,
+      "Total Cost": 8500.00,
+      "Plan Rows": 50,
+      "Plan Width": 16,
+      "Actual Startup Time": 0.050,
+      "Actual Total Time": 450.000,
+      "Actual Rows": 5000,
+      "Actual Loops": 1,
+      "Shared Hit Blocks": 15200,
+      "Shared Read Blocks": 300,
+      "Plans": [
+        {
+          "Node Type": "Seq Scan",
+          "Parent Relationship": "Outer",
+          "Relation Name": "users",
+          "Alias": "u",
+          "Startup Cost": 0.00,
+          "Total Cost": 100.00,
+          "Plan Rows": 50,
+          "Plan Width": 8,
+          "Actual Startup Time": 0.010,
+          "Actual Total Time": 5.000,
+          "Actual Rows": 5000,
+          "Actual Loops": 1,
+          "Filter": "active",
+          "Rows Removed by Filter": 100,
+          "Shared Hit Blocks": 200,
+          "Shared Read Blocks": 0
+        },
+        {
+          "Node Type": "Index Scan",
+          "Parent Relationship": "Inner",
+          "Scan Direction": "Forward",
+          "Index Name": "events_user_id_idx",
+          "Relation Name": "events",
+          "Alias": "e",
+          "Startup Cost": 0.43,
+          "Total Cost": 8.45,
+          "Plan Rows": 1,
+          "Plan Width": 8,
+          "Actual Startup Time": 0.050,
+          "Actual Total Time": 0.080,
+          "Actual Rows": 1,
+          "Actual Loops": 5000,
+          "Index Cond": "(user_id = u.id)",
+          "Shared Hit Blocks": 15000,
+          "Shared Read Blocks": 300
+        }
+      ]
+    },
+    "Planning Time": 0.300,
+    "Triggers": [],
+    "Execution Time": 451.200
+  }
+]`
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func TestParsePostgresText(t *testing.T) {
+	plan, err := ParsePostgres(textPlan)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !plan.Analyzed() || plan.PlanningTime != 0.25 || plan.ExecutionTime != 815 {
+		t.Fatalf("unexpected plan header %+v", plan)
+	}
+	nodes := plan.Nodes()
+	if len(nodes) != 7 {
+		t.Fatalf("expected 7 nodes, got %d", len(nodes))
+	}
+	if plan.Root.SharedHit != 120 || plan.Root.TempWritten != 2110 {
+		t.Fatalf("planning buffers leaked into the plan: %+v", plan.Root)
+	}
+	join := nodes[3]
+	if join.Type != "Hash Join" || len(join.Children) != 2 || join.Parent != nodes[2] || join.Details["Hash Cond"] != "(o.customer_id = c.id)" {
+		t.Fatalf("unexpected join %+v", join)
+	}
+	orders := nodes[4]
+	if orders.Type != "Seq Scan" || orders.Relation != "orders" || orders.Alias != "o" || orders.Label() != "Seq Scan on orders o" {
+		t.Fatalf("unexpected scan %+v", orders)
+	}
+	if !near(join.ExclusiveTime(), 78.9) || !near(nodes[2].ExclusiveTime(), 190.5) {
+		t.Fatalf("unexpected exclusive times %v %v", join.ExclusiveTime(), nodes[2].ExclusiveTime())
+	}
+	if agg := nodes[2]; agg.HashBatches != 5 || agg.DiskKB != 16824 || !agg.Spilled() {
+		t.Fatalf("expected a spilled aggregate %+v", agg)
+	}
+	if sort := nodes[1]; sort.SortMethod != "top-N heapsort" || sort.SortSpaceType != "Memory" || sort.Spilled() {
+		t.Fatalf("unexpected sort %+v", sort)
+	}
+	if hash := nodes[5]; hash.HashBatches != 1 || hash.Spilled() {
+		t.Fatalf("unexpected hash %+v", hash)
+	}
+}
+
+func TestPostgresHotspots(t *testing.T) {
+	plan, err := ParsePostgres(textPlan)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	hotspots := plan.Hotspots()
+	if hotspots[0].Node.Label() != "Seq Scan on orders o" || hotspots[1].Node.Type != "HashAggregate" || hotspots[2].Node.Type != "Hash Join" {
+		t.Fatalf("unexpected ranking %s, %s, %s", hotspots[0].Node.Label(), hotspots[1].Node.Label(), hotspots[2].Node.Label())
+	}
+	if share := hotspots[0].Share; share < 0.63 || share > 0.65 {
+		t.Fatalf("unexpected share %v", share)
+	}
+	want := []string{
+		"rows under-estimated 1200x (100 est, 120k actual)",
+		"filter discards 2.9M rows per loop to keep 120k",
+		"cache hit 0% (40k blocks read)",
+	}
+	if got := hotspots[0].Notes; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected notes %q", got)
+	}
+	if got := hotspots[1].Notes; len(got) != 2 || got[1] != "hash spilled in 5 batches" {
+		t.Fatalf("unexpected aggregate notes %q", got)
+	}
+}
+
+func TestParsePostgresJSON(t *testing.T) {
+	// psql wraps FORMAT JSON output in a header and "+" continuation markers.
+	var decorated strings.Builder
+	decorated.WriteString("      QUERY PLAN\n-------------------\n")
+	for _, line := range strings.Split(jsonPlan, "\n") {
+		decorated.WriteString(" " + line + " +\n")
+	}
+	decorated.WriteString("(1 row)\n")
+
+	for _, input := range []string{jsonPlan, decorated.String()} {
+		plan, err := ParsePostgres(input)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if plan.ExecutionTime != 451.2 || len(plan.Nodes()) != 3 {
+			t.Fatalf("unexpected plan %+v", plan)
+		}
+		probe := plan.Root.Children[1]
+		if probe.Label() != "Index Scan using events_user_id_idx on events e" || probe.Relationship != "Inner" || probe.Details["Index Cond"] != "(user_id = u.id)" {
+			t.Fatalf("unexpected index scan %+v", probe)
+		}
+		if !near(probe.TotalTime(), 400) || !near(plan.Root.ExclusiveTime(), 45) {
+			t.Fatalf("unexpected times %v %v", probe.TotalTime(), plan.Root.ExclusiveTime())
+		}
+		hotspots := plan.Hotspots()
+		if hotspots[0].Node != probe || len(hotspots[0].Notes) != 1 || hotspots[0].Notes[0] != "5000 loops (5000 rows total)" {
+			t.Fatalf("unexpected top hotspot %+v", hotspots[0])
+		}
+		if ratio, ok := probe.HitRatio(); !ok || ratio < 0.98 || ratio > 0.99 {
+			t.Fatalf("unexpected hit ratio %v", ratio)
+		}
+	}
+}
+
+func TestParsePostgresWithoutAnalyze(t *testing.T) {
+	plan, err := ParsePostgres(`Hash Join  (cost=30.50..20000.00 rows=100 width=36)
+  Hash Cond: (o.customer_id = c.id)
+  ->  Seq Scan on orders o  (cost=0.00..19000.00 rows=100 width=8)
+        Filter: (status = 'paid'::text)
+  ->  Hash  (cost=18.00..18.00 rows=1000 width=36)
+        ->  Seq Scan on customers c  (cost=0.00..18.00 rows=1000 width=36)
+`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if plan.Analyzed() {
+		t.Fatal("plain EXPLAIN has no actual figures")
+	}
+	hotspots := plan.Hotspots()
+	if hotspots[0].Node.Relation != "orders" || hotspots[0].Self != 19000 || len(hotspots[0].Notes) != 0 {
+		t.Fatalf("expected ranking by exclusive cost, got %+v", hotspots[0])
+	}
+	if _, err := ParsePostgres("not a plan"); err == nil {
+		t.Fatal("expected an error for text without plan nodes")
+	}
+}
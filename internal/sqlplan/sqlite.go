@@ -0,0 +1,104 @@
+package sqlplan
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// |--SEARCH c USING INTEGER PRIMARY KEY (rowid=?)
+	// |  `--SCAN e
+	sqliteTreePattern = regexp.MustCompile("^((?:[|` ]  )*)[|`]--(.+)$")
+	// 3|0|0|SCAN o, as the rows (id, parent, notused, detail) a driver returns.
+	sqliteRowPattern = regexp.MustCompile(`^(\d+)[|,\t ]+(\d+)[|,\t ]+\d+[|,\t ]+(.+)$`)
+	// SCAN o USING COVERING INDEX idx, SEARCH TABLE orders AS o USING INDEX idx (status=?)
+	sqliteAccessPattern = regexp.MustCompile(`^(SCAN|SEARCH)(?: TABLE)? (\S+)(?: AS (\S+))?(?: USING (?:(AUTOMATIC (?:PARTIAL )?(?:COVERING )?INDEX)|((?:COVERING )?INDEX) (\S+)|(INTEGER PRIMARY KEY|PRIMARY KEY)))?(?: \((.*)\))?`)
+	sqliteTempPattern   = regexp.MustCompile(`^USE TEMP B-TREE FOR (.+)$`)
+)
+
+// ParseSQLite parses the output of SQLite's EXPLAIN QUERY PLAN, either the tree the
+// sqlite3 shell prints or the id|parent|notused|detail rows a driver returns. The
+// plan has no costs or row counts; nodes carry warnings for full scans, automatic
+// indexes and temp b-trees instead.
+func ParseSQLite(text string) (*Plan, error) {
+	root := &Node{Type: "QUERY PLAN", Text: "QUERY PLAN", Details: map[string]string{}}
+	var (
+		stack []*Node // stack[d] is the last node at depth d
+		byID  = map[int]*Node{}
+		found bool
+	)
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimRight(line, " ")
+		if m := sqliteTreePattern.FindStringSubmatch(line); m != nil {
+			depth := len(m[1]) / 3
+			if depth > len(stack) {
+				depth = len(stack)
+			}
+			parent := root
+			if depth > 0 {
+				parent = stack[depth-1]
+			}
+			n := sqliteNode(m[2])
+			parent.Children = append(parent.Children, n)
+			stack = append(stack[:depth], n)
+			found = true
+			continue
+		}
+		if m := sqliteRowPattern.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			parentID, _ := strconv.Atoi(m[2])
+			parent, ok := byID[parentID]
+			if !ok {
+				parent = root
+			}
+			n := sqliteNode(strings.TrimSpace(m[3]))
+			parent.Children = append(parent.Children, n)
+			byID[id] = n
+			found = true
+		}
+	}
+	if !found {
+		return nil, errors.New("parse plan: no EXPLAIN QUERY PLAN lines found")
+	}
+	p := &Plan{Root: root}
+	p.link()
+	return p, nil
+}
+
+func sqliteNode(detail string) *Node {
+	n := &Node{Type: detail, Text: detail, Details: map[string]string{}}
+	if m := sqliteAccessPattern.FindStringSubmatch(detail); m != nil {
+		n.Type, n.Relation, n.Alias = m[1], m[2], m[3]
+		switch {
+		case m[4] != "":
+			n.Index = m[4]
+			n.Warnings = append(n.Warnings, "automatic index built for every execution; a permanent index would avoid it")
+		case m[6] != "":
+			n.Index = m[6]
+		case m[7] != "":
+			n.Index = m[7]
+		}
+		if m[8] != "" {
+			n.Details["Index Cond"] = "(" + m[8] + ")"
+		}
+		if n.Type == "SCAN" {
+			if n.Index == "" {
+				n.Warnings = append(n.Warnings, "full table scan")
+			} else {
+				n.Warnings = append(n.Warnings, "full index scan")
+			}
+		}
+		return n
+	}
+	if m := sqliteTempPattern.FindStringSubmatch(detail); m != nil {
+		n.Type = "USE TEMP B-TREE"
+		n.Warnings = append(n.Warnings, "sorts in a temp b-tree for "+m[1])
+		return n
+	}
+	if strings.HasPrefix(detail, "CORRELATED ") {
+		n.Warnings = append(n.Warnings, "re-run for every outer row")
+	}
+	return n
+}
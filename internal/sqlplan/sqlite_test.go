@@ -0,0 +1,68 @@
+package sqlplan
+
+import (
+	"strings"
+	"testing"
+)
+
+// sqliteTree is the sqlite3 shell's EXPLAIN QUERY PLAN of a filtered join with a
+// subquery.
+const sqliteTree = `QUERY PLAN
+|--SCAN o
+|--SEARCH c USING INTEGER PRIMARY KEY (rowid=?)
+|--LIST SUBQUERY 1
+|  |--SCAN e
+|  ` + "`" + `--CREATE BLOOM FILTER
+|--SEARCH e2 USING AUTOMATIC COVERING INDEX (user_id=?)
+` + "`" + `--USE TEMP B-TREE FOR ORDER BY
+`
+
+func TestParseSQLiteTree(t *testing.T) {
+	plan, err := ParseSQLite(sqliteTree)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if plan.Analyzed() || plan.Costed() || len(plan.Root.Children) != 5 {
+		t.Fatalf("unexpected plan: %d top-level nodes", len(plan.Root.Children))
+	}
+	search := plan.Root.Children[1]
+	if search.Type != "SEARCH" || search.Relation != "c" || search.Index != "INTEGER PRIMARY KEY" || search.Details["Index Cond"] != "(rowid=?)" || search.Label() != "SEARCH c USING INTEGER PRIMARY KEY (rowid=?)" {
+		t.Fatalf("unexpected search %+v", search)
+	}
+	sub := plan.Root.Children[2]
+	if len(sub.Children) != 2 || sub.Children[0].Relation != "e" {
+		t.Fatalf("unexpected subquery %+v", sub)
+	}
+
+	hotspots := plan.Hotspots()
+	var ranked []string
+	for _, h := range hotspots[:4] {
+		ranked = append(ranked, h.Node.Label()+": "+strings.Join(h.Notes, ", "))
+	}
+	want := []string{
+		"SCAN o: full table scan",
+		"SCAN e: full table scan",
+		"SEARCH e2 USING AUTOMATIC COVERING INDEX (user_id=?): automatic index built for every execution; a permanent index would avoid it",
+		"USE TEMP B-TREE FOR ORDER BY: sorts in a temp b-tree for ORDER BY",
+	}
+	if strings.Join(ranked, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected ranking:\n%s", strings.Join(ranked, "\n"))
+	}
+}
+
+func TestParseSQLiteRows(t *testing.T) {
+	plan, err := ParseSQLite("id|parent|notused|detail\n2|0|0|SEARCH TABLE orders AS o USING INDEX orders_status_idx (status=?)\n5|0|0|SCAN TABLE customers AS c USING COVERING INDEX customers_name_idx\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	nodes := plan.Root.Children
+	if len(nodes) != 2 || nodes[0].Relation != "orders" || nodes[0].Alias != "o" || nodes[0].Index != "orders_status_idx" {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+	if notes := Notes(nodes[1]); len(notes) != 1 || notes[0] != "full index scan" {
+		t.Fatalf("unexpected notes %q", notes)
+	}
+	if _, err := ParseSQLite("SELECT 1;"); err == nil {
+		t.Fatal("expected an error without plan lines")
+	}
+}
@@ -0,0 +1,120 @@
+package sqlschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CatalogQuery exports what the PostgreSQL catalogs know about user tables as one
+// JSON document: columns, index definitions and sizes, pg_stats and row
+// estimates. Run it with `psql -XAt -f catalog.sql > schema.json`; it reads only
+// catalog views and is safe against production.
+const CatalogQuery = `SELECT json_build_object(
+  'tables', (SELECT json_agg(json_build_object('schema', n.nspname, 'table', c.relname,
+      'rows', c.reltuples, 'bytes', pg_total_relation_size(c.oid)) ORDER BY n.nspname, c.relname)
+    FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+    WHERE c.relkind IN ('r', 'p') AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'),
+  'columns', (SELECT json_agg(json_build_object('schema', table_schema, 'table', table_name,
+      'column', column_name, 'type', data_type, 'nullable', is_nullable = 'YES') ORDER BY table_schema, table_name, ordinal_position)
+    FROM information_schema.columns WHERE table_schema NOT IN ('pg_catalog', 'information_schema')),
+  'indexes', (SELECT json_agg(json_build_object('schema', schemaname, 'table', tablename, 'name', indexname,
+      'definition', indexdef, 'bytes', pg_relation_size(format('%I.%I', schemaname, indexname)::regclass)) ORDER BY schemaname, tablename, indexname)
+    FROM pg_indexes WHERE schemaname NOT IN ('pg_catalog', 'information_schema')),
+  'stats', (SELECT json_agg(json_build_object('schema', schemaname, 'table', tablename, 'column', attname,
+      'null_frac', null_frac, 'n_distinct', n_distinct, 'correlation', correlation, 'avg_width', avg_width) ORDER BY schemaname, tablename, attname)
+    FROM pg_stats WHERE schemaname NOT IN ('pg_catalog', 'information_schema'))
+);
+`
+
+type catalog struct {
+	Tables []struct {
+		Schema string  `json:"schema"`
+		Table  string  `json:"table"`
+		Rows   float64 `json:"rows"`
+		Bytes  int64   `json:"bytes"`
+	} `json:"tables"`
+	Columns []struct {
+		Schema   string `json:"schema"`
+		Table    string `json:"table"`
+		Column   string `json:"column"`
+		Type     string `json:"type"`
+		Nullable bool   `json:"nullable"`
+	} `json:"columns"`
+	Indexes []struct {
+		Schema     string `json:"schema"`
+		Table      string `json:"table"`
+		Name       string `json:"name"`
+		Definition string `json:"definition"`
+		Bytes      int64  `json:"bytes"`
+	} `json:"indexes"`
+	Stats []struct {
+		Schema      string   `json:"schema"`
+		Table       string   `json:"table"`
+		Column      string   `json:"column"`
+		NullFrac    float64  `json:"null_frac"`
+		NDistinct   float64  `json:"n_distinct"`
+		Correlation *float64 `json:"correlation"`
+		AvgWidth    int      `json:"avg_width"`
+	} `json:"stats"`
+}
+
+// ParseCatalog reads the JSON document CatalogQuery produces.
+func ParseCatalog(text string) (*Schema, error) {
+	var c catalog
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &c); err != nil {
+		return nil, fmt.Errorf("parse schema JSON: %w", err)
+	}
+	s := &Schema{}
+	for _, t := range c.Tables {
+		table := s.table(t.Schema + "." + quoteIfDotted(t.Table))
+		table.Rows, table.Bytes = max(t.Rows, 0), t.Bytes
+	}
+	for _, col := range c.Columns {
+		t := s.table(col.Schema + "." + quoteIfDotted(col.Table))
+		t.Columns = append(t.Columns, &Column{Name: col.Column, Type: col.Type, NotNull: !col.Nullable})
+	}
+	for _, ix := range c.Indexes {
+		_, index, ok := ParseCreateIndex(ix.Definition)
+		if !ok {
+			continue
+		}
+		t := s.table(ix.Schema + "." + quoteIfDotted(ix.Table))
+		index.Name, index.Bytes = ix.Name, ix.Bytes
+		// pg_indexes has no constraint flag; the conventional name marks the primary key.
+		index.Primary = index.Unique && ix.Name == ix.Table+"_pkey"
+		t.Indexes = append(t.Indexes, index)
+	}
+	for _, st := range c.Stats {
+		t := s.Table(st.Schema + "." + quoteIfDotted(st.Table))
+		if t == nil {
+			continue
+		}
+		if col := t.Column(st.Column); col != nil {
+			col.Stats = &ColumnStats{NullFrac: st.NullFrac, NDistinct: st.NDistinct, AvgWidth: st.AvgWidth}
+			if st.Correlation != nil {
+				col.Stats.Correlation = *st.Correlation
+			}
+		}
+	}
+	if len(s.Tables) == 0 {
+		return nil, errors.New("parse schema JSON: no tables, columns or indexes; was it produced by the documented catalog query?")
+	}
+	return s, nil
+}
+
+func quoteIfDotted(name string) string {
+	if strings.Contains(name, ".") {
+		return `"` + name + `"`
+	}
+	return name
+}
+
+// Load reads a schema in either format: JSON from CatalogQuery or DDL.
+func Load(text string) (*Schema, error) {
+	if strings.HasPrefix(strings.TrimSpace(text), "{") {
+		return ParseCatalog(text)
+	}
+	return ParseDDL(text)
+}
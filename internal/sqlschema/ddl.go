@@ -0,0 +1,401 @@
+package sqlschema
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ParseDDL reads CREATE TABLE, CREATE INDEX and ALTER TABLE ... ADD CONSTRAINT
+// statements as pg_dump --schema-only, SQLite's .schema or MySQL's SHOW CREATE
+// TABLE print them. Other statements (functions, sequences, grants) are skipped.
+func ParseDDL(text string) (*Schema, error) {
+	s := &Schema{}
+	for _, stmt := range SplitStatements(text) {
+		switch {
+		case createTablePattern.MatchString(stmt):
+			parseCreateTable(s, stmt)
+		case createIndexPattern.MatchString(stmt):
+			if table, ix, ok := ParseCreateIndex(stmt); ok {
+				t := s.table(table)
+				t.Indexes = append(t.Indexes, ix)
+			}
+		case alterIndexPattern.MatchString(stmt):
+			if table, ix, ok := ParseIndexDDL(stmt); ok {
+				t := s.table(table)
+				t.Indexes = append(t.Indexes, ix)
+			}
+		case alterConstraintPattern.MatchString(stmt):
+			parseAlterConstraint(s, stmt)
+		}
+	}
+	if len(s.Tables) == 0 {
+		return nil, errors.New("parse schema: no CREATE TABLE or CREATE INDEX statements found")
+	}
+	return s, nil
+}
+
+var (
+	createTablePattern     = regexp.MustCompile(`(?is)^CREATE\s+(?:(?:GLOBAL|LOCAL)\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?((?:"[^"]+"|` + "`[^`]+`" + `|[\w$]+)(?:\.(?:"[^"]+"|` + "`[^`]+`" + `|[\w$]+))?)\s*\(`)
+	createIndexPattern     = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:(\S+)\s+)?ON\s+(?:ONLY\s+)?(\S+?)\s*(?:USING\s+(\w+)\s*)?\(`)
+	alterIndexPattern      = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\S+)\s+ADD\s+(UNIQUE\s+)?(?:INDEX|KEY)\s+(?:(\S+?)\s*)?\(`)
+	alterConstraintPattern = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?(?:IF\s+EXISTS\s+)?(\S+)\s+ADD\s+(?:CONSTRAINT\s+(\S+)\s+)?(PRIMARY\s+KEY|UNIQUE)\s*(?:USING\s+INDEX\s+\S+)?\s*\(`)
+	includePattern         = regexp.MustCompile(`(?is)^\s*INCLUDE\s*\(`)
+	wherePattern           = regexp.MustCompile(`(?is)\bWHERE\s+(.+)$`)
+	// Items of a CREATE TABLE body that are constraints or indexes, not columns.
+	tableConstraintPattern = regexp.MustCompile(`(?is)^(?:CONSTRAINT\s+(\S+)\s+)?(PRIMARY\s+KEY|UNIQUE(?:\s+(?:KEY|INDEX))?|(?:KEY|INDEX)|FULLTEXT(?:\s+(?:KEY|INDEX))?|SPATIAL(?:\s+(?:KEY|INDEX))?|FOREIGN\s+KEY|CHECK|EXCLUDE)\b\s*(\S+?)?\s*(?:USING\s+\w+\s*)?\(`)
+	orderSuffixPattern     = regexp.MustCompile(`(?i)\s+(?:ASC|DESC|NULLS\s+(?:FIRST|LAST)|COLLATE\s+\S+)\b.*$`)
+	prefixLengthPattern    = regexp.MustCompile(`^(.+?)\s*\(\d+\)$`)
+)
+
+func parseCreateTable(s *Schema, stmt string) {
+	m := createTablePattern.FindStringSubmatchIndex(stmt)
+	open := m[1] - 1
+	body, _, ok := parenthesized(stmt, open)
+	if !ok {
+		return
+	}
+	t := s.table(stmt[m[2]:m[3]])
+	for _, item := range SplitTopLevel(body) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if c := tableConstraintPattern.FindStringSubmatchIndex(item); c != nil {
+			addTableConstraint(t, item, c)
+			continue
+		}
+		col := parseColumn(item)
+		if col == nil {
+			continue
+		}
+		t.Columns = append(t.Columns, col)
+		upper := strings.ToUpper(item)
+		switch {
+		case strings.Contains(upper, "PRIMARY KEY"):
+			t.Indexes = append(t.Indexes, &Index{Name: t.Name + "_pkey", Columns: []string{col.Name}, Unique: true, Primary: true, Method: "btree"})
+		case regexp.MustCompile(`\bUNIQUE\b`).MatchString(upper):
+			t.Indexes = append(t.Indexes, &Index{Name: t.Name + "_" + col.Name + "_key", Columns: []string{col.Name}, Unique: true, Method: "btree"})
+		}
+	}
+}
+
+func parseColumn(item string) *Column {
+	fields := identPattern.FindStringIndex(item)
+	if fields == nil || fields[0] != 0 {
+		return nil
+	}
+	name := unquote(item[:fields[1]])
+	rest := strings.TrimSpace(item[fields[1]:])
+	typ := rest
+	if i := regexp.MustCompile(`(?i)\s(?:NOT\s+NULL|NULL|DEFAULT|PRIMARY|UNIQUE|REFERENCES|CHECK|CONSTRAINT|GENERATED|COLLATE|AUTO_INCREMENT|COMMENT)\b`).FindStringIndex(" " + rest); i != nil {
+		typ = strings.TrimSpace(rest[:max(i[0]-1, 0)])
+	}
+	return &Column{Name: name, Type: typ, NotNull: regexp.MustCompile(`(?i)\bNOT\s+NULL\b|\bPRIMARY\s+KEY\b`).MatchString(rest)}
+}
+
+func addTableConstraint(t *Table, item string, m []int) {
+	kind := strings.ToUpper(strings.Join(strings.Fields(item[m[4]:m[5]]), " "))
+	switch {
+	case strings.HasPrefix(kind, "FOREIGN"), kind == "CHECK", kind == "EXCLUDE":
+		return
+	}
+	cols, rest, ok := parenthesized(item, m[1]-1)
+	if !ok {
+		return
+	}
+	name := ""
+	if m[2] >= 0 {
+		name = unquote(item[m[2]:m[3]])
+	}
+	if m[6] >= 0 && name == "" {
+		name = unquote(item[m[6]:m[7]])
+	}
+	ix := &Index{Name: name, Columns: indexKeys(cols), Method: "btree"}
+	switch {
+	case kind == "PRIMARY KEY":
+		ix.Primary, ix.Unique = true, true
+		if ix.Name == "" {
+			ix.Name = t.Name + "_pkey"
+		}
+	case strings.HasPrefix(kind, "UNIQUE"):
+		ix.Unique = true
+	case strings.HasPrefix(kind, "FULLTEXT"):
+		ix.Method = "fulltext"
+	case strings.HasPrefix(kind, "SPATIAL"):
+		ix.Method = "spatial"
+	}
+	if ix.Name == "" {
+		ix.Name = t.Name + "_" + strings.Join(ix.Columns, "_") + "_key"
+	}
+	if inc := includePattern.FindStringIndex(rest); inc != nil {
+		if include, _, ok := parenthesized(rest, inc[1]-1); ok {
+			ix.Include = indexKeys(include)
+		}
+	}
+	t.Indexes = append(t.Indexes, ix)
+}
+
+func parseAlterConstraint(s *Schema, stmt string) {
+	m := alterConstraintPattern.FindStringSubmatchIndex(stmt)
+	cols, _, ok := parenthesized(stmt, m[1]-1)
+	if !ok {
+		return
+	}
+	t := s.table(stmt[m[2]:m[3]])
+	ix := &Index{Columns: indexKeys(cols), Unique: true, Method: "btree"}
+	if m[4] >= 0 {
+		ix.Name = unquote(stmt[m[4]:m[5]])
+	}
+	if strings.HasPrefix(strings.ToUpper(stmt[m[6]:m[7]]), "PRIMARY") {
+		ix.Primary = true
+	}
+	t.Indexes = append(t.Indexes, ix)
+}
+
+// ParseCreateIndex parses one CREATE INDEX statement, returning the table it is on.
+func ParseCreateIndex(stmt string) (table string, ix *Index, ok bool) {
+	stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+	m := createIndexPattern.FindStringSubmatchIndex(stmt)
+	if m == nil {
+		return "", nil, false
+	}
+	cols, rest, ok := parenthesized(stmt, m[1]-1)
+	if !ok {
+		return "", nil, false
+	}
+	ix = &Index{Columns: indexKeys(cols), Unique: m[2] >= 0, Method: "btree"}
+	if m[4] >= 0 {
+		ix.Name = unquote(stmt[m[4]:m[5]])
+	}
+	if m[8] >= 0 {
+		ix.Method = strings.ToLower(stmt[m[8]:m[9]])
+	}
+	if inc := includePattern.FindStringIndex(rest); inc != nil {
+		if include, after, ok := parenthesized(rest, inc[1]-1); ok {
+			ix.Include = indexKeys(include)
+			rest = after
+		}
+	}
+	if w := wherePattern.FindStringSubmatch(rest); w != nil {
+		ix.Where = strings.TrimSpace(w[1])
+	}
+	return stmt[m[6]:m[7]], ix, true
+}
+
+// ParseIndexDDL parses a statement that adds one index: CREATE INDEX, or MySQL's
+// ALTER TABLE ... ADD INDEX (trailing ALGORITHM/LOCK options are ignored).
+func ParseIndexDDL(stmt string) (table string, ix *Index, ok bool) {
+	if table, ix, ok := ParseCreateIndex(stmt); ok {
+		return table, ix, true
+	}
+	stmt = strings.TrimSuffix(strings.TrimSpace(stmt), ";")
+	m := alterIndexPattern.FindStringSubmatchIndex(stmt)
+	if m == nil {
+		return "", nil, false
+	}
+	cols, _, ok := parenthesized(stmt, m[1]-1)
+	if !ok {
+		return "", nil, false
+	}
+	ix = &Index{Columns: indexKeys(cols), Unique: m[4] >= 0, Method: "btree"}
+	if m[6] >= 0 {
+		ix.Name = unquote(stmt[m[6]:m[7]])
+	}
+	return stmt[m[2]:m[3]], ix, true
+}
+
+// indexKeys splits an index column list, dropping sort order, collation, operator
+// classes and MySQL prefix lengths: "lower(email) text_pattern_ops, created_at
+// DESC" becomes ["lower(email)", "created_at"].
+func indexKeys(list string) []string {
+	var keys []string
+	for _, item := range SplitTopLevel(list) {
+		item = strings.TrimSpace(orderSuffixPattern.ReplaceAllString(strings.TrimSpace(item), ""))
+		if m := prefixLengthPattern.FindStringSubmatch(item); m != nil && identPattern.FindString(m[1]) == m[1] {
+			item = m[1]
+		}
+		if loc := identPattern.FindStringIndex(item); loc != nil && loc[0] == 0 && loc[1] < len(item) && item[loc[1]] == ' ' {
+			// "name text_pattern_ops": a plain column followed by an operator class.
+			item = item[:loc[1]]
+		}
+		if identPattern.FindString(item) == item {
+			item = unquote(item)
+		}
+		keys = append(keys, item)
+	}
+	return keys
+}
+
+// parenthesized returns the text inside the parenthesis opening at open and the
+// text after its closing parenthesis.
+func parenthesized(s string, open int) (inside, after string, ok bool) {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return s[open+1 : i], s[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// SplitTopLevel splits on commas outside parentheses and quotes.
+func SplitTopLevel(s string) []string {
+	var (
+		parts []string
+		depth int
+		quote byte
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// SplitStatements splits SQL into statements on semicolons outside strings,
+// quoted identifiers, dollar-quoted bodies and comments, which it drops.
+// Statements are trimmed; empty ones are omitted.
+func SplitStatements(sql string) []string {
+	var stmts []string
+	for _, st := range Statements(sql) {
+		stmts = append(stmts, st.Text)
+	}
+	return stmts
+}
+
+// Statement is a statement of a SQL file and the 1-based lines it starts and
+// ends on.
+type Statement struct {
+	Text    string
+	Line    int
+	EndLine int
+}
+
+// Statements is SplitStatements keeping each statement's line.
+func Statements(sql string) []Statement {
+	var (
+		stmts []Statement
+		cur   strings.Builder
+		start = -1 // offset of the current statement's first character
+		last  = -1 // offset of its last character, or of the semicolon ending it
+	)
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, Statement{Text: s, Line: 1 + strings.Count(sql[:start], "\n"), EndLine: 1 + strings.Count(sql[:last], "\n")})
+		}
+		cur.Reset()
+		start = -1
+	}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if start < 0 && c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ';' && !strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") && !(c == '#' && (i == 0 || sql[i-1] == '\n')) {
+			start = i
+		}
+		if start >= 0 && c != ' ' && c != '\t' && c != '\n' && c != '\r' && !strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") && !(c == '#' && (i == 0 || sql[i-1] == '\n')) {
+			last = i
+		}
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end
+				cur.WriteByte('\n')
+			}
+		case c == '#' && (i == 0 || sql[i-1] == '\n'):
+			// MySQL comment line.
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+			cur.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`':
+			end := ClosingQuote(sql, i)
+			cur.WriteString(sql[i : end+1])
+			i, last = end, end
+		case c == '$':
+			if tag := DollarTag(sql[i:]); tag != "" {
+				end := strings.Index(sql[i+len(tag):], tag)
+				if end < 0 {
+					cur.WriteString(sql[i:])
+					i = len(sql)
+					continue
+				}
+				cur.WriteString(sql[i : i+len(tag)+end+len(tag)])
+				i += len(tag) + end + len(tag) - 1
+				last = i
+				continue
+			}
+			cur.WriteByte(c)
+		case c == ';':
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// DollarTag returns the PostgreSQL dollar-quote tag s starts with, such as $$ or
+// $body$, or "" when it starts with none.
+func DollarTag(s string) string {
+	return dollarTag.FindString(s)
+}
+
+// ClosingQuote returns the index of the quote closing the one at start, or the
+// last index when it is unterminated; doubled quotes are escapes.
+func ClosingQuote(s string, start int) int {
+	q := s[start]
+	for i := start + 1; i < len(s); i++ {
+		if s[i] == q {
+			if i+1 < len(s) && s[i+1] == q {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(s) - 1
+}
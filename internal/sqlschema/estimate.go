@@ -0,0 +1,121 @@
+package sqlschema
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IndexEstimate is the expected on-disk size of a B-tree index.
+type IndexEstimate struct {
+	Rows float64
+	// EntryBytes is the size of one leaf entry: tuple header, aligned key data and
+	// line pointer.
+	EntryBytes int
+	// Bytes is zero when the table has no row estimate.
+	Bytes int64
+}
+
+// B-tree layout constants, from PostgreSQL: an 8-byte index tuple header, 8-byte
+// alignment, a 4-byte line pointer per entry and leaf pages filled to 90%.
+const (
+	indexTupleHeader = 8
+	linePointer      = 4
+	leafFillFactor   = 0.9
+	pageBytes        = 8192
+	// defaultWidth stands in for variable-length values without statistics.
+	defaultWidth = 32
+)
+
+// EstimateIndex estimates the leaf level of a B-tree on the table from its row
+// estimate and the key and INCLUDE column widths: pg_stats avg_width when known,
+// otherwise the width of the declared type. withPrimaryKey adds the primary key
+// columns to every entry, as InnoDB secondary indexes store them. A partial
+// index is estimated as if it covered every row.
+func (t *Table) EstimateIndex(ix *Index, withPrimaryKey bool) IndexEstimate {
+	keys := append(append([]string{}, ix.Columns...), ix.Include...)
+	if withPrimaryKey {
+		for _, other := range t.Indexes {
+			if other.Primary {
+				keys = append(keys, other.Columns...)
+			}
+		}
+	}
+	width := 0
+	for _, key := range keys {
+		width += t.keyWidth(key)
+	}
+	entry := (indexTupleHeader+width+7)/8*8 + linePointer
+	est := IndexEstimate{Rows: t.Rows, EntryBytes: entry}
+	if t.Rows > 0 {
+		pages := math.Ceil(t.Rows * float64(entry) / leafFillFactor / pageBytes)
+		est.Bytes = int64(pages) * pageBytes
+	}
+	return est
+}
+
+// keyWidth is the stored width of an index key; an expression over one column,
+// such as lower(email), is as wide as that column.
+func (t *Table) keyWidth(key string) int {
+	refs := columnRefs(key)
+	if len(refs) != 1 {
+		return defaultWidth
+	}
+	col := t.Column(refs[0])
+	if col == nil {
+		return defaultWidth
+	}
+	if col.Stats != nil && col.Stats.AvgWidth > 0 {
+		return col.Stats.AvgWidth
+	}
+	return typeWidth(col.Type)
+}
+
+var typeLengthPattern = regexp.MustCompile(`\((\d+)`)
+
+// typeWidth is the width in bytes of a value of a PostgreSQL, MySQL or SQLite
+// column type.
+func typeWidth(typ string) int {
+	t := strings.ToLower(typ)
+	switch {
+	case strings.HasPrefix(t, "bool"), strings.HasPrefix(t, "tinyint"):
+		return 1
+	case strings.HasPrefix(t, "smallint"), t == "int2":
+		return 2
+	case strings.HasPrefix(t, "bigint"), t == "int8", strings.HasPrefix(t, "bigserial"),
+		strings.HasPrefix(t, "timestamp"), strings.HasPrefix(t, "datetime"), strings.HasPrefix(t, "time"),
+		strings.HasPrefix(t, "double"), t == "float8", t == "float":
+		return 8
+	case strings.HasPrefix(t, "int"), strings.HasPrefix(t, "serial"), strings.HasPrefix(t, "mediumint"),
+		t == "real", t == "float4", t == "date":
+		return 4
+	case t == "uuid":
+		return 16
+	case strings.HasPrefix(t, "numeric"), strings.HasPrefix(t, "decimal"):
+		return 12
+	}
+	// Strings with a declared length: the length plus a 1-byte header, capped at
+	// the default since most values are shorter than the limit.
+	if m := typeLengthPattern.FindStringSubmatch(t); m != nil && (strings.Contains(t, "char") || strings.Contains(t, "binary")) {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			return min(n+1, defaultWidth)
+		}
+	}
+	return defaultWidth
+}
+
+// Serves reports whether an index on table can help query: the query names the
+// table and the index's leading column.
+func Serves(table string, ix *Index, query string) bool {
+	schema, bare := splitQualified(table)
+	if !(&Table{Schema: schema, Name: bare}).MentionedIn(query) || len(ix.Columns) == 0 {
+		return false
+	}
+	for _, ref := range columnRefs(ix.Columns[0]) {
+		if regexp.MustCompile(`(?i)(^|[^\w])"?` + regexp.QuoteMeta(ref) + `"?($|[^\w])`).MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package sqlschema
+
+import "testing"
+
+func TestEstimateIndex(t *testing.T) {
+	s, err := ParseDDL(`
+CREATE TABLE orders (id bigint PRIMARY KEY, customer_id integer NOT NULL, code varchar(6), note text);
+`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	orders := s.Table("orders")
+	orders.Rows = 1e6
+
+	cases := []struct {
+		ix     *Index
+		withPK bool
+		entry  int
+		bytes  int64
+	}{
+		// 8-byte header + 4 + 7 = 19, aligned to 24, plus the 4-byte line pointer.
+		{&Index{Columns: []string{"customer_id", "code"}}, false, 28, 31113216},
+		{&Index{Columns: []string{"customer_id", "code"}}, true, 36, 40001536},
+		{&Index{Columns: []string{"lower(note)"}}, false, 44, 48889856},
+	}
+	for _, tc := range cases {
+		est := orders.EstimateIndex(tc.ix, tc.withPK)
+		if est.EntryBytes != tc.entry || est.Bytes != tc.bytes {
+			t.Fatalf("%v (pk %v): got %d B per entry, %d B; want %d, %d", tc.ix.Columns, tc.withPK, est.EntryBytes, est.Bytes, tc.entry, tc.bytes)
+		}
+	}
+
+	if est := (&Table{}).EstimateIndex(&Index{Columns: []string{"x"}}, false); est.Bytes != 0 {
+		t.Fatalf("a table without rows should have no size estimate, got %d", est.Bytes)
+	}
+	if !Serves("public.orders", &Index{Columns: []string{"customer_id"}}, "select * from orders o where o.customer_id = 1") {
+		t.Fatal("expected the index to serve a filter on its leading column")
+	}
+	if Serves("orders", &Index{Columns: []string{"code"}}, "select * from orders where customer_id = 1") {
+		t.Fatal("an index whose leading column is unused should not serve the query")
+	}
+}
@@ -0,0 +1,424 @@
+// Package sqlschema reads database schemas from DDL dumps (pg_dump --schema-only,
+// SQLite .schema, MySQL SHOW CREATE TABLE) or from a JSON export of the PostgreSQL
+// catalogs, and answers the questions index advice depends on: which columns
+// exist, which indexes already cover them, and which indexes are redundant.
+package sqlschema
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Schema is a set of tables in the order they were defined.
+type Schema struct {
+	Tables []*Table
+}
+
+// Table is a table with its columns, indexes and, from the catalog export, size.
+type Table struct {
+	// Schema is the namespace ("public"), empty when the dump does not qualify names.
+	Schema  string
+	Name    string
+	Columns []*Column
+	Indexes []*Index
+	// Rows and Bytes are the planner's row estimate and the total relation size;
+	// zero when unknown.
+	Rows  float64
+	Bytes int64
+}
+
+// QualifiedName is "schema.name", or the bare name for unqualified tables.
+func (t *Table) QualifiedName() string {
+	if t.Schema == "" {
+		return t.Name
+	}
+	return t.Schema + "." + t.Name
+}
+
+// Column is a table column with its statistics when known.
+type Column struct {
+	Name    string
+	Type    string
+	NotNull bool
+	Stats   *ColumnStats
+}
+
+// ColumnStats are the pg_stats figures for a column.
+type ColumnStats struct {
+	NullFrac float64
+	// NDistinct is the number of distinct values or, when negative, minus the
+	// fraction of rows that are distinct (-1 means unique).
+	NDistinct   float64
+	Correlation float64
+	// AvgWidth is the average stored width in bytes.
+	AvgWidth int
+}
+
+// Distinct estimates the number of distinct values in a table of rows rows.
+func (s ColumnStats) Distinct(rows float64) float64 {
+	if s.NDistinct < 0 {
+		return -s.NDistinct * rows
+	}
+	return s.NDistinct
+}
+
+// Index is an index or a primary key or unique constraint.
+type Index struct {
+	Name string
+	// Columns are the key columns in order; expressions such as "lower(email)"
+	// are kept verbatim.
+	Columns []string
+	Include []string
+	Unique  bool
+	Primary bool
+	// Method is the access method, "btree" unless stated.
+	Method string
+	// Where is the predicate of a partial index.
+	Where string
+	// Bytes is the index size from the catalog export; zero when unknown.
+	Bytes int64
+}
+
+// Definition renders the index as DDL-like text for prompts and reports.
+func (ix *Index) Definition() string {
+	var b strings.Builder
+	switch {
+	case ix.Primary:
+		b.WriteString("PRIMARY KEY")
+	case ix.Unique:
+		b.WriteString("UNIQUE " + ix.Method)
+	default:
+		b.WriteString(ix.Method)
+	}
+	fmt.Fprintf(&b, " (%s)", strings.Join(ix.Columns, ", "))
+	if len(ix.Include) > 0 {
+		fmt.Fprintf(&b, " INCLUDE (%s)", strings.Join(ix.Include, ", "))
+	}
+	if ix.Where != "" {
+		b.WriteString(" WHERE " + ix.Where)
+	}
+	return b.String()
+}
+
+// Table finds a table by bare or schema-qualified name, ignoring case and quotes.
+func (s *Schema) Table(name string) *Table {
+	schema, bare := splitQualified(name)
+	for _, t := range s.Tables {
+		if strings.EqualFold(t.Name, bare) && (schema == "" || t.Schema == "" || strings.EqualFold(t.Schema, schema)) {
+			return t
+		}
+	}
+	return nil
+}
+
+// table returns the named table, creating it when missing.
+func (s *Schema) table(name string) *Table {
+	if t := s.Table(name); t != nil {
+		return t
+	}
+	schema, bare := splitQualified(name)
+	t := &Table{Schema: schema, Name: bare}
+	s.Tables = append(s.Tables, t)
+	return t
+}
+
+// Column finds a column by name, ignoring case and quotes.
+func (t *Table) Column(name string) *Column {
+	name = unquote(name)
+	for _, c := range t.Columns {
+		if strings.EqualFold(c.Name, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// Referenced lists the tables a query mentions by name, in schema order; all
+// tables when it mentions none.
+func (s *Schema) Referenced(query string) []*Table {
+	var tables []*Table
+	for _, t := range s.Tables {
+		if t.MentionedIn(query) {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		return s.Tables
+	}
+	return tables
+}
+
+// MentionedIn reports whether query names the table, bare or schema-qualified.
+func (t *Table) MentionedIn(query string) bool {
+	return regexp.MustCompile(`(?i)(^|[^\w.])(?:"?` + regexp.QuoteMeta(t.Schema) + `"?\.)?"?` + regexp.QuoteMeta(t.Name) + `"?($|[^\w])`).MatchString(query)
+}
+
+// Redundancy is an index that another index makes unnecessary.
+type Redundancy struct {
+	Table *Table
+	Index *Index
+	// CoveredBy is the index that already serves every lookup Index serves.
+	CoveredBy *Index
+	// Duplicate is true for identical definitions, false for a leading prefix.
+	Duplicate bool
+}
+
+// String is "orders_status_idx on public.orders is a prefix of orders_status_created_idx:
+// btree (status) vs btree (status, created_at)".
+func (r Redundancy) String() string {
+	verb := "is a prefix of"
+	if r.Duplicate {
+		verb = "duplicates"
+	}
+	return fmt.Sprintf("%s on %s %s %s: %s vs %s", r.Index.Name, r.Table.QualifiedName(), verb, r.CoveredBy.Name, r.Index.Definition(), r.CoveredBy.Definition())
+}
+
+// Redundant finds duplicate indexes and B-tree indexes whose columns are a leading
+// prefix of another B-tree index with the same predicate. Indexes enforcing
+// uniqueness are never reported as redundant to a non-unique one, since dropping
+// them would drop the constraint.
+func (s *Schema) Redundant() []Redundancy {
+	var out []Redundancy
+	for _, t := range s.Tables {
+		for _, ix := range t.Indexes {
+			if r, ok := coveredBy(t, ix); ok {
+				out = append(out, r)
+			}
+		}
+	}
+	return out
+}
+
+func coveredBy(t *Table, ix *Index) (Redundancy, bool) {
+	for _, other := range t.Indexes {
+		if other == ix || other.Method != ix.Method || !strings.EqualFold(other.Where, ix.Where) {
+			continue
+		}
+		if (ix.Unique || ix.Primary) && !sameColumns(ix.Columns, other.Columns) {
+			continue
+		}
+		if (ix.Unique || ix.Primary) && !(other.Unique || other.Primary) {
+			continue
+		}
+		if sameColumns(ix.Columns, other.Columns) && sameColumns(ix.Include, other.Include) {
+			// Of two duplicates report the one that is not the constraint, or the later one.
+			if keepFirst(ix, other, t) {
+				continue
+			}
+			return Redundancy{Table: t, Index: ix, CoveredBy: other, Duplicate: true}, true
+		}
+		if ix.Method == "btree" && len(ix.Include) == 0 && len(ix.Columns) < len(other.Columns) && sameColumns(ix.Columns, other.Columns[:len(ix.Columns)]) {
+			return Redundancy{Table: t, Index: ix, CoveredBy: other}, true
+		}
+	}
+	return Redundancy{}, false
+}
+
+// keepFirst decides which of two identical indexes stays: constraints beat plain
+// indexes, otherwise the one defined first.
+func keepFirst(ix, other *Index, t *Table) bool {
+	rank := func(i *Index) int {
+		switch {
+		case i.Primary:
+			return 2
+		case i.Unique:
+			return 1
+		}
+		return 0
+	}
+	if rank(ix) != rank(other) {
+		return rank(ix) > rank(other)
+	}
+	for _, i := range t.Indexes {
+		if i == ix {
+			return true
+		}
+		if i == other {
+			return false
+		}
+	}
+	return false
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(normalizeExpr(a[i]), normalizeExpr(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Problem is something wrong with a proposed index.
+type Problem struct {
+	// Kind is "table", "column" (does not exist) or "covered" (an existing index
+	// already serves it).
+	Kind    string
+	Message string
+}
+
+// Check validates a proposed index against the schema: the table and every plain
+// column must exist, and no existing index may already cover it. Expressions are
+// checked for the column names they reference.
+func (s *Schema) Check(table string, ix *Index) []Problem {
+	t := s.Table(table)
+	if t == nil {
+		return []Problem{{Kind: "table", Message: fmt.Sprintf("table %s does not exist", unquote(table))}}
+	}
+	var problems []Problem
+	var missing []string
+	for _, col := range append(append([]string{}, ix.Columns...), ix.Include...) {
+		for _, name := range columnRefs(col) {
+			if t.Column(name) == nil && len(t.Columns) > 0 {
+				missing = append(missing, name)
+			}
+		}
+	}
+	sort.Strings(missing)
+	for _, name := range dedupe(missing) {
+		problems = append(problems, Problem{Kind: "column", Message: fmt.Sprintf("column %s does not exist on %s", name, t.QualifiedName())})
+	}
+	probe := *ix
+	if probe.Method == "" {
+		probe.Method = "btree"
+	}
+	for _, existing := range t.Indexes {
+		if existing.Method != probe.Method || !strings.EqualFold(existing.Where, probe.Where) {
+			continue
+		}
+		if len(probe.Columns) <= len(existing.Columns) && sameColumns(probe.Columns, existing.Columns[:len(probe.Columns)]) && containsAll(append(append([]string{}, existing.Columns...), existing.Include...), probe.Include) {
+			problems = append(problems, Problem{Kind: "covered", Message: fmt.Sprintf("already covered by %s: %s", existing.Name, existing.Definition())})
+			break
+		}
+	}
+	return problems
+}
+
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if strings.EqualFold(normalizeExpr(h), normalizeExpr(w)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func dedupe(sorted []string) []string {
+	var out []string
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+var (
+	identPattern    = regexp.MustCompile(`"[^"]+"|` + "`[^`]+`" + `|[A-Za-z_][A-Za-z0-9_$]*`)
+	literalPattern  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlKeywordsExpr = map[string]bool{"and": true, "or": true, "not": true, "null": true, "is": true, "true": true, "false": true, "case": true, "when": true, "then": true, "else": true, "end": true, "in": true, "like": true, "between": true, "collate": true, "asc": true, "desc": true, "nulls": true, "first": true, "last": true}
+)
+
+// columnRefs lists the column names an index key refers to: the key itself when
+// it is a plain column, otherwise the identifiers in the expression that are not
+// function names, casts or keywords.
+func columnRefs(key string) []string {
+	key = literalPattern.ReplaceAllString(key, "''")
+	var refs []string
+	locs := identPattern.FindAllStringIndex(key, -1)
+	for _, loc := range locs {
+		word := key[loc[0]:loc[1]]
+		rest := strings.TrimLeft(key[loc[1]:], " ")
+		before := strings.TrimRight(key[:loc[0]], " ")
+		switch {
+		case strings.HasPrefix(rest, "("): // function call
+		case strings.HasSuffix(before, "::"): // cast target
+		case sqlKeywordsExpr[strings.ToLower(word)]:
+		default:
+			refs = append(refs, unquote(word))
+		}
+	}
+	return refs
+}
+
+// splitQualified splits "public.orders" or "\"my.schema\".\"orders\"" at the last
+// dot outside quotes.
+func splitQualified(name string) (schema, bare string) {
+	name = strings.TrimSpace(name)
+	quoted, dot := false, -1
+	for i, r := range name {
+		switch {
+		case r == '"' || r == '`':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			dot = i
+		}
+	}
+	if dot < 0 {
+		return "", unquote(name)
+	}
+	return unquote(name[:dot]), unquote(name[dot+1:])
+}
+
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 {
+		switch {
+		case s[0] == '"' && s[len(s)-1] == '"', s[0] == '`' && s[len(s)-1] == '`', s[0] == '[' && s[len(s)-1] == ']':
+			return s[1 : len(s)-1]
+		}
+	}
+	return s
+}
+
+// normalizeExpr makes "LOWER((email)::text)" and "lower(email)" compare equal.
+func normalizeExpr(s string) string {
+	s = strings.ToLower(unquote(strings.TrimSpace(s)))
+	s = strings.NewReplacer("::text", "", "::character varying", "", `"`, "", "`", "", " ", "").Replace(s)
+	for strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") && balanced(s[1:len(s)-1]) {
+		s = s[1 : len(s)-1]
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(s, "((", "("), "))", ")")
+}
+
+func balanced(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// FormatBytes renders a size as "512 B", "24.0 kB", "180.0 MB" or "1.2 GB".
+func FormatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "kMGT"[exp])
+}
@@ -0,0 +1,226 @@
+package sqlschema
+
+import (
+	"strings"
+	"testing"
+)
+
+// pgDump is an excerpt of pg_dump --schema-only output.
+const pgDump = `--
+-- PostgreSQL database dump
+--
+
+SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+
+CREATE FUNCTION public.touch() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+  NEW.updated_at := now(); -- keep ; inside the body
+  RETURN NEW;
+END;
+$$;
+
+CREATE TABLE public.customers (
+    id bigint NOT NULL,
+    email text NOT NULL,
+    name text
+);
+
+CREATE TABLE public.orders (
+    id bigint NOT NULL,
+    customer_id bigint NOT NULL,
+    status text DEFAULT 'new'::text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    note text,
+    CONSTRAINT orders_status_check CHECK ((status <> ''::text))
+);
+
+ALTER TABLE ONLY public.customers
+    ADD CONSTRAINT customers_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.customers
+    ADD CONSTRAINT customers_email_key UNIQUE (email);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_pkey PRIMARY KEY (id);
+
+CREATE INDEX customers_lower_email_idx ON public.customers USING btree (lower(email));
+CREATE INDEX customers_email_idx ON public.customers USING btree (email);
+CREATE INDEX orders_customer_id_idx ON public.orders USING btree (customer_id);
+CREATE INDEX orders_customer_created_idx ON public.orders USING btree (customer_id, created_at DESC) INCLUDE (status);
+CREATE INDEX orders_status_idx ON public.orders USING btree (status);
+CREATE INDEX orders_status_idx1 ON public.orders USING btree (status);
+CREATE INDEX orders_open_idx ON public.orders USING btree (created_at) WHERE (status = 'open'::text);
+CREATE INDEX orders_note_trgm ON public.orders USING gin (note public.gin_trgm_ops);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES public.customers(id);
+`
+
+func TestParseDDLPgDump(t *testing.T) {
+	s, err := ParseDDL(pgDump)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(s.Tables) != 2 {
+		t.Fatalf("expected 2 tables, got %d", len(s.Tables))
+	}
+	orders := s.Table("orders")
+	if orders == nil || orders.QualifiedName() != "public.orders" || len(orders.Columns) != 5 {
+		t.Fatalf("unexpected orders %+v", orders)
+	}
+	if c := orders.Column("created_at"); c == nil || c.Type != "timestamp with time zone" || !c.NotNull {
+		t.Fatalf("unexpected column %+v", c)
+	}
+	if c := orders.Column("status"); c.Type != "text" || !c.NotNull {
+		t.Fatalf("unexpected status column %+v", c)
+	}
+	var defs []string
+	for _, ix := range orders.Indexes {
+		defs = append(defs, ix.Name+" "+ix.Definition())
+	}
+	want := []string{
+		"orders_pkey PRIMARY KEY (id)",
+		"orders_customer_id_idx btree (customer_id)",
+		"orders_customer_created_idx btree (customer_id, created_at) INCLUDE (status)",
+		"orders_status_idx btree (status)",
+		"orders_status_idx1 btree (status)",
+		"orders_open_idx btree (created_at) WHERE (status = 'open'::text)",
+		"orders_note_trgm gin (note)",
+	}
+	if strings.Join(defs, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected indexes:\n%s", strings.Join(defs, "\n"))
+	}
+	if got := s.Referenced("SELECT * FROM orders o WHERE o.status = 'x'"); len(got) != 1 || got[0] != orders {
+		t.Fatalf("unexpected referenced tables %v", got)
+	}
+	if got := s.Referenced("SELECT 1"); len(got) != 2 {
+		t.Fatalf("a query naming no table should reference all, got %d", len(got))
+	}
+}
+
+func TestRedundant(t *testing.T) {
+	s, err := ParseDDL(pgDump)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var got []string
+	for _, r := range s.Redundant() {
+		got = append(got, r.String())
+	}
+	want := []string{
+		"customers_email_idx on public.customers duplicates customers_email_key: btree (email) vs UNIQUE btree (email)",
+		"orders_customer_id_idx on public.orders is a prefix of orders_customer_created_idx: btree (customer_id) vs btree (customer_id, created_at) INCLUDE (status)",
+		"orders_status_idx1 on public.orders duplicates orders_status_idx: btree (status) vs btree (status)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected redundancies:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s, err := ParseDDL(pgDump)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cases := []struct {
+		stmt string
+		want string
+	}{
+		{"CREATE INDEX CONCURRENTLY orders_status_created_idx ON orders (status, created_at);", ""},
+		{"CREATE INDEX ON public.orders (customer_id);", "covered: already covered by orders_customer_id_idx: btree (customer_id)"},
+		{"CREATE INDEX x ON orders (customer_id) INCLUDE (status)", "covered: already covered by orders_customer_created_idx: btree (customer_id, created_at) INCLUDE (status)"},
+		{"CREATE INDEX x ON orders (stauts, lower(emial))", "column: column emial does not exist on public.orders|column: column stauts does not exist on public.orders"},
+		{"CREATE INDEX x ON invoices (id)", "table: table invoices does not exist"},
+		{"CREATE INDEX x ON customers (lower((email)::text))", "covered: already covered by customers_lower_email_idx: btree (lower(email))"},
+		{"CREATE INDEX x ON orders (created_at) WHERE status = 'paid'", ""},
+		{"ALTER TABLE orders ADD INDEX idx_status (status), ALGORITHM=INPLACE, LOCK=NONE;", "covered: already covered by orders_status_idx: btree (status)"},
+	}
+	for _, tc := range cases {
+		table, ix, ok := ParseIndexDDL(tc.stmt)
+		if !ok {
+			t.Fatalf("could not parse %q", tc.stmt)
+		}
+		var got []string
+		for _, p := range s.Check(table, ix) {
+			got = append(got, p.Kind+": "+p.Message)
+		}
+		if strings.Join(got, "|") != tc.want {
+			t.Fatalf("%s:\n got %q\nwant %q", tc.stmt, strings.Join(got, "|"), tc.want)
+		}
+	}
+}
+
+func TestParseDDLMySQLAndSQLite(t *testing.T) {
+	mysql := "CREATE TABLE `orders` (\n  `id` bigint unsigned NOT NULL AUTO_INCREMENT,\n  `customer_id` bigint NOT NULL,\n  `status` varchar(16) NOT NULL DEFAULT 'new',\n  `note` text,\n  PRIMARY KEY (`id`),\n  UNIQUE KEY `uniq_customer_status` (`customer_id`,`status`),\n  KEY `idx_customer` (`customer_id`),\n  KEY `idx_note` (`note`(32)),\n  FULLTEXT KEY `ft_note` (`note`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;\n"
+	s, err := ParseDDL(mysql)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	orders := s.Tables[0]
+	var defs []string
+	for _, ix := range orders.Indexes {
+		defs = append(defs, ix.Name+" "+ix.Definition())
+	}
+	if got := strings.Join(defs, "|"); got != "orders_pkey PRIMARY KEY (id)|uniq_customer_status UNIQUE btree (customer_id, status)|idx_customer btree (customer_id)|idx_note btree (note)|ft_note fulltext (note)" {
+		t.Fatalf("unexpected MySQL indexes %q", got)
+	}
+	if c := orders.Column("status"); c == nil || c.Type != "varchar(16)" {
+		t.Fatalf("unexpected column %+v", c)
+	}
+	if r := s.Redundant(); len(r) != 1 || r[0].Index.Name != "idx_customer" {
+		t.Fatalf("unexpected redundancies %v", r)
+	}
+
+	sqlite := "CREATE TABLE events(id INTEGER PRIMARY KEY, user_id INT NOT NULL, kind TEXT UNIQUE);\nCREATE INDEX events_user_idx ON events(user_id);\n"
+	s, err = ParseDDL(sqlite)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	events := s.Table("events")
+	if len(events.Columns) != 3 || len(events.Indexes) != 3 || !events.Indexes[0].Primary || !events.Indexes[1].Unique || events.Indexes[1].Columns[0] != "kind" {
+		t.Fatalf("unexpected SQLite table %+v", events)
+	}
+}
+
+func TestParseCatalog(t *testing.T) {
+	doc := `{"tables" : [{"schema" : "public", "table" : "orders", "rows" : 1200000, "bytes" : 188743680}],
+"columns" : [{"schema" : "public", "table" : "orders", "column" : "id", "type" : "bigint", "nullable" : false}, {"schema" : "public", "table" : "orders", "column" : "status", "type" : "text", "nullable" : false}],
+"indexes" : [{"schema" : "public", "table" : "orders", "name" : "orders_pkey", "definition" : "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id)", "bytes" : 26984448}],
+"stats" : [{"schema" : "public", "table" : "orders", "column" : "status", "null_frac" : 0, "n_distinct" : 5, "correlation" : 0.12, "avg_width" : 5}, {"schema" : "public", "table" : "orders", "column" : "id", "null_frac" : 0, "n_distinct" : -1, "correlation" : null, "avg_width" : 8}]}`
+	s, err := Load(doc)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	orders := s.Table("public.orders")
+	if orders.Rows != 1200000 || orders.Bytes != 188743680 || len(orders.Columns) != 2 {
+		t.Fatalf("unexpected table %+v", orders)
+	}
+	if ix := orders.Indexes[0]; !ix.Primary || ix.Bytes != 26984448 {
+		t.Fatalf("unexpected index %+v", ix)
+	}
+	if st := orders.Column("id").Stats; st == nil || st.Distinct(orders.Rows) != 1200000 || st.AvgWidth != 8 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+	if st := orders.Column("status").Stats; st.Distinct(orders.Rows) != 5 || st.Correlation != 0.12 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	stmts := SplitStatements("SELECT 'a;b'; /* x; */ CREATE FUNCTION f() AS $body$ a; b $body$;\n-- c;\nSELECT \"x;y\"")
+	if len(stmts) != 3 || stmts[1] != "CREATE FUNCTION f() AS $body$ a; b $body$" || stmts[2] != `SELECT "x;y"` {
+		t.Fatalf("unexpected statements %q", stmts)
+	}
+	lines := Statements("-- header\nBEGIN;\n\n/* note */ ALTER TABLE t\n  ADD c int;\nCOMMIT;")
+	if len(lines) != 3 || lines[0].Line != 2 || lines[1].Line != 4 || lines[1].EndLine != 5 || lines[2].Line != 6 || lines[2].EndLine != 6 {
+		t.Fatalf("unexpected statement lines %+v", lines)
+	}
+	for b, want := range map[int64]string{512: "512 B", 24576: "24.0 kB", 188743680: "180.0 MB"} {
+		if got := FormatBytes(b); got != want {
+			t.Fatalf("FormatBytes(%d) = %q, want %q", b, got, want)
+		}
+	}
+}
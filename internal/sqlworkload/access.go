@@ -0,0 +1,195 @@
+package sqlworkload
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// Access is how one query reads one table: the columns it compares to values
+// for equality (=, IN, IS NULL), joins on, compares by range (<, >, BETWEEN,
+// LIKE) and sorts or groups on, each in order of first appearance.
+type Access struct {
+	Table    *sqlschema.Table
+	Equality []string
+	Join     []string
+	Range    []string
+	Sort     []string
+}
+
+// Lookup is the columns an index should match for equality: the equality
+// filters or, for a table the query only joins to, the join columns, since
+// the table is then the inner side of a nested loop.
+func (a *Access) Lookup() []string {
+	if len(a.Equality) > 0 {
+		return a.Equality
+	}
+	return a.Join
+}
+
+const ident = `(?:"[^"]+"|[a-z_][\w$]*)`
+
+var (
+	tableRefPattern = regexp.MustCompile(`\b(?:from|join|update|into)\s+(` + ident + `(?:\.` + ident + `)?)(?:\s+(?:as\s+)?(` + ident + `))?`)
+	// The SET list of an UPDATE assigns rather than filters.
+	setListPattern   = regexp.MustCompile(`\bset\s+.*?(\bwhere\b|$)`)
+	predicatePattern = regexp.MustCompile(`(?:^|[^\w."])(?:(` + ident + `)\.)?(` + ident + `)\s*(<>|!=|<=|>=|=|<|>|\bnot\s+in\b|\bin\b|\bbetween\b|\bnot\s+i?like\b|\bi?like\b|\bis\s+not\b|\bis\b)\s*(?:(` + ident + `)\.)?(` + ident + `)?(\s*\()?`)
+	orderByPattern   = regexp.MustCompile(`\b(?:order|group)\s+by\s+(.+?)(?:\b(?:limit|offset|having|for|union|window|fetch)\b|\)|$)`)
+	sortSuffix       = regexp.MustCompile(`\s+(?:asc|desc|nulls\s+(?:first|last))\b.*$`)
+	columnRefPattern = regexp.MustCompile(`^(?:(` + ident + `)\.)?(` + ident + `)$`)
+	notAlias         = map[string]bool{"where": true, "join": true, "inner": true, "left": true, "right": true, "full": true, "cross": true, "natural": true, "outer": true, "lateral": true, "on": true, "using": true, "group": true, "order": true, "limit": true, "offset": true, "set": true, "values": true, "returning": true, "union": true, "select": true, "for": true, "window": true, "having": true, "default": true, "only": true}
+	notColumn        = map[string]bool{"and": true, "or": true, "not": true, "null": true, "true": true, "false": true, "where": true, "on": true, "when": true, "then": true, "else": true, "case": true, "select": true, "any": true, "all": true, "some": true, "exists": true, "distinct": true}
+)
+
+// Accesses resolves the columns a normalised statement filters, joins and sorts
+// on against the schema. Qualified references resolve through table aliases,
+// bare ones to the only referenced table with that column; anything else, such
+// as columns of CTEs or subqueries, is ignored.
+func Accesses(s *sqlschema.Schema, normalized string) []*Access {
+	aliases := map[string]*sqlschema.Table{}
+	var tables []*sqlschema.Table
+	for _, m := range tableRefPattern.FindAllStringSubmatch(normalized, -1) {
+		t := s.Table(m[1])
+		if t == nil {
+			continue
+		}
+		if !containsTable(tables, t) {
+			tables = append(tables, t)
+		}
+		aliases[strings.ToLower(t.Name)] = t
+		aliases[strings.Trim(m[1], `"`)] = t
+		if alias := strings.Trim(m[2], `"`); alias != "" && !notAlias[alias] {
+			aliases[alias] = t
+		}
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	resolve := func(qualifier, name string) (*sqlschema.Table, string) {
+		name = strings.Trim(name, `"`)
+		if notColumn[name] {
+			return nil, ""
+		}
+		if qualifier != "" {
+			if t := aliases[strings.Trim(qualifier, `"`)]; t != nil && t.Column(name) != nil {
+				return t, t.Column(name).Name
+			}
+			return nil, ""
+		}
+		var found *sqlschema.Table
+		for _, t := range tables {
+			if t.Column(name) != nil {
+				if found != nil {
+					return nil, "" // ambiguous
+				}
+				found = t
+			}
+		}
+		if found == nil {
+			return nil, ""
+		}
+		return found, found.Column(name).Name
+	}
+
+	byTable := map[*sqlschema.Table]*Access{}
+	access := func(t *sqlschema.Table) *Access {
+		if byTable[t] == nil {
+			byTable[t] = &Access{Table: t}
+		}
+		return byTable[t]
+	}
+
+	filters := setListPattern.ReplaceAllString(normalized, "$1")
+	for _, m := range predicatePattern.FindAllStringSubmatch(filters, -1) {
+		op := strings.Join(strings.Fields(m[3]), " ")
+		lt, lcol := resolve(m[1], m[2])
+		switch op {
+		case "<>", "!=", "not in", "not like", "not ilike", "is not":
+			continue
+		case "=", "in", "is":
+			// In "x = any(?)" the right-hand side is a function call, not a column.
+			var rt *sqlschema.Table
+			var rcol string
+			if op == "=" && m[5] != "" && m[6] == "" {
+				rt, rcol = resolve(m[4], m[5])
+			}
+			switch {
+			case lt != nil && rt != nil:
+				a := access(lt)
+				a.Join = appendColumn(a.Join, lcol)
+				a = access(rt)
+				a.Join = appendColumn(a.Join, rcol)
+			case lt != nil:
+				a := access(lt)
+				a.Equality = appendColumn(a.Equality, lcol)
+			}
+		default:
+			if lt != nil {
+				a := access(lt)
+				a.Range = appendColumn(a.Range, lcol)
+			}
+		}
+	}
+	for _, m := range orderByPattern.FindAllStringSubmatch(filters, -1) {
+		for _, item := range strings.Split(m[1], ",") {
+			ref := columnRefPattern.FindStringSubmatch(sortSuffix.ReplaceAllString(strings.TrimSpace(item), ""))
+			if ref == nil {
+				continue
+			}
+			if t, col := resolve(ref[1], ref[2]); t != nil {
+				a := access(t)
+				a.Sort = appendColumn(a.Sort, col)
+			}
+		}
+	}
+
+	var out []*Access
+	for _, t := range tables {
+		a := byTable[t]
+		if a == nil {
+			continue
+		}
+		a.Range = without(a.Range, a.Lookup())
+		out = append(out, a)
+	}
+	return out
+}
+
+func containsTable(tables []*sqlschema.Table, t *sqlschema.Table) bool {
+	for _, other := range tables {
+		if other == t {
+			return true
+		}
+	}
+	return false
+}
+
+func appendColumn(cols []string, col string) []string {
+	for _, c := range cols {
+		if strings.EqualFold(c, col) {
+			return cols
+		}
+	}
+	return append(cols, col)
+}
+
+func without(cols, drop []string) []string {
+	var out []string
+	for _, c := range cols {
+		if !hasColumn(drop, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func hasColumn(cols []string, col string) bool {
+	for _, c := range cols {
+		if strings.EqualFold(c, col) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package sqlworkload
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// Pick is an index of the recommended set.
+type Pick struct {
+	Table   *sqlschema.Table
+	Columns []string
+	// Queries are the workload queries the index serves, heaviest first.
+	Queries []*Query
+	// Share is their fraction of the workload's weight.
+	Share float64
+}
+
+// Shared reports whether the index serves more than one query.
+func (p Pick) Shared() bool {
+	return len(p.Queries) > 1
+}
+
+// Recommendation is a small index set for a workload.
+type Recommendation struct {
+	Picks []Pick
+	// Share is the fraction of the workload's weight the picks serve.
+	Share float64
+	// Existing are queries every table access of which an existing index serves.
+	Existing []*Query
+	// Unindexed are queries without a filter, join or sort the schema resolves.
+	Unindexed []*Query
+}
+
+// minPickShare stops the selection once the next index would serve less than
+// this fraction of the workload: one more index for a rare query costs every
+// write to the table.
+const minPickShare = 0.01
+
+// Recommend picks indexes greedily: each round takes the candidate serving the
+// most workload weight not yet served, preferring one that serves more queries
+// and then fewer columns. Candidates are the ideal B-tree key of each access:
+// its equality columns, those most queries share first so that one index can
+// serve several queries by prefix, then its first range column or, failing
+// that, its sort columns. An index serves an access when its leading columns
+// are exactly the access's equality columns, or start with its range or sort
+// column when it has none. Accesses an existing B-tree already serves are
+// skipped.
+func Recommend(s *sqlschema.Schema, w *Workload) *Recommendation {
+	type item struct {
+		query  *Query
+		access *Access
+	}
+	rec := &Recommendation{}
+	var items []item
+	for _, q := range w.Queries {
+		accesses := Accesses(s, q.Normalized)
+		if len(accesses) == 0 {
+			rec.Unindexed = append(rec.Unindexed, q)
+			continue
+		}
+		open := 0
+		for _, a := range accesses {
+			if !servedByExisting(a) {
+				items = append(items, item{q, a})
+				open++
+			}
+		}
+		if open == 0 {
+			rec.Existing = append(rec.Existing, q)
+		}
+	}
+
+	// How much weight looks each table column up by equality.
+	freq := map[*sqlschema.Table]map[string]float64{}
+	for _, it := range items {
+		if freq[it.access.Table] == nil {
+			freq[it.access.Table] = map[string]float64{}
+		}
+		for _, col := range it.access.Lookup() {
+			freq[it.access.Table][strings.ToLower(col)] += w.Weight(it.query)
+		}
+	}
+
+	type candidate struct {
+		table *sqlschema.Table
+		cols  []string
+	}
+	var candidates []candidate
+	seen := map[string]bool{}
+	for _, it := range items {
+		cols := idealKey(it.access, freq[it.access.Table])
+		key := it.access.Table.QualifiedName() + "(" + strings.ToLower(strings.Join(cols, ",")) + ")"
+		if len(cols) > 0 && !seen[key] {
+			seen[key] = true
+			candidates = append(candidates, candidate{it.access.Table, cols})
+		}
+	}
+
+	total := 0.0
+	for _, q := range w.Queries {
+		total += w.Weight(q)
+	}
+	served := make([]bool, len(items))
+	servedQuery := map[*Query]bool{}
+	for {
+		best, bestGain, bestQueries := -1, 0.0, []*Query(nil)
+		for i, c := range candidates {
+			var queries []*Query
+			gain := 0.0
+			for j, it := range items {
+				if served[j] || it.access.Table != c.table || !serves(c.cols, it.access) || containsQuery(queries, it.query) {
+					continue
+				}
+				queries = append(queries, it.query)
+				gain += w.Weight(it.query)
+			}
+			if best < 0 || gain > bestGain ||
+				gain == bestGain && (len(queries) > len(bestQueries) ||
+					len(queries) == len(bestQueries) && len(c.cols) < len(candidates[best].cols)) {
+				best, bestGain, bestQueries = i, gain, queries
+			}
+		}
+		if best < 0 || bestGain == 0 || total > 0 && bestGain/total < minPickShare {
+			break
+		}
+		c := candidates[best]
+		for j, it := range items {
+			if it.access.Table == c.table && serves(c.cols, it.access) {
+				served[j] = true
+			}
+		}
+		pick := Pick{Table: c.table, Columns: c.cols, Queries: bestQueries}
+		for _, q := range bestQueries {
+			pick.Share += w.Weight(q) / total
+			if !servedQuery[q] {
+				servedQuery[q] = true
+				rec.Share += w.Weight(q) / total
+			}
+		}
+		rec.Picks = append(rec.Picks, pick)
+		candidates = append(candidates[:best], candidates[best+1:]...)
+	}
+	return rec
+}
+
+// idealKey orders the access's lookup columns by how much of the workload
+// shares them, then by selectivity, and appends the range or sort columns.
+func idealKey(a *Access, freq map[string]float64) []string {
+	eq := append([]string{}, a.Lookup()...)
+	distinct := func(name string) float64 {
+		if col := a.Table.Column(name); col != nil && col.Stats != nil {
+			return col.Stats.Distinct(a.Table.Rows)
+		}
+		return 0
+	}
+	sort.SliceStable(eq, func(i, j int) bool {
+		fi, fj := freq[strings.ToLower(eq[i])], freq[strings.ToLower(eq[j])]
+		if fi != fj {
+			return fi > fj
+		}
+		return distinct(eq[i]) > distinct(eq[j])
+	})
+	switch {
+	case len(a.Range) > 0:
+		return append(eq, a.Range[0])
+	default:
+		for _, col := range a.Sort {
+			eq = appendColumn(eq, col)
+		}
+		return eq
+	}
+}
+
+func serves(cols []string, a *Access) bool {
+	if len(cols) == 0 {
+		return false
+	}
+	if lookup := a.Lookup(); len(lookup) > 0 {
+		if len(cols) < len(lookup) {
+			return false
+		}
+		for _, col := range cols[:len(lookup)] {
+			if !hasColumn(lookup, col) {
+				return false
+			}
+		}
+		return true
+	}
+	lead := append(append([]string{}, a.Range...), a.Sort...)
+	return len(lead) > 0 && strings.EqualFold(cols[0], lead[0])
+}
+
+func servedByExisting(a *Access) bool {
+	for _, ix := range a.Table.Indexes {
+		if (ix.Method == "btree" || ix.Method == "") && ix.Where == "" && serves(ix.Columns, a) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsQuery(queries []*Query, q *Query) bool {
+	for _, other := range queries {
+		if other == q {
+			return true
+		}
+	}
+	return false
+}
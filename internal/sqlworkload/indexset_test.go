@@ -0,0 +1,71 @@
+package sqlworkload
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+const shopSchema = `
+CREATE TABLE orders (id bigint PRIMARY KEY, customer_id bigint NOT NULL, status text NOT NULL, created_at timestamptz NOT NULL);
+CREATE TABLE customers (id bigint PRIMARY KEY, email text NOT NULL, region text);
+CREATE INDEX orders_status_idx ON orders (status);
+`
+
+func TestAccesses(t *testing.T) {
+	s, err := sqlschema.ParseDDL(shopSchema)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	q := Normalize(`SELECT o.id, c.email FROM orders o JOIN customers AS c ON c.id = o.customer_id
+		WHERE c.region = $1 AND o.created_at >= $2 AND o.status <> 'void' ORDER BY o.created_at DESC`)
+	var got []string
+	for _, a := range Accesses(s, q) {
+		got = append(got, a.Table.Name+" eq="+strings.Join(a.Equality, ",")+" join="+strings.Join(a.Join, ",")+" range="+strings.Join(a.Range, ",")+" sort="+strings.Join(a.Sort, ","))
+	}
+	want := []string{
+		"orders eq= join=customer_id range=created_at sort=created_at",
+		"customers eq=region join=id range= sort=",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected accesses:\n%s", strings.Join(got, "\n"))
+	}
+
+	update := Accesses(s, Normalize("UPDATE orders SET status = 'x', customer_id = 3 WHERE id = 7"))
+	if len(update) != 1 || strings.Join(update[0].Equality, ",") != "id" {
+		t.Fatalf("the SET list should not count as a filter: %+v", update[0])
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	s, err := sqlschema.ParseDDL(shopSchema)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	w := &Workload{}
+	w.Add("SELECT * FROM orders WHERE customer_id = $1 AND status = $2 ORDER BY created_at DESC LIMIT 20", "", 1000, 5000, 0)
+	w.Add("SELECT * FROM orders WHERE customer_id = $1", "", 500, 2000, 0)
+	w.Add("SELECT o.id FROM orders o JOIN customers c ON c.id = o.customer_id WHERE c.region = $1 AND o.created_at > $2", "", 100, 3000, 0)
+	w.Add("SELECT count(*) FROM orders WHERE status = $1", "", 40, 400, 0)
+	w.Add("SELECT email FROM customers WHERE lower(email) = $1", "", 1, 1, 0)
+	w.Add("SELECT now()", "", 10, 10, 0)
+	w.Sort()
+
+	rec := Recommend(s, w)
+	var got []string
+	for _, p := range rec.Picks {
+		got = append(got, p.Table.Name+"("+strings.Join(p.Columns, ",")+")")
+	}
+	// One index on orders serves all three customer lookups by prefix.
+	want := []string{"orders(customer_id,status,created_at)", "customers(region)"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected index set %v", got)
+	}
+	if !rec.Picks[0].Shared() || len(rec.Picks[0].Queries) != 3 || rec.Picks[1].Shared() {
+		t.Fatalf("expected the orders index to be shared by 3 queries: %+v", rec.Picks)
+	}
+	if rec.Share < 0.95 || len(rec.Existing) != 1 || len(rec.Unindexed) != 2 {
+		t.Fatalf("unexpected coverage %.3f, existing %d, unindexed %d", rec.Share, len(rec.Existing), len(rec.Unindexed))
+	}
+}
@@ -0,0 +1,284 @@
+// Package sqlworkload turns a set of queries, from .sql files or a
+// pg_stat_statements export, into a weighted workload of normalised statements,
+// finds the columns each one filters, joins and sorts on, and picks a small set
+// of indexes that serves the heaviest of them.
+package sqlworkload
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/sqlschema"
+)
+
+// StatStatementsQuery exports pg_stat_statements in the CSV layout
+// ParseStatStatements reads. Use total_time and mean_time before PostgreSQL 13.
+const StatStatementsQuery = `\copy (SELECT query, calls, total_exec_time, mean_exec_time, rows FROM pg_stat_statements WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database()) ORDER BY total_exec_time DESC LIMIT 500) TO 'workload.csv' CSV HEADER`
+
+// Query is one distinct statement of the workload.
+type Query struct {
+	// Fingerprint identifies the normalised statement.
+	Fingerprint string
+	// Normalized has literals and parameters replaced by ?, comments dropped,
+	// keywords lower-cased and whitespace collapsed.
+	Normalized string
+	// Text is the first statement seen with this fingerprint.
+	Text string
+	// Sources are the files the statement came from.
+	Sources []string
+	// Calls counts executions: from pg_stat_statements, or one per occurrence.
+	Calls float64
+	// TotalTime is the total execution time in milliseconds; zero when unknown.
+	TotalTime float64
+	Rows      float64
+}
+
+// MeanTime is the average execution time in milliseconds.
+func (q *Query) MeanTime() float64 {
+	if q.Calls == 0 {
+		return 0
+	}
+	return q.TotalTime / q.Calls
+}
+
+// Workload is a set of distinct queries.
+type Workload struct {
+	// Queries are heaviest first once Sort has run.
+	Queries []*Query
+	// Statements counts the statements read before merging by fingerprint.
+	Statements int
+	byPrint    map[string]*Query
+}
+
+// Add merges a statement into the workload, summing calls and time of
+// statements with the same fingerprint.
+func (w *Workload) Add(text, source string, calls, totalTime, rows float64) {
+	normalized := Normalize(text)
+	if normalized == "" {
+		return
+	}
+	w.Statements++
+	fp := Fingerprint(normalized)
+	if w.byPrint == nil {
+		w.byPrint = map[string]*Query{}
+	}
+	q := w.byPrint[fp]
+	if q == nil {
+		q = &Query{Fingerprint: fp, Normalized: normalized, Text: strings.TrimSpace(text)}
+		w.byPrint[fp] = q
+		w.Queries = append(w.Queries, q)
+	}
+	if source != "" && (len(q.Sources) == 0 || q.Sources[len(q.Sources)-1] != source) {
+		q.Sources = append(q.Sources, source)
+	}
+	q.Calls += calls
+	q.TotalTime += totalTime
+	q.Rows += rows
+}
+
+// Timed reports whether the workload carries execution times, so queries are
+// weighed by total time rather than by calls.
+func (w *Workload) Timed() bool {
+	for _, q := range w.Queries {
+		if q.TotalTime > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Weight is what the query costs the workload: its total time when timed,
+// otherwise its calls.
+func (w *Workload) Weight(q *Query) float64 {
+	if w.Timed() {
+		return q.TotalTime
+	}
+	return q.Calls
+}
+
+// Share is the query's fraction of the workload's total weight.
+func (w *Workload) Share(q *Query) float64 {
+	total := 0.0
+	for _, other := range w.Queries {
+		total += w.Weight(other)
+	}
+	if total == 0 {
+		return 0
+	}
+	return w.Weight(q) / total
+}
+
+// Sort orders the queries heaviest first, by fingerprint on ties.
+func (w *Workload) Sort() {
+	timed := w.Timed()
+	weight := func(q *Query) float64 {
+		if timed {
+			return q.TotalTime
+		}
+		return q.Calls
+	}
+	sort.SliceStable(w.Queries, func(i, j int) bool {
+		a, b := w.Queries[i], w.Queries[j]
+		if weight(a) != weight(b) {
+			return weight(a) > weight(b)
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+}
+
+// LoadDir reads every .sql file under dir; each statement counts as one call.
+func LoadDir(dir string) (*Workload, error) {
+	w := &Workload{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".sql") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range sqlschema.SplitStatements(string(data)) {
+			w.Add(stmt, path, 1, 0, 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(w.Queries) == 0 {
+		return nil, fmt.Errorf("no SQL statements in .sql files under %s", dir)
+	}
+	w.Sort()
+	return w, nil
+}
+
+// ParseStatStatements reads a CSV export of pg_stat_statements with a header
+// row. Only the query column is required; calls, total_exec_time (or
+// total_time) and rows are used when present.
+func ParseStatStatements(text, source string) (*Workload, error) {
+	records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse pg_stat_statements CSV: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, errors.New("parse pg_stat_statements CSV: expected a header row and at least one query")
+	}
+	col := map[string]int{}
+	for i, name := range records[0] {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	queryCol, ok := col["query"]
+	if !ok {
+		return nil, errors.New("parse pg_stat_statements CSV: no query column; export with a header row (CSV HEADER)")
+	}
+	number := func(record []string, names ...string) float64 {
+		for _, name := range names {
+			if i, ok := col[name]; ok && i < len(record) {
+				if v, err := strconv.ParseFloat(strings.TrimSpace(record[i]), 64); err == nil {
+					return v
+				}
+			}
+		}
+		return 0
+	}
+	w := &Workload{}
+	for _, record := range records[1:] {
+		if queryCol >= len(record) {
+			continue
+		}
+		calls := number(record, "calls")
+		if calls == 0 {
+			calls = 1
+		}
+		w.Add(record[queryCol], source, calls, number(record, "total_exec_time", "total_time"), number(record, "rows"))
+	}
+	if len(w.Queries) == 0 {
+		return nil, errors.New("parse pg_stat_statements CSV: no queries")
+	}
+	w.Sort()
+	return w, nil
+}
+
+var (
+	numberPattern     = regexp.MustCompile(`\b\d+(?:\.\d+)?(?:e[+-]?\d+)?\b`)
+	paramPattern      = regexp.MustCompile(`\$\d+`)
+	inListPattern     = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+	valuesListPattern = regexp.MustCompile(`\(\?\)(?:\s*,\s*\(\?\))+`)
+	spacePattern      = regexp.MustCompile(`\s+`)
+	punctSpacePattern = regexp.MustCompile(`\s*([(),=<>!+*/-])\s*`)
+)
+
+// Normalize replaces literals, numbers and bind parameters ($1, ?) with
+// ?, collapses IN and VALUES lists, drops comments and a trailing semicolon,
+// lower-cases everything outside quoted identifiers and collapses whitespace, so
+// statements that differ only in their values normalise alike.
+func Normalize(sql string) string {
+	var b strings.Builder
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += end
+			b.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+				continue
+			}
+			i += end + 3
+			b.WriteByte(' ')
+		case c == '\'':
+			i = sqlschema.ClosingQuote(sql, i)
+			b.WriteByte('?')
+		case c == '"' || c == '`':
+			end := sqlschema.ClosingQuote(sql, i)
+			b.WriteString(sql[i : end+1])
+			i = end
+		case c == '$' && sqlschema.DollarTag(sql[i:]) != "":
+			tag := sqlschema.DollarTag(sql[i:])
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += len(tag) + end + len(tag) - 1
+			}
+			b.WriteByte('?')
+		case 'A' <= c && c <= 'Z':
+			b.WriteByte(c + 'a' - 'A')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	s := b.String()
+	s = paramPattern.ReplaceAllString(s, "?")
+	s = numberPattern.ReplaceAllString(s, "?")
+	s = inListPattern.ReplaceAllString(s, "(?)")
+	s = valuesListPattern.ReplaceAllString(s, "(?)")
+	s = strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+	return strings.TrimSpace(strings.TrimSuffix(s, ";"))
+}
+
+// Fingerprint is a short hash of a normalised statement that ignores spacing
+// around punctuation, so "a=?" and "a = ?" share one.
+func Fingerprint(normalized string) string {
+	h := fnv.New64a()
+	h.Write([]byte(punctSpacePattern.ReplaceAllString(normalized, "$1")))
+	return fmt.Sprintf("%016x", h.Sum64())[:12]
+}
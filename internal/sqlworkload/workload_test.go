@@ -0,0 +1,75 @@
+package sqlworkload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{"SELECT * FROM Orders WHERE id = 42 AND note = 'it''s' -- trailing\n;", "select * from orders where id = ? and note = ?"},
+		{"select *\n  from orders\n where status IN ('a', 'b', $3) /* c */ limit 10", "select * from orders where status in (?) limit ?"},
+		{`SELECT "CamelCase".x FROM "CamelCase" WHERE y::text = $1`, `select "CamelCase".x from "CamelCase" where y::text = ?`},
+		{"insert into t (a, b) values (1, 'x'), (2, 'y')", "insert into t (a, b) values (?)"},
+		{"select t1.c2 from t1", "select t1.c2 from t1"},
+	}
+	for _, tc := range cases {
+		if got := Normalize(tc.in); got != tc.want {
+			t.Fatalf("Normalize(%q)\n got %q\nwant %q", tc.in, got, tc.want)
+		}
+	}
+	if Fingerprint("select * from t where a=?") != Fingerprint("select * from t where a = ?") {
+		t.Fatal("fingerprints should ignore spacing around operators")
+	}
+}
+
+func TestParseStatStatements(t *testing.T) {
+	const export = `query,calls,total_exec_time,mean_exec_time,rows
+"SELECT * FROM orders WHERE customer_id = $1",1200,3600.5,3.0004,24000
+"SELECT * FROM orders WHERE customer_id = $1 ",300,900,3,6000
+"UPDATE orders SET status = $1 WHERE id = $2",50,4000,80,50
+`
+	w, err := ParseStatStatements(export, "workload.csv")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if w.Statements != 3 || len(w.Queries) != 2 || !w.Timed() {
+		t.Fatalf("expected 3 statements merged into 2 timed queries, got %d/%d", w.Statements, len(w.Queries))
+	}
+	top := w.Queries[0]
+	if top.Calls != 1500 || top.TotalTime != 4500.5 || top.Normalized != "select * from orders where customer_id = ?" {
+		t.Fatalf("unexpected heaviest query %+v", top)
+	}
+	if share := w.Share(top); share < 0.52 || share > 0.53 {
+		t.Fatalf("unexpected share %.3f", share)
+	}
+	if _, err := ParseStatStatements("calls\n1\n", ""); err == nil {
+		t.Fatal("expected an error without a query column")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.sql":        "SELECT * FROM orders WHERE id = 1; SELECT * FROM orders WHERE id = 2;",
+		"nested/b.sql": "select * from orders where status = 'x'",
+		"notes.txt":    "SELECT 1",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if w.Timed() || len(w.Queries) != 2 || w.Queries[0].Calls != 2 {
+		t.Fatalf("expected the repeated statement first with 2 calls, got %+v", w.Queries[0])
+	}
+}
@@ -0,0 +1,202 @@
+package stackdump
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Group is a set of goroutines with identical stacks. Arguments are ignored, so
+// workers blocked at the same line on different values end up in one group.
+type Group struct {
+	State      string
+	Frames     []Frame
+	CreatedBy  *Frame
+	Goroutines []*Goroutine
+	// MaxWait is the longest Wait of the goroutines in the group.
+	MaxWait string
+}
+
+// IDs returns the goroutine IDs in the group.
+func (g *Group) IDs() []int {
+	ids := make([]int, len(g.Goroutines))
+	for i, gr := range g.Goroutines {
+		ids[i] = gr.ID
+	}
+	return ids
+}
+
+// Top returns the innermost frame outside the runtime, or the innermost frame if
+// every frame is in the runtime.
+func (g *Group) Top() Frame {
+	return topFrame(g.Frames)
+}
+
+// Runtime reports whether every frame of the group, and the go statement that
+// started it, belong to the runtime: GC workers, the finalizer, timers.
+func (g *Group) Runtime() bool {
+	for _, f := range g.Frames {
+		if !f.Runtime() {
+			return false
+		}
+	}
+	return g.CreatedBy == nil || g.CreatedBy.Runtime()
+}
+
+func topFrame(frames []Frame) Frame {
+	for _, f := range frames {
+		if !f.Runtime() {
+			return f
+		}
+	}
+	if len(frames) > 0 {
+		return frames[0]
+	}
+	return Frame{}
+}
+
+// Groups deduplicates the goroutines of d by state and stack. Groups keep the order
+// of their first goroutine, so the panicking goroutine's group comes first.
+func (d *Dump) Groups() []*Group {
+	var groups []*Group
+	byKey := map[string]*Group{}
+	for _, g := range d.Goroutines {
+		key := stackKey(g)
+		group, ok := byKey[key]
+		if !ok {
+			group = &Group{State: g.State, Frames: g.Frames, CreatedBy: g.CreatedBy}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+		group.Goroutines = append(group.Goroutines, g)
+		if waitMinutes(g.Wait) > waitMinutes(group.MaxWait) {
+			group.MaxWait = g.Wait
+		}
+	}
+	return groups
+}
+
+func stackKey(g *Goroutine) string {
+	var b strings.Builder
+	b.WriteString(g.State)
+	for _, f := range g.Frames {
+		fmt.Fprintf(&b, "|%s@%s", f.Func, f.Location())
+	}
+	if g.CreatedBy != nil {
+		fmt.Fprintf(&b, "|created:%s@%s", g.CreatedBy.Func, g.CreatedBy.Location())
+	}
+	return b.String()
+}
+
+func waitMinutes(wait string) int {
+	var n int
+	fmt.Sscanf(wait, "%d", &n)
+	return n
+}
+
+// blockingStates are wait reasons that only end when another goroutine acts.
+var blockingStates = map[string]bool{
+	"chan receive":            true,
+	"chan send":               true,
+	"chan receive (nil chan)": true,
+	"chan send (nil chan)":    true,
+	"select":                  true,
+	"select (no cases)":       true,
+	"semacquire":              true,
+	"sync.Mutex.Lock":         true,
+	"sync.RWMutex.Lock":       true,
+	"sync.RWMutex.RLock":      true,
+	"sync.WaitGroup.Wait":     true,
+	"sync.Cond.Wait":          true,
+}
+
+// Blocked reports whether the goroutine waits on a mutex, channel or other
+// goroutine, as opposed to I/O, timers or the scheduler.
+func (g *Goroutine) Blocked() bool {
+	return blockingStates[g.State]
+}
+
+// Contention is a resource many goroutines are blocked on.
+type Contention struct {
+	State string
+	// Resource is the address of the mutex or channel when the runtime printed it,
+	// otherwise the call site the goroutines are blocked at.
+	Resource string
+	// Site is the innermost non-runtime frame where the goroutines block.
+	Site       Frame
+	Goroutines []*Goroutine
+}
+
+var addressPattern = regexp.MustCompile(`^0x[0-9a-f]+$`)
+
+// resourceCalls are the runtime and sync functions whose first argument is the
+// mutex, channel or wait group being waited on. Inlined frames print "(...)" and
+// register arguments a trailing "?", so only exact addresses are trusted.
+var resourceCalls = []string{".(*Mutex).lockSlow", ".(*RWMutex).Lock", ".(*RWMutex).RLock", ".(*WaitGroup).Wait", ".(*Cond).Wait", "runtime.chanrecv", "runtime.chansend"}
+
+// resource identifies what g is blocked on, or "" when the dump does not say.
+func resource(g *Goroutine) string {
+	for _, f := range g.Frames {
+		for _, call := range resourceCalls {
+			if !strings.HasSuffix(f.Func, call) {
+				continue
+			}
+			arg, _, _ := strings.Cut(f.Args, ", ")
+			if addressPattern.MatchString(arg) && arg != "0x0" {
+				return arg
+			}
+		}
+	}
+	return ""
+}
+
+// Contentions finds resources that at least minWaiters goroutines are blocked on,
+// most waiters first. When the runtime already reported a deadlock every blocked
+// resource is returned.
+func (d *Dump) Contentions(minWaiters int) []Contention {
+	if d.Deadlocked() {
+		minWaiters = 1
+	}
+	var contentions []*Contention
+	byKey := map[string]*Contention{}
+	for _, g := range d.Goroutines {
+		if !g.Blocked() {
+			continue
+		}
+		site := topFrame(g.Frames)
+		res := resource(g)
+		if res == "" {
+			res = site.Location()
+		}
+		key := g.State + "|" + res
+		c, ok := byKey[key]
+		if !ok {
+			c = &Contention{State: g.State, Resource: res, Site: site}
+			byKey[key] = c
+			contentions = append(contentions, c)
+		}
+		c.Goroutines = append(c.Goroutines, g)
+	}
+
+	var out []Contention
+	for _, c := range contentions {
+		if len(c.Goroutines) >= minWaiters {
+			out = append(out, *c)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return len(out[i].Goroutines) > len(out[j].Goroutines)
+	})
+	return out
+}
+
+// Deadlocked reports whether the runtime itself detected a deadlock.
+func (d *Dump) Deadlocked() bool {
+	for _, r := range d.Reason {
+		if strings.Contains(r, "all goroutines are asleep") {
+			return true
+		}
+	}
+	return false
+}
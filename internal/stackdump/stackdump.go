@@ -0,0 +1,252 @@
+// Package stackdump parses Go tracebacks — panics, fatal errors and the goroutine
+// dumps written on SIGQUIT or with GOTRACEBACK=all — and groups identical stacks.
+package stackdump
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/riskiramdan/ShELDon/internal/testjson"
+)
+
+// Frame is one call in a goroutine's stack.
+type Frame struct {
+	// Func is the fully qualified function, e.g. "example.com/x/store.(*Store).Get".
+	Func string
+	// Args is the raw argument list printed by the runtime, without parentheses.
+	Args string
+	File string
+	Line int
+}
+
+// Location is "file:line".
+func (f Frame) Location() string {
+	return f.File + ":" + strconv.Itoa(f.Line)
+}
+
+// Package returns the import path of the package declaring Func. The function
+// starts at the first dot after the last slash, but the last path element may hold
+// dots too: the linker writes them as %2e, and a major version suffix such as the
+// .v3 of gopkg.in/yaml.v3 is kept with the path when printed unescaped.
+func (f Frame) Package() string {
+	slash := strings.LastIndex(f.Func, "/")
+	rest := f.Func[slash+1:]
+	dot := -1
+	for i := strings.Index(rest, "."); i >= 0; {
+		if !versionSuffix.MatchString(rest[i+1:]) {
+			dot = i
+			break
+		}
+		next := strings.Index(rest[i+1:], ".")
+		if next < 0 {
+			break
+		}
+		i += 1 + next
+	}
+	if dot < 0 {
+		return strings.ReplaceAll(f.Func, "%2e", ".")
+	}
+	return strings.ReplaceAll(f.Func[:slash+1+dot], "%2e", ".")
+}
+
+// Runtime reports whether the frame belongs to the Go runtime, the testing package
+// or standard library synchronisation code rather than to the program.
+func (f Frame) Runtime() bool {
+	switch pkg := f.Package(); {
+	case f.Func == "panic", pkg == "runtime", pkg == "sync", pkg == "time", pkg == "testing",
+		strings.HasPrefix(pkg, "internal/"), strings.HasPrefix(pkg, "runtime/"):
+		return true
+	}
+	return testjson.IsToolchainPath(f.File)
+}
+
+// Goroutine is one goroutine in a dump.
+type Goroutine struct {
+	ID int
+	// State is the wait reason in brackets, e.g. "chan receive" or "sync.Mutex.Lock".
+	State string
+	// Wait is how long the goroutine has been blocked, e.g. "5 minutes"; "" if short.
+	Wait string
+	// Locked reports "locked to thread".
+	Locked bool
+	Frames []Frame
+	// CreatedBy is the go statement that started the goroutine; nil for goroutine 1.
+	CreatedBy *Frame
+	// Elided reports that the runtime truncated the stack.
+	Elided bool
+}
+
+// Dump is a parsed traceback.
+type Dump struct {
+	// Reason holds the lines explaining why the dump was written: "panic: ...",
+	// "fatal error: ...", "SIGQUIT: quit", "[signal SIGSEGV ...]".
+	Reason []string
+	// Goroutines are in the order printed; after a panic the first one is the
+	// goroutine that panicked.
+	Goroutines []*Goroutine
+	// Outside holds the non-blank input lines that are not part of the traceback,
+	// such as the log output around a crash.
+	Outside []string
+}
+
+var (
+	// goroutine 6 [sync.Mutex.Lock]:
+	// goroutine 6 gp=0xc000007c00 m=nil [chan receive, 5 minutes]:
+	headerPattern = regexp.MustCompile(`^goroutine (\d+)(?: [a-z]+=\S+)* \[([^\]]*)\]:$`)
+	// \t/path/to/file.go:42 +0x1d fp=0x... sp=0x... pc=0x...
+	filePattern = regexp.MustCompile(`^\t(.+?):(\d+)(?: \+0x[0-9a-f]+)?(?: .*)?$`)
+	// created by main.main in goroutine 1
+	createdPattern = regexp.MustCompile(`^created by (\S+?)(?: in goroutine \d+)?$`)
+	// v3. in "yaml.v3.Unmarshal": a major version ending an import path.
+	versionSuffix = regexp.MustCompile(`^v[0-9]+\.`)
+)
+
+var reasonPrefixes = []string{"panic:", "fatal error:", "[signal ", "SIG", "unexpected fault address", "runtime:"}
+
+// Parse reads a traceback. Lines that are not part of one (log output around a
+// crash, register dumps, "exit status 2") are ignored, so a whole log file can be
+// passed in.
+func Parse(r io.Reader) (*Dump, error) {
+	dump := &Dump{}
+	var (
+		current *Goroutine
+		pending *Frame // call line waiting for its file line
+		created bool   // pending is the "created by" frame
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if m := headerPattern.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			current = &Goroutine{ID: id}
+			current.State, current.Wait, current.Locked = parseState(m[2])
+			dump.Goroutines = append(dump.Goroutines, current)
+			pending = nil
+			continue
+		}
+		if current == nil || line == "" {
+			switch {
+			case current == nil && isReason(line):
+				dump.Reason = append(dump.Reason, line)
+			case line != "":
+				dump.Outside = append(dump.Outside, line)
+			}
+			if line == "" {
+				current, pending = nil, nil
+			}
+			continue
+		}
+
+		if pending != nil {
+			if m := filePattern.FindStringSubmatch(line); m != nil {
+				pending.File = m[1]
+				pending.Line, _ = strconv.Atoi(m[2])
+				if created {
+					current.CreatedBy = pending
+				} else {
+					current.Frames = append(current.Frames, *pending)
+				}
+				pending = nil
+				continue
+			}
+			pending = nil
+		}
+		switch {
+		case strings.HasPrefix(line, "...") && strings.Contains(line, "frames elided"):
+			current.Elided = true
+		case createdPattern.MatchString(line):
+			pending, created = &Frame{Func: createdPattern.FindStringSubmatch(line)[1]}, true
+		default:
+			if fn, args, ok := splitCall(line); ok {
+				pending, created = &Frame{Func: fn, Args: args}, false
+				continue
+			}
+			// Anything else ends the goroutine: register dumps, log lines, exit status.
+			current = nil
+			dump.Outside = append(dump.Outside, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+// splitCall splits "example.com/x.(*T).M(0xc000010000, {0x4b2c1e, 0x3})" at the
+// parenthesis opening the argument list; receivers contain parentheses too.
+func splitCall(line string) (fn, args string, ok bool) {
+	if !strings.HasSuffix(line, ")") || strings.HasPrefix(line, "\t") {
+		return "", "", false
+	}
+	depth := 0
+	for i := len(line) - 1; i >= 0; i-- {
+		switch line[i] {
+		case ')':
+			depth++
+		case '(':
+			depth--
+			if depth == 0 {
+				fn = line[:i]
+				if fn == "" || strings.ContainsAny(fn, " \t") {
+					return "", "", false
+				}
+				return fn, line[i+1 : len(line)-1], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func isReason(line string) bool {
+	for _, prefix := range reasonPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return strings.HasPrefix(strings.TrimSpace(line), "panic:")
+}
+
+// parseState splits "chan receive, 5 minutes, locked to thread".
+func parseState(s string) (state, wait string, locked bool) {
+	parts := strings.Split(s, ", ")
+	state = parts[0]
+	for _, p := range parts[1:] {
+		switch {
+		case p == "locked to thread":
+			locked = true
+		case strings.HasSuffix(p, "minutes"), strings.HasSuffix(p, "minute"):
+			wait = p
+		}
+	}
+	return state, wait, locked
+}
+
+// Panicked reports whether the dump was written by a panic or a fatal error such as
+// a concurrent map write, in which case the first goroutine is the one that failed.
+// A runtime-detected deadlock is not attributed to any goroutine.
+func (d *Dump) Panicked() bool {
+	if d.Deadlocked() {
+		return false
+	}
+	for _, r := range d.Reason {
+		if strings.HasPrefix(strings.TrimSpace(r), "panic:") || strings.HasPrefix(r, "fatal error:") {
+			return true
+		}
+	}
+	return false
+}
+
+// UserFrames returns the frames of g outside the runtime, innermost first.
+func (g *Goroutine) UserFrames() []Frame {
+	var frames []Frame
+	for _, f := range g.Frames {
+		if !f.Runtime() {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
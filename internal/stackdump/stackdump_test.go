@@ -0,0 +1,278 @@
+package stackdump
+
+import (
+	"strings"
+	"testing"
+)
+
+// panicDump is GOTRACEBACK=all output of a panic while four goroutines wait on a
+// mutex that was never unlocked (trimmed to three of them).
+const panicDump = `panic: stuck
+
+goroutine 1 [running]:
+main.main()
+	/src/sd/main.go:31 +0x1b8
+
+goroutine 6 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x1022131c070)
+	/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+	/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:46
+example.com/sd/store.(*Store).Get(0x0?, {0x48cce8, 0x1})
+	/src/sd/store/store.go:18 +0x5c
+main.main.func1()
+	/src/sd/main.go:20 +0x2c
+created by main.main in goroutine 1
+	/src/sd/main.go:19 +0xfb
+
+goroutine 7 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x1022131c070)
+	/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+	/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:46
+example.com/sd/store.(*Store).Get(0x0?, {0x48cce8, 0x1})
+	/src/sd/store/store.go:18 +0x5c
+main.main.func1()
+	/src/sd/main.go:20 +0x2c
+created by main.main in goroutine 1
+	/src/sd/main.go:19 +0xfb
+
+goroutine 8 [sync.Mutex.Lock]:
+internal/sync.runtime_SemacquireMutex(0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x1022131c070)
+	/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+	/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:46
+example.com/sd/store.(*Store).Get(0x0?, {0x48cce8, 0x1})
+	/src/sd/store/store.go:18 +0x5c
+main.main.func1()
+	/src/sd/main.go:20 +0x2c
+created by main.main in goroutine 1
+	/src/sd/main.go:19 +0xfb
+
+goroutine 10 [sleep]:
+time.Sleep(0x34630b8a000)
+	/usr/local/go/src/runtime/time.go:368 +0x165
+main.main.func2()
+	/src/sd/main.go:25 +0x1d
+created by main.main in goroutine 1
+	/src/sd/main.go:24 +0x16e
+`
+
+// quitDump is a SIGQUIT dump (GOTRACEBACK=system style headers and frames), trimmed.
+const quitDump = `SIGQUIT: quit
+PC=0x40c84e m=0 sigcode=0
+
+goroutine 0 gp=0x53e900 m=0 mp=0x53f6c0 [idle]:
+internal/runtime/syscall/linux.Syscall6()
+	/usr/local/go/src/internal/runtime/syscall/linux/asm_linux_amd64.s:36 +0xe fp=0x7fff00db4680 sp=0x7fff00db4678 pc=0x40c84e
+internal/runtime/syscall/linux.EpollWait(0x0?, {0x7fff00db470c?, 0x0?, 0x0?}, 0x0?, 0x0?)
+	/usr/local/go/src/internal/runtime/syscall/linux/syscall_linux.go:32 +0x45 fp=0x7fff00db46d0 sp=0x7fff00db4680 pc=0x40c665
+runtime.netpoll(0x1ac5daef4008?)
+	/usr/local/go/src/runtime/netpoll_epoll.go:119 +0xd3 fp=0x7fff00db4d60 sp=0x7fff00db46d0 pc=0x43fe33
+runtime.findRunnable()
+	/usr/local/go/src/runtime/proc.go:3769 +0x97c fp=0x7fff00db4f30 sp=0x7fff00db4d60 pc=0x44bf5c
+runtime.schedule()
+	/usr/local/go/src/runtime/proc.go:4179 +0xb1 fp=0x7fff00db4f70 sp=0x7fff00db4f30 pc=0x44d5b1
+runtime.park_m(0x1ac5daef7c20)
+	/usr/local/go/src/runtime/proc.go:4319 +0x279 fp=0x7fff00db4fd0 sp=0x7fff00db4f70 pc=0x44da39
+runtime.mcall()
+	/usr/local/go/src/runtime/asm_amd64.s:463 +0x53 fp=0x7fff00db4fe8 sp=0x7fff00db4fd0 pc=0x47a7f3
+
+goroutine 1 gp=0x1ac5daef61e0 m=nil [chan receive]:
+runtime.gopark(0x7f45dce37108?, 0x70?, 0xc0?, 0xf6?, 0x1ac5daf5e070?)
+	/usr/local/go/src/runtime/proc.go:474 +0xca fp=0x1ac5daf3edd0 sp=0x1ac5daf3edb0 pc=0x476e8a
+runtime.chanrecv(0x1ac5daf5e070, 0x0, 0x1)
+	/usr/local/go/src/runtime/chan.go:667 +0x4ae fp=0x1ac5daf3ee48 sp=0x1ac5daf3edd0 pc=0x41314e
+runtime.chanrecv1(0x18?, 0x52e3c8?)
+	/usr/local/go/src/runtime/chan.go:509 +0x12 fp=0x1ac5daf3ee70 sp=0x1ac5daf3ee48 pc=0x412c92
+main.main()
+	/src/sd/main.go:28 +0x1c5 fp=0x1ac5daf3eeb8 sp=0x1ac5daf3ee70 pc=0x483445
+runtime.main()
+	/usr/local/go/src/runtime/proc.go:302 +0x427 fp=0x1ac5daf3efe0 sp=0x1ac5daf3eeb8 pc=0x445f27
+runtime.goexit({})
+	/usr/local/go/src/runtime/asm_amd64.s:1264 +0x1 fp=0x1ac5daf3efe8 sp=0x1ac5daf3efe0 pc=0x47c1e1
+
+goroutine 6 gp=0x1ac5daef7680 m=nil [sync.Mutex.Lock]:
+runtime.gopark(0x546fa0?, 0x0?, 0x70?, 0x80?, 0x0?)
+	/usr/local/go/src/runtime/proc.go:474 +0xca fp=0x1ac5daf2a660 sp=0x1ac5daf2a640 pc=0x476e8a
+runtime.goparkunlock(...)
+	/usr/local/go/src/runtime/proc.go:480
+runtime.semacquire1(0x1ac5daf16054, 0x0, 0x3, 0x2, 0x16)
+	/usr/local/go/src/runtime/sema.go:192 +0x232 fp=0x1ac5daf2a6c8 sp=0x1ac5daf2a660 pc=0x457352
+internal/sync.runtime_SemacquireMutex(0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/sema.go:95 +0x25 fp=0x1ac5daf2a700 sp=0x1ac5daf2a6c8 pc=0x477b65
+internal/sync.(*Mutex).lockSlow(0x1ac5daf16050)
+	/usr/local/go/src/internal/sync/mutex.go:149 +0x15a fp=0x1ac5daf2a750 sp=0x1ac5daf2a700 pc=0x47f27a
+internal/sync.(*Mutex).Lock(...)
+	/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:46
+example.com/sd/store.(*Store).Get(0x0?, {0x48cce8, 0x1})
+	/src/sd/store/store.go:18 +0x5c fp=0x1ac5daf2a7b0 sp=0x1ac5daf2a750 pc=0x48319c
+main.main.func1()
+	/src/sd/main.go:20 +0x2c fp=0x1ac5daf2a7e0 sp=0x1ac5daf2a7b0 pc=0x48348c
+runtime.goexit({})
+	/usr/local/go/src/runtime/asm_amd64.s:1264 +0x1 fp=0x1ac5daf2a7e8 sp=0x1ac5daf2a7e0 pc=0x47c1e1
+created by main.main in goroutine 1
+	/src/sd/main.go:19 +0xfb
+
+rax    0xfffffffffffffffc
+rbx    0x3
+`
+
+const deadlockDump = `fatal error: all goroutines are asleep - deadlock!
+
+goroutine 1 [chan receive]:
+main.main()
+	/src/dl/main.go:13 +0xbd
+
+goroutine 5 [sync.Mutex.Lock, 2 minutes]:
+internal/sync.runtime_SemacquireMutex(0x0?, 0x0?, 0x0?)
+	/usr/local/go/src/runtime/sema.go:95 +0x25
+internal/sync.(*Mutex).lockSlow(0x21fad76fe108)
+	/usr/local/go/src/internal/sync/mutex.go:149 +0x15a
+internal/sync.(*Mutex).Lock(...)
+	/usr/local/go/src/internal/sync/mutex.go:70
+sync.(*Mutex).Lock(...)
+	/usr/local/go/src/sync/mutex.go:46
+main.main.func1()
+	/src/dl/main.go:10 +0x38
+created by main.main in goroutine 1
+	/src/dl/main.go:9 +0xb1
+exit status 2
+`
+
+func TestParsePanic(t *testing.T) {
+	dump, err := Parse(strings.NewReader("2024/05/01 12:00:00 starting\n" + panicDump + "exit status 2\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(dump.Reason) != 1 || dump.Reason[0] != "panic: stuck" || !dump.Panicked() {
+		t.Fatalf("unexpected reason %q", dump.Reason)
+	}
+	if strings.Join(dump.Outside, "\n") != "2024/05/01 12:00:00 starting\nexit status 2" {
+		t.Fatalf("unexpected lines outside the dump %q", dump.Outside)
+	}
+	if len(dump.Goroutines) != 5 {
+		t.Fatalf("expected 5 goroutines, got %d", len(dump.Goroutines))
+	}
+	g := dump.Goroutines[1]
+	if g.ID != 6 || g.State != "sync.Mutex.Lock" || len(g.Frames) != 6 {
+		t.Fatalf("unexpected goroutine %+v", g)
+	}
+	if g.CreatedBy == nil || g.CreatedBy.Func != "main.main" || g.CreatedBy.Location() != "/src/sd/main.go:19" {
+		t.Fatalf("unexpected creator %+v", g.CreatedBy)
+	}
+	user := g.UserFrames()
+	if len(user) != 2 || user[0].Func != "example.com/sd/store.(*Store).Get" || user[0].Package() != "example.com/sd/store" || user[1].Package() != "main" {
+		t.Fatalf("unexpected user frames %+v", user)
+	}
+	if user[0].Args != "0x0?, {0x48cce8, 0x1}" || user[0].Line != 18 {
+		t.Fatalf("unexpected frame %+v", user[0])
+	}
+}
+
+func TestFramePackage(t *testing.T) {
+	for fn, want := range map[string]string{
+		"main.main.func1":                           "main",
+		"example.com/sd/store.(*Store).Get":         "example.com/sd/store",
+		"gopkg.in/yaml.v3.(*decoder).unmarshal":     "gopkg.in/yaml.v3",
+		"gopkg.in/yaml%2ev3.(*decoder).unmarshal":   "gopkg.in/yaml.v3",
+		"gopkg.in/yaml.v3.Unmarshal":                "gopkg.in/yaml.v3",
+		"github.com/go-chi/chi/v5.(*Mux).ServeHTTP": "github.com/go-chi/chi/v5",
+		"github.com/x/y.v2.v3.Get":                  "github.com/x/y.v2.v3",
+		"sync.(*Mutex).Lock":                        "sync",
+		"panic":                                     "panic",
+	} {
+		if got := (Frame{Func: fn}).Package(); got != want {
+			t.Errorf("Package of %s = %s, want %s", fn, got, want)
+		}
+	}
+}
+
+func TestGroupsAndContentions(t *testing.T) {
+	dump, err := Parse(strings.NewReader(panicDump))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	groups := dump.Groups()
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(groups))
+	}
+	if ids := groups[1].IDs(); len(ids) != 3 || ids[0] != 6 || ids[2] != 8 {
+		t.Fatalf("unexpected group ids %v", ids)
+	}
+	if top := groups[1].Top(); top.Location() != "/src/sd/store/store.go:18" {
+		t.Fatalf("unexpected top frame %+v", top)
+	}
+
+	contentions := dump.Contentions(3)
+	if len(contentions) != 1 {
+		t.Fatalf("expected one contention, got %+v", contentions)
+	}
+	c := contentions[0]
+	if c.Resource != "0x1022131c070" || len(c.Goroutines) != 3 || c.Site.Func != "example.com/sd/store.(*Store).Get" {
+		t.Fatalf("unexpected contention %+v", c)
+	}
+	if got := dump.Contentions(4); len(got) != 0 {
+		t.Fatalf("expected no contention with 4 waiters, got %+v", got)
+	}
+}
+
+func TestParseSIGQUIT(t *testing.T) {
+	dump, err := Parse(strings.NewReader(quitDump))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if dump.Panicked() || len(dump.Reason) != 1 || dump.Reason[0] != "SIGQUIT: quit" {
+		t.Fatalf("unexpected reason %q", dump.Reason)
+	}
+	if len(dump.Goroutines) != 3 {
+		t.Fatalf("expected 3 goroutines, got %d", len(dump.Goroutines))
+	}
+	main := dump.Goroutines[1]
+	if main.ID != 1 || main.State != "chan receive" || !main.Blocked() {
+		t.Fatalf("unexpected goroutine %+v", main)
+	}
+	if top := (&Group{Frames: main.Frames}).Top(); top.Location() != "/src/sd/main.go:28" {
+		t.Fatalf("unexpected top frame %+v", top)
+	}
+	if res := resource(main); res != "0x1ac5daf5e070" {
+		t.Fatalf("expected the channel address, got %q", res)
+	}
+	if last := dump.Goroutines[2]; last.CreatedBy == nil || len(last.UserFrames()) != 2 {
+		t.Fatalf("register dump leaked into goroutine %+v", last)
+	}
+}
+
+func TestDeadlock(t *testing.T) {
+	dump, err := Parse(strings.NewReader(deadlockDump))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !dump.Deadlocked() || dump.Panicked() {
+		t.Fatalf("expected a runtime deadlock, reason %q", dump.Reason)
+	}
+	if g := dump.Goroutines[1]; g.Wait != "2 minutes" {
+		t.Fatalf("unexpected wait %q", g.Wait)
+	}
+	contentions := dump.Contentions(3)
+	if len(contentions) != 2 {
+		t.Fatalf("a runtime deadlock reports every blocked resource, got %+v", contentions)
+	}
+	if contentions[0].Resource != "/src/dl/main.go:13" || contentions[1].Resource != "0x21fad76fe108" {
+		t.Fatalf("unexpected resources %+v", contentions)
+	}
+}
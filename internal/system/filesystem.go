@@ -11,6 +11,7 @@ type FileManager interface {
 	Read(path string) (string, error)
 	ReadFile(path string) ([]byte, error)
 	WriteFile(path string, data string) error
+	Remove(path string) error
 	IsInteractive() bool
 }
 
@@ -55,6 +56,11 @@ func (fm *OSFileManager) WriteFile(path string, data string) error {
 	return os.WriteFile(path, []byte(data), 0o644)
 }
 
+// Remove deletes the file at path.
+func (fm *OSFileManager) Remove(path string) error {
+	return os.Remove(path)
+}
+
 // IsInteractive reports whether stdin is attached to a terminal.
 func (fm *OSFileManager) IsInteractive() bool {
 	return fm.interactive
@@ -9,6 +9,7 @@ import (
 // Shell abstracts running shell commands.
 type Shell interface {
 	Run(command string) (string, error)
+	Exec(dir, name string, args ...string) (string, error)
 }
 
 // BashShell executes commands using `bash -lc`.
@@ -29,3 +30,15 @@ func (BashShell) Run(command string) (string, error) {
 	}
 	return string(out), nil
 }
+
+// Exec runs name with args in dir without a shell and returns combined stdout and
+// stderr. The output is returned even when the command fails so callers can inspect it.
+func (BashShell) Exec(dir, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("%s %v: %w", name, args, err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,160 @@
+package testjson
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Location is a source position mentioned in test output.
+type Location struct {
+	File string
+	Line int
+}
+
+var (
+	// "    store_test.go:42: got 1, want 2" as written by t.Errorf and friends.
+	logLinePattern = regexp.MustCompile(`^\s+[\w.\-]+\.go:\d+: `)
+	// Any file:line reference, with an optional absolute or relative path.
+	locationPattern = regexp.MustCompile(`((?:[A-Za-z]:)?[^\s:()"']*\.go):(\d+)`)
+	// Lines testify's assert and require packages use to describe a failure.
+	testifyPrefixes = []string{"Error Trace:", "Error:", "Messages:", "expected:", "actual  :", "actual:", "Diff:"}
+)
+
+const maxKeyLines = 40
+
+// KeyLines picks the lines of output that explain a failure: panics and fatal
+// errors with the first frames of their stack, t.Error/t.Fatal messages, testify
+// assertion details, and --- FAIL markers. Toolchain frames are skipped.
+func KeyLines(output []string) []string {
+	var (
+		lines         []string
+		frames        = -1 // frames still to keep after a panic; -1 outside a panic
+		seenGoroutine bool
+		inDiff        bool
+		prev          string
+	)
+	for _, raw := range output {
+		line := strings.TrimRight(raw, " \t")
+		trimmed := strings.TrimSpace(line)
+		previous := prev
+		prev = line
+		switch {
+		case trimmed == "":
+			inDiff = false
+			continue
+		case strings.HasPrefix(trimmed, "panic:") || strings.HasPrefix(trimmed, "fatal error:") || strings.HasPrefix(trimmed, "[recovered]"):
+			lines = append(lines, line)
+			frames = 6
+			seenGoroutine = false
+			continue
+		case frames >= 0 && strings.HasPrefix(trimmed, "goroutine ") && !seenGoroutine:
+			lines = append(lines, line)
+			seenGoroutine = true
+			continue
+		case frames >= 0 && strings.HasPrefix(raw, "\t") && locationPattern.MatchString(trimmed):
+			// A stack frame: keep it with the function line printed above it.
+			if frames > 0 && !IsToolchainPath(trimmed) {
+				lines = append(lines, previous, line)
+				frames--
+			}
+			continue
+		case strings.HasPrefix(trimmed, "--- FAIL:"), logLinePattern.MatchString(line):
+			lines = append(lines, line)
+			continue
+		}
+		for _, prefix := range testifyPrefixes {
+			if strings.HasPrefix(trimmed, prefix) {
+				lines = append(lines, line)
+				inDiff = prefix == "Diff:"
+				break
+			}
+		}
+		if inDiff && !strings.HasPrefix(trimmed, "Diff:") && (strings.HasPrefix(trimmed, "-") || strings.HasPrefix(trimmed, "+") || strings.HasPrefix(trimmed, "@@")) {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxKeyLines {
+		lines = append(lines[:maxKeyLines], "... (truncated)")
+	}
+	return lines
+}
+
+// Locations returns the distinct file:line references in output, in order of
+// appearance, excluding the Go toolchain's own sources.
+func Locations(output []string) []Location {
+	var locs []Location
+	seen := map[Location]bool{}
+	for _, line := range output {
+		for _, m := range locationPattern.FindAllStringSubmatch(line, -1) {
+			n, err := strconv.Atoi(m[2])
+			if err != nil || IsToolchainPath(m[1]) {
+				continue
+			}
+			loc := Location{File: m[1], Line: n}
+			if !seen[loc] {
+				seen[loc] = true
+				locs = append(locs, loc)
+			}
+		}
+	}
+	return locs
+}
+
+// toolchainPackages are the standard library directories whose frames are
+// plumbing rather than the bug.
+var toolchainPackages = []string{"runtime/", "testing/", "reflect/", "internal/", "sync/", "syscall/", "os/signal/"}
+
+// versionedGOROOT matches Go installations named by version, whose location varies
+// by machine: golang.org/dl SDKs, toolchains fetched by GOTOOLCHAIN and Homebrew.
+var versionedGOROOT = regexp.MustCompile(`/(?:sdk/go1[^/]*|golang\.org/toolchain@[^/]+|Cellar/go/[^/]+/libexec)/src/`)
+
+// goroot is the local toolchain's source directory, "<GOROOT>/src/", once
+// SetGOROOT has recorded it.
+var goroot atomic.Pointer[string]
+
+// SetGOROOT records the GOROOT the go command reports (`go env GOROOT`), so that
+// IsToolchainPath recognises this machine's standard library as well as the
+// versioned installs it knows by name.
+func SetGOROOT(root string) {
+	root = strings.TrimSuffix(filepath.ToSlash(strings.TrimSpace(root)), "/")
+	if root == "" {
+		goroot.Store(nil)
+		return
+	}
+	src := root + "/src/"
+	goroot.Store(&src)
+}
+
+// IsToolchainPath reports whether s refers to the Go runtime or testing sources,
+// which never hold the bug. Standard library paths must sit under a GOROOT, so
+// user code in a GOPATH's src/internal is not mistaken for them.
+func IsToolchainPath(s string) bool {
+	s = filepath.ToSlash(s)
+	for _, marker := range []string{"/go/pkg/mod/github.com/stretchr/testify", "<autogenerated>"} {
+		if strings.Contains(s, marker) {
+			return true
+		}
+	}
+	src := -1
+	if root := goroot.Load(); root != nil {
+		if i := strings.Index(s, *root); i >= 0 {
+			src = i + len(*root)
+		}
+	}
+	if src < 0 {
+		loc := versionedGOROOT.FindStringIndex(s)
+		if loc == nil {
+			return false
+		}
+		src = loc[1]
+	}
+	for _, pkg := range toolchainPackages {
+		if strings.HasPrefix(s[src:], pkg) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+// Package testjson parses the event stream written by `go test -json` into packages
+// and tests, and picks the lines of their output that explain a failure.
+package testjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is one line of `go test -json` output (see `go doc test2json`).
+type Event struct {
+	Time    time.Time
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+	// ImportPath is set on build-output and build-fail events.
+	ImportPath string
+}
+
+// Test is the outcome and output of one test or subtest.
+type Test struct {
+	Package string
+	Name    string
+	Action  string // final action: pass, fail or skip; "" if the run was cut short
+	Elapsed float64
+	Output  []string
+}
+
+// Failed reports whether the test failed.
+func (t *Test) Failed() bool { return t.Action == "fail" }
+
+// Parent returns the name of the enclosing test, or "" for a top-level test.
+func (t *Test) Parent() string {
+	if i := strings.LastIndex(t.Name, "/"); i >= 0 {
+		return t.Name[:i]
+	}
+	return ""
+}
+
+// Package is the outcome of one package.
+type Package struct {
+	Path   string
+	Action string
+	// Output holds package-level output: build errors, panics outside a test, the
+	// final FAIL/ok line.
+	Output []string
+	// Tests are in order of first appearance.
+	Tests []*Test
+	tests map[string]*Test
+}
+
+// Failed reports whether the package failed, including failing to build.
+func (p *Package) Failed() bool { return p.Action == "fail" || p.Action == "build-fail" }
+
+// Test returns the named test, or nil.
+func (p *Package) Test(name string) *Test { return p.tests[name] }
+
+// Report is a whole `go test -json` run.
+type Report struct {
+	Packages []*Package
+	// Stray holds lines that were not JSON events, such as build errors that older
+	// toolchains print as plain text.
+	Stray []string
+}
+
+// Parse reads a `go test -json` stream. Lines that are not events are kept in
+// Report.Stray rather than rejected, since CI logs often interleave other output.
+func Parse(r io.Reader) (*Report, error) {
+	report := &Report{}
+	byPath := map[string]*Package{}
+	pkgFor := func(path string) *Package {
+		p, ok := byPath[path]
+		if !ok {
+			p = &Package{Path: path, tests: map[string]*Test{}}
+			byPath[path] = p
+			report.Packages = append(report.Packages, p)
+		}
+		return p
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var ev Event
+		if !strings.HasPrefix(strings.TrimSpace(line), "{") || json.Unmarshal([]byte(line), &ev) != nil || ev.Action == "" {
+			if strings.TrimSpace(line) != "" {
+				report.Stray = append(report.Stray, line)
+			}
+			continue
+		}
+
+		switch ev.Action {
+		case "build-output":
+			p := pkgFor(buildPackage(ev.ImportPath))
+			p.Output = append(p.Output, strings.TrimRight(ev.Output, "\n"))
+			continue
+		case "build-fail":
+			p := pkgFor(buildPackage(ev.ImportPath))
+			if p.Action == "" {
+				p.Action = "build-fail"
+			}
+			continue
+		}
+
+		p := pkgFor(ev.Package)
+		if ev.Test == "" {
+			switch ev.Action {
+			case "output":
+				p.Output = append(p.Output, strings.TrimRight(ev.Output, "\n"))
+			case "pass", "fail", "skip":
+				if p.Action != "build-fail" {
+					p.Action = ev.Action
+				}
+			}
+			continue
+		}
+
+		t, ok := p.tests[ev.Test]
+		if !ok {
+			t = &Test{Package: ev.Package, Name: ev.Test}
+			p.tests[ev.Test] = t
+			p.Tests = append(p.Tests, t)
+		}
+		switch ev.Action {
+		case "output":
+			t.Output = append(t.Output, strings.TrimRight(ev.Output, "\n"))
+		case "pass", "fail", "skip":
+			t.Action = ev.Action
+			t.Elapsed = ev.Elapsed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// buildPackage maps a build ImportPath such as "example.com/x [example.com/x.test]"
+// to the package it belongs to.
+func buildPackage(importPath string) string {
+	path, _, _ := strings.Cut(importPath, " ")
+	return strings.TrimSuffix(path, "_test")
+}
+
+// Failure is one failing top-level test, or a package that failed outside any test
+// (build failure, TestMain, timeout panic) when Test is nil.
+type Failure struct {
+	Package *Package
+	Test    *Test
+	// Subtests lists the failing subtests of Test, deepest last.
+	Subtests []*Test
+}
+
+// Name is "<package>.<Test>" or the package path for package-level failures.
+func (f Failure) Name() string {
+	if f.Test == nil {
+		return f.Package.Path
+	}
+	return f.Package.Path + "." + f.Test.Name
+}
+
+// Output concatenates the output of the test and its failing subtests, or the
+// package output for package-level failures.
+func (f Failure) Output() []string {
+	if f.Test == nil {
+		return f.Package.Output
+	}
+	out := append([]string(nil), f.Test.Output...)
+	for _, sub := range f.Subtests {
+		out = append(out, sub.Output...)
+	}
+	return out
+}
+
+// Failures lists failures grouped by package, in the order packages and tests ran.
+// A test that was still running when its package failed (panic, timeout) counts as
+// failed.
+func (r *Report) Failures() []Failure {
+	var failures []Failure
+	for _, p := range r.Packages {
+		if !p.Failed() {
+			continue
+		}
+		before := len(failures)
+		for _, t := range p.Tests {
+			if t.Parent() != "" || !(t.Failed() || t.Action == "") {
+				continue
+			}
+			f := Failure{Package: p, Test: t}
+			for _, sub := range p.Tests {
+				if strings.HasPrefix(sub.Name, t.Name+"/") && (sub.Failed() || sub.Action == "") {
+					f.Subtests = append(f.Subtests, sub)
+				}
+			}
+			sort.SliceStable(f.Subtests, func(i, j int) bool {
+				return strings.Count(f.Subtests[i].Name, "/") < strings.Count(f.Subtests[j].Name, "/")
+			})
+			failures = append(failures, f)
+		}
+		if len(failures) == before {
+			failures = append(failures, Failure{Package: p})
+		}
+	}
+	return failures
+}
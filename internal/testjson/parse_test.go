@@ -0,0 +1,158 @@
+package testjson
+
+import (
+	"strings"
+	"testing"
+)
+
+// sampleRun is trimmed `go test -json` output of a package with a failing subtest,
+// a panicking test and a passing one.
+const sampleRun = `{"Action":"start","Package":"example.com/tj"}
+{"Action":"run","Package":"example.com/tj","Test":"TestSum"}
+{"Action":"output","Package":"example.com/tj","Test":"TestSum","Output":"=== RUN   TestSum\n"}
+{"Action":"run","Package":"example.com/tj","Test":"TestSum/one"}
+{"Action":"output","Package":"example.com/tj","Test":"TestSum/one","Output":"=== RUN   TestSum/one\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestSum/one","Output":"    calc_test.go:13: Sum([1]) = 0, want 1\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestSum/one","Output":"--- FAIL: TestSum/one (0.00s)\n"}
+{"Action":"fail","Package":"example.com/tj","Test":"TestSum/one","Elapsed":0}
+{"Action":"run","Package":"example.com/tj","Test":"TestSum/none"}
+{"Action":"output","Package":"example.com/tj","Test":"TestSum/none","Output":"=== RUN   TestSum/none\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestSum/none","Output":"--- PASS: TestSum/none (0.00s)\n"}
+{"Action":"pass","Package":"example.com/tj","Test":"TestSum/none","Elapsed":0}
+{"Action":"output","Package":"example.com/tj","Test":"TestSum","Output":"--- FAIL: TestSum (0.00s)\n"}
+{"Action":"fail","Package":"example.com/tj","Test":"TestSum","Elapsed":0}
+{"Action":"run","Package":"example.com/tj","Test":"TestDiv"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"=== RUN   TestDiv\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"--- FAIL: TestDiv (0.00s)\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"panic: runtime error: integer divide by zero [recovered, repanicked]\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"goroutine 9 [running]:\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"testing.tRunner.func1.2({0x6b7150, 0x6ef100})\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"\t/usr/local/go/src/testing/testing.go:2123 +0x232\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"testing.tRunner.func1()\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"\t/usr/local/go/src/testing/testing.go:2126 +0x329\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"panic({0x6b7150?, 0x6ef100?})\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"\t/usr/local/go/src/runtime/panic.go:859 +0x125\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"example.com/tj.Div(...)\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"\t/src/tj/calc.go:3\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"example.com/tj.TestDiv(0x1be226ef8908?)\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"\t/src/tj/calc_test.go:20 +0xa\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"testing.tRunner(0x1be226ef8908, 0x6d4c18)\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"\t/usr/local/go/src/testing/testing.go:2193 +0xea\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"created by testing.(*T).Run in goroutine 1\n"}
+{"Action":"output","Package":"example.com/tj","Test":"TestDiv","Output":"\t/usr/local/go/src/testing/testing.go:2258 +0x4d4\n"}
+{"Action":"fail","Package":"example.com/tj","Test":"TestDiv","Elapsed":0}
+{"Action":"output","Package":"example.com/tj","Output":"FAIL\texample.com/tj\t0.005s\n"}
+{"Action":"fail","Package":"example.com/tj","Elapsed":0.005}
+go: downloading example.com/other v1.0.0
+`
+
+func TestParseAndFailures(t *testing.T) {
+	withGOROOT(t, "/usr/local/go")
+	report, err := Parse(strings.NewReader(sampleRun))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(report.Packages) != 1 || !report.Packages[0].Failed() {
+		t.Fatalf("expected one failed package, got %+v", report.Packages)
+	}
+	if len(report.Stray) != 1 || !strings.HasPrefix(report.Stray[0], "go: downloading") {
+		t.Fatalf("expected the non-JSON line to be kept as stray, got %q", report.Stray)
+	}
+
+	failures := report.Failures()
+	if len(failures) != 2 {
+		t.Fatalf("expected 2 failures, got %d", len(failures))
+	}
+	sum, div := failures[0], failures[1]
+	if sum.Name() != "example.com/tj.TestSum" || len(sum.Subtests) != 1 || sum.Subtests[0].Name != "TestSum/one" {
+		t.Fatalf("unexpected TestSum failure: %s %+v", sum.Name(), sum.Subtests)
+	}
+	if div.Test.Name != "TestDiv" || div.Test.Elapsed != 0 {
+		t.Fatalf("unexpected second failure: %+v", div.Test)
+	}
+
+	keys := strings.Join(KeyLines(sum.Output()), "\n")
+	if !strings.Contains(keys, "calc_test.go:13: Sum([1]) = 0, want 1") || !strings.Contains(keys, "--- FAIL: TestSum/one") {
+		t.Fatalf("unexpected key lines for TestSum:\n%s", keys)
+	}
+
+	keys = strings.Join(KeyLines(div.Output()), "\n")
+	for _, want := range []string{"panic: runtime error: integer divide by zero", "goroutine 9 [running]:", "example.com/tj.Div(...)\n\t/src/tj/calc.go:3", "\t/src/tj/calc_test.go:20 +0xa"} {
+		if !strings.Contains(keys, want) {
+			t.Fatalf("expected %q in key lines:\n%s", want, keys)
+		}
+	}
+	if strings.Contains(keys, "testing.go") || strings.Contains(keys, "panic.go") {
+		t.Fatalf("toolchain frames should be dropped:\n%s", keys)
+	}
+
+	locs := Locations(div.Output())
+	if len(locs) != 2 || locs[0] != (Location{File: "/src/tj/calc.go", Line: 3}) || locs[1] != (Location{File: "/src/tj/calc_test.go", Line: 20}) {
+		t.Fatalf("unexpected locations: %+v", locs)
+	}
+}
+
+func TestBuildFailureAndTestify(t *testing.T) {
+	run := `{"ImportPath":"example.com/tj [example.com/tj.test]","Action":"build-output","Output":"# example.com/tj [example.com/tj.test]\n"}
+{"ImportPath":"example.com/tj [example.com/tj.test]","Action":"build-output","Output":"./calc_test.go:7:2: undefined: Mul\n"}
+{"ImportPath":"example.com/tj [example.com/tj.test]","Action":"build-fail"}
+{"Action":"start","Package":"example.com/tj"}
+{"Action":"output","Package":"example.com/tj","Output":"FAIL\texample.com/tj [build failed]\n"}
+{"Action":"fail","Package":"example.com/tj","Elapsed":0}
+`
+	report, err := Parse(strings.NewReader(run))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	failures := report.Failures()
+	if len(failures) != 1 || failures[0].Test != nil || failures[0].Package.Action != "build-fail" {
+		t.Fatalf("expected one package-level build failure, got %+v", failures)
+	}
+	if locs := Locations(failures[0].Output()); len(locs) != 1 || locs[0] != (Location{File: "./calc_test.go", Line: 7}) {
+		t.Fatalf("unexpected locations: %+v", locs)
+	}
+
+	testify := []string{
+		"=== RUN   TestStore",
+		"    store_test.go:31: ",
+		"        \tError Trace:\t/src/store/store_test.go:31",
+		"        \tError:      \tNot equal: ",
+		"        \t            \texpected: 2",
+		"        \t            \tactual  : 1",
+		"        \tTest:       \tTestStore",
+		"--- FAIL: TestStore (0.00s)",
+	}
+	keys := KeyLines(testify)
+	if len(keys) != 5 || !strings.Contains(keys[1], "Not equal") {
+		t.Fatalf("unexpected testify key lines: %q", keys)
+	}
+}
+
+// withGOROOT records root as the local GOROOT for the rest of the test, as the
+// commands do with the output of `go env GOROOT`.
+func withGOROOT(t *testing.T, root string) {
+	SetGOROOT(root)
+	t.Cleanup(func() { SetGOROOT("") })
+}
+
+func TestIsToolchainPath(t *testing.T) {
+	withGOROOT(t, "/opt/go/")
+	for path, want := range map[string]bool{
+		"/opt/go/src/runtime/panic.go":   true,
+		"/opt/go/src/net/http/server.go": false,
+		// Only the recorded GOROOT and versioned installs count; other locations are not guessed.
+		"/usr/local/go/src/testing/testing.go":                                                  false,
+		"/home/me/sdk/go1.22.4/src/internal/sync/mutex.go":                                      true,
+		"/home/me/go/pkg/mod/golang.org/toolchain@v0.0.1-go1.23.0.linux-amd64/src/sync/once.go": true,
+		// Code under a GOPATH or any other src directory is the user's.
+		"/home/me/go/src/internal/store/store.go": false,
+		"/src/runtime/app/main.go":                false,
+		"/src/tj/calc.go":                         false,
+		"<autogenerated>":                         true,
+	} {
+		if got := IsToolchainPath(path); got != want {
+			t.Errorf("IsToolchainPath(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -31,7 +31,17 @@ func NormalizeCode(s string) string {
 	return s
 }
 
-// ExtractFunction attempts to extract the named Go function body.
+// PackageName returns the package clause name of a Go source file, or "" when unparsable.
+func PackageName(src string) string {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+	return file.Name.Name
+}
+
+// ExtractFunction attempts to extract the named Go function body. fn is either a
+// plain function name or a method selector such as "Service.Create" or "(*Service).Create".
 func ExtractFunction(src, fn string) string {
 	fset := token.NewFileSet()
 	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
@@ -44,7 +54,7 @@ func ExtractFunction(src, fn string) string {
 		if !ok {
 			continue
 		}
-		if fnDecl.Name.Name != fn {
+		if !MatchFunc(fnDecl, fn) {
 			continue
 		}
 
@@ -57,3 +67,80 @@ func ExtractFunction(src, fn string) string {
 	}
 	return ""
 }
+
+// FunctionAt returns the selector of the function declared in src whose body spans
+// line (1-based), or "" when line is outside every function.
+func FunctionAt(src string, line int) string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return ""
+	}
+	for _, decl := range file.Decls {
+		fnDecl, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		if fset.Position(fnDecl.Pos()).Line <= line && line <= fset.Position(fnDecl.End()).Line {
+			return FuncSelector(fnDecl)
+		}
+	}
+	return ""
+}
+
+// NormalizeSelector turns "(*Service).Create" and "*Service.Create" into "Service.Create".
+func NormalizeSelector(fn string) string {
+	fn = strings.TrimSpace(fn)
+	fn = strings.NewReplacer("(", "", ")", "", "*", "").Replace(fn)
+	return fn
+}
+
+// FuncSelector returns "Name" for functions and "Type.Name" for methods.
+func FuncSelector(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	return receiverTypeName(fd.Recv.List[0].Type) + "." + fd.Name.Name
+}
+
+// MatchFunc reports whether fd is the function or method named by fn.
+// A bare name only matches plain functions, so methods never shadow them.
+func MatchFunc(fd *ast.FuncDecl, fn string) bool {
+	return FuncSelector(fd) == NormalizeSelector(fn)
+}
+
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// ExportedFunctions lists selectors of exported functions and of exported methods on
+// exported types declared in src, in source order.
+func ExportedFunctions(src string) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, decl := range file.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || !fd.Name.IsExported() {
+			continue
+		}
+		sel := FuncSelector(fd)
+		if typ, _, isMethod := strings.Cut(sel, "."); isMethod && !ast.IsExported(typ) {
+			continue
+		}
+		out = append(out, sel)
+	}
+	return out
+}
@@ -41,3 +41,51 @@ func target(a int) int {
 		t.Fatalf("expected missing function to return empty string")
 	}
 }
+
+func TestPackageName(t *testing.T) {
+	if got := PackageName("// doc\npackage demo\n\nfunc x() {}\n"); got != "demo" {
+		t.Fatalf("expected demo, got %q", got)
+	}
+	if got := PackageName("not go"); got != "" {
+		t.Fatalf("expected empty name for invalid source, got %q", got)
+	}
+}
+
+func TestExtractMethodSelector(t *testing.T) {
+	src := `
+package demo
+
+type Service struct{}
+type repo struct{}
+
+func Create() string { return "func" }
+
+func (s *Service) Create() string { return "method" }
+
+func (r repo) Save() {}
+
+func helper() {}
+`
+	for _, sel := range []string{"Service.Create", "(*Service).Create", "*Service.Create"} {
+		if got := ExtractFunction(src, sel); got != `func (s *Service) Create() string { return "method" }` {
+			t.Fatalf("%s: unexpected extraction %q", sel, got)
+		}
+	}
+	if got := ExtractFunction(src, "Create"); got != `func Create() string { return "func" }` {
+		t.Fatalf("expected plain function for bare name, got %q", got)
+	}
+
+	exported := ExportedFunctions(src)
+	if len(exported) != 2 || exported[0] != "Create" || exported[1] != "Service.Create" {
+		t.Fatalf("unexpected exported functions: %v", exported)
+	}
+}
+
+func TestFunctionAt(t *testing.T) {
+	src := "package demo\n\nfunc A() {\n\tprintln()\n}\n\ntype T struct{}\n\nfunc (t *T) B() {}\n"
+	for line, want := range map[int]string{4: "A", 9: "T.B", 7: "", 100: ""} {
+		if got := FunctionAt(src, line); got != want {
+			t.Fatalf("line %d: expected %q, got %q", line, want, got)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Package unidiff parses unified diffs (as produced by `git diff`) into files, hunks
+// and numbered lines so commands can reason about locations instead of raw text.
+package unidiff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineKind is the leading marker of a hunk line.
+type LineKind byte
+
+const (
+	Context   LineKind = ' '
+	Added     LineKind = '+'
+	Removed   LineKind = '-'
+	NoNewline LineKind = '\\'
+)
+
+// Line is a single hunk line with its position in the old and new file.
+// OldLine is zero for additions and NewLine is zero for removals.
+type Line struct {
+	Kind    LineKind
+	Content string
+	OldLine int
+	NewLine int
+}
+
+// Hunk is one `@@` section of a file diff.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// Section is the optional function context git prints after the second @@.
+	Section string
+	Lines   []Line
+}
+
+// File is the diff of a single path.
+type File struct {
+	OldPath    string
+	NewPath    string
+	OldMode    string
+	NewMode    string
+	IsNew      bool
+	IsDeleted  bool
+	IsRename   bool
+	IsCopy     bool
+	IsBinary   bool
+	Similarity int
+	// Header holds the raw lines preceding the first hunk, newline-terminated.
+	Header string
+	Hunks  []Hunk
+}
+
+// Path returns the most relevant path: the new one, or the old one for deletions.
+func (f File) Path() string {
+	if f.IsDeleted || f.NewPath == "" {
+		return f.OldPath
+	}
+	return f.NewPath
+}
+
+// ModeChanged reports whether the diff changes the file mode.
+func (f File) ModeChanged() bool {
+	return f.OldMode != "" && f.NewMode != "" && f.OldMode != f.NewMode
+}
+
+// Splittable reports whether hunks can be applied independently of each other,
+// i.e. the change is a plain textual modification of an existing file.
+func (f File) Splittable() bool {
+	return !f.IsNew && !f.IsDeleted && !f.IsRename && !f.IsCopy && !f.IsBinary && !f.ModeChanged()
+}
+
+// Added returns the number of added lines across all hunks.
+func (f File) Added() int {
+	return f.count(Added)
+}
+
+// Removed returns the number of removed lines across all hunks.
+func (f File) Removed() int {
+	return f.count(Removed)
+}
+
+func (f File) count(kind LineKind) int {
+	n := 0
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.Kind == kind {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// String reproduces the file diff in a form `git apply` accepts.
+func (f File) String() string {
+	var b strings.Builder
+	b.WriteString(f.Header)
+	for _, h := range f.Hunks {
+		b.WriteString(h.String())
+	}
+	return b.String()
+}
+
+// HeaderLine renders the `@@ -a,b +c,d @@ section` line without a trailing newline.
+func (h Hunk) HeaderLine() string {
+	line := fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+	if h.Section != "" {
+		line += " " + h.Section
+	}
+	return line
+}
+
+// String reproduces the hunk, including its header.
+func (h Hunk) String() string {
+	var b strings.Builder
+	b.WriteString(h.HeaderLine())
+	b.WriteByte('\n')
+	for _, l := range h.Lines {
+		b.WriteByte(byte(l.Kind))
+		b.WriteString(l.Content)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// Parse reads a unified diff. Text before the first file header (for example the
+// commit message printed by `git show`) is ignored.
+func Parse(text string) ([]File, error) {
+	var (
+		files   []File
+		cur     *File
+		hunk    *Hunk
+		oldRem  int
+		newRem  int
+		oldLine int
+		newLine int
+	)
+
+	flush := func() {
+		if cur != nil {
+			files = append(files, *cur)
+		}
+		cur, hunk = nil, nil
+	}
+	header := func(line string) {
+		cur.Header += line + "\n"
+	}
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		if hunk != nil && (oldRem > 0 || newRem > 0) {
+			kind := Context
+			content := line
+			if line != "" {
+				kind, content = LineKind(line[0]), line[1:]
+			}
+			l := Line{Kind: kind, Content: content}
+			switch kind {
+			case Context:
+				l.OldLine, l.NewLine = oldLine, newLine
+				oldLine, newLine = oldLine+1, newLine+1
+				oldRem, newRem = oldRem-1, newRem-1
+			case Removed:
+				l.OldLine = oldLine
+				oldLine++
+				oldRem--
+			case Added:
+				l.NewLine = newLine
+				newLine++
+				newRem--
+			case NoNewline:
+			default:
+				return nil, fmt.Errorf("unidiff: unexpected line %d in hunk of %s: %q", i+1, cur.Path(), line)
+			}
+			hunk.Lines = append(hunk.Lines, l)
+			if oldRem < 0 || newRem < 0 {
+				return nil, fmt.Errorf("unidiff: hunk at line %d of %s exceeds its declared size", i+1, cur.Path())
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			cur = &File{}
+			cur.OldPath, cur.NewPath = splitGitPaths(strings.TrimPrefix(line, "diff --git "))
+			header(line)
+		case strings.HasPrefix(line, "--- ") && (cur == nil || len(cur.Hunks) > 0) &&
+			i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// Plain `diff -u` output without a git header.
+			flush()
+			cur = &File{}
+			fallthrough
+		case cur != nil && len(cur.Hunks) == 0 && strings.HasPrefix(line, "--- "):
+			if p := cleanPath(strings.TrimPrefix(line, "--- "), "a/"); p == "" {
+				cur.IsNew = true
+			} else {
+				cur.OldPath = p
+			}
+			header(line)
+		case cur != nil && len(cur.Hunks) == 0 && strings.HasPrefix(line, "+++ "):
+			if p := cleanPath(strings.TrimPrefix(line, "+++ "), "b/"); p == "" {
+				cur.IsDeleted = true
+			} else {
+				cur.NewPath = p
+			}
+			header(line)
+		case cur != nil && strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("unidiff: malformed hunk header at line %d: %q", i+1, line)
+			}
+			h := Hunk{
+				OldStart: atoi(m[1], 0),
+				OldLines: atoi(m[2], 1),
+				NewStart: atoi(m[3], 0),
+				NewLines: atoi(m[4], 1),
+				Section:  m[5],
+			}
+			cur.Hunks = append(cur.Hunks, h)
+			hunk = &cur.Hunks[len(cur.Hunks)-1]
+			oldRem, newRem = h.OldLines, h.NewLines
+			oldLine, newLine = h.OldStart, h.NewStart
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			hunk.Lines = append(hunk.Lines, Line{Kind: NoNewline, Content: line[1:]})
+		case cur != nil && len(cur.Hunks) == 0:
+			parseExtendedHeader(cur, line)
+			header(line)
+		}
+	}
+	if hunk != nil && (oldRem > 0 || newRem > 0) {
+		return nil, fmt.Errorf("unidiff: truncated hunk in %s", cur.Path())
+	}
+	flush()
+	return files, nil
+}
+
+func parseExtendedHeader(f *File, line string) {
+	key, value := line, ""
+	for _, prefix := range []string{
+		"old mode ", "new mode ", "deleted file mode ", "new file mode ",
+		"rename from ", "rename to ", "copy from ", "copy to ", "similarity index ",
+	} {
+		if strings.HasPrefix(line, prefix) {
+			key, value = strings.TrimSpace(prefix), strings.TrimPrefix(line, prefix)
+			break
+		}
+	}
+	switch key {
+	case "old mode":
+		f.OldMode = value
+	case "new mode":
+		f.NewMode = value
+	case "deleted file mode":
+		f.IsDeleted, f.OldMode = true, value
+	case "new file mode":
+		f.IsNew, f.NewMode = true, value
+	case "rename from":
+		f.IsRename, f.OldPath = true, unquote(value)
+	case "rename to":
+		f.IsRename, f.NewPath = true, unquote(value)
+	case "copy from":
+		f.IsCopy, f.OldPath = true, unquote(value)
+	case "copy to":
+		f.IsCopy, f.NewPath = true, unquote(value)
+	case "similarity index":
+		f.Similarity = atoi(strings.TrimSuffix(value, "%"), 0)
+	default:
+		if strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch" {
+			f.IsBinary = true
+		}
+	}
+}
+
+// splitGitPaths splits the "a/x b/x" tail of a `diff --git` line. Paths containing
+// " b/" are ambiguous here; later --- / +++ / rename lines take precedence.
+func splitGitPaths(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		if end := closingQuote(s); end > 0 {
+			return cleanPath(s[:end+1], "a/"), cleanPath(strings.TrimSpace(s[end+1:]), "b/")
+		}
+	}
+	if idx := strings.LastIndex(s, " b/"); idx >= 0 {
+		return cleanPath(s[:idx], "a/"), cleanPath(s[idx+1:], "b/")
+	}
+	if a, b, ok := strings.Cut(s, " "); ok {
+		return cleanPath(a, "a/"), cleanPath(b, "b/")
+	}
+	return s, s
+}
+
+// cleanPath unquotes a diff path, strips its a/ or b/ prefix and maps /dev/null to "".
+func cleanPath(p, prefix string) string {
+	p = strings.TrimSpace(p)
+	// `diff -u` appends a tab-separated timestamp.
+	if idx := strings.IndexByte(p, '\t'); idx >= 0 {
+		p = p[:idx]
+	}
+	p = unquote(p)
+	if p == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(p, prefix)
+}
+
+func unquote(p string) string {
+	if strings.HasPrefix(p, `"`) {
+		if s, err := strconv.Unquote(p); err == nil {
+			return s
+		}
+	}
+	return p
+}
+
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func atoi(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
@@ -0,0 +1,123 @@
+package unidiff
+
+import (
+	"strings"
+	"testing"
+)
+
+const fixture = `diff --git a/pkg/a.go b/pkg/a.go
+index 1111111..2222222 100644
+--- a/pkg/a.go
++++ b/pkg/a.go
+@@ -10,4 +10,4 @@ func A() {
+ 	x := 1
+-	y := 2
++	y := 3
++	z := 4
+--- not a header, a removed line starting with dashes
+ }
+\ No newline at end of file
+diff --git a/old.go b/new.go
+similarity index 95%
+rename from old.go
+rename to new.go
+diff --git a/logo.png b/logo.png
+new file mode 100644
+index 0000000..3333333
+Binary files /dev/null and b/logo.png differ
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`
+
+func TestParse(t *testing.T) {
+	files, err := Parse(fixture)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected 4 files, got %d", len(files))
+	}
+
+	a := files[0]
+	if a.Path() != "pkg/a.go" || len(a.Hunks) != 1 || !a.Splittable() {
+		t.Fatalf("unexpected first file: %#v", a)
+	}
+	h := a.Hunks[0]
+	if h.OldStart != 10 || h.NewLines != 4 || h.Section != "func A() {" {
+		t.Fatalf("unexpected hunk header: %#v", h)
+	}
+	if a.Added() != 2 || a.Removed() != 2 {
+		t.Fatalf("expected +2 -2, got +%d -%d", a.Added(), a.Removed())
+	}
+	if got := h.Lines[3]; got.Kind != Added || got.NewLine != 12 || got.Content != "\tz := 4" {
+		t.Fatalf("unexpected added line: %#v", got)
+	}
+	if got := h.Lines[4]; got.Kind != Removed || got.OldLine != 12 {
+		t.Fatalf("expected dashed removal inside hunk, got %#v", got)
+	}
+	if h.Lines[len(h.Lines)-1].Kind != NoNewline {
+		t.Fatalf("expected trailing no-newline marker")
+	}
+	if !strings.Contains(a.String(), "--- not a header") || !strings.HasSuffix(a.String(), "\\ No newline at end of file\n") {
+		t.Fatalf("round trip lost content: %q", a.String())
+	}
+
+	if r := files[1]; !r.IsRename || r.OldPath != "old.go" || r.NewPath != "new.go" || r.Similarity != 95 || r.Splittable() {
+		t.Fatalf("unexpected rename: %#v", r)
+	}
+	if b := files[2]; !b.IsBinary || !b.IsNew || b.Path() != "logo.png" {
+		t.Fatalf("unexpected binary: %#v", b)
+	}
+	if m := files[3]; !m.ModeChanged() || m.NewMode != "100755" {
+		t.Fatalf("unexpected mode change: %#v", m)
+	}
+}
+
+func TestParsePlainUnified(t *testing.T) {
+	text := "--- a.txt\t2024-01-01\n+++ b.txt\t2024-01-02\n@@ -1 +1 @@\n-old\n+new\n"
+	files, err := Parse(text)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(files) != 1 || files[0].OldPath != "a.txt" || files[0].NewPath != "b.txt" {
+		t.Fatalf("unexpected files: %#v", files)
+	}
+}
+
+func TestParseTruncatedHunk(t *testing.T) {
+	if _, err := Parse("diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -1,3 +1,3 @@\n a\n"); err == nil {
+		t.Fatalf("expected truncated hunk error")
+	}
+}
+
+func TestRenderBudget(t *testing.T) {
+	files, err := Parse(fixture)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out := Render(files, RenderOptions{LineNumbers: true})
+	if !strings.Contains(out, "    12 +\tz := 4") {
+		t.Fatalf("expected numbered added line, got:\n%s", out)
+	}
+	if !strings.Contains(out, "logo.png (added, binary file; content omitted)") {
+		t.Fatalf("expected binary summary, got:\n%s", out)
+	}
+
+	big := "diff --git a/big.txt b/big.txt\n--- a/big.txt\n+++ b/big.txt\n@@ -1,0 +1,200 @@\n" +
+		strings.Repeat("+filler line\n", 200)
+	files, err = Parse(fixture + big)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	out = Render(files, RenderOptions{MaxBytes: 1200})
+	if len(out) > 1400 {
+		t.Fatalf("expected budgeted output, got %d bytes", len(out))
+	}
+	if !strings.Contains(out, "z := 4") {
+		t.Fatalf("expected small hunk kept whole, got:\n%s", out)
+	}
+	if !strings.Contains(out, "lines omitted") {
+		t.Fatalf("expected large hunk truncated, got:\n%s", out)
+	}
+}
@@ -0,0 +1,146 @@
+package unidiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RenderOptions controls how a parsed diff is presented to a model.
+type RenderOptions struct {
+	// MaxBytes caps the rendered size; hunks share the budget fairly so that one
+	// huge hunk cannot crowd out the rest. Zero means unlimited.
+	MaxBytes int
+	// LineNumbers prefixes every kept line with its new-file line number so the
+	// model can cite accurate file:line locations.
+	LineNumbers bool
+}
+
+// Render formats files for prompt input. Binary files are summarised in one line
+// instead of being dumped.
+func Render(files []File, opts RenderOptions) string {
+	type block struct {
+		header string
+		hunks  []string
+	}
+
+	blocks := make([]block, 0, len(files))
+	headerSize, hunkSize := 0, 0
+	for _, f := range files {
+		b := block{header: renderFileHeader(f)}
+		headerSize += len(b.header)
+		if !f.IsBinary {
+			for _, h := range f.Hunks {
+				text := renderHunk(h, opts.LineNumbers)
+				hunkSize += len(text)
+				b.hunks = append(b.hunks, text)
+			}
+		}
+		blocks = append(blocks, b)
+	}
+
+	if opts.MaxBytes > 0 && headerSize+hunkSize > opts.MaxBytes {
+		var all []*string
+		for i := range blocks {
+			for j := range blocks[i].hunks {
+				all = append(all, &blocks[i].hunks[j])
+			}
+		}
+		allocate(all, opts.MaxBytes-headerSize)
+	}
+
+	var out strings.Builder
+	for _, b := range blocks {
+		out.WriteString(b.header)
+		for _, h := range b.hunks {
+			out.WriteString(h)
+		}
+	}
+	return out.String()
+}
+
+// allocate water-fills budget across hunks: small hunks are kept whole and the
+// remainder is split evenly among the larger ones, which get truncated.
+func allocate(hunks []*string, budget int) {
+	order := make([]int, len(hunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return len(*hunks[order[a]]) < len(*hunks[order[b]]) })
+
+	for n, idx := range order {
+		share := 0
+		if budget > 0 {
+			share = budget / (len(order) - n)
+		}
+		text := *hunks[idx]
+		if len(text) > share {
+			text = truncateLines(text, share)
+			*hunks[idx] = text
+		}
+		budget -= len(text)
+	}
+}
+
+// truncateLines keeps whole lines up to max bytes and notes how many were dropped.
+func truncateLines(text string, max int) string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var b strings.Builder
+	kept := 0
+	for _, l := range lines {
+		// Always keep the first (@@ header) line so the location survives.
+		if kept > 0 && b.Len()+len(l) > max {
+			break
+		}
+		b.WriteString(l)
+		kept++
+	}
+	if dropped := len(lines) - kept; dropped > 0 {
+		fmt.Fprintf(&b, "... (%d lines omitted)\n", dropped)
+	}
+	return b.String()
+}
+
+func renderFileHeader(f File) string {
+	var status string
+	switch {
+	case f.IsNew:
+		status = "added"
+	case f.IsDeleted:
+		status = "deleted"
+	case f.IsRename:
+		status = "renamed from " + f.OldPath
+	case f.IsCopy:
+		status = "copied from " + f.OldPath
+	default:
+		status = "modified"
+	}
+	if f.ModeChanged() {
+		status += fmt.Sprintf(", mode %s -> %s", f.OldMode, f.NewMode)
+	}
+	if f.IsBinary {
+		return fmt.Sprintf("=== %s (%s, binary file; content omitted)\n", f.Path(), status)
+	}
+	return fmt.Sprintf("=== %s (%s, +%d -%d)\n", f.Path(), status, f.Added(), f.Removed())
+}
+
+func renderHunk(h Hunk, numbers bool) string {
+	if !numbers {
+		return h.String()
+	}
+	var b strings.Builder
+	b.WriteString(h.HeaderLine())
+	b.WriteByte('\n')
+	for _, l := range h.Lines {
+		switch l.Kind {
+		case Removed, NoNewline:
+			fmt.Fprintf(&b, "%6s %c%s\n", "", l.Kind, l.Content)
+		default:
+			fmt.Fprintf(&b, "%6d %c%s\n", l.NewLine, l.Kind, l.Content)
+		}
+	}
+	return b.String()
+}